@@ -58,4 +58,5 @@ const (
 	rubyRails               = "google.ruby.rails"
 	rubyRuntime             = "google.ruby.runtime"
 	utilsNginx              = "google.utils.nginx"
+	utilsSupervisor         = "google.utils.supervisor"
 )
@@ -96,6 +96,16 @@ func TestAcceptanceGo(t *testing.T) {
 			FilesMustExist:    []string{"/layers/google.go.build/bin/main"},
 			FilesMustNotExist: []string{"/layers/google.go.runtime", "/workspace/main.go"},
 		},
+		{
+			// The supervisor reads supervisor.yaml, so it must run before the source is cleared.
+			Name:              "supervisor with clear source",
+			App:               "supervised",
+			Env:               []string{"GOOGLE_CLEAR_SOURCE=true"},
+			MustUse:           []string{goClearSource, utilsSupervisor},
+			MustOutput:        []string{`Supervising process "web"`},
+			FilesMustExist:    []string{"/layers/google.utils.supervisor/supervisor/supervisor.json"},
+			FilesMustNotExist: []string{"/workspace/main.go"},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -194,7 +194,7 @@ func TestAcceptance(t *testing.T) {
 			Name:          "function with vendored deps",
 			App:           "vendor_dependencies",
 			MustUse:       []string{npm},
-			MustNotOutput: []string{"npm ci --quiet (NODE_ENV=production)"},
+			MustNotOutput: []string{"npm ci --quiet --prefer-offline"},
 			MustOutput:    []string{"npm rebuild"},
 			Env:           []string{"GOOGLE_VENDOR_NPM_DEPENDENCIES=true"},
 			Setup:         setupNodeModules,
@@ -0,0 +1,3 @@
+module example.com/package
+
+go 1.11
\ No newline at end of file
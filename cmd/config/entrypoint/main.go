@@ -19,17 +19,12 @@ package main
 import (
 	"fmt"
 	"os"
-	"regexp"
-	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-)
-
-var (
-	processRe = regexp.MustCompile(`(?m)^(\w+):\s*(.+)$`)
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
 )
 
 func main() {
@@ -109,35 +104,29 @@ func buildFn(ctx *gcp.Context) error {
 
 // addProcfileProcesses adds all processes from the given Procfile contents.
 func addProcfileProcesses(ctx *gcp.Context, content string) error {
-	matches := processRe.FindAllStringSubmatch(content, -1)
-	if len(matches) == 0 {
+	processes := procfile.Parse(content)
+	if len(processes) == 0 {
 		return gcp.UserErrorf("did not find any processes in Procfile")
 	}
 
-	found := make(map[string]bool, len(matches))
-	for _, match := range matches {
-		// Sanity check, if this fails there is a mistake in the regex.
-		// One group for overall match and two subgroups.
-		if len(match) != 3 {
-			return gcp.InternalErrorf("invalid process match, want slice of two strings, got: %v", match)
-		}
-		name, command := match[1], strings.TrimSpace(match[2])
-		if found[name] {
-			ctx.Warnf("Skipping duplicate %s process: %s", gcp.WebProcess, command)
+	found := make(map[string]bool, len(processes))
+	for _, p := range processes {
+		if found[p.Name] {
+			ctx.Warnf("Skipping duplicate %s process: %s", p.Name, p.Command)
 			continue
 		}
-		found[name] = true
+		found[p.Name] = true
 
-		if name == gcp.WebProcess {
-			ctx.Logf("Using entrypoint from Procfile: %s", command)
-			ctx.AddProcess(name, []string{command}, gcp.AsDefaultProcess())
+		if p.Name == gcp.WebProcess {
+			ctx.Logf("Using entrypoint from Procfile: %s", p.Command)
+			ctx.AddProcess(p.Name, []string{p.Command}, gcp.AsDefaultProcess())
 		} else {
-			ctx.AddProcess(name, []string{command})
+			ctx.AddProcess(p.Name, []string{p.Command})
 		}
 	}
 
 	if !found[gcp.WebProcess] {
-		return gcp.UserErrorf("web process not found in Procfile: %#v", matches)
+		return gcp.UserErrorf("web process not found in Procfile: %#v", processes)
 	}
 	return nil
 }
@@ -15,7 +15,10 @@
 package main
 
 import (
+	"bytes"
+	"log"
 	"reflect"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
@@ -187,6 +190,19 @@ dev:     foo
 	}
 }
 
+func TestAddProcfileProcessesDuplicateWarning(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := gcp.NewContext(gcp.WithLogger(log.New(&logs, "", 0)))
+	content := "web: foo\nworker: bar\nworker: baz\n"
+
+	if err := addProcfileProcesses(ctx, content); err != nil {
+		t.Fatalf("addProcfileProcesses(%s) got error: %v", content, err)
+	}
+	if want := "WARNING: Skipping duplicate worker process: baz"; !strings.Contains(logs.String(), want) {
+		t.Errorf("addProcfileProcesses(%s) logs = %q, want warning %q", content, logs.String(), want)
+	}
+}
+
 func TestAddProcfileWebProcessesError(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
@@ -78,6 +79,10 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.CacheMiss(cacheTag)
 	}
 
+	if err := ar.GenerateNuGetConfig(ctx); err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+
 	// Run restore regardless of cache status because it generates files expected by publish.
 	cmd := []string{"dotnet", "restore", "--packages", pkgLayer.Path, proj}
 	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
@@ -121,6 +126,9 @@ func buildFn(ctx *gcp.Context) error {
 	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
 		return err
 	}
+	if err := dotnet.RunTests(ctx); err != nil {
+		return err
+	}
 
 	// Set GOOGLE_ASP_NET_CORE_VERSION, so subsequent buildpacks know which runtime version to install
 	runtimeVersion, err := dotnet.GetRuntimeVersion(ctx, outputDirectory)
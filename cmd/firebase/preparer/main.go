@@ -27,6 +27,7 @@ var (
 	projectID                     = flag.String("project_id", "", "User's GCP project ID")
 	envReferencedOutputFilePath   = flag.String("env_referenced_output_filepath", "", "File path to write sanitized environment variables & referenced secret material to")
 	envDereferencedOutputFilePath = flag.String("env_dereferenced_output_filepath", "", "File path to write sanitized environment variables & dereferenced secret material to")
+	buildEnvOutputFilePath        = flag.String("build_env_output_filepath", "", "Optional file path to write environment variables for the build, but not the runtime, to")
 )
 
 func main() {
@@ -44,7 +45,7 @@ func main() {
 		log.Fatal("--env_dereferenced_output_filepath flag not specified.")
 	}
 
-	err := preparer.Prepare(*apphostingEnvFilePath, *projectID, *envReferencedOutputFilePath, *envDereferencedOutputFilePath)
+	err := preparer.Prepare(*apphostingEnvFilePath, *projectID, *envReferencedOutputFilePath, *envDereferencedOutputFilePath, *buildEnvOutputFilePath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -22,15 +22,12 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
-)
-
-const (
-	noGoFileError         = "no Go files in"
-	cannotFindModuleError = "cannot find module"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
 )
 
 func main() {
@@ -82,7 +79,24 @@ func buildFn(ctx *gcp.Context) error {
 	if workdir == "" {
 		workdir = ctx.ApplicationRoot()
 	}
-	if _, err := ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
+	runTests, err := testrun.Enabled(ctx)
+	if err != nil {
+		return err
+	}
+	if runTests {
+		if err := testrun.Run(ctx, testrun.Config{
+			Command: []string{"go", "test", "-json", "./..."},
+			GoJSON:  true,
+			Options: []gcp.ExecOption{gcp.WithEnv("GOCACHE=" + cl.Path), gcp.WithWorkDir(workdir)},
+		}); err != nil {
+			return err
+		}
+	}
+	if _, err := ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithStderrTail, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	// Only the modules compiled into the binary are audited, rather than everything in go.sum.
+	if err := audit.Run(ctx, audit.GoBinary(outBin)); err != nil {
 		return err
 	}
 
@@ -161,17 +175,3 @@ func goBuildFlags() []string {
 	}
 	return flags
 }
-
-func printTipsAndKeepStderrTail(ctx *gcp.Context) gcp.MessageProducer {
-	return func(result *gcp.ExecResult) string {
-		if result.ExitCode != 0 {
-			// If `go build` fails with any of those two errors, there's a great chance
-			// that we are not building the right package.
-			if strings.Contains(result.Stderr, noGoFileError) || strings.Contains(result.Stderr, cannotFindModuleError) {
-				ctx.Tipf("Tip: %q env var configures which Go package is built. Default is '.'", env.Buildable)
-			}
-		}
-
-		return gcp.KeepStderrTail(result)
-	}
-}
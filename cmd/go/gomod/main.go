@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 )
@@ -74,6 +75,11 @@ func buildFn(ctx *gcp.Context) error {
 		return gcp.UserErrorf("go.mod exists but is not writable")
 	}
 	env := []string{"GOPATH=" + l.Path, "GO111MODULE=on"}
+	arEnv, err := ar.GenerateGoConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	env = append(env, arEnv...)
 
 	// BuildDirEnv should only be set by App Engine buildpacks.
 	workdir := os.Getenv(golang.BuildDirEnv)
@@ -18,6 +18,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
@@ -64,10 +65,39 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	// Configure the entrypoint for production.
+	if err := optimizeStartup(ctx, executable); err != nil {
+		return err
+	}
 	ctx.AddWebProcess(command)
 	return nil
 }
 
+// optimizeStartup replaces the JDK with a jlink runtime and trains an AppCDS archive, if requested.
+func optimizeStartup(ctx *gcp.Context, executable string) error {
+	javaCmd := "java"
+	jlink, err := java.JlinkEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	if jlink {
+		home, err := java.CreateJRE(ctx, executable)
+		if err != nil {
+			return fmt.Errorf("creating jlink runtime: %w", err)
+		}
+		javaCmd = filepath.Join(home, "bin", "java")
+	}
+	appCDS, err := java.AppCDSEnabled()
+	if err != nil {
+		return err
+	}
+	if appCDS {
+		if err := java.CreateAppCDSArchive(ctx, javaCmd, executable); err != nil {
+			return fmt.Errorf("creating AppCDS archive: %w", err)
+		}
+	}
+	return nil
+}
+
 func getEntrypoint(ctx *gcp.Context) string {
 	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
 		return entrypoint
@@ -23,11 +23,15 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
 )
 
 const (
@@ -85,7 +89,14 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
+	runTests, err := testrun.Enabled(ctx)
+	if err != nil {
+		return err
+	}
 	command := []string{gradle, "clean", "assemble", "-x", "test", "--build-cache"}
+	if runTests {
+		command = []string{gradle, "clean", "assemble", "test", "--build-cache"}
+	}
 
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
 		if strings.Contains(buildArgs, "project-cache-dir") {
@@ -98,11 +109,34 @@ func buildFn(ctx *gcp.Context) error {
 		command = append([]string{gradle}, strings.Fields(gradleBuildArgs)...)
 	}
 
+	arInit, err := ar.GenerateGradleConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	if arInit != "" {
+		command = append(command, "--init-script", arInit)
+	}
+
 	if !ctx.Debug() && !devmode.Enabled(ctx) {
 		command = append(command, "--quiet")
 	}
 
-	if _, err := ctx.Exec(command, gcp.WithUserAttribution); err != nil {
+	if runTests {
+		// The test task writes a report for each test class to every project's build directory.
+		if err := testrun.Run(ctx, testrun.Config{
+			Command: command,
+			Reports: []string{"build/test-results/test/TEST-*.xml", "*/build/test-results/test/TEST-*.xml"},
+		}); err != nil {
+			return err
+		}
+	} else if _, err := ctx.Exec(command, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	// The built archives hold the resolved artifacts that are packaged with the application.
+	if err := audit.Run(ctx, audit.JavaArchives(
+		filepath.Join(ctx.ApplicationRoot(), "build/libs/*.[jw]ar"),
+		filepath.Join(ctx.ApplicationRoot(), "*/build/libs/*.[jw]ar"),
+	)); err != nil {
 		return err
 	}
 
@@ -182,25 +216,12 @@ func installGradle(ctx *gcp.Context) (string, error) {
 		return "", fmt.Errorf("Gradle version %s does not exist at %s (status %d)", gradleVersion, downloadURL, code)
 	}
 
-	tmpDir := "/tmp"
-	gradleZip := filepath.Join(tmpDir, "gradle.zip")
-	defer ctx.RemoveAll(gradleZip)
-
-	curl := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s --output %s", downloadURL, gradleZip)
-	if _, err := ctx.Exec([]string{"bash", "-c", curl}); err != nil {
+	if err := fetch.UseCache(ctx); err != nil {
 		return "", err
 	}
-
-	unzip := fmt.Sprintf("unzip -q %s -d %s", gradleZip, tmpDir)
-	if _, err := ctx.Exec([]string{"bash", "-c", unzip}); err != nil {
-		return "", err
-	}
-
-	gradleExtracted := filepath.Join(tmpDir, fmt.Sprintf("gradle-%s", gradleVersion))
-	defer ctx.RemoveAll(gradleExtracted)
-	install := fmt.Sprintf("mv %s/* %s", gradleExtracted, gradlel.Path)
-	if _, err := ctx.Exec([]string{"bash", "-c", install}); err != nil {
-		return "", err
+	// The distribution contains a single top-level gradle-<version> directory.
+	if err := fetch.Archive(downloadURL, gradlel.Path, 1); err != nil {
+		return "", fmt.Errorf("installing Gradle: %w", err)
 	}
 
 	ctx.SetMetadata(gradlel, versionKey, gradleVersion)
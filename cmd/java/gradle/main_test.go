@@ -20,6 +20,7 @@ import (
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
@@ -81,6 +82,20 @@ func TestBuildCommand(t *testing.T) {
 				"gradle clean assemble -x test --build-cache",
 			},
 		},
+		{
+			name: "run tests",
+			app:  "gradle_micronaut",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v gradle || true`, mockprocess.WithStdout("Gradle 0.0.0")),
+			},
+			envs: []string{env.RunTests + "=true"},
+			wantCommands: []string{
+				"gradle clean assemble test --build-cache",
+			},
+			doNotWantCommands: []string{
+				"-x test",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
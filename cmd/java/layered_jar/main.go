@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements the java/layered-jar buildpack.
+// The layered-jar buildpack extracts Spring Boot and Class-Path executable jars into separate
+// launch layers, so that dependencies are only rebuilt and pushed when they change.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+)
+
+const (
+	digestKey = "digest"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if _, ok := os.LookupEnv(java.LayeredJarEnv); ok {
+		enabled, err := env.IsPresentAndTrue(java.LayeredJarEnv)
+		if err != nil {
+			return nil, gcp.UserErrorf("%v", err)
+		}
+		if !enabled {
+			return gcp.OptOut(fmt.Sprintf("%s is set to false", java.LayeredJarEnv)), nil
+		}
+	}
+	appCDS, err := java.AppCDSEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if appCDS {
+		return gcp.OptOut(fmt.Sprintf("the AppCDS archive requested by %s is trained on the executable jar", java.AppCDSEnv)), nil
+	}
+	if env.IsFlex() {
+		return gcp.OptOut("the entrypoint is configured by the App Engine flexible environment"), nil
+	}
+	if os.Getenv(env.Entrypoint) != "" {
+		return gcp.OptOut(fmt.Sprintf("%s is set", env.Entrypoint)), nil
+	}
+	if entrypoint, _ := appyaml.EntrypointIfExists(ctx.ApplicationRoot()); entrypoint != "" {
+		return gcp.OptOut("the entrypoint is set in app.yaml"), nil
+	}
+	// The jar may not be built yet, so whether it can be layered is only known at build time.
+	return gcp.OptInAlways(), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if devmode.Enabled(ctx) {
+		ctx.Logf("Dev mode is enabled, keeping the executable jar.")
+		return nil
+	}
+	jar, err := java.ExecutableJar(ctx)
+	if err != nil {
+		return fmt.Errorf("finding executable jar: %w", err)
+	}
+	layout, err := java.ReadJarLayout(jar)
+	if err != nil {
+		return fmt.Errorf("reading layout of %s: %w", jar, err)
+	}
+	if layout == nil {
+		ctx.Logf("%s is neither a Spring Boot jar nor references its dependencies with Class-Path, keeping the executable jar.", jar)
+		return nil
+	}
+
+	paths := map[string]string{}
+	for _, jl := range layout.Layers {
+		if len(jl.Entries) == 0 && len(jl.Files) == 0 {
+			continue
+		}
+		// The layer is cached so that its contents are restored along with its metadata; a launch-only
+		// layer would be recreated empty on every build.
+		l, err := ctx.Layer(jl.Name, gcp.CacheLayer, gcp.LaunchLayer)
+		if err != nil {
+			return fmt.Errorf("creating %s layer: %w", jl.Name, err)
+		}
+		paths[jl.Name] = l.Path
+		if ctx.GetMetadata(l, digestKey) == jl.Digest {
+			ctx.CacheHit(jl.Name)
+			continue
+		}
+		ctx.CacheMiss(jl.Name)
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing %s layer: %w", jl.Name, err)
+		}
+		if err := java.ExtractJarLayer(jar, jl, l.Path); err != nil {
+			return fmt.Errorf("extracting %s layer: %w", jl.Name, err)
+		}
+		ctx.SetMetadata(l, digestKey, jl.Digest)
+	}
+
+	var classpath []string
+	for _, e := range layout.Classpath {
+		if p, ok := paths[e.Layer]; ok {
+			classpath = append(classpath, filepath.Join(p, e.Path))
+		}
+	}
+	ctx.Logf("Launching %s from %d layers.", layout.MainClass, len(paths))
+	ctx.AddWebProcess([]string{"java", "-classpath", strings.Join(classpath, string(os.PathListSeparator)), layout.MainClass})
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name: "default",
+			want: 0,
+		},
+		{
+			name: "explicitly enabled",
+			env:  []string{"GOOGLE_JAVA_LAYERED_JAR=true"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			env:  []string{"GOOGLE_JAVA_LAYERED_JAR=false"},
+			want: 100,
+		},
+		{
+			name: "invalid",
+			env:  []string{"GOOGLE_JAVA_LAYERED_JAR=maybe"},
+			want: 1,
+		},
+		{
+			name: "appcds",
+			env:  []string{"GOOGLE_JAVA_APPCDS=true"},
+			want: 100,
+		},
+		{
+			name: "entrypoint set",
+			env:  []string{"GOOGLE_ENTRYPOINT=java -jar app.jar"},
+			want: 100,
+		},
+		{
+			name: "flex",
+			env:  []string{"GOOGLE_FLEX_APPLICATION=true"},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
@@ -24,11 +24,15 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
 )
 
 const (
@@ -92,7 +96,15 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	command := []string{mvn, "clean", "package", "--batch-mode", "-DskipTests", "-Dhttp.keepAlive=false"}
+	runTests, err := testrun.Enabled(ctx)
+	if err != nil {
+		return err
+	}
+	command := []string{mvn, "clean", "package", "--batch-mode"}
+	if !runTests {
+		command = append(command, "-DskipTests")
+	}
+	command = append(command, "-Dhttp.keepAlive=false")
 
 	pomPath, err := pomFilePath(ctx)
 	if err != nil {
@@ -113,11 +125,41 @@ func buildFn(ctx *gcp.Context) error {
 		command = append([]string{mvn}, strings.Fields(mvnBuildArgs)...)
 	}
 
+	userSettings, rest := userSettingsPath(ctx, command)
+	arSettings, err := ar.GenerateMavenConfig(ctx, pomPath, userSettings)
+	if err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	if arSettings != "" {
+		// The generated settings are a copy of the user settings with the credentials added.
+		command = append(rest, "--settings", arSettings)
+	}
+
 	if !ctx.Debug() && !devmode.Enabled(ctx) {
 		command = append(command, "--quiet")
 	}
 
-	if _, err := ctx.Exec(command, gcp.WithStdoutTail, gcp.WithUserAttribution); err != nil {
+	moduleDir := filepath.Join(ctx.ApplicationRoot(), filepath.Dir(pomPath))
+	if runTests {
+		// Surefire writes a report for each test class to every module's target directory.
+		if err := testrun.Run(ctx, testrun.Config{
+			Command: command,
+			Reports: []string{
+				filepath.Join(moduleDir, "target/surefire-reports/TEST-*.xml"),
+				filepath.Join(moduleDir, "*/target/surefire-reports/TEST-*.xml"),
+			},
+			Options: []gcp.ExecOption{gcp.WithStdoutTail},
+		}); err != nil {
+			return err
+		}
+	} else if _, err := ctx.Exec(command, gcp.WithStdoutTail, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	// The built archives hold the resolved artifacts that are packaged with the application.
+	if err := audit.Run(ctx, audit.JavaArchives(
+		filepath.Join(moduleDir, "target/*.[jw]ar"),
+		filepath.Join(moduleDir, "*/target/*.[jw]ar"),
+	)); err != nil {
 		return err
 	}
 
@@ -221,15 +263,40 @@ func installMaven(ctx *gcp.Context) (string, error) {
 	if code != http.StatusOK {
 		return "", gcp.InternalErrorf("Maven version %s does not exist at %s (status %d).", mavenVersion, archiveURL, code)
 	}
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, mvnl.Path)
-	if _, err := ctx.Exec([]string{"bash", "-c", command}); err != nil {
+	if err := fetch.UseCache(ctx); err != nil {
 		return "", err
 	}
+	if err := fetch.Tarball(archiveURL, mvnl.Path, 1); err != nil {
+		return "", fmt.Errorf("installing Maven: %w", err)
+	}
 
 	ctx.SetMetadata(mvnl, versionKey, mavenVersion)
 	return filepath.Join(mvnl.Path, "bin", "mvn"), nil
 }
 
+// userSettingsPath returns the user settings.xml that Maven reads given the command, which is
+// ~/.m2/settings.xml unless the command selects another file, and the command without the option
+// that selects it.
+func userSettingsPath(ctx *gcp.Context, command []string) (string, []string) {
+	path := filepath.Join(ctx.HomeDir(), ".m2", "settings.xml")
+	var rest []string
+	for i := 0; i < len(command); i++ {
+		switch arg := command[i]; {
+		case (arg == "-s" || arg == "--settings") && i+1 < len(command):
+			path = command[i+1]
+			i++
+		case strings.HasPrefix(arg, "--settings="):
+			path = strings.TrimPrefix(arg, "--settings=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.ApplicationRoot(), path)
+	}
+	return path, rest
+}
+
 func pomFilePath(ctx *gcp.Context) (string, error) {
 	buildable := os.Getenv(env.Buildable)
 	pomPath := filepath.Join(buildable, "pom.xml")
@@ -20,7 +20,11 @@ import (
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/google/go-cmp/cmp"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -90,6 +94,20 @@ func TestBuildCommand(t *testing.T) {
 				"mvn clean package --batch-mode -DskipTests -Dhttp.keepAlive=false",
 			},
 		},
+		{
+			name: "run tests",
+			app:  "hello_quarkus_maven",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^bash -c command -v mvn || true`, mockprocess.WithStdout("Apache Maven")),
+			},
+			envs: []string{env.RunTests + "=true"},
+			wantCommands: []string{
+				"mvn clean package --batch-mode -Dhttp.keepAlive=false",
+			},
+			doNotWantCommands: []string{
+				"-DskipTests",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -121,3 +139,44 @@ func TestBuildCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSettingsPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		command  []string
+		wantPath string
+		wantRest []string
+	}{
+		{
+			name:     "default",
+			command:  []string{"mvn", "clean", "package"},
+			wantPath: "/home/cnb/.m2/settings.xml",
+			wantRest: []string{"mvn", "clean", "package"},
+		},
+		{
+			name:     "short option",
+			command:  []string{"mvn", "-s", "ci/settings.xml", "package"},
+			wantPath: "/workspace/ci/settings.xml",
+			wantRest: []string{"mvn", "package"},
+		},
+		{
+			name:     "long option",
+			command:  []string{"mvn", "package", "--settings=/etc/maven/settings.xml"},
+			wantPath: "/etc/maven/settings.xml",
+			wantRest: []string{"mvn", "package"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("HOME", "/home/cnb")
+			ctx := gcp.NewContext(gcp.WithApplicationRoot("/workspace"))
+			gotPath, gotRest := userSettingsPath(ctx, tc.command)
+			if gotPath != tc.wantPath {
+				t.Errorf("userSettingsPath(%v) path = %q, want %q", tc.command, gotPath, tc.wantPath)
+			}
+			if diff := cmp.Diff(tc.wantRest, gotRest); diff != "" {
+				t.Errorf("userSettingsPath(%v) command mismatch (-want +got):\n%s", tc.command, diff)
+			}
+		})
+	}
+}
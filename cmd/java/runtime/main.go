@@ -24,6 +24,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 )
 
@@ -89,6 +90,15 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", javaLayer, err)
 	}
+	jlink, err := java.JlinkEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	if jlink {
+		// The java/entrypoint buildpack adds a minimal runtime assembled by jlink to the image instead.
+		ctx.Logf("Leaving the JDK out of the application image, %s is set.", java.JlinkEnv)
+		l.Launch = false
+	}
 	jdkRuntime := runtime.OpenJDK
 	// Java 21 should fetch Jdk from Canonical instead of Adoptium.
 	if strings.HasPrefix(featureVersion, "21") {
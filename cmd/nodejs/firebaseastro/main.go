@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/firebaseastro buildpack.
+// The nodejs/firebaseastro buildpack does some prep work for astro and writes the output bundle configuration.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/Masterminds/semver"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	adapterNodePackage = "@astrojs/node"
+)
+
+var (
+	// astroBundle describes the output of @astrojs/node in standalone mode. The standalone server
+	// listens on localhost unless HOST is set.
+	astroBundle = nodejs.BundleYAML{
+		RunCommand:   "env HOST=0.0.0.0 node dist/server/entry.mjs",
+		NeededDirs:   []string{"dist", "node_modules", "package.json"},
+		StaticAssets: []string{"dist/client"},
+	}
+
+	astro = nodejs.FrameworkAdapter{
+		Name:    "astro",
+		Package: "astro",
+		// MinVersion is the lowest version of astro supported by the firebaseastro buildpack.
+		MinVersion:  semver.MustParse("4.0.0"),
+		ConfigFiles: []string{"astro.config.mjs", "astro.config.js", "astro.config.ts", "astro.config.mts", "astro.config.cjs"},
+		Validate: func(pjs *nodejs.PackageJSON) error {
+			if nodejs.DeclaredVersion(pjs, adapterNodePackage) == "" {
+				return gcp.UserErrorf("%s not found in package.json, astro apps must use %s in standalone mode to run on App Hosting", adapterNodePackage, adapterNodePackage)
+			}
+			return nil
+		},
+		Build: func(*gcp.Context, *nodejs.PackageJSON) (nodejs.FrameworkBuild, error) {
+			return nodejs.FrameworkBuild{Command: "astro build", OtherScripts: []string{"astro check && astro build"}, Bundle: astroBundle}, nil
+		},
+	}
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	return astro.DetectFn(ctx)
+}
+
+func buildFn(ctx *gcp.Context) error {
+	return astro.BuildFn(ctx)
+}
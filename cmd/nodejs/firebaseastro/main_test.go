@@ -0,0 +1,139 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with astro config",
+			files: map[string]string{
+				"package.json":     "",
+				"astro.config.mjs": "",
+			},
+			want: 0,
+		},
+		{
+			name: "without astro config",
+			files: map[string]string{
+				"package.json": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		codeDir      string
+		wantExitCode int
+		wantBundle   *nodejs.BundleYAML
+	}{
+		{
+			name: "writes bundle.yaml",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "astro build"
+					},
+					"dependencies": {
+						"@astrojs/node": "^8.2.0",
+						"astro": "4.4.0"
+					}
+				}`,
+			},
+			codeDir:    "CodeDir-astro-bundleyaml",
+			wantBundle: &astroBundle,
+		},
+		{
+			name: "error out without the node adapter",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"astro": "4.4.0"
+					}
+				}`,
+			},
+			codeDir:      "CodeDir-astro-static",
+			wantExitCode: 1,
+		},
+		{
+			name: "error out if the version is below 4.0.0",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"@astrojs/node": "^6.0.0",
+						"astro": "3.6.5"
+					}
+				}`,
+			},
+			codeDir:      "CodeDir-astro-unsupported",
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithTempDir(tc.codeDir),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			if tc.wantBundle != nil {
+				raw, err := os.ReadFile(filepath.Join(os.TempDir(), tc.codeDir, ".apphosting", "bundle.yaml"))
+				if err != nil {
+					t.Fatalf("reading bundle.yaml: %v", err)
+				}
+				var got nodejs.BundleYAML
+				if err := yaml.Unmarshal(raw, &got); err != nil {
+					t.Fatalf("unmarshalling bundle.yaml: %v", err)
+				}
+				if diff := cmp.Diff(*tc.wantBundle, got); diff != "" {
+					t.Errorf("unexpected bundle.yaml (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/firebasenuxt buildpack.
+// The nodejs/firebasenuxt buildpack does some prep work for nuxt and writes the output bundle configuration.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/Masterminds/semver"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// nuxtBundle describes the output of the default node-server nitro preset, which bundles its
+	// dependencies into .output.
+	nuxtBundle = nodejs.BundleYAML{
+		RunCommand:   "node .output/server/index.mjs",
+		NeededDirs:   []string{".output"},
+		StaticAssets: []string{".output/public"},
+	}
+
+	nuxt = nodejs.FrameworkAdapter{
+		Name:    "nuxt",
+		Package: "nuxt",
+		// MinVersion is the lowest version of nuxt supported by the firebasenuxt buildpack.
+		MinVersion:  semver.MustParse("3.0.0"),
+		ConfigFiles: []string{"nuxt.config.ts", "nuxt.config.js", "nuxt.config.mjs"},
+		Build: func(*gcp.Context, *nodejs.PackageJSON) (nodejs.FrameworkBuild, error) {
+			return nodejs.FrameworkBuild{Command: "nuxt build", OtherScripts: []string{"nuxi build"}, Bundle: nuxtBundle}, nil
+		},
+	}
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	return nuxt.DetectFn(ctx)
+}
+
+func buildFn(ctx *gcp.Context) error {
+	return nuxt.BuildFn(ctx)
+}
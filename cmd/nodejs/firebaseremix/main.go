@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/firebaseremix buildpack.
+// The nodejs/firebaseremix buildpack does some prep work for remix and writes the output bundle configuration.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/Masterminds/semver"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	devPackage   = "@remix-run/dev"
+	servePackage = "@remix-run/serve"
+)
+
+var (
+	viteConfigFiles = []string{"vite.config.ts", "vite.config.js", "vite.config.mjs"}
+
+	remix = nodejs.FrameworkAdapter{
+		Name:    "remix",
+		Package: devPackage,
+		// MinVersion is the lowest version of remix supported by the firebaseremix buildpack.
+		MinVersion:  semver.MustParse("2.0.0"),
+		ConfigFiles: []string{"remix.config.js", "remix.config.mjs", "remix.config.cjs"},
+		Detect:      detectRemix,
+		Build:       buildRemix,
+	}
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	return remix.DetectFn(ctx)
+}
+
+func buildFn(ctx *gcp.Context) error {
+	return remix.BuildFn(ctx)
+}
+
+// detectRemix opts in to apps with a remix config, or that depend on the remix compiler. Remix
+// apps using the vite plugin don't have a remix config.
+func detectRemix(ctx *gcp.Context, pjs *nodejs.PackageJSON, configFile string) (gcp.DetectResult, error) {
+	if configFile != "" {
+		return gcp.OptInFileFound(configFile), nil
+	}
+	if nodejs.DeclaredVersion(pjs, devPackage) != "" {
+		return gcp.OptIn(devPackage + " dependency found"), nil
+	}
+	return gcp.OptOut("remix config not found"), nil
+}
+
+// buildRemix returns the build of the classic remix compiler or of the remix vite plugin.
+func buildRemix(ctx *gcp.Context, pjs *nodejs.PackageJSON) (nodejs.FrameworkBuild, error) {
+	vite, err := usesVite(ctx)
+	if err != nil {
+		return nodejs.FrameworkBuild{}, err
+	}
+	bundle, err := remixBundle(pjs, vite)
+	if err != nil {
+		return nodejs.FrameworkBuild{}, err
+	}
+	command := "remix build"
+	if vite {
+		command = "remix vite:build"
+	}
+	return nodejs.FrameworkBuild{Command: command, Bundle: bundle}, nil
+}
+
+func usesVite(ctx *gcp.Context) (bool, error) {
+	for _, f := range viteConfigFiles {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// remixBundle describes the output of the remix compiler, which is served by remix-serve or the
+// app's own start script.
+func remixBundle(pjs *nodejs.PackageJSON, vite bool) (nodejs.BundleYAML, error) {
+	serverBuild, staticAssets := "build/index.js", "public"
+	neededDirs := []string{"build", "node_modules", "package.json", "public"}
+	if vite {
+		serverBuild, staticAssets = "build/server/index.js", "build/client"
+		neededDirs = []string{"build", "node_modules", "package.json"}
+	}
+
+	var runCommand string
+	switch {
+	case nodejs.DeclaredVersion(pjs, servePackage) != "":
+		runCommand = "node_modules/.bin/remix-serve " + serverBuild
+	case nodejs.HasScript(pjs, "start"):
+		runCommand = "npm run start"
+	default:
+		return nodejs.BundleYAML{}, gcp.UserErrorf("%s or a start script not found in package.json, remix apps must declare how to serve %s", servePackage, serverBuild)
+	}
+	return nodejs.BundleYAML{
+		RunCommand:   runCommand,
+		NeededDirs:   neededDirs,
+		StaticAssets: []string{staticAssets},
+	}, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with remix config",
+			files: map[string]string{
+				"package.json":    "{}",
+				"remix.config.js": "",
+			},
+			want: 0,
+		},
+		{
+			name: "with remix vite plugin",
+			files: map[string]string{
+				"package.json":   `{"devDependencies": {"@remix-run/dev": "^2.8.0"}}`,
+				"vite.config.ts": "",
+			},
+			want: 0,
+		},
+		{
+			name: "without remix",
+			files: map[string]string{
+				"package.json":   `{"devDependencies": {"vite": "^5.0.0"}}`,
+				"vite.config.ts": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		codeDir      string
+		wantExitCode int
+		wantBundle   *nodejs.BundleYAML
+	}{
+		{
+			name: "vite app served by remix-serve",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "remix vite:build"
+					},
+					"dependencies": {
+						"@remix-run/serve": "^2.8.0"
+					},
+					"devDependencies": {
+						"@remix-run/dev": "2.8.1"
+					}
+				}`,
+				"vite.config.ts": "",
+			},
+			codeDir: "CodeDir-remix-vite",
+			wantBundle: &nodejs.BundleYAML{
+				RunCommand:   "node_modules/.bin/remix-serve build/server/index.js",
+				NeededDirs:   []string{"build", "node_modules", "package.json"},
+				StaticAssets: []string{"build/client"},
+			},
+		},
+		{
+			name: "classic app with start script",
+			files: map[string]string{
+				"package.json": `{
+					"scripts": {
+						"build": "remix build",
+						"start": "node server.js"
+					},
+					"devDependencies": {
+						"@remix-run/dev": "2.0.0"
+					}
+				}`,
+				"remix.config.js": "",
+			},
+			codeDir: "CodeDir-remix-classic",
+			wantBundle: &nodejs.BundleYAML{
+				RunCommand:   "npm run start",
+				NeededDirs:   []string{"build", "node_modules", "package.json", "public"},
+				StaticAssets: []string{"public"},
+			},
+		},
+		{
+			name: "error out without a server",
+			files: map[string]string{
+				"package.json": `{
+					"devDependencies": {
+						"@remix-run/dev": "2.8.1"
+					}
+				}`,
+			},
+			codeDir:      "CodeDir-remix-no-server",
+			wantExitCode: 1,
+		},
+		{
+			name: "error out if the version is below 2.0.0",
+			files: map[string]string{
+				"package.json": `{
+					"dependencies": {
+						"@remix-run/serve": "1.19.3"
+					},
+					"devDependencies": {
+						"@remix-run/dev": "1.19.3"
+					}
+				}`,
+			},
+			codeDir:      "CodeDir-remix-unsupported",
+			wantExitCode: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(tc.files),
+				bpt.WithTempDir(tc.codeDir),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			if tc.wantBundle != nil {
+				raw, err := os.ReadFile(filepath.Join(os.TempDir(), tc.codeDir, ".apphosting", "bundle.yaml"))
+				if err != nil {
+					t.Fatalf("reading bundle.yaml: %v", err)
+				}
+				var got nodejs.BundleYAML
+				if err := yaml.Unmarshal(raw, &got); err != nil {
+					t.Fatalf("unmarshalling bundle.yaml: %v", err)
+				}
+				if diff := cmp.Diff(*tc.wantBundle, got); diff != "" {
+					t.Errorf("unexpected bundle.yaml (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/firebasesveltekit buildpack.
+// The nodejs/firebasesveltekit buildpack does some prep work for sveltekit and writes the output bundle configuration.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/Masterminds/semver"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	kitPackage         = "@sveltejs/kit"
+	adapterNodePackage = "@sveltejs/adapter-node"
+)
+
+var (
+	// kitBundle describes the default output of @sveltejs/adapter-node, which imports the
+	// production dependencies at runtime.
+	kitBundle = nodejs.BundleYAML{
+		RunCommand:   "node build",
+		NeededDirs:   []string{"build", "node_modules", "package.json"},
+		StaticAssets: []string{"build/client"},
+	}
+
+	sveltekit = nodejs.FrameworkAdapter{
+		Name:    "sveltekit",
+		Package: kitPackage,
+		// MinVersion is the lowest version of sveltekit supported by the firebasesveltekit buildpack.
+		MinVersion:  semver.MustParse("1.0.0"),
+		ConfigFiles: []string{"svelte.config.js", "svelte.config.mjs", "svelte.config.ts"},
+		Detect:      detectKit,
+		Validate: func(pjs *nodejs.PackageJSON) error {
+			if nodejs.DeclaredVersion(pjs, adapterNodePackage) == "" {
+				return gcp.UserErrorf("%s not found in package.json, sveltekit apps must use %s to run on App Hosting", adapterNodePackage, adapterNodePackage)
+			}
+			return nil
+		},
+		Build: func(*gcp.Context, *nodejs.PackageJSON) (nodejs.FrameworkBuild, error) {
+			return nodejs.FrameworkBuild{Command: "vite build", Bundle: kitBundle}, nil
+		},
+	}
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	return sveltekit.DetectFn(ctx)
+}
+
+func buildFn(ctx *gcp.Context) error {
+	return sveltekit.BuildFn(ctx)
+}
+
+// detectKit opts in to apps with a svelte config that depend on sveltekit. Svelte apps without
+// sveltekit also have a svelte config.
+func detectKit(ctx *gcp.Context, pjs *nodejs.PackageJSON, configFile string) (gcp.DetectResult, error) {
+	if configFile == "" {
+		return gcp.OptOut("svelte config not found"), nil
+	}
+	if nodejs.DeclaredVersion(pjs, kitPackage) == "" {
+		return gcp.OptOut(kitPackage + " dependency not found"), nil
+	}
+	return gcp.OptInFileFound(configFile), nil
+}
@@ -62,21 +62,6 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 // installed in the npm or yarn buildpack with other dependencies.
 // For a function that does not, also install the framework.
 func buildFn(ctx *gcp.Context) error {
-	if _, ok := os.LookupEnv(env.FunctionSource); ok {
-		return gcp.UserErrorf("%s is not currently supported for Node.js buildpacks", env.FunctionSource)
-	}
-
-	indexJSExists, err := ctx.FileExists("index.js")
-	if err != nil {
-		return err
-	}
-	// Function source code should be defined in the "main" field in package.json, index.js or function.js.
-	// https://cloud.google.com/functions/docs/writing#structuring_source_code
-	fnFile := "function.js"
-	if indexJSExists {
-		fnFile = "index.js"
-	}
-
 	// Determine if the function has dependency on functions-framework.
 	hasFrameworkDependency := false
 	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
@@ -85,9 +70,11 @@ func buildFn(ctx *gcp.Context) error {
 	}
 	if pjs != nil {
 		_, hasFrameworkDependency = pjs.Dependencies[functionsFrameworkPackage]
-		if pjs.Main != "" {
-			fnFile = pjs.Main
-		}
+	}
+
+	fnFile, sourceOverride, err := functionFile(ctx, pjs)
+	if err != nil {
+		return err
 	}
 
 	fnFileExists, err := ctx.FileExists(fnFile)
@@ -202,10 +189,60 @@ func buildFn(ctx *gcp.Context) error {
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
+	if sourceOverride {
+		// Point the framework at the resolved module rather than the package.json "main" field, and
+		// pass the same module to later buildpacks so that app_start.json agrees with the launch env.
+		l.LaunchEnvironment.Override(env.FunctionSourceLaunch, fnFile)
+		l.BuildEnvironment.Override(env.FunctionSource, fnFile)
+	}
 	ctx.AddWebProcess([]string{"/bin/bash", "-c", ff})
 	return nil
 }
 
+// functionFile returns the module that contains the function, relative to the application root,
+// and whether the framework must be told to load it instead of resolving the package.json "main"
+// field. The module is GOOGLE_FUNCTION_SOURCE if set, otherwise the "main" field in package.json,
+// index.js or function.js. TypeScript sources resolve to the JavaScript files tsc emitted for them.
+// https://cloud.google.com/functions/docs/writing#structuring_source_code
+func functionFile(ctx *gcp.Context, pjs *nodejs.PackageJSON) (string, bool, error) {
+	if source, ok := os.LookupEnv(env.FunctionSource); ok {
+		fnFile, err := nodejs.CompiledFile(ctx, source)
+		if err != nil {
+			return "", false, err
+		}
+		return fnFile, true, nil
+	}
+	if pjs != nil && pjs.Main != "" {
+		fnFile, err := nodejs.CompiledFile(ctx, pjs.Main)
+		if err != nil {
+			return "", false, err
+		}
+		return fnFile, fnFile != pjs.Main, nil
+	}
+	indexJSExists, err := ctx.FileExists(ctx.ApplicationRoot(), "index.js")
+	if err != nil {
+		return "", false, err
+	}
+	if indexJSExists {
+		return "index.js", false, nil
+	}
+	functionJSExists, err := ctx.FileExists(ctx.ApplicationRoot(), "function.js")
+	if err != nil {
+		return "", false, err
+	}
+	if functionJSExists {
+		return "function.js", false, nil
+	}
+	fnFile, err := nodejs.TypeScriptEntrypoint(ctx, "index.js", "index.ts", "function.ts", "src/index.ts", "src/function.ts")
+	if err != nil {
+		return "", false, err
+	}
+	if fnFile != "" {
+		return fnFile, true, nil
+	}
+	return "function.js", false, nil
+}
+
 // installFunctionsFramework downloads the functions-framework package to node_modules in the given layer.
 func installFunctionsFramework(ctx *gcp.Context, l *libcnb.Layer) error {
 	cvt := filepath.Join(ctx.BuildpackRoot(), "converter", "without-framework")
@@ -16,11 +16,14 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 )
 
 func TestDetect(t *testing.T) {
@@ -146,3 +149,87 @@ func setEnv(t *testing.T, keyVal string) {
 		}
 	})
 }
+
+func TestFunctionFile(t *testing.T) {
+	testCases := []struct {
+		name         string
+		pjs          *nodejs.PackageJSON
+		files        []string
+		env          []string
+		want         string
+		wantOverride bool
+		wantErr      bool
+	}{
+		{
+			name:  "index.js",
+			files: []string{"index.js", "function.js"},
+			want:  "index.js",
+		},
+		{
+			name:  "function.js",
+			files: []string{"function.js"},
+			want:  "function.js",
+		},
+		{
+			name:  "main",
+			pjs:   &nodejs.PackageJSON{Main: "app.js"},
+			files: []string{"index.js", "app.js"},
+			want:  "app.js",
+		},
+		{
+			name:         "function source",
+			pjs:          &nodejs.PackageJSON{Main: "app.js"},
+			files:        []string{"app.js", "fns/hello.js"},
+			env:          []string{"GOOGLE_FUNCTION_SOURCE=fns/hello.js"},
+			want:         "fns/hello.js",
+			wantOverride: true,
+		},
+		{
+			name:         "typescript function source",
+			files:        []string{"tsconfig.json", "src/hello.ts", "dist/hello.js"},
+			env:          []string{"GOOGLE_FUNCTION_SOURCE=src/hello.ts"},
+			want:         "dist/hello.js",
+			wantOverride: true,
+		},
+		{
+			name:         "typescript index",
+			files:        []string{"tsconfig.json", "src/index.ts", "dist/index.js"},
+			want:         "dist/index.js",
+			wantOverride: true,
+		},
+		{
+			name:    "typescript not compiled",
+			files:   []string{"tsconfig.json", "index.ts"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, keyVal := range tc.env {
+				setEnv(t, keyVal)
+			}
+			root := t.TempDir()
+			for _, f := range tc.files {
+				content := ""
+				if f == "tsconfig.json" {
+					content = `{"compilerOptions": {"outDir": "dist"}}`
+				}
+				if err := os.MkdirAll(filepath.Join(root, filepath.Dir(f)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(root, f), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, gotOverride, err := functionFile(ctx, tc.pjs)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("functionFile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want || gotOverride != tc.wantOverride {
+				t.Errorf("functionFile() = (%q, %t), want (%q, %t)", got, gotOverride, tc.want, tc.wantOverride)
+			}
+		})
+	}
+}
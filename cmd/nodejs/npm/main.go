@@ -23,15 +23,20 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
 )
 
 const (
 	cacheTag = "prod dependencies"
+	// npmCacheLayer holds npm's own package cache, which survives changes to the lockfile.
+	npmCacheLayer = "npm_cache"
 )
 
 func main() {
@@ -85,13 +90,31 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := audit.Run(ctx, audit.NPMLockfile(filepath.Join(ctx.ApplicationRoot(), lockfile))); err != nil {
+		return err
+	}
 
 	buildCmds, isCustomBuild := nodejs.DetermineBuildCommands(pjs, "npm")
+	tscCmd, err := nodejs.TypeScriptBuildCommand(ctx, pjs, "npm")
+	if err != nil {
+		return err
+	}
+	if tscCmd != "" {
+		buildCmds = append(buildCmds, tscCmd)
+	}
+	runTests, err := testrun.Enabled(ctx)
+	if err != nil {
+		return err
+	}
+	var testCmd []string
+	if runTests {
+		testCmd = nodejs.TestCommand(pjs, "npm")
+	}
 	// Respect the user's NODE_ENV value if it's set
 	buildNodeEnv, nodeEnvPresent := os.LookupEnv(nodejs.EnvNodeEnv)
 	if !nodeEnvPresent {
-		if len(buildCmds) > 0 {
-			// Assume that dev dependencies are required to run build scripts to
+		if len(buildCmds) > 0 || len(testCmd) > 0 {
+			// Assume that dev dependencies are required to run build scripts and tests to
 			// support the most use cases possible.
 			buildNodeEnv = nodejs.EnvDevelopment
 		} else {
@@ -105,19 +128,25 @@ func buildFn(ctx *gcp.Context) error {
 			return err
 		}
 	} else {
+		npmCache, err := cache.NewPackageCache(ctx, npmCacheLayer)
+		if err != nil {
+			return err
+		}
+		installEnv := gcp.WithEnv("NODE_ENV="+buildNodeEnv, "npm_config_cache="+npmCache.Path)
 		cached, err := nodejs.CheckOrClearCache(ctx, ml, cache.WithStrings(buildNodeEnv), cache.WithFiles("package.json", lockfile))
 		if err != nil {
 			return fmt.Errorf("checking cache: %w", err)
 		}
 		if cached {
-			// Restore cached node_modules.
-			if _, err := ctx.Exec([]string{"cp", "--archive", nm, "node_modules"}, gcp.WithUserTimingAttribution); err != nil {
+			// Restore cached node_modules. It is not linked to the layer because npm install and build
+			// scripts may modify it.
+			if err := ctx.RestoreTree("node_modules", nm, fileutil.Reflink); err != nil {
 				return err
 			}
 
 			// Always run npm install to run preinstall/postinstall scripts.
 			// Otherwise it should be a no-op because the lockfile is unchanged.
-			if _, err := ctx.Exec([]string{"npm", "install", "--quiet"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution); err != nil {
+			if _, err := ctx.Exec([]string{"npm", "install", "--quiet"}, installEnv, gcp.WithUserAttribution); err != nil {
 				return err
 			}
 		} else {
@@ -127,33 +156,44 @@ func buildFn(ctx *gcp.Context) error {
 				return err
 			}
 
-			if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet"}, gcp.WithEnv("NODE_ENV="+buildNodeEnv), gcp.WithUserAttribution); err != nil {
+			// The lockfile changed, but npm's package cache still holds the unchanged packages, so only
+			// the changed ones are downloaded.
+			if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet", "--prefer-offline"}, installEnv, gcp.WithUserAttribution); err != nil {
+				return err
+			}
+			if err := npmCache.Record(ctx); err != nil {
 				return err
 			}
 			// Ensure node_modules exists even if no dependencies were installed.
 			if err := ctx.MkdirAll("node_modules", 0755); err != nil {
 				return err
 			}
-			if _, err := ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution); err != nil {
+			if err := ctx.RestoreTree(nm, "node_modules", fileutil.Reflink); err != nil {
 				return err
 			}
 		}
 	}
 
-	if len(buildCmds) > 0 {
-		// If there are multiple build scripts to run, run them one-by-one so the logs are
-		// easier to understand.
-		for _, cmd := range buildCmds {
-			split := strings.Split(cmd, " ")
-			if _, err := ctx.Exec(split, gcp.WithUserAttribution); err != nil {
-				if !isCustomBuild {
-					return fmt.Errorf(`%w
+	// If there are multiple build scripts to run, run them one-by-one so the logs are
+	// easier to understand.
+	for _, cmd := range buildCmds {
+		split := strings.Split(cmd, " ")
+		if _, err := ctx.Exec(split, gcp.WithUserAttribution); err != nil {
+			if !isCustomBuild {
+				return fmt.Errorf(`%w
 NOTE: Running the default build script can be skipped by passing the empty environment variable "%s=" to the build`, err, nodejs.GoogleNodeRunScriptsEnv)
-				}
-				return err
 			}
+			return err
 		}
+	}
+	if len(testCmd) > 0 {
+		if err := testrun.Run(ctx, testrun.Config{Command: testCmd, Reports: nodejs.TestReports}); err != nil {
+			return err
+		}
+	}
 
+	// devDependencies installed for the build scripts and tests are not needed at run time.
+	if len(buildCmds) > 0 || len(testCmd) > 0 {
 		shouldPrune, err := shouldPrune(ctx, pjs)
 		if err != nil {
 			return err
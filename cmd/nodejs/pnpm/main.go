@@ -21,13 +21,18 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
 )
 
 const (
 	cacheTag  = "prod dependencies"
 	pnpmLayer = "pnpm_engine"
+	// pnpmStoreLayer holds pnpm's content-addressable package store.
+	pnpmStoreLayer = "pnpm_store"
 )
 
 func main() {
@@ -62,6 +67,9 @@ func buildFn(ctx *gcp.Context) error {
 	if err := installPNPM(ctx, pjs); err != nil {
 		return gcp.InternalErrorf("installing pnpm: %w", err)
 	}
+	if err := audit.Run(ctx, audit.PNPMLockfile(filepath.Join(ctx.ApplicationRoot(), nodejs.PNPMLock))); err != nil {
+		return err
+	}
 
 	if err := pnpmInstallModules(ctx, pjs); err != nil {
 		return err
@@ -75,27 +83,54 @@ func buildFn(ctx *gcp.Context) error {
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
 
 	// Configure the entrypoint for production.
-	ctx.AddWebProcess([]string{"pnpm", "run", "start"})
+	cmd, err := nodejs.StartCommand(ctx, pjs, "pnpm")
+	if err != nil {
+		return gcp.InternalErrorf("detecting start command: %w", err)
+	}
+	ctx.AddWebProcess(cmd)
 	return nil
 }
 
 func pnpmInstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	buildCmds, _ := nodejs.DetermineBuildCommands(pjs, "pnpm")
+	tscCmd, err := nodejs.TypeScriptBuildCommand(ctx, pjs, "pnpm")
+	if err != nil {
+		return err
+	}
+	if tscCmd != "" {
+		buildCmds = append(buildCmds, tscCmd)
+	}
+	runTests, err := testrun.Enabled(ctx)
+	if err != nil {
+		return err
+	}
+	var testCmd []string
+	if runTests {
+		testCmd = nodejs.TestCommand(pjs, "pnpm")
+	}
 	// Respect the user's NODE_ENV value if it's set
 	buildNodeEnv, nodeEnvPresent := os.LookupEnv(nodejs.EnvNodeEnv)
 	if !nodeEnvPresent {
-		if len(buildCmds) > 0 {
-			// Assume that dev dependencies are required to run build scripts to
+		if len(buildCmds) > 0 || len(testCmd) > 0 {
+			// Assume that dev dependencies are required to run build scripts and tests to
 			// support the most use cases possible.
 			buildNodeEnv = nodejs.EnvDevelopment
 		} else {
 			buildNodeEnv = nodejs.EnvProduction
 		}
 	}
-	cmd := []string{"pnpm", "install"}
+	store, err := cache.NewPackageCache(ctx, pnpmStoreLayer)
+	if err != nil {
+		return err
+	}
+	storeFlag := "--store-dir=" + store.Path
+	cmd := []string{"pnpm", "install", storeFlag}
 	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true"), gcp.WithEnv("NODE_ENV="+buildNodeEnv)); err != nil {
 		return gcp.UserErrorf("installing pnpm dependencies: %w", err)
 	}
+	if err := store.Record(ctx); err != nil {
+		return err
+	}
 	if len(buildCmds) > 0 {
 		// If there are multiple build scripts to run, run them one-by-one so the logs are
 		// easier to understand.
@@ -106,10 +141,15 @@ func pnpmInstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 			}
 		}
 	}
+	if len(testCmd) > 0 {
+		if err := testrun.Run(ctx, testrun.Config{Command: testCmd, Reports: nodejs.TestReports}); err != nil {
+			return err
+		}
+	}
 	if buildNodeEnv == nodejs.EnvDevelopment && !nodeEnvPresent && nodejs.HasDevDependencies(pjs) {
 		// If we installed dependencies with NODE_ENV=development and the user didn't explicitly set
 		// NODE_ENV we should prune the devDependencies from the final app image.
-		cmd := []string{"pnpm", "prune", "--prod"}
+		cmd := []string{"pnpm", "prune", "--prod", storeFlag}
 		if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("CI=true")); err != nil {
 			return gcp.UserErrorf("pruning devDependencies: %w", err)
 		}
@@ -23,15 +23,20 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
 )
 
 const (
 	cacheTag  = "prod dependencies"
 	yarnLayer = "yarn_engine"
+	// yarnCacheLayer holds Yarn 1's own package cache, which survives changes to the lockfile. Yarn 2+
+	// is not given one because Plug'n'Play loads packages from its cache at run time.
+	yarnCacheLayer = "yarn_cache"
 )
 
 func main() {
@@ -66,6 +71,9 @@ func buildFn(ctx *gcp.Context) error {
 	if err := installYarn(ctx, pjs); err != nil {
 		return fmt.Errorf("installing Yarn: %w", err)
 	}
+	if err := audit.Run(ctx, audit.YarnLockfile(filepath.Join(ctx.ApplicationRoot(), nodejs.YarnLock))); err != nil {
+		return err
+	}
 
 	if yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot()); err != nil {
 		return err
@@ -87,7 +95,10 @@ func buildFn(ctx *gcp.Context) error {
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
 
 	// Configure the entrypoint for production.
-	cmd := []string{"yarn", "run", "start"}
+	cmd, err := nodejs.StartCommand(ctx, pjs, "yarn")
+	if err != nil {
+		return fmt.Errorf("detecting start command: %w", err)
+	}
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
@@ -121,10 +132,15 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
 
-	_, err = nodejs.CheckOrClearCache(ctx, ml, cache.WithFiles("package.json", nodejs.YarnLock))
+	cached, err := nodejs.CheckOrClearCache(ctx, ml, cache.WithFiles("package.json", nodejs.YarnLock))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
+	yarnCache, err := cache.NewPackageCache(ctx, yarnCacheLayer)
+	if err != nil {
+		return err
+	}
+	cacheEnv := gcp.WithEnv("YARN_CACHE_FOLDER=" + yarnCache.Path)
 
 	// Use Yarn's --modules-folder flag to install directly into the layer and then symlink them into
 	// the app dir.
@@ -166,32 +182,55 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	}
 	gcpBuild := nodejs.HasGCPBuild(pjs)
 	appHostingBuildScript, appHostingBuildScriptPresent := os.LookupEnv(nodejs.AppHostingBuildEnv)
-	if gcpBuild || appHostingBuildScriptPresent {
+	tscCmd, err := nodejs.TypeScriptBuildCommand(ctx, pjs, "yarn")
+	if err != nil {
+		return err
+	}
+	testCmd, err := testCommand(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	installDevDependencies := gcpBuild || appHostingBuildScriptPresent || tscCmd != "" || len(testCmd) > 0
+	if installDevDependencies {
 		// Setting --production=false causes the devDependencies to be installed regardless of the
-		// NODE_ENV value. The allows the customer's lifecycle hooks to access to them. We purge the
-		// devDependencies from the final app.
+		// NODE_ENV value. The allows the customer's lifecycle hooks and tests to access to them. We
+		// purge the devDependencies from the final app.
 		cmd = append(cmd, "--production=false")
 	}
 
 	// Add the layer's node_modules/.bin to the path so it is available in postinstall scripts.
 	nodeBin := filepath.Join(layerModules, ".bin")
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, cacheEnv, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
 		return err
 	}
+	if !cached {
+		if err := yarnCache.Record(ctx); err != nil {
+			return err
+		}
+	}
 
-	if gcpBuild || appHostingBuildScriptPresent {
+	if installDevDependencies {
 		if appHostingBuildScriptPresent {
 			if _, err := ctx.Exec(strings.Split(appHostingBuildScript, " "), gcp.WithUserAttribution); err != nil {
 				return err
 			}
-		} else {
+		} else if tscCmd != "" {
+			if _, err := ctx.Exec(strings.Split(tscCmd, " "), gcp.WithUserAttribution); err != nil {
+				return err
+			}
+		} else if gcpBuild {
 			if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
 				return err
 			}
 		}
+		if len(testCmd) > 0 {
+			if err := testrun.Run(ctx, testrun.Config{Command: testCmd, Reports: nodejs.TestReports}); err != nil {
+				return err
+			}
+		}
 
-		// If there was a gcp-build script we installed all the devDependencies above. We should try to
-		// prune them from the final app image.
+		// If there was a gcp-build script or tests we installed all the devDependencies above. We
+		// should try to prune them from the final app image.
 		nodeEnv := nodejs.NodeEnv()
 		if nodejs.NodeEnv() != nodejs.EnvProduction {
 			ctx.Logf("Retaining devDependencies because NODE_ENV=%q", nodeEnv)
@@ -202,7 +241,7 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 			if freezeLockfile {
 				cmd = append(cmd, "--frozen-lockfile")
 			}
-			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, cacheEnv); err != nil {
 				return err
 			}
 		}
@@ -236,6 +275,21 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
 			return err
 		}
+	} else if tscCmd, err := nodejs.TypeScriptBuildCommand(ctx, pjs, "yarn"); err != nil {
+		return err
+	} else if tscCmd != "" {
+		if _, err := ctx.Exec(strings.Split(tscCmd, " "), gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+	testCmd, err := testCommand(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	if len(testCmd) > 0 {
+		if err := testrun.Run(ctx, testrun.Config{Command: testCmd, Reports: nodejs.TestReports}); err != nil {
+			return err
+		}
 	}
 
 	// If there are no devDependencies, there is nothing to prune. We are done.
@@ -264,6 +318,15 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	return nil
 }
 
+// testCommand returns the command that runs the application's tests, or nil if they are not run.
+func testCommand(ctx *gcp.Context, pjs *nodejs.PackageJSON) ([]string, error) {
+	runTests, err := testrun.Enabled(ctx)
+	if err != nil || !runTests {
+		return nil, err
+	}
+	return nodejs.TestCommand(pjs, "yarn"), nil
+}
+
 func installYarn(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	yrl, err := ctx.Layer(yarnLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
@@ -18,7 +18,9 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 )
@@ -48,5 +50,15 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("composer install: %w", err)
 	}
 
-	return nil
+	// composer install writes composer.lock if the application has none.
+	lockExists, err := ctx.FileExists("composer.lock")
+	if err != nil {
+		return err
+	}
+	if lockExists {
+		if err := audit.Run(ctx, audit.ComposerLock(filepath.Join(ctx.ApplicationRoot(), "composer.lock"))); err != nil {
+			return err
+		}
+	}
+	return php.RunTests(ctx)
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appstart"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/Masterminds/semver"
 )
 
@@ -97,6 +98,26 @@ func entrypoint(ctx *gcp.Context) (*appstart.Entrypoint, error) {
 		ctx.Warnf("Installed gunicorn version %q is less than supported version %q.", version, minVersion)
 	}
 
+	// The default command serves main:app with gunicorn, generate a command for any other app.
+	target, err := python.FindAppTarget(ctx, ctx.ApplicationRoot())
+	if err != nil {
+		return nil, fmt.Errorf("finding application object: %w", err)
+	}
+	if target != nil && (target.ASGI || target.String() != "main:app") {
+		server := python.ServerGunicorn
+		if installed, err := python.InstalledPackages(ctx); err != nil {
+			ctx.Warnf("Unable to determine the installed web servers, defaulting to %s: %v", server, err)
+		} else {
+			server = python.ChooseServer(*target, installed)
+		}
+		cmd := python.WebCommand(server, *target)
+		ctx.Logf("Serving %s application %q with %q", target.Framework, target, cmd)
+		return &appstart.Entrypoint{
+			Type:    appstart.EntrypointGenerated.String(),
+			Command: cmd,
+		}, nil
+	}
+
 	return &appstart.Entrypoint{
 		Type:    appstart.EntrypointDefault.String(),
 		Command: appengine.DefaultCommand,
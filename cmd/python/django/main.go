@@ -0,0 +1,200 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/django buildpack.
+// The django buildpack collects static files and checks the deployment settings and migrations of
+// Django projects. Static files are collected into a launch layer with a settings module that
+// extends the project settings with a STATIC_ROOT in that layer. The application is launched with
+// DJANGO_SETTINGS_MODULE set to that module so that the collected files are served, unless
+// DJANGO_SETTINGS_MODULE is set at runtime.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	django     = "django"
+	layerName  = "django"
+	overlayPkg = "google_buildpacks_django"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	manageExists, err := ctx.FileExists("manage.py")
+	if err != nil {
+		return nil, err
+	}
+	if !manageExists {
+		return gcp.OptOutFileNotFound("manage.py"), nil
+	}
+	// Dependencies are installed by the pip buildpack after detection, so whether Django is among
+	// the installed distributions is checked during the build.
+	return gcp.OptInFileFound("manage.py"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	installed, err := python.InstalledPackages(ctx)
+	if err != nil {
+		ctx.Warnf("Skipping Django build steps because the installed packages could not be listed: %v", err)
+		return nil
+	}
+	if !installed[django] {
+		ctx.Warnf("Skipping Django build steps because Django is not installed.")
+		return nil
+	}
+
+	l, err := ctx.Layer(layerName, gcp.BuildLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	envs, err := configureSettings(ctx, l.Path)
+	if err != nil {
+		return err
+	}
+	collected, err := collectStatic(ctx, envs)
+	if err != nil {
+		return err
+	}
+	if collected && len(envs) > 0 {
+		setLaunchSettings(ctx, l, envs)
+	}
+
+	checkDeploy(ctx, envs)
+	return checkMigrations(ctx, envs)
+}
+
+// setLaunchSettings serves the collected files with the same settings at launch, e.g. through
+// WhiteNoise. A DJANGO_SETTINGS_MODULE set at runtime takes precedence.
+func setLaunchSettings(ctx *gcp.Context, l *libcnb.Layer, envs []string) {
+	for _, e := range envs {
+		k, v, _ := strings.Cut(e, "=")
+		if k == "PYTHONPATH" {
+			l.LaunchEnvironment.Prepend(k, string(os.PathListSeparator), filepath.Join(l.Path, "pythonpath"))
+			continue
+		}
+		if k == "DJANGO_SETTINGS_MODULE" {
+			ctx.Logf("Setting DJANGO_SETTINGS_MODULE=%s at launch to serve the collected static files, it extends the project settings with STATIC_ROOT. Set DJANGO_SETTINGS_MODULE at runtime to use other settings.", v)
+		}
+		l.LaunchEnvironment.Default(k, v)
+	}
+}
+
+// configureSettings writes a settings module to the layer that extends the project settings with a
+// STATIC_ROOT inside the layer, and returns the env vars that select it.
+func configureSettings(ctx *gcp.Context, layerPath string) ([]string, error) {
+	settings, err := python.DjangoSettingsModule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	staticRoot := filepath.Join(layerPath, "static")
+	if settings == "" {
+		ctx.Warnf("Unable to determine DJANGO_SETTINGS_MODULE from manage.py, static files are collected into the STATIC_ROOT in the project settings.")
+		return nil, nil
+	}
+	pkgDir := filepath.Join(layerPath, "pythonpath", overlayPkg)
+	if err := ctx.MkdirAll(pkgDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ctx.WriteFile(filepath.Join(pkgDir, "__init__.py"), nil, 0644); err != nil {
+		return nil, err
+	}
+	if err := ctx.WriteFile(filepath.Join(pkgDir, "settings.py"), []byte(python.DjangoSettingsOverlay(settings, staticRoot)), 0644); err != nil {
+		return nil, err
+	}
+	pythonPath := filepath.Join(layerPath, "pythonpath")
+	if p := os.Getenv("PYTHONPATH"); p != "" {
+		pythonPath = pythonPath + string(os.PathListSeparator) + p
+	}
+	return []string{
+		"STATIC_ROOT=" + staticRoot,
+		"DJANGO_SETTINGS_MODULE=" + overlayPkg + ".settings",
+		"PYTHONPATH=" + pythonPath,
+	}, nil
+}
+
+// collectStatic runs collectstatic and returns whether the static files were collected. Failures
+// are not fatal for the same reason as in checkDeploy.
+func collectStatic(ctx *gcp.Context, envs []string) (bool, error) {
+	ctx.Logf("Collecting Django static files")
+	result, err := ctx.Exec([]string{"python3", "manage.py", "collectstatic", "--noinput"}, gcp.WithEnv(envs...), gcp.WithUserAttribution)
+	if err == nil {
+		return true, nil
+	}
+	if result == nil {
+		return false, gcp.InternalErrorf("collecting static files: %v", err)
+	}
+	if strings.Contains(result.Combined, "Unknown command: 'collectstatic'") {
+		ctx.Logf("Skipping collectstatic because django.contrib.staticfiles is not installed.")
+		return false, nil
+	}
+	ctx.Warnf("Collecting Django static files returned non-zero exit code %d, static files will not be served from the image:\n%s", result.ExitCode, result.Combined)
+	return false, nil
+}
+
+// checkDeploy reports the warnings from Django's deployment checklist. Failures are not fatal because
+// the build environment often lacks the secrets and services the settings are checked against. The
+// command output is not logged as it runs because it is reported in the warnings.
+func checkDeploy(ctx *gcp.Context, envs []string) {
+	result, err := ctx.Exec([]string{"python3", "manage.py", "check", "--deploy"}, gcp.WithEnv(envs...), gcp.WithLogOutput(false), gcp.WithUserAttribution)
+	if result == nil {
+		ctx.Warnf("Running Django deployment checks failed: %v", err)
+		return
+	}
+	if err != nil {
+		ctx.Warnf("Django deployment checks returned non-zero exit code %d:\n%s", result.ExitCode, result.Combined)
+		return
+	}
+	for _, w := range python.DjangoCheckWarnings(result.Combined) {
+		ctx.Warnf("Django deployment check: %s", w)
+	}
+}
+
+// checkMigrations fails the build if the models have changes that are not reflected in migrations.
+func checkMigrations(ctx *gcp.Context, envs []string) error {
+	skip, err := env.IsPresentAndTrue(python.DjangoSkipMigrationCheckEnv)
+	if err != nil {
+		return err
+	}
+	result, err := ctx.Exec([]string{"python3", "manage.py", "makemigrations", "--check", "--dry-run"}, gcp.WithEnv(envs...), gcp.WithUserAttribution)
+	if err == nil {
+		return nil
+	}
+	if result == nil {
+		ctx.Warnf("Running Django migration checks failed: %v", err)
+		return nil
+	}
+	// Other failures, such as settings that need services missing from the build environment, are
+	// not fatal for the same reason as in checkDeploy.
+	if !strings.Contains(result.Combined, "Migrations for") {
+		ctx.Warnf("Django migration checks returned non-zero exit code %d:\n%s", result.ExitCode, result.Combined)
+		return nil
+	}
+	if skip {
+		ctx.Warnf("Ignoring pending Django migrations because %s is set:\n%s", python.DjangoSkipMigrationCheckEnv, result.Combined)
+		return nil
+	}
+	return gcp.UserErrorf("model changes are not reflected in migrations, run \"python manage.py makemigrations\" and commit the result, or set %s=true to skip this check:\n%s", python.DjangoSkipMigrationCheckEnv, result.Combined)
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	bpt "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+const manage = `import os
+import sys
+
+if __name__ == "__main__":
+    os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings")
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "manage.py",
+			files: map[string]string{
+				"manage.py":        manage,
+				"requirements.txt": "Django==4.2.7\ngunicorn\n",
+			},
+			want: 0,
+		},
+		{
+			name: "no manage.py",
+			files: map[string]string{
+				"requirements.txt": "django\n",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bpt.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	pipList := mockprocess.New(`^python3 -m pip list`, mockprocess.WithStdout(`[{"name": "Django", "version": "4.2.7"}]`))
+	testCases := []struct {
+		name              string
+		envs              []string
+		mocks             []*mockprocess.Mock
+		wantCommands      []string
+		doNotWantCommands []string
+		wantOutputOnce    []string
+		wantError         bool
+	}{
+		{
+			name: "runs django steps",
+			mocks: []*mockprocess.Mock{
+				pipList,
+				mockprocess.New(`^python3 manage.py check --deploy`, mockprocess.WithStderr("?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.")),
+			},
+			wantCommands: []string{
+				"python3 manage.py collectstatic --noinput",
+				"python3 manage.py check --deploy",
+				"python3 manage.py makemigrations --check --dry-run",
+			},
+			wantOutputOnce: []string{"SECURE_HSTS_SECONDS"},
+		},
+		{
+			name: "collectstatic fails",
+			mocks: []*mockprocess.Mock{
+				pipList,
+				mockprocess.New(`^python3 manage.py collectstatic`, mockprocess.WithStderr("django.core.exceptions.ImproperlyConfigured: The SECRET_KEY setting must not be empty."), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{
+				"python3 manage.py check --deploy",
+				"python3 manage.py makemigrations --check --dry-run",
+			},
+		},
+		{
+			name: "django not installed",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^python3 -m pip list`, mockprocess.WithStdout(`[{"name": "gunicorn", "version": "21.2.0"}]`)),
+			},
+			doNotWantCommands: []string{"python3 manage.py collectstatic --noinput"},
+		},
+		{
+			name: "installed packages cannot be listed",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^python3 -m pip list`, mockprocess.WithStderr("No module named pip"), mockprocess.WithExitCode(1)),
+			},
+			doNotWantCommands: []string{"python3 manage.py collectstatic --noinput"},
+		},
+		{
+			name: "pending migrations",
+			mocks: []*mockprocess.Mock{
+				pipList,
+				mockprocess.New(`^python3 manage.py makemigrations`, mockprocess.WithStdout("Migrations for 'polls':\n  polls/migrations/0002_question_text.py"), mockprocess.WithExitCode(1)),
+			},
+			wantError: true,
+		},
+		{
+			name: "migration check fails without pending migrations",
+			mocks: []*mockprocess.Mock{
+				pipList,
+				mockprocess.New(`^python3 manage.py makemigrations`, mockprocess.WithStderr("django.db.utils.OperationalError: could not connect to server"), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{"python3 manage.py makemigrations --check --dry-run"},
+		},
+		{
+			name: "pending migrations with check skipped",
+			envs: []string{"GOOGLE_DJANGO_SKIP_MIGRATION_CHECK=true"},
+			mocks: []*mockprocess.Mock{
+				pipList,
+				mockprocess.New(`^python3 manage.py makemigrations`, mockprocess.WithStdout("Migrations for 'polls':\n  polls/migrations/0002_question_text.py"), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{"python3 manage.py makemigrations --check --dry-run"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []bpt.Option{
+				bpt.WithTestName(tc.name),
+				bpt.WithFiles(map[string]string{"manage.py": manage}),
+				bpt.WithEnvs(tc.envs...),
+				bpt.WithExecMocks(tc.mocks...),
+			}
+			result, err := bpt.RunBuild(t, buildFn, opts...)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("RunBuild() got no error, want error, build output: %s", result.Output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, cmd := range tc.doNotWantCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q not to be executed, but it was, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, want := range tc.wantOutputOnce {
+				if got := strings.Count(result.Output, want); got != 1 {
+					t.Errorf("build output contains %q %d times, want once, build output: %s", want, got, result.Output)
+				}
+			}
+		})
+	}
+}
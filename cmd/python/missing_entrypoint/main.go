@@ -13,8 +13,9 @@
 // limitations under the License.
 
 // Implements python/missing-entrypoint buildpack.
-// This buildpack's goal is to display a clear error message when
-// no entrypoint is defined on a Python application.
+// This buildpack generates a web process for Python applications without an entrypoint by finding
+// the Flask, Django, FastAPI or Starlette application object, and displays a clear error message
+// when there is none.
 package main
 
 import (
@@ -23,6 +24,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 )
 
@@ -46,17 +48,38 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	hasMain, err := ctx.HasAtLeastOne("main.py")
+	target, err := python.FindAppTarget(ctx, ctx.ApplicationRoot())
 	if err != nil {
-		return fmt.Errorf("finding main.py files: %w", err)
+		return fmt.Errorf("finding application object: %w", err)
 	}
-	if !hasMain {
-		return fmt.Errorf("for Python, provide a main.py file or set an entrypoint with %q env var or by creating a %q file", env.Entrypoint, "Procfile")
+	if target == nil {
+		hasMain, err := ctx.HasAtLeastOne("main.py")
+		if err != nil {
+			return fmt.Errorf("finding main.py files: %w", err)
+		}
+		if !hasMain {
+			return fmt.Errorf("for Python, provide a main.py file or set an entrypoint with %q env var or by creating a %q file", env.Entrypoint, "Procfile")
+		}
+		target = &python.AppTarget{Module: "main", Attr: "app"}
+	} else {
+		ctx.Logf("Found %s application %q", target.Framework, target)
 	}
 
-	cmd := []string{"gunicorn", "-b", ":8080", "main:app"}
+	server := python.ServerGunicorn
+	if installed, err := python.InstalledPackages(ctx); err != nil {
+		ctx.Warnf("Unable to determine the installed web servers, defaulting to %s: %v", server, err)
+	} else {
+		server = python.ChooseServer(*target, installed)
+		if !installed[string(server)] {
+			ctx.Warnf("%s is not installed, add it to requirements.txt to serve %q", server, target)
+		} else if server == python.ServerGunicorn && target.ASGI && !installed["uvicorn"] {
+			ctx.Warnf("%q is an ASGI application, add uvicorn to requirements.txt to serve it with gunicorn", target)
+		}
+	}
+
+	cmd := []string{"/bin/bash", "-c", python.WebCommand(server, *target)}
 	ctx.Logf("Setting default entrypoint: %q", strings.Join(cmd, " "))
-	ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDefaultProcess())
+	ctx.AddWebProcess(cmd)
 
 	return nil
 }
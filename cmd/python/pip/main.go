@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/buildpacks/libcnb"
@@ -79,7 +80,17 @@ func buildFn(ctx *gcp.Context) error {
 	if err := python.InstallRequirements(ctx, l, reqs...); err != nil {
 		return fmt.Errorf("installing dependencies: %w", err)
 	}
+	if err := checkDependencies(ctx); err != nil {
+		return err
+	}
+	if err := audit.Run(ctx, audit.PythonDists(l.Path)); err != nil {
+		return err
+	}
+	return python.RunTests(ctx)
+}
 
+// checkDependencies fails if the installed dependencies are incompatible with each other.
+func checkDependencies(ctx *gcp.Context) error {
 	ctx.Logf("Checking for incompatible dependencies.")
 	result, err := ctx.Exec([]string{"python3", "-m", "pip", "check"}, gcp.WithUserAttribution)
 	if result == nil {
@@ -98,5 +109,4 @@ func buildFn(ctx *gcp.Context) error {
 		return nil
 	}
 	return gcp.UserErrorf("found incompatible dependencies: %q", result.Stdout)
-
 }
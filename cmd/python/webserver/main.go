@@ -13,14 +13,15 @@
 // limitations under the License.
 
 // Implements python/webserver buildpack.
-// The webserver buildpack installs gunicorn if a custom entrypoint is not specified.
+// The webserver buildpack installs gunicorn, and uvicorn for ASGI apps, if a custom entrypoint is
+// not specified.
 package main
 
 import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -29,11 +30,17 @@ import (
 
 const (
 	layerName = "gunicorn"
+
+	gunicorn = "gunicorn"
+	uvicorn  = "uvicorn"
 )
 
 var (
-	gunicornRegexp = regexp.MustCompile(`(?m)^gunicorn\b([^-]|$)`)
-	eggRegexp      = regexp.MustCompile(`(?m)#egg=gunicorn$`)
+	// requirementsFiles are the requirements files in the buildpack that install each web server.
+	requirementsFiles = map[string]string{
+		gunicorn: "requirements.txt",
+		uvicorn:  "requirements-asgi.txt",
+	}
 )
 
 func main() {
@@ -48,17 +55,17 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	if requirementsExists {
-		present, err := gunicornPresentInRequirements(ctx, "requirements.txt")
-		if err != nil {
-			return nil, fmt.Errorf("error detecting gunicorn: %w", err)
-		}
-		if present {
-			return gcp.OptOut("gunicorn present in requirements.txt"), nil
-		}
-		return gcp.OptIn("gunicorn missing from requirements.txt", gcp.WithBuildPlans(python.RequirementsProvidesPlan)), nil
+	if !requirementsExists {
+		return gcp.OptIn("requirements.txt with gunicorn not found", gcp.WithBuildPlans(python.RequirementsProvidesPlan)), nil
 	}
-	return gcp.OptIn("requirements.txt with gunicorn not found", gcp.WithBuildPlans(python.RequirementsProvidesPlan)), nil
+	reqs, err := missingRequirements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error detecting gunicorn: %w", err)
+	}
+	if len(reqs) == 0 {
+		return gcp.OptOut("gunicorn present in requirements.txt"), nil
+	}
+	return gcp.OptIn(fmt.Sprintf("%s missing from requirements.txt", strings.Join(reqs, ", ")), gcp.WithBuildPlans(python.RequirementsProvidesPlan)), nil
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -66,22 +73,50 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
 	}
+	reqs, err := missingRequirements(ctx)
+	if err != nil {
+		return err
+	}
 
 	// The pip install is performed by the pip buildpack; see python.InstallRequirements.
-	ctx.Debugf("Adding webserver requirements.txt to the list of requirements files to install.")
-	r := filepath.Join(ctx.BuildpackRoot(), "requirements.txt")
-	l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
+	for _, req := range reqs {
+		ctx.Debugf("Adding %s requirements file to the list of requirements files to install.", req)
+		r := filepath.Join(ctx.BuildpackRoot(), requirementsFiles[req])
+		l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
+	}
 	return nil
 }
 
-func gunicornPresentInRequirements(ctx *gcp.Context, path string) (bool, error) {
-	content, err := ctx.ReadFile(path)
+// missingRequirements returns the web servers the app needs that requirements.txt does not list:
+// gunicorn, and uvicorn for ASGI apps that don't use hypercorn.
+func missingRequirements(ctx *gcp.Context) ([]string, error) {
+	var content string
+	requirementsExists, err := ctx.FileExists("requirements.txt")
+	if err != nil {
+		return nil, err
+	}
+	if requirementsExists {
+		raw, err := ctx.ReadFile("requirements.txt")
+		if err != nil {
+			return nil, err
+		}
+		content = string(raw)
+	}
+
+	var reqs []string
+	if !containsGunicorn(content) {
+		reqs = append(reqs, gunicorn)
+	}
+	target, err := python.FindAppTarget(ctx, ctx.ApplicationRoot())
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if target != nil && target.ASGI && !python.RequirementPresent(content, uvicorn) && !python.RequirementPresent(content, string(python.ServerHypercorn)) {
+		reqs = append(reqs, uvicorn)
 	}
-	return containsGunicorn(string(content)), nil
+	return reqs, nil
 }
 
 func containsGunicorn(s string) bool {
-	return gunicornRegexp.MatchString(s) || eggRegexp.MatchString(s)
+	return python.RequirementPresent(s, gunicorn)
 }
@@ -56,6 +56,27 @@ func TestDetect(t *testing.T) {
 				"requirements.txt": "gunicorn==19.3.0"},
 			want: 100,
 		},
+		{
+			name: "asgi app has gunicorn without uvicorn",
+			files: map[string]string{
+				"main.py":          "from fastapi import FastAPI\napp = FastAPI()\n",
+				"requirements.txt": "fastapi\ngunicorn==19.3.0"},
+			want: 0,
+		},
+		{
+			name: "asgi app has gunicorn and uvicorn",
+			files: map[string]string{
+				"main.py":          "from fastapi import FastAPI\napp = FastAPI()\n",
+				"requirements.txt": "fastapi\ngunicorn==19.3.0\nuvicorn[standard]"},
+			want: 100,
+		},
+		{
+			name: "asgi app has hypercorn",
+			files: map[string]string{
+				"main.py":          "from fastapi import FastAPI\napp = FastAPI()\n",
+				"requirements.txt": "fastapi\ngunicorn==19.3.0\nhypercorn"},
+			want: 100,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
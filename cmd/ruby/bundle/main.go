@@ -20,9 +20,13 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -30,6 +34,9 @@ const (
 	layerName         = "gems"
 	dependencyHashKey = "dependency_hash"
 	rubyVersionKey    = "ruby_version"
+	// bundlerCacheLayer holds Bundler's own cache of downloaded gems and gem indexes, which survives
+	// changes to the lockfile.
+	bundlerCacheLayer = "bundler_cache"
 )
 
 func main() {
@@ -86,6 +93,9 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		lockFile = "gems.locked"
 	}
+	if err := audit.Run(ctx, audit.GemfileLock(filepath.Join(ctx.ApplicationRoot(), lockFile))); err != nil {
+		return err
+	}
 
 	// Remove any user-provided local bundle config and cache that can interfere with the build process.
 	if err := ctx.RemoveAll(".bundle"); err != nil {
@@ -148,8 +158,20 @@ func buildFn(ctx *gcp.Context) error {
 		if _, err := ctx.Exec([]string{"bundle", "config", "--local", "path", localGemsDir}, gcp.WithUserAttribution); err != nil {
 			return err
 		}
+		if err := ar.GenerateBundlerConfig(ctx); err != nil {
+			return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+		}
+		bundlerCache, err := cache.NewPackageCache(ctx, bundlerCacheLayer)
+		if err != nil {
+			return err
+		}
 		if _, err := ctx.Exec([]string{"bundle", "install"},
-			gcp.WithEnv("NOKOGIRI_USE_SYSTEM_LIBRARIES=1", "MALLOC_ARENA_MAX=2", "LANG=C.utf8"), gcp.WithUserAttribution); err != nil {
+			gcp.WithEnv("NOKOGIRI_USE_SYSTEM_LIBRARIES=1", "MALLOC_ARENA_MAX=2", "LANG=C.utf8"),
+			gcp.WithEnv("BUNDLE_USER_CACHE="+bundlerCache.Path, "BUNDLE_GLOBAL_GEM_CACHE=true"),
+			gcp.WithUserAttribution); err != nil {
+			return err
+		}
+		if err := bundlerCache.Record(ctx); err != nil {
 			return err
 		}
 
@@ -176,11 +198,11 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	// Always link local .bundle directory to the actual installation stored in the layer.
-	if err := ctx.Symlink(bundleOutput, ".bundle"); err != nil {
+	if err := ctx.RestoreTree(".bundle", bundleOutput, fileutil.Symlink); err != nil {
 		return err
 	}
 
-	return nil
+	return ruby.RunTests(ctx, lockFile)
 }
 
 // checkCache checks whether cached dependencies exist and match.
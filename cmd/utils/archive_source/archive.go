@@ -0,0 +1,224 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ignorefile"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	formatGzip = "gzip"
+	formatZstd = "zstd"
+
+	// ignoreFile lists additional patterns to leave out of the archive, using the same syntax as
+	// gcloud. Negated patterns can re-include the default exclusions.
+	ignoreFile = ".gcloudignore"
+)
+
+var (
+	// defaultIgnores are left out of the archive unless re-included by the ignore file.
+	defaultIgnores = []string{"/.git/", "node_modules/", "/.googlebuild/"}
+
+	// defaultModTime is the timestamp the lifecycle uses for reproducible image layers.
+	defaultModTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+)
+
+// archiveSource writes a reproducible archive of dirName to fileName and returns its hex-encoded
+// SHA-256 digest. Entries are written in lexical order with normalized timestamps and ownership, so
+// the same source tree always yields the same archive.
+func archiveSource(ctx *gcp.Context, fileName, dirName, format string) (string, error) {
+	m, err := ignoreMatcher(dirName)
+	if err != nil {
+		return "", err
+	}
+	mtime, err := modTime()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return "", gcp.InternalErrorf("creating %s: %v", fileName, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	cw, err := compressor(io.MultiWriter(f, h), format)
+	if err != nil {
+		return "", err
+	}
+	tw := tar.NewWriter(cw)
+	a := &archiver{tw: tw, root: dirName, ignore: m, mtime: mtime}
+	if err := a.addDir(""); err != nil {
+		return "", gcp.InternalErrorf("archiving %s: %v", dirName, err)
+	}
+	if err := tw.Close(); err != nil {
+		return "", gcp.InternalErrorf("writing %s: %v", fileName, err)
+	}
+	if err := cw.Close(); err != nil {
+		return "", gcp.InternalErrorf("compressing %s: %v", fileName, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", gcp.InternalErrorf("closing %s: %v", fileName, err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	ctx.Logf("Archived source to %s (sha256:%s, %d entries)", fileName, digest, a.entries)
+	return digest, nil
+}
+
+// ignoreMatcher returns a matcher for the paths to leave out of the archive of dir.
+func ignoreMatcher(dir string) (*ignorefile.Matcher, error) {
+	m, err := ignorefile.New(defaultIgnores...)
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing default ignores: %v", err)
+	}
+	um, err := ignorefile.ReadFile(filepath.Join(dir, ignoreFile))
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", ignoreFile, err)
+	}
+	m.Extend(um)
+	return m, nil
+}
+
+// modTime returns the timestamp to use for all archive entries.
+func modTime() (time.Time, error) {
+	t, err := env.SourceDate(defaultModTime)
+	if err != nil {
+		return time.Time{}, gcp.UserErrorf("%v", err)
+	}
+	return t, nil
+}
+
+// compressor wraps w in a writer for the given compression format. The output only depends on the
+// input bytes: the gzip header carries no name or timestamp and zstd encodes on a single goroutine.
+func compressor(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case formatGzip:
+		zw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+		if err != nil {
+			return nil, gcp.InternalErrorf("creating gzip writer: %v", err)
+		}
+		return zw, nil
+	case formatZstd:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, gcp.InternalErrorf("creating zstd writer: %v", err)
+		}
+		return zw, nil
+	default:
+		return nil, gcp.InternalErrorf("unsupported archive format %q", format)
+	}
+}
+
+// archiver adds the files below root to a tar stream.
+type archiver struct {
+	tw      *tar.Writer
+	root    string
+	ignore  *ignorefile.Matcher
+	mtime   time.Time
+	entries int
+}
+
+// addDir adds the contents of root/rel in lexical order.
+func (a *archiver) addDir(rel string) error {
+	entries, err := os.ReadDir(filepath.Join(a.root, rel))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		er := path.Join(rel, e.Name())
+		if a.ignore.Match(er, e.IsDir()) {
+			// A negated pattern may re-include something below an ignored directory; its parents are
+			// then created implicitly on extraction.
+			if e.IsDir() && a.ignore.HasNegations() {
+				if err := a.addDir(er); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := a.add(er); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add writes the entry for root/rel, recursing into directories. Entries other than regular files,
+// directories and symlinks are skipped.
+func (a *archiver) add(rel string) error {
+	p := filepath.Join(a.root, rel)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	var link string
+	switch info.Mode().Type() {
+	case 0, fs.ModeDir:
+	case fs.ModeSymlink:
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("creating header for %s: %w", rel, err)
+	}
+	hdr.Name = filepath.ToSlash(rel)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.ModTime = a.mtime
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing header for %s: %w", rel, err)
+	}
+	a.entries++
+
+	switch {
+	case info.IsDir():
+		return a.addDir(rel)
+	case info.Mode().IsRegular():
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(a.tw, f); err != nil {
+			return fmt.Errorf("writing %s: %w", rel, err)
+		}
+	}
+	return nil
+}
@@ -27,7 +27,8 @@ import (
 )
 
 const (
-	archiveName = "source-code.tar.gz"
+	archiveName     = "source-code.tar.gz"
+	zstdArchiveName = "source-code.tar.zst"
 )
 
 func main() {
@@ -52,12 +53,17 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	format, name, err := archiveFormat()
+	if err != nil {
+		return err
+	}
 	sl, err := ctx.Layer("src", gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
-	sp := filepath.Join(sl.Path, archiveName)
-	if err := archiveSource(ctx, sp, ctx.ApplicationRoot()); err != nil {
+	sp := filepath.Join(sl.Path, name)
+	digest, err := archiveSource(ctx, sp, ctx.ApplicationRoot(), format)
+	if err != nil {
 		return err
 	}
 
@@ -66,23 +72,25 @@ func buildFn(ctx *gcp.Context) error {
 	if err := ctx.MkdirAll(googleBuildPath, 0755); err != nil {
 		return err
 	}
-	stable := filepath.Join(ctx.ApplicationRoot(), ".googlebuild", archiveName)
+	stable := filepath.Join(ctx.ApplicationRoot(), ".googlebuild", name)
 	if err := ctx.Symlink(sp, stable); err != nil {
 		return err
 	}
 	ctx.AddLabel("source-archive", stable)
+	ctx.AddLabel("source-archive-sha256", digest)
 
 	return nil
 }
 
-// archiveSource archives user's source code in a layer
-func archiveSource(ctx *gcp.Context, fileName, dirName string) error {
-	if _, err := ctx.Exec([]string{"tar",
-		"--create", "--gzip", "--preserve-permissions",
-		"--file=" + fileName,
-		"--directory", dirName,
-		"."}, gcp.WithUserTimingAttribution); err != nil {
-		return err
+// archiveFormat returns the compression format requested by env.SourceArchiveFormat and the
+// matching archive file name.
+func archiveFormat() (string, string, error) {
+	switch f := os.Getenv(env.SourceArchiveFormat); f {
+	case "", formatGzip:
+		return formatGzip, archiveName, nil
+	case formatZstd:
+		return formatZstd, zstdArchiveName, nil
+	default:
+		return "", "", gcp.UserErrorf("invalid %s %q, must be one of %q or %q", env.SourceArchiveFormat, f, formatGzip, formatZstd)
 	}
-	return nil
 }
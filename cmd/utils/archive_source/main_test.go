@@ -15,16 +15,25 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"testing"
+	"time"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestDetect(t *testing.T) {
@@ -119,7 +128,7 @@ func TestArchiveSource(t *testing.T) {
 			name: "archive symlinks",
 			files: []testFile{
 				testFile{Path: "src/index.js", Content: `console.log("Hello World");`},
-				testFile{Path: "node_modules/.bin/start", SymLink: "src/index.js"},
+				testFile{Path: "bin/start", SymLink: "src/index.js"},
 			},
 		},
 	}
@@ -166,7 +175,9 @@ func TestArchiveSource(t *testing.T) {
 			defer os.RemoveAll(srcDir)
 
 			sp := filepath.Join(srcDir, archiveName)
-			archiveSource(gcp.NewContext(), sp, appDir)
+			if _, err := archiveSource(gcp.NewContext(), sp, appDir, formatGzip); err != nil {
+				t.Fatalf("archiveSource() got error: %v", err)
+			}
 
 			if _, err := os.Stat(sp); err != nil {
 				if os.IsNotExist(err) {
@@ -227,3 +238,183 @@ func TestArchiveSource(t *testing.T) {
 		})
 	}
 }
+
+func TestArchiveSourceFilters(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "default ignores",
+			files: map[string]string{
+				"index.js":                  "",
+				".git/HEAD":                 "",
+				"node_modules/foo/index.js": "",
+				"src/node_modules/bar.js":   "",
+				".googlebuild/old.tar.gz":   "",
+			},
+			want: []string{"index.js", "src/"},
+		},
+		{
+			name: "gcloudignore",
+			files: map[string]string{
+				".gcloudignore": "*.log\n/tmp/\n",
+				"index.js":      "",
+				"debug.log":     "",
+				"src/app.log":   "",
+				"tmp/cache":     "",
+			},
+			want: []string{".gcloudignore", "index.js", "src/"},
+		},
+		{
+			name: "gcloudignore re-includes default",
+			files: map[string]string{
+				".gcloudignore":             "!node_modules/\n",
+				"index.js":                  "",
+				"node_modules/foo/index.js": "",
+			},
+			want: []string{".gcloudignore", "index.js", "node_modules/", "node_modules/foo/", "node_modules/foo/index.js"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			appDir := t.TempDir()
+			writeFiles(t, appDir, tc.files)
+			sp := filepath.Join(t.TempDir(), archiveName)
+			if _, err := archiveSource(gcp.NewContext(), sp, appDir, formatGzip); err != nil {
+				t.Fatalf("archiveSource() got error: %v", err)
+			}
+
+			var got []string
+			for _, hdr := range readHeaders(t, sp, formatGzip) {
+				got = append(got, hdr.Name)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("archiveSource() entries mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArchiveSourceReproducible(t *testing.T) {
+	files := map[string]string{
+		"index.js":     `console.log("Hello World");`,
+		"package.json": "{}",
+		"src/lib.js":   "module.exports = {};",
+	}
+	for _, format := range []string{formatGzip, formatZstd} {
+		t.Run(format, func(t *testing.T) {
+			var digests []string
+			for i, mtime := range []time.Time{time.Unix(1000, 0), time.Unix(2000, 0)} {
+				appDir := t.TempDir()
+				writeFiles(t, appDir, files)
+				for f := range files {
+					if err := os.Chtimes(filepath.Join(appDir, f), mtime, mtime); err != nil {
+						t.Fatalf("setting times of %s: %v", f, err)
+					}
+				}
+				sp := filepath.Join(t.TempDir(), fmt.Sprintf("archive-%d", i))
+				digest, err := archiveSource(gcp.NewContext(), sp, appDir, format)
+				if err != nil {
+					t.Fatalf("archiveSource() got error: %v", err)
+				}
+				content, err := os.ReadFile(sp)
+				if err != nil {
+					t.Fatalf("reading %s: %v", sp, err)
+				}
+				if want := fmt.Sprintf("%x", sha256.Sum256(content)); digest != want {
+					t.Errorf("archiveSource() = %q, want sha256 of archive %q", digest, want)
+				}
+				for _, hdr := range readHeaders(t, sp, format) {
+					if !hdr.ModTime.Equal(defaultModTime) || hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" {
+						t.Errorf("entry %s not normalized: mtime=%v uid=%d gid=%d uname=%q gname=%q", hdr.Name, hdr.ModTime, hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname)
+					}
+				}
+				digests = append(digests, digest)
+			}
+			if digests[0] != digests[1] {
+				t.Errorf("archiveSource() digests differ for the same source: %v", digests)
+			}
+		})
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	testCases := []struct {
+		value      string
+		wantFormat string
+		wantName   string
+		wantErr    bool
+	}{
+		{value: "", wantFormat: formatGzip, wantName: archiveName},
+		{value: "gzip", wantFormat: formatGzip, wantName: archiveName},
+		{value: "zstd", wantFormat: formatZstd, wantName: zstdArchiveName},
+		{value: "bzip2", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(env.SourceArchiveFormat, tc.value)
+			format, name, err := archiveFormat()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("archiveFormat() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if format != tc.wantFormat || name != tc.wantName {
+				t.Errorf("archiveFormat() = (%q, %q), want (%q, %q)", format, name, tc.wantFormat, tc.wantName)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", fn, err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatalf("writing file %s: %v", fn, err)
+		}
+	}
+}
+
+func readHeaders(t *testing.T, fileName, format string) []*tar.Header {
+	t.Helper()
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("opening %s: %v", fileName, err)
+	}
+	defer f.Close()
+	var r io.Reader
+	switch format {
+	case formatGzip:
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("opening gzip stream: %v", err)
+		}
+		if zr.Name != "" || !zr.ModTime.IsZero() {
+			t.Errorf("gzip header has name %q and mtime %v, want none", zr.Name, zr.ModTime)
+		}
+		r = zr
+	case formatZstd:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatalf("opening zstd stream: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var hdrs []*tar.Header
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return hdrs
+		}
+		if err != nil {
+			t.Fatalf("reading %s: %v", fileName, err)
+		}
+		hdrs = append(hdrs, hdr)
+	}
+}
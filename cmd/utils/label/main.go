@@ -14,15 +14,18 @@
 
 // Implements utils/label-image buildpack.
 // The label-image buildpack adds any environment variables with the "GOOGLE_LABEL_" prefix as
-// labels in the final application image.
+// labels in the final application image, along with OCI provenance labels describing the source.
 package main
 
 import (
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/provenance"
 )
 
 func main() {
@@ -47,5 +50,78 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		ctx.AddLabel(key, value)
 	}
+	return addProvenanceLabels(ctx)
+}
+
+// addProvenanceLabels adds the standard OCI labels describing the source revision, read from .git
+// and overridden by env vars, and the runtime versions installed during the build.
+func addProvenanceLabels(ctx *gcp.Context) error {
+	src, err := provenance.FromGit(ctx.ApplicationRoot())
+	if err != nil {
+		// Provenance is best-effort and must not fail the build.
+		ctx.Warnf("Failed to read git metadata, skipping: %v", err)
+	}
+	if src == nil {
+		if err == nil && os.Getenv(env.SourceRevision) == "" {
+			// Builders that clear the source run this buildpack first, so .git is missing from the
+			// uploaded source rather than deleted.
+			ctx.Warnf("No git metadata found in the application source, the image will not be labeled with its source revision. Set %s to label it.", env.SourceRevision)
+		}
+		src = &provenance.Source{}
+	} else {
+		src.Dirty = isDirty(ctx)
+	}
+	if v := os.Getenv(env.SourceRevision); v != "" {
+		src.Revision = v
+	}
+	if v := os.Getenv(env.SourceURL); v != "" {
+		src.URL = provenance.SanitizeURL(v)
+	}
+	version := src.Tag
+	if v := os.Getenv(env.SourceVersion); v != "" {
+		version = v
+	}
+
+	// The creation time is only recorded when SOURCE_DATE_EPOCH fixes it, since the time of the build
+	// would make the digest of every image unique.
+	created, err := env.SourceDate(time.Time{})
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !created.IsZero() {
+		ctx.AddNamespacedLabel(provenance.LabelCreated, created.Format(time.RFC3339))
+	}
+	if src.Revision != "" {
+		ctx.AddNamespacedLabel(provenance.LabelRevision, src.Revision)
+	}
+	if src.URL != "" {
+		ctx.AddNamespacedLabel(provenance.LabelSource, src.URL)
+	}
+	if version != "" {
+		ctx.AddNamespacedLabel(provenance.LabelVersion, version)
+	}
+	if src.Branch != "" {
+		ctx.AddLabel("source-branch", src.Branch)
+	}
+	if src.Dirty {
+		ctx.AddLabel("source-dirty", "true")
+	}
+	if versions := ctx.BuildInstalledRuntimeVersions(); len(versions) > 0 {
+		ctx.AddLabel("runtime-versions", strings.Join(versions, ","))
+	}
 	return nil
 }
+
+// isDirty returns true if git is available and reports uncommitted changes to tracked files.
+func isDirty(ctx *gcp.Context) bool {
+	if _, err := exec.LookPath("git"); err != nil {
+		return false
+	}
+	result, err := ctx.Exec([]string{"git", "status", "--porcelain", "--untracked-files=no"},
+		gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithLogCommand(false), gcp.WithLogOutput(false))
+	if err != nil {
+		ctx.Warnf("Failed to determine if the source has uncommitted changes: %v", err)
+		return false
+	}
+	return strings.TrimSpace(result.Stdout) != ""
+}
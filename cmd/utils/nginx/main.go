@@ -22,7 +22,6 @@ import (
 	"path/filepath"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-	"github.com/buildpacks/libcnb"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 )
@@ -46,34 +45,36 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	// install nginx
-	nl, err := install(ctx, "nginx", nginxVerConstraint, runtime.Nginx)
+	nl, err := ctx.Layer("nginx", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	pl, err := ctx.Layer("pid1", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+
+	// Download nginx and pid1 concurrently; the installs below then read them from disk.
+	if err := runtime.PrefetchTarball(ctx, runtime.Nginx, nginxVerConstraint, nl); err != nil {
+		return err
+	}
+	if err := runtime.PrefetchTarball(ctx, runtime.Pid1, pid1VerConstraint, pl); err != nil {
+		return err
+	}
+
+	// install nginx
+	if _, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nginx, nginxVerConstraint, nl); err != nil {
 		return err
 	}
 	nl.LaunchEnvironment.Append("PATH", string(os.PathListSeparator), filepath.Join(nl.Path, "sbin"))
 	nl.BuildEnvironment.Default("NGINX_ROOT", nl.Path)
 
 	// install pid1
-	pl, err := install(ctx, "pid1", pid1VerConstraint, runtime.Pid1)
-	if err != nil {
+	if _, err = runtime.InstallTarballIfNotCached(ctx, runtime.Pid1, pid1VerConstraint, pl); err != nil {
 		return err
 	}
-
 	pl.LaunchEnvironment.Append("PATH", string(os.PathListSeparator), pl.Path)
 	pl.BuildEnvironment.Default("PID1_DIR", pl.Path)
 
 	return nil
 }
-
-func install(ctx *gcp.Context, name, verConstraint string, ir runtime.InstallableRuntime) (*libcnb.Layer, error) {
-	l, err := ctx.Layer(name, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
-	if err != nil {
-		return nil, fmt.Errorf("creating layer: %w", err)
-	}
-	if _, err = runtime.InstallTarballIfNotCached(ctx, ir, verConstraint, l); err != nil {
-		return nil, err
-	}
-
-	return l, nil
-}
@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/supervisor buildpack.
+// The supervisor buildpack runs several processes in one container as the default web process,
+// for example a web server next to a queue worker, or an app behind a local nginx.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/supervisor"
+)
+
+const (
+	// superviseBinary is the name under which this executable runs as the launch-time supervisor.
+	superviseBinary = "supervise"
+	configName      = "supervisor.json"
+)
+
+func main() {
+	// The buildpack copies its own executable into the launch image, where it runs as the supervisor.
+	if filepath.Base(os.Args[0]) == superviseBinary {
+		os.Exit(supervisor.Main(os.Args[1:]))
+	}
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if os.Getenv(supervisor.ProcessesEnv) != "" {
+		return gcp.OptInEnvSet(supervisor.ProcessesEnv), nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), supervisor.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return gcp.OptInFileFound(supervisor.ConfigFile), nil
+	}
+	return gcp.OptOut(fmt.Sprintf("%s not set and %s not found", supervisor.ProcessesEnv, supervisor.ConfigFile)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	order, err := cfg.StartOrder()
+	if err != nil {
+		return gcp.UserErrorf("ordering processes: %v", err)
+	}
+	for _, p := range order {
+		ctx.Logf("Supervising process %q (restart: %s): %s", p.Name, p.Restart, p.Command)
+	}
+
+	l, err := ctx.Layer("supervisor", gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	binDir := filepath.Join(l.Path, "bin")
+	if err := ctx.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+	bin := filepath.Join(binDir, superviseBinary)
+	if err := fileutil.CopyFile(bin, filepath.Join(ctx.BuildpackRoot(), "bin", "main")); err != nil {
+		return gcp.InternalErrorf("copying supervisor binary: %v", err)
+	}
+	if err := os.Chmod(bin, 0755); err != nil {
+		return gcp.InternalErrorf("making %s executable: %v", bin, err)
+	}
+	configPath := filepath.Join(l.Path, configName)
+	if err := cfg.Write(configPath); err != nil {
+		return gcp.InternalErrorf("writing supervisor config: %v", err)
+	}
+
+	ctx.AddWebProcess([]string{bin, configPath})
+	return nil
+}
+
+// loadConfig reads the processes to supervise from the process types selected by
+// GOOGLE_SUPERVISE_PROCESSES or, if unset, from supervisor.yaml.
+func loadConfig(ctx *gcp.Context) (*supervisor.Config, error) {
+	if names := os.Getenv(supervisor.ProcessesEnv); names != "" {
+		content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), "Procfile"))
+		if err != nil {
+			return nil, gcp.UserErrorf("%s is set but the Procfile could not be read: %v", supervisor.ProcessesEnv, err)
+		}
+		cfg, err := supervisor.FromProcfile(string(content), strings.Split(names, ","))
+		if err != nil {
+			return nil, gcp.UserErrorf("invalid %s: %v", supervisor.ProcessesEnv, err)
+		}
+		return cfg, nil
+	}
+
+	content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), supervisor.ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := supervisor.ParseConfig(content)
+	if err != nil {
+		return nil, gcp.UserErrorf("invalid %s: %v", supervisor.ConfigFile, err)
+	}
+	return cfg, nil
+}
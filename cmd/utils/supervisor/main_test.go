@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name:  "supervisor.yaml",
+			files: map[string]string{"supervisor.yaml": "processes: []"},
+			want:  0,
+		},
+		{
+			name: "processes env",
+			env:  []string{"GOOGLE_SUPERVISE_PROCESSES=web,worker"},
+			want: 0,
+		},
+		{
+			name:  "procfile only",
+			files: map[string]string{"Procfile": "web: ./server\nworker: ./worker"},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		env       string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "from supervisor.yaml",
+			files:     map[string]string{"supervisor.yaml": "processes:\n- name: app\n  command: ./app\n- name: nginx\n  command: nginx\n  after: [app]"},
+			wantNames: []string{"app", "nginx"},
+		},
+		{
+			name:      "from Procfile",
+			files:     map[string]string{"Procfile": "web: ./server\nworker: ./worker\nclock: ./clock"},
+			env:       "web,worker",
+			wantNames: []string{"web", "worker"},
+		},
+		{
+			name:    "missing Procfile process",
+			files:   map[string]string{"Procfile": "web: ./server"},
+			env:     "web,worker",
+			wantErr: true,
+		},
+		{
+			name:    "invalid supervisor.yaml",
+			files:   map[string]string{"supervisor.yaml": "processes:\n- name: app"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.env != "" {
+				t.Setenv("GOOGLE_SUPERVISE_PROCESSES", tc.env)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			cfg, err := loadConfig(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("loadConfig() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var names []string
+			for _, p := range cfg.Processes {
+				names = append(names, p.Name)
+			}
+			if len(names) != len(tc.wantNames) {
+				t.Fatalf("loadConfig() got processes %v, want %v", names, tc.wantNames)
+			}
+			for i := range names {
+				if names[i] != tc.wantNames[i] {
+					t.Errorf("loadConfig() got processes %v, want %v", names, tc.wantNames)
+				}
+			}
+		})
+	}
+}
@@ -10,8 +10,10 @@ require (
 	github.com/google/go-licenses v0.0.0-20200602185517-f29a4c695c3d // indirect
 	github.com/hashicorp/go-retryablehttp v0.6.7
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.16.5
 	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/rs/xid v0.0.0-20170604230408-02dd45c33376
+	github.com/ulikunitz/xz v0.5.11
 	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8
 	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
 	gopkg.in/yaml.v2 v2.3.0
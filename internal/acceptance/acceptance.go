@@ -46,6 +46,7 @@ import (
 	"github.com/rs/xid"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/checktools"
+	bpenv "github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 )
 
 const (
@@ -930,6 +931,11 @@ func buildCommand(srcDir, image, builderName, runName string, env map[string]str
 	for k, v := range env {
 		args = append(args, "--env", fmt.Sprintf("%s=%s", k, v))
 	}
+	// The acceptance tests cover every version a builder can install, including those past their
+	// decommission date. Tests of the decommission failure set the variable themselves.
+	if _, ok := env[bpenv.AllowDecommissionedRuntime]; !ok {
+		args = append(args, "--env", bpenv.AllowDecommissionedRuntime+"=true")
+	}
 	// Prevents a race condition in pack when concurrently running builds with the same builder.
 	// Pack generates an "emphemeral builder" that contains env vars, adding an env var with a random
 	// value ensures that the generated builder sha is unique and removing it after one build will
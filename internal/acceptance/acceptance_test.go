@@ -37,6 +37,28 @@ func TestBuildCommand(t *testing.T) {
 				"--env X_GOOGLE_SKIP_RUNTIME_LAUNCH=true",
 			},
 		},
+		{
+			name:        "decommissioned runtimes are allowed",
+			srcDir:      "some/src/dir",
+			image:       "my-image",
+			builderName: "gcr.io/my-builder",
+			mustContainArgs: []string{
+				"--env GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME=true",
+			},
+		},
+		{
+			name:        "decommissioned runtimes can be disallowed",
+			srcDir:      "some/src/dir",
+			image:       "my-image",
+			builderName: "gcr.io/my-builder",
+			env:         map[string]string{"GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME": "false"},
+			mustContainArgs: []string{
+				"--env GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME=false",
+			},
+			mustNotContainArgs: []string{
+				"--env GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME=true",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
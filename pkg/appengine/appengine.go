@@ -18,11 +18,14 @@ package appengine
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appstart"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
 )
 
 const (
@@ -72,10 +75,68 @@ func getConfig(ctx *gcp.Context, runtime string, eg appstart.EntrypointGenerator
 		ctx.Logf("Using %s: %s", env.GAEMain, val)
 		c.MainExecutable = val
 	}
-	ctx.Logf("Using config %#v", c)
+
+	sc, err := appyaml.ServingConfiguration(ctx.ApplicationRoot())
+	if err != nil {
+		return appstart.Config{}, fmt.Errorf("reading app.yaml: %w", err)
+	}
+	if sc.ReadinessPath != "" || sc.LivenessPath != "" {
+		c.HealthCheck = &appstart.HealthCheck{ReadinessPath: sc.ReadinessPath, LivenessPath: sc.LivenessPath}
+	}
+	if sc.Warmup {
+		c.Warmup = &appstart.Warmup{Path: appstart.WarmupPath}
+	}
+	c.Env = appstart.DefaultEnv(c.Runtime)
+	for k, v := range sc.Env {
+		c.Env[k] = v
+	}
+	if len(c.Env) == 0 {
+		c.Env = nil
+	}
+
+	workers, err := getWorkers(ctx)
+	if err != nil {
+		return appstart.Config{}, fmt.Errorf("getting workers: %w", err)
+	}
+	c.Workers = workers
+
+	ctx.Logf("Using config %#v", c.Redacted())
 	return c, nil
 }
 
+// getWorkers returns the non-web process types declared in the application's Procfile, if any.
+func getWorkers(ctx *gcp.Context) ([]appstart.Worker, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), "Procfile")
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return nil, err
+	}
+	b, err := ctx.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var workers []appstart.Worker
+	seen := make(map[string]bool)
+	for _, p := range procfile.Parse(string(b)) {
+		if p.Name == gcp.WebProcess {
+			continue
+		}
+		if seen[p.Name] {
+			ctx.Warnf("Skipping duplicate %s process: %s", p.Name, p.Command)
+			continue
+		}
+		seen[p.Name] = true
+		workers = append(workers, appstart.Worker{
+			Name: p.Name,
+			Entrypoint: appstart.Entrypoint{
+				Type:    appstart.EntrypointUser.String(),
+				Command: p.Command,
+			},
+		})
+	}
+	return workers, nil
+}
+
 // Build serves as a common builder for App Engine buildpacks.
 func Build(ctx *gcp.Context, runtime string, eg appstart.EntrypointGenerator) error {
 	c, err := getConfig(ctx, runtime, eg)
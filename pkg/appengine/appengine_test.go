@@ -15,12 +15,17 @@
 package appengine
 
 import (
+	"bytes"
+	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appstart"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestConfig(t *testing.T) {
@@ -94,6 +99,84 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestConfigServingSettings(t *testing.T) {
+	testCases := []struct {
+		name        string
+		runtime     string
+		appYAML     string
+		procfile    string
+		want        appstart.Config
+		wantWarning string
+	}{
+		{
+			name:    "app.yaml health checks, env and warmup",
+			runtime: "python311",
+			appYAML: `
+readiness_check:
+  path: /ready
+liveness_check:
+  path: /live
+env_variables:
+  FOO: bar
+  PYTHONUNBUFFERED: "FALSE"
+inbound_services:
+- warmup
+`,
+			want: appstart.Config{
+				Runtime:     "python311",
+				Entrypoint:  appstart.Entrypoint{Type: appstart.EntrypointDefault.String(), Command: DefaultCommand},
+				HealthCheck: &appstart.HealthCheck{ReadinessPath: "/ready", LivenessPath: "/live"},
+				Warmup:      &appstart.Warmup{Path: appstart.WarmupPath},
+				Env:         map[string]string{"FOO": "bar", "PYTHONUNBUFFERED": "FALSE"},
+			},
+		},
+		{
+			name:     "procfile workers",
+			runtime:  "go121",
+			procfile: "web: ./server\nworker: ./worker\nworker: ./other\ncron: ./cron",
+			want: appstart.Config{
+				Runtime:    "go121",
+				Entrypoint: appstart.Entrypoint{Type: appstart.EntrypointDefault.String(), Command: DefaultCommand},
+				Workers: []appstart.Worker{
+					{Name: "worker", Entrypoint: appstart.Entrypoint{Type: appstart.EntrypointUser.String(), Command: "./worker"}},
+					{Name: "cron", Entrypoint: appstart.Entrypoint{Type: appstart.EntrypointUser.String(), Command: "./cron"}},
+				},
+			},
+			wantWarning: "Skipping duplicate worker process: ./other",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tc.appYAML != "" {
+				path := filepath.Join(root, "app.yaml")
+				if err := os.WriteFile(path, []byte(tc.appYAML), 0644); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("GAE_APPLICATION_YAML_PATH", path)
+			}
+			if tc.procfile != "" {
+				if err := os.WriteFile(filepath.Join(root, "Procfile"), []byte(tc.procfile), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var logs bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root), gcp.WithLogger(log.New(&logs, "", 0)))
+
+			got, err := getConfig(ctx, tc.runtime, nil)
+			if err != nil {
+				t.Fatalf("getConfig() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("getConfig() mismatch (-want +got):\n%s", diff)
+			}
+			if tc.wantWarning != "" && !strings.Contains(logs.String(), "WARNING: "+tc.wantWarning) {
+				t.Errorf("getConfig() logs = %q, want warning %q", logs.String(), tc.wantWarning)
+			}
+		})
+	}
+}
+
 func setEnv(t *testing.T, name, value string) {
 	t.Helper()
 
@@ -16,9 +16,12 @@
 package appstart
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
@@ -27,13 +30,57 @@ const (
 	// ConfigDir is the location relative to the user's application where the configFile lives.
 	ConfigDir  = ".googleconfig"
 	configFile = ConfigDir + "/app_start.json"
+
+	// SchemaVersion is the version of the app_start.json schema written by this package.
+	// Version 1 files predate the field and only carry runtime, entrypoint and main.
+	SchemaVersion = 2
+
+	// WarmupPath is the URL path of the App Engine warmup handler.
+	WarmupPath = "/_ah/warmup"
+)
+
+var (
+	envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// workerNameRe accepts every process type that pkg/procfile parses, so workers declared in a
+	// valid Procfile always start.
+	workerNameRe = regexp.MustCompile(`^\w[\w-]*$`)
+
+	// defaultEnv holds the launch environment each language runtime is served with by default.
+	defaultEnv = map[string]map[string]string{
+		"dotnet": {"DOTNET_RUNNING_IN_CONTAINER": "true"},
+		"nodejs": {"NODE_ENV": "production"},
+		"python": {"PYTHONUNBUFFERED": "TRUE"},
+	}
 )
 
 // Config holds the parameters to pass into app_start.json
 type Config struct {
-	Runtime        string     `json:"runtime,omitempty"`
-	Entrypoint     Entrypoint `json:"entrypoint"`
-	MainExecutable string     `json:"main,omitempty"`
+	SchemaVersion  int               `json:"schemaVersion,omitempty"`
+	Runtime        string            `json:"runtime,omitempty"`
+	Entrypoint     Entrypoint        `json:"entrypoint"`
+	Workers        []Worker          `json:"workers,omitempty"`
+	MainExecutable string            `json:"main,omitempty"`
+	HealthCheck    *HealthCheck      `json:"healthCheck,omitempty"`
+	Warmup         *Warmup           `json:"warmup,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+}
+
+// Worker is a named, non-serving process started next to the main entrypoint.
+type Worker struct {
+	Name       string     `json:"name"`
+	Entrypoint Entrypoint `json:"entrypoint"`
+}
+
+// HealthCheck contains the HTTP paths the serving layer probes to determine whether the
+// application is ready to receive traffic and whether it is still alive.
+type HealthCheck struct {
+	ReadinessPath string `json:"readinessPath,omitempty"`
+	LivenessPath  string `json:"livenessPath,omitempty"`
+}
+
+// Warmup contains the HTTP path of the handler called before an instance receives traffic.
+type Warmup struct {
+	Path string `json:"path"`
 }
 
 // Entrypoint contains the command to start the application
@@ -59,11 +106,136 @@ func (et EntrypointType) String() string {
 	return [...]string{"Default", "Generated", "User"}[et]
 }
 
+func validEntrypointType(t string) bool {
+	for _, et := range []EntrypointType{EntrypointDefault, EntrypointGenerated, EntrypointUser} {
+		if t == et.String() {
+			return true
+		}
+	}
+	return false
+}
+
 // EntrypointGenerator is a function that returns an entrypoint.
 type EntrypointGenerator func(*gcp.Context) (*Entrypoint, error)
 
+// DefaultEnv returns the default launch environment for the language of the given runtime,
+// e.g. "python311" and "python" both return the Python defaults. The result may be modified.
+func DefaultEnv(runtime string) map[string]string {
+	lang := strings.TrimRight(runtime, "0123456789")
+	env := make(map[string]string, len(defaultEnv[lang]))
+	for k, v := range defaultEnv[lang] {
+		env[k] = v
+	}
+	return env
+}
+
+// Redacted returns a copy of the config to log, with the values of its environment variables
+// hidden because they may hold secrets, such as the database password in app.yaml env_variables.
+func (c Config) Redacted() Config {
+	if c.Env == nil {
+		return c
+	}
+	env := make(map[string]string, len(c.Env))
+	for k := range c.Env {
+		env[k] = "<redacted>"
+	}
+	c.Env = env
+	return c
+}
+
+// Validate returns an error if the config would be rejected by the serving layer.
+func (c Config) Validate() error {
+	if c.SchemaVersion < 0 || c.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("unsupported schemaVersion %d, want at most %d", c.SchemaVersion, SchemaVersion)
+	}
+	if err := c.Entrypoint.validate(); err != nil {
+		return fmt.Errorf("invalid entrypoint: %w", err)
+	}
+	seen := make(map[string]bool, len(c.Workers))
+	for _, w := range c.Workers {
+		if !workerNameRe.MatchString(w.Name) {
+			return fmt.Errorf("invalid worker name %q", w.Name)
+		}
+		if seen[w.Name] {
+			return fmt.Errorf("duplicate worker %q", w.Name)
+		}
+		seen[w.Name] = true
+		if err := w.Entrypoint.validate(); err != nil {
+			return fmt.Errorf("invalid entrypoint for worker %q: %w", w.Name, err)
+		}
+	}
+	if c.HealthCheck != nil {
+		if err := validatePath("readinessPath", c.HealthCheck.ReadinessPath, true); err != nil {
+			return err
+		}
+		if err := validatePath("livenessPath", c.HealthCheck.LivenessPath, true); err != nil {
+			return err
+		}
+	}
+	if c.Warmup != nil {
+		if err := validatePath("warmup path", c.Warmup.Path, false); err != nil {
+			return err
+		}
+	}
+	for k := range c.Env {
+		if !envNameRe.MatchString(k) {
+			return fmt.Errorf("invalid env var name %q", k)
+		}
+	}
+	return nil
+}
+
+func (e Entrypoint) validate() error {
+	if strings.TrimSpace(e.Command) == "" {
+		return fmt.Errorf("command must not be empty")
+	}
+	if !validEntrypointType(e.Type) {
+		return fmt.Errorf("unknown type %q", e.Type)
+	}
+	return nil
+}
+
+func validatePath(name, path string, optional bool) error {
+	if path == "" && optional {
+		return nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("%s %q must start with '/'", name, path)
+	}
+	return nil
+}
+
+// ReadConfig reads and validates an app_start.json file. Unknown fields are rejected.
+func ReadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(b)
+}
+
+// ParseConfig parses and validates the contents of an app_start.json file. Unknown fields are
+// rejected so that typos do not silently fall back to defaults.
+func ParseConfig(data []byte) (Config, error) {
+	var c Config
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("unmarshalling JSON: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
 // Write writes the config to the default config file in a new layer.
 func (c Config) Write(ctx *gcp.Context) error {
+	c.SchemaVersion = SchemaVersion
+	if err := c.Validate(); err != nil {
+		return gcp.UserErrorf("invalid app_start.json config: %v", err)
+	}
+
 	l, err := ctx.Layer("config", gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
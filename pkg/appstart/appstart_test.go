@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appstart
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "version 1",
+			data: `{"runtime":"go","entrypoint":{"type":"Default","command":"/serve","workdir":""}}`,
+			want: Config{
+				Runtime:    "go",
+				Entrypoint: Entrypoint{Type: "Default", Command: "/serve"},
+			},
+		},
+		{
+			name: "version 2",
+			data: `{
+				"schemaVersion": 2,
+				"runtime": "python311",
+				"entrypoint": {"type": "User", "command": "gunicorn main:app", "workdir": ""},
+				"workers": [{"name": "worker", "entrypoint": {"type": "User", "command": "celery worker", "workdir": ""}}],
+				"healthCheck": {"readinessPath": "/ready", "livenessPath": "/live"},
+				"warmup": {"path": "/_ah/warmup"},
+				"env": {"PYTHONUNBUFFERED": "TRUE"}
+			}`,
+			want: Config{
+				SchemaVersion: 2,
+				Runtime:       "python311",
+				Entrypoint:    Entrypoint{Type: "User", Command: "gunicorn main:app"},
+				Workers:       []Worker{{Name: "worker", Entrypoint: Entrypoint{Type: "User", Command: "celery worker"}}},
+				HealthCheck:   &HealthCheck{ReadinessPath: "/ready", LivenessPath: "/live"},
+				Warmup:        &Warmup{Path: "/_ah/warmup"},
+				Env:           map[string]string{"PYTHONUNBUFFERED": "TRUE"},
+			},
+		},
+		{
+			name: "procfile worker names",
+			data: `{"entrypoint":{"type":"Default","command":"/serve"},"workers":[{"name":"_private","entrypoint":{"type":"User","command":"a"}},{"name":"9-lives","entrypoint":{"type":"User","command":"b"}}]}`,
+			want: Config{
+				Entrypoint: Entrypoint{Type: "Default", Command: "/serve"},
+				Workers: []Worker{
+					{Name: "_private", Entrypoint: Entrypoint{Type: "User", Command: "a"}},
+					{Name: "9-lives", Entrypoint: Entrypoint{Type: "User", Command: "b"}},
+				},
+			},
+		},
+		{
+			name:    "invalid worker name",
+			data:    `{"entrypoint":{"type":"Default","command":"/serve"},"workers":[{"name":"-w","entrypoint":{"type":"User","command":"a"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			data:    `{"entrypoint":{"type":"Default","command":"/serve"},"readiness":"/ready"}`,
+			wantErr: true,
+		},
+		{
+			name:    "future schema version",
+			data:    `{"schemaVersion":3,"entrypoint":{"type":"Default","command":"/serve"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty command",
+			data:    `{"entrypoint":{"type":"Default","command":" "}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown entrypoint type",
+			data:    `{"entrypoint":{"type":"Custom","command":"/serve"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate worker",
+			data:    `{"entrypoint":{"type":"Default","command":"/serve"},"workers":[{"name":"w","entrypoint":{"type":"User","command":"a"}},{"name":"w","entrypoint":{"type":"User","command":"b"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "relative readiness path",
+			data:    `{"entrypoint":{"type":"Default","command":"/serve"},"healthCheck":{"readinessPath":"ready"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty warmup path",
+			data:    `{"entrypoint":{"type":"Default","command":"/serve"},"warmup":{"path":""}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid env name",
+			data:    `{"entrypoint":{"type":"Default","command":"/serve"},"env":{"1FOO":"bar"}}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tc.data))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseConfig() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDefaultEnv(t *testing.T) {
+	testCases := []struct {
+		runtime string
+		want    map[string]string
+	}{
+		{runtime: "python", want: map[string]string{"PYTHONUNBUFFERED": "TRUE"}},
+		{runtime: "nodejs20", want: map[string]string{"NODE_ENV": "production"}},
+		{runtime: "go121", want: map[string]string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.runtime, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, DefaultEnv(tc.runtime)); diff != "" {
+				t.Errorf("DefaultEnv(%q) mismatch (-want +got):\n%s", tc.runtime, diff)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := Config{Runtime: "python", Env: map[string]string{"DB_PASSWORD": "hunter2", "PYTHONUNBUFFERED": "TRUE"}}
+
+	got := c.Redacted()
+
+	want := Config{Runtime: "python", Env: map[string]string{"DB_PASSWORD": "<redacted>", "PYTHONUNBUFFERED": "<redacted>"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Redacted() mismatch (-want +got):\n%s", diff)
+	}
+	if c.Env["DB_PASSWORD"] != "hunter2" {
+		t.Errorf("Redacted() modified the config, Env = %v", c.Env)
+	}
+}
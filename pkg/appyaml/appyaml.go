@@ -24,8 +24,28 @@ import (
 )
 
 type appYaml struct {
-	Entrypoint    string        `yaml:"entrypoint"`
-	RuntimeConfig RuntimeConfig `yaml:"runtime_config"`
+	Entrypoint      string            `yaml:"entrypoint"`
+	RuntimeConfig   RuntimeConfig     `yaml:"runtime_config"`
+	ReadinessCheck  healthCheck       `yaml:"readiness_check"`
+	LivenessCheck   healthCheck       `yaml:"liveness_check"`
+	EnvVariables    map[string]string `yaml:"env_variables"`
+	InboundServices []string          `yaml:"inbound_services"`
+}
+
+type healthCheck struct {
+	Path string `yaml:"path"`
+}
+
+// ServingConfig contains the app.yaml settings used by the serving layer to start and probe the app.
+type ServingConfig struct {
+	// ReadinessPath is the path of readiness_check, empty if not specified.
+	ReadinessPath string
+	// LivenessPath is the path of liveness_check, empty if not specified.
+	LivenessPath string
+	// Env contains env_variables.
+	Env map[string]string
+	// Warmup is true if the warmup inbound service is enabled.
+	Warmup bool
 }
 
 // RuntimeConfig The runtime_config specified in users app.yaml.
@@ -107,3 +127,27 @@ func PhpConfiguration(root string) (RuntimeConfig, error) {
 
 	return a.RuntimeConfig, nil
 }
+
+// ServingConfiguration returns the health check, environment and inbound service settings from
+// GAE app.yaml, or an empty ServingConfig if there is no app.yaml.
+func ServingConfiguration(root string) (ServingConfig, error) {
+	a, err := appYamlIfExists(root)
+	if err != nil {
+		return ServingConfig{}, err
+	}
+	if a == nil {
+		return ServingConfig{}, nil
+	}
+
+	sc := ServingConfig{
+		ReadinessPath: a.ReadinessCheck.Path,
+		LivenessPath:  a.LivenessCheck.Path,
+		Env:           a.EnvVariables,
+	}
+	for _, s := range a.InboundServices {
+		if s == "warmup" {
+			sc.Warmup = true
+		}
+	}
+	return sc, nil
+}
@@ -19,6 +19,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestGetEntrypointIfExists(t *testing.T) {
@@ -132,6 +134,64 @@ runtime_config:
 	}
 }
 
+func TestServingConfiguration(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		path    string
+		content []byte
+		want    ServingConfig
+	}{
+		{
+			name: "no app.yaml",
+			want: ServingConfig{},
+		},
+		{
+			name: "all settings",
+			env:  []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path: "app.yaml",
+			content: []byte(`
+readiness_check:
+  path: /ready
+liveness_check:
+  path: /live
+env_variables:
+  FOO: bar
+inbound_services:
+- mail
+- warmup
+`),
+			want: ServingConfig{
+				ReadinessPath: "/ready",
+				LivenessPath:  "/live",
+				Env:           map[string]string{"FOO": "bar"},
+				Warmup:        true,
+			},
+		},
+		{
+			name:    "no serving settings",
+			env:     []string{"GAE_APPLICATION_YAML_PATH=app.yaml"},
+			path:    "app.yaml",
+			content: []byte("entrypoint: my entrypoint"),
+			want:    ServingConfig{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempRoot := t.TempDir()
+			writeFile(tc.path, tempRoot, tc.content, tc.env, t)
+
+			got, err := ServingConfiguration(tempRoot)
+			if err != nil {
+				t.Fatalf("ServingConfiguration() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ServingConfiguration() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func writeFile(path, root string, content []byte, envs []string, t *testing.T) {
 	if path != "" {
 		fp := filepath.Join(root, path)
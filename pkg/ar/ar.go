@@ -16,7 +16,6 @@
 package ar
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -27,7 +26,6 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-	"golang.org/x/oauth2/google"
 	"gopkg.in/yaml.v2"
 )
 
@@ -137,7 +135,7 @@ func GeneratePythonConfig(ctx *gcp.Context) error {
 		return nil
 	}
 
-	tok, err := findDefaultCredentials()
+	tok, err := accessToken(ctx)
 	if err != nil {
 		// findDefaultCredentials will return an error any time Application Default Credentials are
 		// missing (e.g. running the buildpacks locally outside of GCB). Credentials might not
@@ -227,7 +225,7 @@ func GenerateNPMConfig(ctx *gcp.Context) error {
 		return nil
 	}
 
-	tok, err := findDefaultCredentials()
+	tok, err := accessToken(ctx)
 	if err != nil {
 		// findDefaultCredentials will return an error any time Application Default Credentials are
 		// missing (e.g. running the buildpacks locally outside of GCB). Credentials might not
@@ -279,21 +277,6 @@ func writeNpmConfig(wr io.Writer, repos []string, tok string) error {
 	return nil
 }
 
-// findDefaultCredentials searches for "Application Default Credentials" using the google/oauth
-// package (see https://cloud.google.com/docs/authentication/production#automatically).
-var findDefaultCredentials = func() (string, error) {
-	ctx := context.Background()
-	src, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
-	if err != nil {
-		return "", err
-	}
-	tok, err := src.TokenSource.Token()
-	if err != nil {
-		return "", err
-	}
-	return tok.AccessToken, nil
-}
-
 // GenerateYarnConfig adds auth token to .yarnrc.yml in the user's HOME directory
 // necessary for Yarn to make authenticated requests to Artifact Registry (see
 // https://cloud.google.com/artifact-registry/docs/nodejs/authentication).
@@ -332,7 +315,7 @@ func GenerateYarnConfig(ctx *gcp.Context) error {
 		return nil
 	}
 
-	tok, err := findDefaultCredentials()
+	tok, err := accessToken(ctx)
 	if err != nil {
 		// findDefaultCredentials will return an error any time Application Default Credentials are
 		// missing (e.g. running the buildpacks locally outside of GCB). Credentials might not
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// gemSourceRegexp matches an Artifact Registry gem source in a Gemfile, e.g.
+// source "https://us-ruby.pkg.dev/my-project/my-repo".
+var gemSourceRegexp = regexp.MustCompile(`(?m)^\s*source\s*\(?\s*["'](https://[a-z0-9-]+[.]pkg[.]dev/[^"']*)["']`)
+
+// GenerateBundlerConfig adds credentials to the global Bundler config in the user's HOME directory
+// for the Artifact Registry gem sources in the project's Gemfile (see
+// https://bundler.io/man/bundle-config.1.html#CREDENTIALS-FOR-GEM-SOURCES).
+func GenerateBundlerConfig(ctx *gcp.Context) error {
+	userConfig := bundlerUserConfig(ctx)
+	userConfigExists, err := ctx.FileExists(userConfig)
+	if err != nil {
+		return err
+	}
+	if userConfigExists {
+		ctx.Debugf("Found an existing user-level Bundler config. Skipping Bundler credential creation.")
+		return nil
+	}
+
+	gemfile := filepath.Join(ctx.ApplicationRoot(), "Gemfile")
+	gemfileExists, err := ctx.FileExists(gemfile)
+	if err != nil || !gemfileExists {
+		return err
+	}
+	content, err := ctx.ReadFile(gemfile)
+	if err != nil {
+		return err
+	}
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, m := range gemSourceRegexp.FindAllStringSubmatch(string(content), -1) {
+		h := registryURLRegexp.FindStringSubmatch(m[1])
+		if h == nil || seen[h[1]] {
+			continue
+		}
+		seen[h[1]] = true
+		hosts = append(hosts, h[1])
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	tok, err := accessToken(ctx)
+	if err != nil {
+		// Credentials might not be required for the bundle install to succeed so we should not fail
+		// the build here.
+		ctx.Warnf("Skipping Bundler credential creation. Unable to find Application Default Credentials: %v", err)
+		return nil
+	}
+	ctx.Debugf("Configuring Bundler credentials for: %s", strings.Join(hosts, ", "))
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, h := range hosts {
+		fmt.Fprintf(&sb, "%s: %q\n", bundlerHostKey(h), "oauth2accesstoken:"+tok)
+	}
+	if err := ctx.MkdirAll(filepath.Dir(userConfig), 0755); err != nil {
+		return err
+	}
+	return ctx.WriteFile(userConfig, []byte(sb.String()), 0600)
+}
+
+// bundlerHostKey returns the Bundler config key for credentials to a gem source host, e.g.
+// BUNDLE_US___RUBY__PKG__DEV for us-ruby.pkg.dev.
+func bundlerHostKey(host string) string {
+	key := strings.NewReplacer("-", "___", ".", "__").Replace(strings.ToUpper(host))
+	return "BUNDLE_" + key
+}
+
+// bundlerUserConfig returns the path of the global Bundler config file.
+func bundlerUserConfig(ctx *gcp.Context) string {
+	if cfg := os.Getenv("BUNDLE_USER_CONFIG"); cfg != "" {
+		return cfg
+	}
+	if home := os.Getenv("BUNDLE_USER_HOME"); home != "" {
+		return filepath.Join(home, "config")
+	}
+	return filepath.Join(ctx.HomeDir(), ".bundle", "config")
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateBundlerConfig(t *testing.T) {
+	testCases := []struct {
+		name       string
+		gemfile    string
+		wantConfig string
+	}{
+		{
+			name:    "rubygems.org only",
+			gemfile: "source \"https://rubygems.org\"\n\ngem \"rails\"\n",
+		},
+		{
+			name:    "Artifact Registry sources",
+			gemfile: "source \"https://rubygems.org\"\n\nsource 'https://us-ruby.pkg.dev/project/repo' do\n  gem 'private'\nend\n",
+			wantConfig: `---
+BUNDLE_US___RUBY__PKG__DEV: "oauth2accesstoken:token"
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer SetTokenSource(func() (string, error) {
+				return "token", nil
+			})()
+			tempHome := t.TempDir()
+			t.Setenv("HOME", tempHome)
+			t.Setenv("BUNDLE_USER_CONFIG", "")
+			t.Setenv("BUNDLE_USER_HOME", "")
+			appDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(appDir, "Gemfile"), []byte(tc.gemfile), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir))
+
+			if err := GenerateBundlerConfig(ctx); err != nil {
+				t.Fatalf("GenerateBundlerConfig() got error: %v", err)
+			}
+			cfgPath := filepath.Join(tempHome, ".bundle", "config")
+			got, err := os.ReadFile(cfgPath)
+			if err != nil && tc.wantConfig != "" {
+				t.Fatalf("reading %s: %v", cfgPath, err)
+			}
+			if diff := cmp.Diff(tc.wantConfig, string(got)); diff != "" {
+				t.Errorf("unexpected Bundler config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+type composerHTTPBasic struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type composerAuth struct {
+	HTTPBasic map[string]composerHTTPBasic `json:"http-basic"`
+}
+
+// GenerateComposerConfig generates an auth.json file in the Composer home directory with HTTP basic
+// credentials for the Artifact Registry repositories declared in the project's composer.json.
+func GenerateComposerConfig(ctx *gcp.Context) error {
+	authPath := filepath.Join(composerHome(ctx), "auth.json")
+	authExists, err := ctx.FileExists(authPath)
+	if err != nil {
+		return err
+	}
+	if authExists {
+		ctx.Debugf("Found an existing Composer auth.json file. Skipping auth.json creation.")
+		return nil
+	}
+
+	composerJSON := filepath.Join(ctx.ApplicationRoot(), "composer.json")
+	composerJSONExists, err := ctx.FileExists(composerJSON)
+	if err != nil || !composerJSONExists {
+		return err
+	}
+	content, err := ctx.ReadFile(composerJSON)
+	if err != nil {
+		return err
+	}
+	// Repositories are either a list or an object keyed by repository name.
+	var project struct {
+		Repositories json.RawMessage `json:"repositories"`
+	}
+	if err := json.Unmarshal(content, &project); err != nil {
+		ctx.Warnf("Skipping Composer credential creation. Unable to parse %s: %v", composerJSON, err)
+		return nil
+	}
+	type repository struct {
+		URL string `json:"url"`
+	}
+	var repos []repository
+	if err := json.Unmarshal(project.Repositories, &repos); err != nil {
+		named := make(map[string]repository)
+		if err := json.Unmarshal(project.Repositories, &named); err != nil {
+			return nil
+		}
+		for _, r := range named {
+			repos = append(repos, r)
+		}
+	}
+	hostSet := make(map[string]bool)
+	for _, r := range repos {
+		if m := registryURLRegexp.FindStringSubmatch(r.URL); m != nil {
+			hostSet[m[1]] = true
+		}
+	}
+	if len(hostSet) == 0 {
+		return nil
+	}
+	var hosts []string
+	for h := range hostSet {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	tok, err := accessToken(ctx)
+	if err != nil {
+		// Credentials might not be required for the composer install to succeed so we should not fail
+		// the build here.
+		ctx.Warnf("Skipping auth.json creation. Unable to find Application Default Credentials: %v", err)
+		return nil
+	}
+	ctx.Debugf("Configuring Composer credentials for: %s", strings.Join(hosts, ", "))
+
+	auth := composerAuth{HTTPBasic: make(map[string]composerHTTPBasic)}
+	for _, h := range hosts {
+		auth.HTTPBasic[h] = composerHTTPBasic{Username: "oauth2accesstoken", Password: tok}
+	}
+	out, err := json.MarshalIndent(auth, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ctx.MkdirAll(filepath.Dir(authPath), 0755); err != nil {
+		return err
+	}
+	return ctx.WriteFile(authPath, out, 0600)
+}
+
+// composerHome returns the directory Composer reads the global auth.json from.
+func composerHome(ctx *gcp.Context) string {
+	if home := os.Getenv("COMPOSER_HOME"); home != "" {
+		return home
+	}
+	return filepath.Join(ctx.HomeDir(), ".composer")
+}
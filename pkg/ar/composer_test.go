@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateComposerConfig(t *testing.T) {
+	testCases := []struct {
+		name       string
+		composer   string
+		wantConfig string
+	}{
+		{
+			name:     "no repositories",
+			composer: `{"require": {"monolog/monolog": "^3.0"}}`,
+		},
+		{
+			name:     "repository list",
+			composer: `{"repositories": [{"type": "composer", "url": "https://us-php.pkg.dev/project/repo/"}, {"type": "vcs", "url": "https://github.com/org/pkg"}]}`,
+			wantConfig: `{
+  "http-basic": {
+    "us-php.pkg.dev": {
+      "username": "oauth2accesstoken",
+      "password": "token"
+    }
+  }
+}`,
+		},
+		{
+			name:     "named repositories",
+			composer: `{"repositories": {"private": {"type": "composer", "url": "https://europe-php.pkg.dev/project/repo"}}}`,
+			wantConfig: `{
+  "http-basic": {
+    "europe-php.pkg.dev": {
+      "username": "oauth2accesstoken",
+      "password": "token"
+    }
+  }
+}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer SetTokenSource(func() (string, error) {
+				return "token", nil
+			})()
+			composerHome := t.TempDir()
+			t.Setenv("COMPOSER_HOME", composerHome)
+			appDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(appDir, "composer.json"), []byte(tc.composer), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir))
+
+			if err := GenerateComposerConfig(ctx); err != nil {
+				t.Fatalf("GenerateComposerConfig() got error: %v", err)
+			}
+			authPath := filepath.Join(composerHome, "auth.json")
+			got, err := os.ReadFile(authPath)
+			if err != nil && tc.wantConfig != "" {
+				t.Fatalf("reading %s: %v", authPath, err)
+			}
+			if diff := cmp.Diff(tc.wantConfig, string(got)); diff != "" {
+				t.Errorf("unexpected auth.json (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// netrcName is the file in the user's HOME directory that the go command reads credentials from.
+const netrcName = ".netrc"
+
+var (
+	// goRepositoryRegexp matches an Artifact Registry Go repository in GOPROXY, e.g.
+	// https://us-go.pkg.dev/my-project/my-repo.
+	goRepositoryRegexp = regexp.MustCompile(`https://([a-z0-9-]+-go[.]pkg[.]dev)/[^,|\s]+`)
+	netrcMachineRegexp = regexp.MustCompile(`(?m)^\s*machine\s+(\S+)`)
+)
+
+// GenerateGoConfig adds credentials to the .netrc file in the user's HOME directory for the
+// Artifact Registry Go repositories listed in GOPROXY (see
+// https://cloud.google.com/artifact-registry/docs/go/authentication). It returns the environment
+// the go command needs to download modules from those repositories. Modules in Artifact Registry
+// are not in the public checksum database, so the user must exclude them from verification with
+// GONOSUMDB or GOPRIVATE.
+func GenerateGoConfig(ctx *gcp.Context) ([]string, error) {
+	proxy := os.Getenv("GOPROXY")
+	var hosts []string
+	for _, m := range goRepositoryRegexp.FindAllStringSubmatch(proxy, -1) {
+		hosts = append(hosts, m[1])
+	}
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+
+	tok, err := accessToken(ctx)
+	if err != nil {
+		// Credentials might not be required for the go command to succeed so we should not fail the
+		// build here.
+		ctx.Warnf("Skipping Go credential creation. Unable to find Application Default Credentials: %v", err)
+		return nil, nil
+	}
+	ctx.Debugf("Configuring Go credentials for: %s", strings.Join(hosts, ", "))
+	if err := appendNetrc(ctx, hosts, tok); err != nil {
+		return nil, err
+	}
+
+	noSumDB, private := os.Getenv("GONOSUMDB"), os.Getenv("GOPRIVATE")
+	if noSumDB == "" && private == "" {
+		ctx.Logf("Neither GONOSUMDB nor GOPRIVATE is set, modules downloaded from Artifact Registry that are not in the public checksum database will fail verification. Set GONOSUMDB to their module path prefixes to download them.")
+	} else {
+		ctx.Logf("Modules matching GONOSUMDB=%q or GOPRIVATE=%q are not verified against the public checksum database.", noSumDB, private)
+	}
+	return []string{"GOPROXY=" + proxy}, nil
+}
+
+// appendNetrc adds an entry to the .netrc file in the user's HOME directory for each host that the
+// file does not already configure.
+func appendNetrc(ctx *gcp.Context, hosts []string, tok string) error {
+	netrcPath := filepath.Join(ctx.HomeDir(), netrcName)
+	netrcExists, err := ctx.FileExists(netrcPath)
+	if err != nil {
+		return err
+	}
+	var content []byte
+	if netrcExists {
+		if content, err = ctx.ReadFile(netrcPath); err != nil {
+			return err
+		}
+	}
+	configured := make(map[string]bool)
+	for _, m := range netrcMachineRegexp.FindAllSubmatch(content, -1) {
+		configured[string(m[1])] = true
+	}
+
+	var sb strings.Builder
+	sb.Write(content)
+	for _, h := range hosts {
+		if configured[h] {
+			ctx.Debugf("Found existing .netrc credentials for %s.", h)
+			continue
+		}
+		configured[h] = true
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("machine %s login oauth2accesstoken password %s\n", h, tok))
+	}
+	return ctx.WriteFile(netrcPath, []byte(sb.String()), 0600)
+}
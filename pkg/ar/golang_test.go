@@ -0,0 +1,114 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateGoConfig(t *testing.T) {
+	testCases := []struct {
+		name       string
+		goproxy    string
+		gonosumdb  string
+		netrc      string
+		tokenError error
+		wantEnv    []string
+		wantNetrc  string
+		wantLog    string
+	}{
+		{
+			name:    "no Artifact Registry proxy",
+			goproxy: "https://proxy.golang.org,direct",
+		},
+		{
+			name:       "credential error",
+			goproxy:    "https://us-go.pkg.dev/project/repo,https://proxy.golang.org,direct",
+			tokenError: fmt.Errorf("Error fetching token"),
+		},
+		{
+			name:      "Artifact Registry proxy",
+			goproxy:   "https://us-go.pkg.dev/project/repo,https://proxy.golang.org,direct",
+			wantEnv:   []string{"GOPROXY=https://us-go.pkg.dev/project/repo,https://proxy.golang.org,direct"},
+			wantNetrc: "machine us-go.pkg.dev login oauth2accesstoken password token\n",
+			wantLog:   "Neither GONOSUMDB nor GOPRIVATE is set",
+		},
+		{
+			name:      "existing GONOSUMDB and .netrc",
+			goproxy:   "https://us-go.pkg.dev/project/repo|https://europe-go.pkg.dev/project/repo",
+			gonosumdb: "example.com/private",
+			netrc:     "machine github.com login user password secret",
+			wantEnv:   []string{"GOPROXY=https://us-go.pkg.dev/project/repo|https://europe-go.pkg.dev/project/repo"},
+			wantNetrc: "machine github.com login user password secret\nmachine us-go.pkg.dev login oauth2accesstoken password token\nmachine europe-go.pkg.dev login oauth2accesstoken password token\n",
+			wantLog:   `Modules matching GONOSUMDB="example.com/private" or GOPRIVATE="" are not verified`,
+		},
+		{
+			name:      "host already in .netrc",
+			goproxy:   "https://us-go.pkg.dev/project/repo",
+			gonosumdb: "example.com/private",
+			netrc:     "machine us-go.pkg.dev login oauth2accesstoken password user-token\n",
+			wantEnv:   []string{"GOPROXY=https://us-go.pkg.dev/project/repo"},
+			wantNetrc: "machine us-go.pkg.dev login oauth2accesstoken password user-token\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer SetTokenSource(func() (string, error) {
+				return "token", tc.tokenError
+			})()
+			tempHome := t.TempDir()
+			t.Setenv("HOME", tempHome)
+			t.Setenv("GOPROXY", tc.goproxy)
+			t.Setenv("GONOSUMDB", tc.gonosumdb)
+			t.Setenv("GOPRIVATE", "")
+			appDir := t.TempDir()
+			netrcPath := filepath.Join(tempHome, ".netrc")
+			if tc.netrc != "" {
+				if err := os.WriteFile(netrcPath, []byte(tc.netrc), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var logs bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir), gcp.WithLogger(log.New(&logs, "", 0)))
+
+			env, err := GenerateGoConfig(ctx)
+			if err != nil {
+				t.Fatalf("GenerateGoConfig() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantEnv, env); diff != "" {
+				t.Errorf("GenerateGoConfig() env mismatch (-want +got):\n%s", diff)
+			}
+			netrc, err := os.ReadFile(netrcPath)
+			if err != nil && tc.wantNetrc != "" {
+				t.Fatalf("reading %s: %v", netrcPath, err)
+			}
+			if diff := cmp.Diff(tc.wantNetrc, string(netrc)); diff != "" {
+				t.Errorf("unexpected .netrc (-want +got):\n%s", diff)
+			}
+			if !strings.Contains(logs.String(), tc.wantLog) {
+				t.Errorf("GenerateGoConfig() logs = %q, want %q", logs.String(), tc.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	mavenSettingsName = "settings.xml"
+	gradleInitName    = "artifact-registry.gradle"
+	// tempDirName is the temporary directory that holds generated credentials which must not be
+	// cached or included in the application image.
+	tempDirName = "artifact-registry"
+)
+
+var (
+	// mavenServersRegexp matches the opening or empty servers element of a settings.xml.
+	mavenServersRegexp = regexp.MustCompile(`<servers\s*/?>`)
+	// mavenRepositoryRegexp matches an Artifact Registry Maven repository URL, e.g.
+	// https://us-maven.pkg.dev/my-project/my-repo.
+	mavenRepositoryRegexp = regexp.MustCompile(`https://[a-z0-9-]+-maven[.]pkg[.]dev/[^\s"'<>)]+`)
+	gradleBuildFiles      = []string{"settings.gradle", "settings.gradle.kts", "build.gradle", "build.gradle.kts"}
+)
+
+type mavenRepository struct {
+	ID  string `xml:"id"`
+	URL string `xml:"url"`
+}
+
+type mavenProject struct {
+	Repositories           []mavenRepository `xml:"repositories>repository"`
+	PluginRepositories     []mavenRepository `xml:"pluginRepositories>pluginRepository"`
+	DistributionManagement struct {
+		Repository         mavenRepository `xml:"repository"`
+		SnapshotRepository mavenRepository `xml:"snapshotRepository"`
+	} `xml:"distributionManagement"`
+}
+
+type mavenServer struct {
+	XMLName  xml.Name `xml:"server"`
+	ID       string   `xml:"id"`
+	Username string   `xml:"username"`
+	Password string   `xml:"password"`
+}
+
+type mavenSettings struct {
+	XMLName xml.Name      `xml:"settings"`
+	Servers []mavenServer `xml:"servers>server"`
+}
+
+// GenerateMavenConfig generates a Maven settings.xml with server credentials for the Artifact
+// Registry repositories declared in the project's pom.xml at pomPath, relative to the application
+// root, or in pom.xml if pomPath is "" (see
+// https://cloud.google.com/artifact-registry/docs/java/authentication). The credentials are added to
+// a copy of the user settings at userSettings, if it exists, so its path is to be used in place of
+// them with "mvn --settings" and the global settings of the Maven installation still apply. The
+// file is written to a temporary directory so the token is neither cached nor part of the image. It
+// returns "" if no credentials are needed.
+func GenerateMavenConfig(ctx *gcp.Context, pomPath, userSettings string) (string, error) {
+	if pomPath == "" {
+		pomPath = "pom.xml"
+	}
+	pom := filepath.Join(ctx.ApplicationRoot(), pomPath)
+	pomExists, err := ctx.FileExists(pom)
+	if err != nil || !pomExists {
+		return "", err
+	}
+	content, err := ctx.ReadFile(pom)
+	if err != nil {
+		return "", err
+	}
+	var project mavenProject
+	if err := xml.Unmarshal(content, &project); err != nil {
+		ctx.Warnf("Skipping Maven credential creation. Unable to parse %s: %v", pom, err)
+		return "", nil
+	}
+
+	repos := append(project.Repositories, project.PluginRepositories...)
+	repos = append(repos, project.DistributionManagement.Repository, project.DistributionManagement.SnapshotRepository)
+	var ids []string
+	seen := make(map[string]bool)
+	for _, r := range repos {
+		if r.ID == "" || seen[r.ID] || !mavenRepositoryRegexp.MatchString(strings.TrimSpace(r.URL)) {
+			continue
+		}
+		seen[r.ID] = true
+		ids = append(ids, r.ID)
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+
+	var user []byte
+	if userSettings != "" {
+		exists, err := ctx.FileExists(userSettings)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			if user, err = ctx.ReadFile(userSettings); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	tok, err := accessToken(ctx)
+	if err != nil {
+		// Credentials might not be required for the Maven build to succeed so we should not fail the
+		// build here.
+		ctx.Warnf("Skipping Maven credential creation. Unable to find Application Default Credentials: %v", err)
+		return "", nil
+	}
+	ctx.Debugf("Configuring Maven credentials for: %s", strings.Join(ids, ", "))
+
+	var servers []mavenServer
+	for _, id := range ids {
+		servers = append(servers, mavenServer{ID: id, Username: "oauth2accesstoken", Password: tok})
+	}
+	if user == nil {
+		out, err := xml.MarshalIndent(mavenSettings{Servers: servers}, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("creating Maven settings: %w", err)
+		}
+		return writeTempConfig(ctx, mavenSettingsName, append([]byte(xml.Header), out...))
+	}
+	out, err := mergeMavenServers(user, servers)
+	if err != nil {
+		ctx.Warnf("Skipping Maven credential creation. Unable to add credentials to %s: %v", userSettings, err)
+		return "", nil
+	}
+	return writeTempConfig(ctx, mavenSettingsName, out)
+}
+
+// mergeMavenServers adds the servers to the content of a settings.xml, leaving the rest of it
+// untouched. Servers that the settings already define keep their credentials.
+func mergeMavenServers(content []byte, servers []mavenServer) ([]byte, error) {
+	var settings mavenSettings
+	if err := xml.Unmarshal(content, &settings); err != nil {
+		return nil, err
+	}
+	defined := make(map[string]bool)
+	for _, s := range settings.Servers {
+		defined[s.ID] = true
+	}
+	var add string
+	for _, s := range servers {
+		if defined[s.ID] {
+			continue
+		}
+		out, err := xml.MarshalIndent(s, "    ", "  ")
+		if err != nil {
+			return nil, err
+		}
+		add += "\n" + string(out)
+	}
+	if add == "" {
+		return content, nil
+	}
+
+	merged := string(content)
+	if loc := mavenServersRegexp.FindStringIndex(merged); loc != nil {
+		if strings.HasSuffix(merged[loc[0]:loc[1]], "/>") {
+			return []byte(merged[:loc[0]] + "<servers>" + add + "\n  </servers>" + merged[loc[1]:]), nil
+		}
+		return []byte(merged[:loc[1]] + add + merged[loc[1]:]), nil
+	}
+	end := strings.LastIndex(merged, "</settings>")
+	if end < 0 {
+		return nil, fmt.Errorf("settings element not closed")
+	}
+	return []byte(merged[:end] + "  <servers>" + add + "\n  </servers>\n" + merged[end:]), nil
+}
+
+// GenerateGradleConfig generates a Gradle init script that sets credentials on every Artifact
+// Registry repository when the project's build scripts reference one (see
+// https://cloud.google.com/artifact-registry/docs/java/authentication). The script is written to a
+// temporary directory so the token is neither cached nor part of the image, and its path is
+// returned for use with "gradle --init-script". It returns "" if no credentials are needed.
+func GenerateGradleConfig(ctx *gcp.Context) (string, error) {
+	found := false
+	for _, f := range gradleBuildFiles {
+		path := filepath.Join(ctx.ApplicationRoot(), f)
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if mavenRepositoryRegexp.Match(content) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", nil
+	}
+
+	tok, err := accessToken(ctx)
+	if err != nil {
+		// Credentials might not be required for the Gradle build to succeed so we should not fail the
+		// build here.
+		ctx.Warnf("Skipping Gradle credential creation. Unable to find Application Default Credentials: %v", err)
+		return "", nil
+	}
+	ctx.Debugf("Configuring Gradle credentials for Artifact Registry repositories.")
+	return writeTempConfig(ctx, gradleInitName, []byte(gradleInitScript(tok)))
+}
+
+// gradleInitScript returns an init script that authenticates every Maven repository hosted by
+// Artifact Registry, including plugin and settings-level repositories.
+func gradleInitScript(tok string) string {
+	return fmt.Sprintf(`def arToken = '%s'
+def arCredentials = { repos ->
+    repos.withType(MavenArtifactRepository).configureEach { repo ->
+        if (repo.url.scheme == 'https' && repo.url.host?.endsWith('-maven.pkg.dev')) {
+            repo.credentials {
+                username = 'oauth2accesstoken'
+                password = arToken
+            }
+        }
+    }
+}
+beforeSettings { settings ->
+    arCredentials(settings.pluginManagement.repositories)
+    if (settings.hasProperty('dependencyResolutionManagement')) {
+        arCredentials(settings.dependencyResolutionManagement.repositories)
+    }
+}
+allprojects {
+    arCredentials(buildscript.repositories)
+    arCredentials(repositories)
+}
+`, strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(tok))
+}
+
+// writeTempConfig writes a generated configuration file to a temporary directory and returns its
+// path.
+func writeTempConfig(ctx *gcp.Context, name string, content []byte) (string, error) {
+	dir, err := ctx.TempDir(tempDirName)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := ctx.WriteFile(path, content, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
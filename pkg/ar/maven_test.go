@@ -0,0 +1,331 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateMavenConfig(t *testing.T) {
+	testCases := []struct {
+		name         string
+		pomPath      string
+		pom          string
+		userSettings string
+		wantConfig   string
+	}{
+		{
+			name: "no Artifact Registry repositories",
+			pom: `<project>
+  <repositories>
+    <repository>
+      <id>central</id>
+      <url>https://repo.maven.apache.org/maven2</url>
+    </repository>
+  </repositories>
+</project>`,
+		},
+		{
+			name: "Artifact Registry repositories",
+			pom: `<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <repositories>
+    <repository>
+      <id>ar-releases</id>
+      <url>https://us-central1-maven.pkg.dev/project/releases</url>
+    </repository>
+    <repository>
+      <id>central</id>
+      <url>https://repo.maven.apache.org/maven2</url>
+    </repository>
+  </repositories>
+  <pluginRepositories>
+    <pluginRepository>
+      <id>ar-plugins</id>
+      <url>https://us-central1-maven.pkg.dev/project/plugins</url>
+    </pluginRepository>
+  </pluginRepositories>
+  <distributionManagement>
+    <repository>
+      <id>ar-releases</id>
+      <url>https://us-central1-maven.pkg.dev/project/releases</url>
+    </repository>
+  </distributionManagement>
+</project>`,
+			wantConfig: `<?xml version="1.0" encoding="UTF-8"?>
+<settings>
+  <servers>
+    <server>
+      <id>ar-releases</id>
+      <username>oauth2accesstoken</username>
+      <password>token</password>
+    </server>
+    <server>
+      <id>ar-plugins</id>
+      <username>oauth2accesstoken</username>
+      <password>token</password>
+    </server>
+  </servers>
+</settings>`,
+		},
+		{
+			name:    "pom.xml in GOOGLE_BUILDABLE",
+			pomPath: "service/pom.xml",
+			pom: `<project>
+  <repositories>
+    <repository>
+      <id>ar</id>
+      <url>https://us-maven.pkg.dev/project/repo</url>
+    </repository>
+  </repositories>
+</project>`,
+			wantConfig: `<?xml version="1.0" encoding="UTF-8"?>
+<settings>
+  <servers>
+    <server>
+      <id>ar</id>
+      <username>oauth2accesstoken</username>
+      <password>token</password>
+    </server>
+  </servers>
+</settings>`,
+		},
+		{
+			name: "user settings with servers",
+			pom: `<project>
+  <repositories>
+    <repository>
+      <id>ar</id>
+      <url>https://us-maven.pkg.dev/project/repo</url>
+    </repository>
+  </repositories>
+</project>`,
+			userSettings: `<settings>
+  <mirrors>
+    <mirror>
+      <id>internal</id>
+      <url>https://mirror.example.com/maven2</url>
+      <mirrorOf>central</mirrorOf>
+    </mirror>
+  </mirrors>
+  <servers>
+    <server>
+      <id>internal</id>
+      <username>user</username>
+    </server>
+  </servers>
+</settings>`,
+			wantConfig: `<settings>
+  <mirrors>
+    <mirror>
+      <id>internal</id>
+      <url>https://mirror.example.com/maven2</url>
+      <mirrorOf>central</mirrorOf>
+    </mirror>
+  </mirrors>
+  <servers>
+    <server>
+      <id>ar</id>
+      <username>oauth2accesstoken</username>
+      <password>token</password>
+    </server>
+    <server>
+      <id>internal</id>
+      <username>user</username>
+    </server>
+  </servers>
+</settings>`,
+		},
+		{
+			name: "user settings without servers",
+			pom: `<project>
+  <repositories>
+    <repository>
+      <id>ar</id>
+      <url>https://us-maven.pkg.dev/project/repo</url>
+    </repository>
+  </repositories>
+</project>`,
+			userSettings: "<settings>\n  <offline>false</offline>\n</settings>\n",
+			wantConfig: `<settings>
+  <offline>false</offline>
+  <servers>
+    <server>
+      <id>ar</id>
+      <username>oauth2accesstoken</username>
+      <password>token</password>
+    </server>
+  </servers>
+</settings>
+`,
+		},
+		{
+			name: "user settings with empty servers",
+			pom: `<project>
+  <repositories>
+    <repository>
+      <id>ar</id>
+      <url>https://us-maven.pkg.dev/project/repo</url>
+    </repository>
+  </repositories>
+</project>`,
+			userSettings: "<settings>\n  <servers/>\n</settings>\n",
+			wantConfig: `<settings>
+  <servers>
+    <server>
+      <id>ar</id>
+      <username>oauth2accesstoken</username>
+      <password>token</password>
+    </server>
+  </servers>
+</settings>
+`,
+		},
+		{
+			name: "user settings with credentials",
+			pom: `<project>
+  <repositories>
+    <repository>
+      <id>ar</id>
+      <url>https://us-maven.pkg.dev/project/repo</url>
+    </repository>
+  </repositories>
+</project>`,
+			userSettings: "<settings>\n  <servers>\n    <server>\n      <id>ar</id>\n      <password>secret</password>\n    </server>\n  </servers>\n</settings>\n",
+			wantConfig:   "<settings>\n  <servers>\n    <server>\n      <id>ar</id>\n      <password>secret</password>\n    </server>\n  </servers>\n</settings>\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer SetTokenSource(func() (string, error) {
+				return "token", nil
+			})()
+			appDir := t.TempDir()
+			pomPath := tc.pomPath
+			if pomPath == "" {
+				pomPath = "pom.xml"
+			}
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(appDir, pomPath)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			userSettings := filepath.Join(t.TempDir(), "settings.xml")
+			if tc.userSettings != "" {
+				if err := os.WriteFile(userSettings, []byte(tc.userSettings), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(appDir, pomPath), []byte(tc.pom), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir), gcp.WithBuildContext(libcnb.BuildContext{
+				Layers: libcnb.Layers{Path: t.TempDir()},
+			}))
+
+			path, err := GenerateMavenConfig(ctx, tc.pomPath, userSettings)
+			if err != nil {
+				t.Fatalf("GenerateMavenConfig() got error: %v", err)
+			}
+			if tc.wantConfig == "" {
+				if path != "" {
+					t.Errorf("GenerateMavenConfig() = %q, want no settings", path)
+				}
+				return
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading %s: %v", path, err)
+			}
+			if diff := cmp.Diff(tc.wantConfig, string(got)); diff != "" {
+				t.Errorf("unexpected settings.xml (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateGradleConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		wantInit bool
+	}{
+		{
+			name: "no Artifact Registry repositories",
+			files: map[string]string{
+				"build.gradle": "repositories {\n    mavenCentral()\n}\n",
+			},
+		},
+		{
+			name: "repository in build.gradle",
+			files: map[string]string{
+				"build.gradle": "repositories {\n    maven {\n        url 'https://us-maven.pkg.dev/project/repo'\n    }\n}\n",
+			},
+			wantInit: true,
+		},
+		{
+			name: "repository in settings.gradle.kts",
+			files: map[string]string{
+				"settings.gradle.kts": "dependencyResolutionManagement {\n    repositories {\n        maven(url = \"https://europe-west1-maven.pkg.dev/project/repo\")\n    }\n}\n",
+			},
+			wantInit: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer SetTokenSource(func() (string, error) {
+				return "token", nil
+			})()
+			appDir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(appDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir), gcp.WithBuildContext(libcnb.BuildContext{
+				Layers: libcnb.Layers{Path: t.TempDir()},
+			}))
+
+			path, err := GenerateGradleConfig(ctx)
+			if err != nil {
+				t.Fatalf("GenerateGradleConfig() got error: %v", err)
+			}
+			if !tc.wantInit {
+				if path != "" {
+					t.Errorf("GenerateGradleConfig() = %q, want no init script", path)
+				}
+				return
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading %s: %v", path, err)
+			}
+			if !strings.Contains(string(got), "def arToken = 'token'") {
+				t.Errorf("init script does not contain the token:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestGradleInitScriptEscapesToken(t *testing.T) {
+	got := gradleInitScript(`a'b\c`)
+	if !strings.Contains(got, `def arToken = 'a\'b\\c'`) {
+		t.Errorf("gradleInitScript() did not escape the token:\n%s", got)
+	}
+}
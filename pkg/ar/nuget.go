@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// registryURLRegexp matches the URL of any Artifact Registry repository, e.g.
+	// https://us-nuget.pkg.dev/my-project/my-repo, and captures its host.
+	registryURLRegexp = regexp.MustCompile(`^https://([a-z0-9-]+[.]pkg[.]dev)/`)
+	// nugetConfigNames are the project-level NuGet config file names recognized by NuGet on Linux.
+	nugetConfigNames = []string{"nuget.config", "NuGet.config", "NuGet.Config"}
+)
+
+type nugetSource struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type nugetConfig struct {
+	PackageSources []nugetSource `xml:"packageSources>add"`
+}
+
+// GenerateNuGetConfig generates a NuGet.Config file in the user's HOME directory with credentials
+// for the Artifact Registry package sources declared in the project's nuget.config. NuGet merges the
+// user-level config with the project-level one, so the sources only need to be declared once.
+func GenerateNuGetConfig(ctx *gcp.Context) error {
+	userConfig := filepath.Join(ctx.HomeDir(), ".nuget", "NuGet", "NuGet.Config")
+	userConfigExists, err := ctx.FileExists(userConfig)
+	if err != nil {
+		return err
+	}
+	if userConfigExists {
+		ctx.Debugf("Found an existing user-level NuGet.Config file. Skipping NuGet.Config creation.")
+		return nil
+	}
+
+	var sources []string
+	for _, name := range nugetConfigNames {
+		projectConfig := filepath.Join(ctx.ApplicationRoot(), name)
+		exists, err := ctx.FileExists(projectConfig)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(projectConfig)
+		if err != nil {
+			return err
+		}
+		var cfg nugetConfig
+		if err := xml.Unmarshal(content, &cfg); err != nil {
+			ctx.Warnf("Skipping NuGet credential creation. Unable to parse %s: %v", projectConfig, err)
+			return nil
+		}
+		for _, s := range cfg.PackageSources {
+			if registryURLRegexp.MatchString(s.Value) {
+				sources = append(sources, s.Key)
+			}
+		}
+		break
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	tok, err := accessToken(ctx)
+	if err != nil {
+		// Credentials might not be required for the restore to succeed so we should not fail the
+		// build here.
+		ctx.Warnf("Skipping NuGet.Config creation. Unable to find Application Default Credentials: %v", err)
+		return nil
+	}
+	ctx.Debugf("Configuring NuGet credentials for: %s", strings.Join(sources, ", "))
+
+	if err := ctx.MkdirAll(filepath.Dir(userConfig), 0755); err != nil {
+		return err
+	}
+	return ctx.WriteFile(userConfig, nugetCredentials(sources, tok), 0600)
+}
+
+// nugetCredentials returns a NuGet config that sets credentials for the given package sources.
+func nugetCredentials(sources []string, tok string) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString("<configuration>\n  <packageSourceCredentials>\n")
+	for _, s := range sources {
+		name := nugetElementName(s)
+		fmt.Fprintf(&b, "    <%s>\n", name)
+		b.WriteString(`      <add key="Username" value="oauth2accesstoken" />` + "\n")
+		b.WriteString(`      <add key="ClearTextPassword" value="`)
+		xml.EscapeText(&b, []byte(tok))
+		b.WriteString("\" />\n")
+		fmt.Fprintf(&b, "    </%s>\n", name)
+	}
+	b.WriteString("  </packageSourceCredentials>\n</configuration>\n")
+	return b.Bytes()
+}
+
+// nugetElementName encodes a package source key as an XML element name the way NuGet does, e.g.
+// "My Source" becomes "My_x0020_Source".
+func nugetElementName(key string) string {
+	var sb strings.Builder
+	for _, r := range key {
+		if r == '-' || r == '.' || r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			sb.WriteRune(r)
+			continue
+		}
+		fmt.Fprintf(&sb, "_x%04X_", r)
+	}
+	return sb.String()
+}
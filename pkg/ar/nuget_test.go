@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateNuGetConfig(t *testing.T) {
+	testCases := []struct {
+		name       string
+		configName string
+		config     string
+		userConfig bool
+		wantConfig string
+	}{
+		{
+			name:       "no Artifact Registry sources",
+			configName: "nuget.config",
+			config: `<configuration>
+  <packageSources>
+    <add key="nuget.org" value="https://api.nuget.org/v3/index.json" />
+  </packageSources>
+</configuration>`,
+		},
+		{
+			name:       "user config already exists",
+			configName: "nuget.config",
+			config: `<configuration>
+  <packageSources>
+    <add key="ar" value="https://us-nuget.pkg.dev/project/repo/v3/index.json" />
+  </packageSources>
+</configuration>`,
+			userConfig: true,
+		},
+		{
+			name:       "Artifact Registry sources",
+			configName: "NuGet.Config",
+			config: `<?xml version="1.0" encoding="utf-8"?>
+<configuration>
+  <packageSources>
+    <add key="nuget.org" value="https://api.nuget.org/v3/index.json" />
+    <add key="My Packages" value="https://us-nuget.pkg.dev/project/repo/v3/index.json" />
+  </packageSources>
+</configuration>`,
+			wantConfig: `<?xml version="1.0" encoding="UTF-8"?>
+<configuration>
+  <packageSourceCredentials>
+    <My_x0020_Packages>
+      <add key="Username" value="oauth2accesstoken" />
+      <add key="ClearTextPassword" value="token" />
+    </My_x0020_Packages>
+  </packageSourceCredentials>
+</configuration>
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer SetTokenSource(func() (string, error) {
+				return "token", nil
+			})()
+			tempHome := t.TempDir()
+			t.Setenv("HOME", tempHome)
+			userConfig := filepath.Join(tempHome, ".nuget", "NuGet", "NuGet.Config")
+			if tc.userConfig {
+				if err := os.MkdirAll(filepath.Dir(userConfig), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(userConfig, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			appDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(appDir, tc.configName), []byte(tc.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir))
+
+			if err := GenerateNuGetConfig(ctx); err != nil {
+				t.Fatalf("GenerateNuGetConfig() got error: %v", err)
+			}
+			got, err := os.ReadFile(userConfig)
+			if err != nil && tc.wantConfig != "" {
+				t.Fatalf("reading %s: %v", userConfig, err)
+			}
+			if diff := cmp.Diff(tc.wantConfig, string(got)); diff != "" {
+				t.Errorf("unexpected NuGet.Config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"golang.org/x/oauth2/google"
+)
+
+// TokenSource returns an OAuth2 access token that authenticates requests to Artifact Registry.
+type TokenSource func() (string, error)
+
+// findDefaultCredentials is the TokenSource used to generate credentials. It defaults to searching
+// for "Application Default Credentials" using the google/oauth package (see
+// https://cloud.google.com/docs/authentication/production#automatically).
+var findDefaultCredentials TokenSource = func() (string, error) {
+	ctx := context.Background()
+	src, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return "", err
+	}
+	tok, err := src.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// accessToken returns a token from findDefaultCredentials and registers it with ctx so that it is
+// redacted if a tool echoes its credentials.
+func accessToken(ctx *gcp.Context) (string, error) {
+	tok, err := findDefaultCredentials()
+	if err != nil {
+		return "", err
+	}
+	ctx.RegisterSecret(tok)
+	return tok, nil
+}
+
+// SetTokenSource replaces the source of Artifact Registry access tokens, e.g. with a
+// MetadataServerTokenSource backed by a fake metadata server in tests. It returns a function that
+// restores the previous source.
+func SetTokenSource(ts TokenSource) func() {
+	orig := findDefaultCredentials
+	findDefaultCredentials = ts
+	return func() {
+		findDefaultCredentials = orig
+	}
+}
+
+// MetadataServerTokenSource returns a TokenSource that fetches access tokens for the default service
+// account from the metadata server at host, e.g. "metadata.google.internal" or "localhost:8080".
+func MetadataServerTokenSource(host string) TokenSource {
+	return func() (string, error) {
+		url := fmt.Sprintf("http://%s/computeMetadata/v1/instance/service-accounts/default/token", host)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("requesting token from metadata server: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("requesting token from metadata server: unexpected status %q", resp.Status)
+		}
+		var tok struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+			return "", fmt.Errorf("decoding metadata server token: %w", err)
+		}
+		if tok.AccessToken == "" {
+			return "", fmt.Errorf("metadata server returned an empty access token")
+		}
+		return tok.AccessToken, nil
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetadataServerTokenSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor header", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"access_token":"fake-token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	restore := SetTokenSource(MetadataServerTokenSource(host))
+	defer restore()
+
+	got, err := findDefaultCredentials()
+	if err != nil {
+		t.Fatalf("findDefaultCredentials() got error: %v", err)
+	}
+	if got != "fake-token" {
+		t.Errorf("findDefaultCredentials() = %q, want %q", got, "fake-token")
+	}
+}
+
+func TestMetadataServerTokenSourceError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no service account", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, err := MetadataServerTokenSource(strings.TrimPrefix(srv.URL, "http://"))(); err == nil {
+		t.Error("MetadataServerTokenSource() got no error, want error")
+	}
+}
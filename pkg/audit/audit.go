@@ -0,0 +1,181 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit checks the resolved dependencies of an application against a local database of
+// OSV advisories. It never accesses the network.
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// maxReportedFindings is the number of findings named in the build error.
+const maxReportedFindings = 10
+
+// Severity is the qualitative severity of an advisory.
+type Severity int
+
+const (
+	// SeverityUnknown is the severity of advisories that record none or only unsupported scores.
+	// Because it may be any severity, such findings fail the build whenever a threshold is set.
+	SeverityUnknown Severity = iota
+	// SeverityLow is a CVSS score below 4.0.
+	SeverityLow
+	// SeverityModerate is a CVSS score from 4.0 to 6.9, also called medium.
+	SeverityModerate
+	// SeverityHigh is a CVSS score from 7.0 to 8.9.
+	SeverityHigh
+	// SeverityCritical is a CVSS score of 9.0 or more.
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:  "UNKNOWN",
+	SeverityLow:      "LOW",
+	SeverityModerate: "MODERATE",
+	SeverityHigh:     "HIGH",
+	SeverityCritical: "CRITICAL",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity parses LOW, MODERATE (or MEDIUM), HIGH or CRITICAL, in any case.
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "LOW":
+		return SeverityLow, nil
+	case "MODERATE", "MEDIUM":
+		return SeverityModerate, nil
+	case "HIGH":
+		return SeverityHigh, nil
+	case "CRITICAL":
+		return SeverityCritical, nil
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q: want LOW, MODERATE, HIGH or CRITICAL", s)
+}
+
+// Package is a resolved dependency.
+type Package struct {
+	// Ecosystem is the OSV ecosystem of the package, such as NPM.
+	Ecosystem string
+	Name      string
+	Version   string
+}
+
+// Dependencies reads the resolved dependencies of an application, such as from a lockfile.
+type Dependencies func() ([]Package, error)
+
+// Run audits the dependencies against the OSV advisories in the directory set by
+// GOOGLE_ADVISORY_DB, if it is set. Findings are reported as warnings, and the build fails if any
+// is at or above the severity set by GOOGLE_AUDIT_FAIL_SEVERITY or has an unknown severity. If no
+// severity is set, the audit cannot fail the build, so dependencies that cannot be read are only
+// reported as a warning.
+func Run(ctx *gcp.Context, deps Dependencies) error {
+	dir := os.Getenv(env.AdvisoryDB)
+	if dir == "" {
+		return nil
+	}
+	threshold, err := failSeverity()
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return gcp.UserErrorf("%s=%q is not a directory of OSV advisories", env.AdvisoryDB, dir)
+	}
+
+	start := time.Now()
+	status := buildererror.StatusInternal
+	defer func() {
+		ctx.Span("Audit dependencies", start, status)
+	}()
+	pkgs, err := deps()
+	if err != nil {
+		if threshold == SeverityUnknown {
+			status = buildererror.StatusOk
+			ctx.Warnf("Skipping the dependency audit, reading dependencies failed: %v", err)
+			return nil
+		}
+		return gcp.UserErrorf("reading dependencies to audit: %v", err)
+	}
+	pkgs = dedupe(pkgs)
+	if len(pkgs) == 0 {
+		status = buildererror.StatusOk
+		return nil
+	}
+	db, err := loadDatabase(dir, pkgs)
+	if err != nil {
+		return gcp.UserErrorf("reading advisories from %s: %v", dir, err)
+	}
+	findings := db.match(pkgs)
+	status = buildererror.StatusOk
+
+	ctx.Logf("Audited %d dependencies against %d advisories: %d known vulnerabilities.", len(pkgs), db.files, len(findings))
+	buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.AuditFindingsCounterID).Increment(int64(len(findings)))
+	var blocking []string
+	for _, f := range findings {
+		ctx.Warnf("Vulnerable dependency: %s", f)
+		if threshold != SeverityUnknown && (f.Severity >= threshold || f.Severity == SeverityUnknown) {
+			blocking = append(blocking, fmt.Sprintf("%s (%s@%s)", f.ID, f.Name, f.Version))
+		}
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+	n := len(blocking)
+	if n > maxReportedFindings {
+		blocking = append(blocking[:maxReportedFindings:maxReportedFindings], fmt.Sprintf("and %d more", n-maxReportedFindings))
+	}
+	return gcp.UserErrorf("found %d vulnerabilities with %s severity or higher, or with unknown severity, in dependencies: %s", n, threshold, strings.Join(blocking, ", "))
+}
+
+// failSeverity returns the severity set by GOOGLE_AUDIT_FAIL_SEVERITY, or SeverityUnknown if
+// findings should not fail the build.
+func failSeverity() (Severity, error) {
+	v := os.Getenv(env.AuditFailSeverity)
+	if v == "" {
+		return SeverityUnknown, nil
+	}
+	s, err := ParseSeverity(v)
+	if err != nil {
+		return SeverityUnknown, gcp.UserErrorf("invalid %s: %v", env.AuditFailSeverity, err)
+	}
+	return s, nil
+}
+
+// dedupe removes repeated packages, such as a package installed at several paths in node_modules.
+func dedupe(pkgs []Package) []Package {
+	seen := map[Package]bool{}
+	var out []Package
+	for _, p := range pkgs {
+		if p.Name == "" || p.Version == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+const lodashAdvisory = `{
+  "id": "GHSA-35jh-r3h4-6jhm",
+  "aliases": ["CVE-2021-23337"],
+  "summary": "Command Injection in lodash",
+  "affected": [{
+    "package": {"ecosystem": "npm", "name": "lodash"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}],
+    "database_specific": {"severity": "HIGH"}
+  }]
+}`
+
+const minimistAdvisory = `{
+  "id": "GHSA-xvch-5gv4-984h",
+  "summary": "Prototype Pollution in minimist",
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N"}],
+  "affected": [{
+    "package": {"ecosystem": "npm", "name": "minimist"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.6"}]}]
+  }]
+}`
+
+const msAdvisory = `{
+  "id": "GHSA-w9mr-4mfr-499f",
+  "summary": "Regular Expression Denial of Service in ms",
+  "affected": [{
+    "package": {"ecosystem": "npm", "name": "ms"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "0.7.1"}]}]
+  }]
+}`
+
+func TestRun(t *testing.T) {
+	deps := []Package{
+		{NPM, "lodash", "4.17.20"},
+		{NPM, "lodash", "4.17.20"},
+		{NPM, "minimist", "1.2.5"},
+		{NPM, "express", "4.18.2"},
+	}
+	testCases := []struct {
+		name          string
+		advisoryDB    bool
+		failSeverity  string
+		deps          []Package
+		depsErr       error
+		wantError     string
+		wantWarnings  []string
+		wantLog       string
+		wantNoWarning bool
+	}{
+		{
+			name:          "no advisory database",
+			deps:          deps,
+			wantNoWarning: true,
+		},
+		{
+			name:       "warnings only",
+			advisoryDB: true,
+			deps:       deps,
+			wantWarnings: []string{
+				"GHSA-35jh-r3h4-6jhm lodash@4.17.20: Command Injection in lodash (HIGH severity), fixed in 4.17.21",
+				"GHSA-xvch-5gv4-984h minimist@1.2.5: Prototype Pollution in minimist (MODERATE severity), fixed in 1.2.6",
+			},
+		},
+		{
+			name:         "below threshold",
+			advisoryDB:   true,
+			failSeverity: "critical",
+			deps:         deps,
+		},
+		{
+			name:         "at threshold",
+			advisoryDB:   true,
+			failSeverity: "HIGH",
+			deps:         deps,
+			wantError:    "found 1 vulnerabilities with HIGH severity or higher, or with unknown severity, in dependencies: GHSA-35jh-r3h4-6jhm (lodash@4.17.20)",
+		},
+		{
+			name:         "unknown severity with threshold",
+			advisoryDB:   true,
+			failSeverity: "CRITICAL",
+			deps:         []Package{{NPM, "ms", "0.7.0"}},
+			wantError:    "found 1 vulnerabilities with CRITICAL severity or higher, or with unknown severity, in dependencies: GHSA-w9mr-4mfr-499f (ms@0.7.0)",
+		},
+		{
+			name:       "unknown severity without threshold",
+			advisoryDB: true,
+			deps:       []Package{{NPM, "ms", "0.7.0"}},
+			wantWarnings: []string{
+				"GHSA-w9mr-4mfr-499f ms@0.7.0: Regular Expression Denial of Service in ms (UNKNOWN severity), fixed in 0.7.1",
+			},
+		},
+		{
+			name:       "unreadable dependencies without threshold",
+			advisoryDB: true,
+			depsErr:    errors.New("invalid lockfile"),
+			wantLog:    "WARNING: Skipping the dependency audit, reading dependencies failed: invalid lockfile",
+		},
+		{
+			name:         "unreadable dependencies with threshold",
+			advisoryDB:   true,
+			failSeverity: "HIGH",
+			depsErr:      errors.New("invalid lockfile"),
+			wantError:    "reading dependencies to audit: invalid lockfile",
+		},
+		{
+			name:         "fixed versions",
+			advisoryDB:   true,
+			failSeverity: "LOW",
+			deps:         []Package{{NPM, "lodash", "4.17.21"}, {NPM, "minimist", "1.2.6"}},
+		},
+		{
+			name:         "invalid threshold",
+			advisoryDB:   true,
+			failSeverity: "severe",
+			deps:         deps,
+			wantError:    `unknown severity "severe"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.advisoryDB {
+				db := t.TempDir()
+				writeFile(t, filepath.Join(db, "npm", "GHSA-35jh-r3h4-6jhm.json"), lodashAdvisory)
+				writeFile(t, filepath.Join(db, "npm", "GHSA-xvch-5gv4-984h.json"), minimistAdvisory)
+				writeFile(t, filepath.Join(db, "npm", "GHSA-w9mr-4mfr-499f.json"), msAdvisory)
+				// Advisories of other ecosystems are not read, so an invalid one does not fail the audit.
+				writeFile(t, filepath.Join(db, "PyPI", "PYSEC-0000-0.json"), "not an advisory")
+				t.Setenv(env.AdvisoryDB, db)
+			}
+			if tc.failSeverity != "" {
+				t.Setenv(env.AuditFailSeverity, tc.failSeverity)
+			}
+			var logs bytes.Buffer
+			ctx := gcp.NewContext(
+				gcp.WithLogger(log.New(&logs, "", 0)),
+				gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}),
+			)
+
+			err := Run(ctx, func() ([]Package, error) { return tc.deps, tc.depsErr })
+			if tc.wantError == "" && err != nil {
+				t.Fatalf("Run() got error: %v", err)
+			}
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("Run() got error: %v, want error containing %q", err, tc.wantError)
+				}
+				if be, ok := err.(*buildererror.Error); !ok || be.Type != buildererror.StatusUnknown {
+					t.Errorf("Run() got error %#v, want a user-attributed error", err)
+				}
+			}
+			for _, w := range tc.wantWarnings {
+				if !strings.Contains(logs.String(), "WARNING: Vulnerable dependency: "+w) {
+					t.Errorf("Run() logs = %q, want warning %q", logs.String(), w)
+				}
+			}
+			if tc.wantLog != "" && !strings.Contains(logs.String(), tc.wantLog) {
+				t.Errorf("Run() logs = %q, want %q", logs.String(), tc.wantLog)
+			}
+			if tc.wantNoWarning && strings.Contains(logs.String(), "WARNING") {
+				t.Errorf("Run() logs = %q, want no warnings", logs.String())
+			}
+		})
+	}
+}
+
+func TestRunNotADirectory(t *testing.T) {
+	t.Setenv(env.AdvisoryDB, filepath.Join(t.TempDir(), "missing"))
+	called := false
+	err := Run(gcp.NewContext(), func() ([]Package, error) {
+		called = true
+		return nil, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "is not a directory of OSV advisories") {
+		t.Errorf("Run() got error: %v, want error for a missing advisory database", err)
+	}
+	if called {
+		t.Error("Run() read dependencies despite a missing advisory database")
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	testCases := []struct {
+		input     string
+		want      Severity
+		wantError bool
+	}{
+		{input: "low", want: SeverityLow},
+		{input: "Medium", want: SeverityModerate},
+		{input: "MODERATE", want: SeverityModerate},
+		{input: " high ", want: SeverityHigh},
+		{input: "CRITICAL", want: SeverityCritical},
+		{input: "", wantError: true},
+		{input: "severe", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseSeverity(tc.input)
+			if gotErr := err != nil; gotErr != tc.wantError {
+				t.Fatalf("ParseSeverity(%q) got error: %v, want error: %v", tc.input, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("ParseSeverity(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	got := dedupe([]Package{
+		{NPM, "a", "1.0.0"},
+		{NPM, "a", "1.0.0"},
+		{NPM, "a", "2.0.0"},
+		{PyPI, "a", "1.0.0"},
+		{NPM, "b", ""},
+	})
+	want := []Package{{NPM, "a", "1.0.0"}, {NPM, "a", "2.0.0"}, {PyPI, "a", "1.0.0"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("dedupe() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
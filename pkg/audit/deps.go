@@ -0,0 +1,466 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// NPMLockfile reads the packages in an npm package-lock.json or npm-shrinkwrap.json. Packages only
+// needed for development are skipped.
+func NPMLockfile(lockfile string) Dependencies {
+	return func() ([]Package, error) {
+		content, err := os.ReadFile(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		var lock struct {
+			// Packages is the lockfile v2 and v3 layout, keyed by install path.
+			Packages map[string]struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+				Dev     bool   `json:"dev"`
+				Link    bool   `json:"link"`
+			} `json:"packages"`
+			// Dependencies is the lockfile v1 layout.
+			Dependencies map[string]npmDependency `json:"dependencies"`
+		}
+		if err := json.Unmarshal(content, &lock); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", lockfile, err)
+		}
+		var pkgs []Package
+		if len(lock.Packages) > 0 {
+			for key, p := range lock.Packages {
+				i := strings.LastIndex(key, "node_modules/")
+				if i < 0 || p.Dev || p.Link {
+					// The root package and workspaces are not dependencies.
+					continue
+				}
+				name := p.Name
+				if name == "" {
+					name = key[i+len("node_modules/"):]
+				}
+				pkgs = append(pkgs, Package{NPM, name, p.Version})
+			}
+			return pkgs, nil
+		}
+		var walk func(deps map[string]npmDependency)
+		walk = func(deps map[string]npmDependency) {
+			for name, d := range deps {
+				if d.Dev {
+					continue
+				}
+				if isRegistryVersion(d.Version) {
+					pkgs = append(pkgs, Package{NPM, name, d.Version})
+				}
+				walk(d.Dependencies)
+			}
+		}
+		walk(lock.Dependencies)
+		return pkgs, nil
+	}
+}
+
+type npmDependency struct {
+	Version      string                   `json:"version"`
+	Dev          bool                     `json:"dev"`
+	Dependencies map[string]npmDependency `json:"dependencies"`
+}
+
+// isRegistryVersion returns whether a lockfile version is a version number rather than a URL,
+// path or alias.
+func isRegistryVersion(v string) bool {
+	return v != "" && !strings.ContainsAny(v, ":/")
+}
+
+// YarnLockfile reads the packages in a yarn.lock written by Yarn 1 or Yarn 2 and later. The
+// lockfile does not mark development packages, so they are included.
+func YarnLockfile(lockfile string) Dependencies {
+	return func() ([]Package, error) {
+		f, err := os.Open(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		var pkgs []Package
+		var name string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "" || strings.HasPrefix(line, "#"):
+				continue
+			case !strings.HasPrefix(line, " "):
+				// An entry starts with its comma-separated specifiers, such as `"a@^1.0.0", a@^1.1.0:`
+				// in Yarn 1 or `"a@npm:^1.0.0, a@npm:^1.1.0":` in Yarn 2.
+				spec := strings.TrimSuffix(line, ":")
+				spec = strings.Trim(strings.TrimSpace(strings.SplitN(spec, ",", 2)[0]), `"`)
+				name = yarnPackageName(spec)
+			case name != "":
+				field := strings.TrimSpace(line)
+				if !strings.HasPrefix(field, "version") {
+					continue
+				}
+				version := strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(field, "version"), ":")), `"`)
+				if isRegistryVersion(version) {
+					pkgs = append(pkgs, Package{NPM, name, version})
+				}
+				name = ""
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", lockfile, err)
+		}
+		return pkgs, nil
+	}
+}
+
+// yarnPackageName returns the package name of a yarn.lock specifier such as `@scope/a@^1.0.0` or
+// `a@npm:^1.0.0`, or "" for workspaces and local packages.
+func yarnPackageName(spec string) string {
+	at := strings.LastIndex(spec, "@")
+	if at <= 0 {
+		// Yarn 2 metadata, such as __metadata.
+		return ""
+	}
+	// Yarn 2 specifiers may contain a second @, as in `a@patch:a@npm%3A1.0.0#...`.
+	if i := strings.Index(spec[1:], "@"); i >= 0 {
+		at = i + 1
+	}
+	name, rng := spec[:at], spec[at+1:]
+	for _, protocol := range []string{"workspace:", "link:", "portal:", "file:"} {
+		if strings.HasPrefix(rng, protocol) {
+			return ""
+		}
+	}
+	return name
+}
+
+// PNPMLockfile reads the packages in a pnpm-lock.yaml. Packages only needed for development are
+// skipped if the lockfile marks them.
+func PNPMLockfile(lockfile string) Dependencies {
+	return func() ([]Package, error) {
+		content, err := os.ReadFile(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		var lock struct {
+			Packages map[string]struct {
+				Name    string `yaml:"name"`
+				Version string `yaml:"version"`
+				Dev     bool   `yaml:"dev"`
+			} `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(content, &lock); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", lockfile, err)
+		}
+		var pkgs []Package
+		for key, p := range lock.Packages {
+			if p.Dev {
+				continue
+			}
+			name, version := pnpmPackageKey(key)
+			if p.Name != "" {
+				name, version = p.Name, p.Version
+			}
+			if isRegistryVersion(version) {
+				pkgs = append(pkgs, Package{NPM, name, version})
+			}
+		}
+		return pkgs, nil
+	}
+}
+
+// pnpmPackageKey returns the name and version of a pnpm-lock.yaml package key, which is
+// `/a/1.0.0_peer@2.0.0` in lockfile v5, `/a@1.0.0(peer@2.0.0)` in v6 and `a@1.0.0` in v9.
+func pnpmPackageKey(key string) (string, string) {
+	key = strings.TrimPrefix(key, "/")
+	if i := strings.Index(key, "("); i >= 0 {
+		key = key[:i]
+	}
+	// A package name has at most one slash, after its @scope.
+	nameSlashes := 0
+	if strings.HasPrefix(key, "@") {
+		nameSlashes = 1
+	}
+	if at := strings.LastIndex(key, "@"); at > 0 && strings.Count(key[:at], "/") == nameSlashes {
+		return key[:at], key[at+1:]
+	}
+	parts := strings.SplitN(key, "/", nameSlashes+2)
+	if len(parts) < nameSlashes+2 {
+		return key, ""
+	}
+	name, version := strings.Join(parts[:nameSlashes+1], "/"), parts[nameSlashes+1]
+	if i := strings.Index(version, "_"); i >= 0 {
+		version = version[:i]
+	}
+	return name, version
+}
+
+// PythonDists reads the Python distributions installed under dir, such as a site-packages directory
+// or a layer holding one.
+func PythonDists(dir string) Dependencies {
+	return func() ([]Package, error) {
+		var pkgs []Package
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			var metadata string
+			switch {
+			case strings.HasSuffix(p, ".dist-info"):
+				metadata = filepath.Join(p, "METADATA")
+			case strings.HasSuffix(p, ".egg-info"):
+				metadata = filepath.Join(p, "PKG-INFO")
+			default:
+				return nil
+			}
+			pkg, err := readPythonMetadata(metadata)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if pkg.Name != "" {
+				pkgs = append(pkgs, pkg)
+			}
+			return filepath.SkipDir
+		})
+		return pkgs, err
+	}
+}
+
+// readPythonMetadata reads the name and version from the headers of a distribution's metadata.
+func readPythonMetadata(metadata string) (Package, error) {
+	f, err := os.Open(metadata)
+	if err != nil {
+		return Package{}, err
+	}
+	defer f.Close()
+	pkg := Package{Ecosystem: PyPI}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() && sc.Text() != "" {
+		if k, v, ok := strings.Cut(sc.Text(), ":"); ok {
+			switch k {
+			case "Name":
+				pkg.Name = strings.TrimSpace(v)
+			case "Version":
+				pkg.Version = strings.TrimSpace(v)
+			}
+		}
+	}
+	return pkg, sc.Err()
+}
+
+// GoBinary reads the modules, and the version of the Go standard library, that a Go binary was
+// built with. Unlike go.sum, the build information only lists modules that are compiled in.
+func GoBinary(binary string) Dependencies {
+	return func() ([]Package, error) {
+		info, err := buildinfo.ReadFile(binary)
+		if err != nil {
+			return nil, fmt.Errorf("reading build information of %s: %w", binary, err)
+		}
+		// The standard library is named "stdlib" in the Go vulnerability database.
+		pkgs := []Package{{Go, "stdlib", strings.TrimPrefix(info.GoVersion, "go")}}
+		for _, m := range info.Deps {
+			if r := m.Replace; r != nil {
+				if r.Version == "" {
+					// Replaced by a local directory.
+					continue
+				}
+				m = r
+			}
+			pkgs = append(pkgs, Package{Go, m.Path, m.Version})
+		}
+		return pkgs, nil
+	}
+}
+
+// GemfileLock reads the gems installed from gem sources in a Gemfile.lock or gems.locked. Gems from
+// git repositories and local paths are skipped. The lockfile does not record Gemfile groups, so gems
+// in the development and test groups are included.
+func GemfileLock(lockfile string) Dependencies {
+	return func() ([]Package, error) {
+		f, err := os.Open(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		var pkgs []Package
+		var section string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := sc.Text()
+			if !strings.HasPrefix(line, " ") {
+				section = strings.TrimSpace(line)
+				continue
+			}
+			// Gems are indented by four spaces under "  specs:", and their dependencies by six.
+			if section != "GEM" || !strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "     ") {
+				continue
+			}
+			name, version, ok := strings.Cut(strings.TrimSpace(line), " (")
+			if !ok {
+				continue
+			}
+			version = strings.TrimSuffix(version, ")")
+			// Platform-specific gems have versions such as 1.13.1-x86_64-linux.
+			if i := strings.Index(version, "-"); i >= 0 {
+				version = version[:i]
+			}
+			pkgs = append(pkgs, Package{RubyGems, name, version})
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", lockfile, err)
+		}
+		return pkgs, nil
+	}
+}
+
+// ComposerLock reads the packages in a composer.lock. Development packages are skipped, as they are
+// not installed with --no-dev.
+func ComposerLock(lockfile string) Dependencies {
+	return func() ([]Package, error) {
+		content, err := os.ReadFile(lockfile)
+		if err != nil {
+			return nil, err
+		}
+		var lock struct {
+			Packages []struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"packages"`
+		}
+		if err := json.Unmarshal(content, &lock); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", lockfile, err)
+		}
+		var pkgs []Package
+		for _, p := range lock.Packages {
+			if strings.HasPrefix(p.Version, "dev-") {
+				// Branches have no version to match.
+				continue
+			}
+			pkgs = append(pkgs, Package{Packagist, p.Name, strings.TrimPrefix(p.Version, "v")})
+		}
+		return pkgs, nil
+	}
+}
+
+// JavaArchives reads the Maven artifacts in the JAR and WAR files that match the glob patterns,
+// from the pom.properties that Maven and Gradle builds embed. The libraries nested in executable
+// archives, as in Spring Boot's BOOT-INF/lib or a WAR's WEB-INF/lib, are read too.
+func JavaArchives(patterns ...string) Dependencies {
+	return func() ([]Package, error) {
+		var pkgs []Package
+		for _, pattern := range patterns {
+			archives, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range archives {
+				zr, err := zip.OpenReader(a)
+				if err != nil {
+					return nil, fmt.Errorf("opening %s: %w", a, err)
+				}
+				found, err := archiveArtifacts(&zr.Reader, true)
+				zr.Close()
+				if err != nil {
+					return nil, fmt.Errorf("reading %s: %w", a, err)
+				}
+				pkgs = append(pkgs, found...)
+			}
+		}
+		return pkgs, nil
+	}
+}
+
+// archiveArtifacts returns the artifacts described by the pom.properties files in an archive and,
+// if nested is set, in the archives it contains.
+func archiveArtifacts(zr *zip.Reader, nested bool) ([]Package, error) {
+	var pkgs []Package
+	for _, f := range zr.File {
+		switch {
+		case strings.HasPrefix(f.Name, "META-INF/maven/") && path.Base(f.Name) == "pom.properties":
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			pkg, err := readPomProperties(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			if pkg.Name != "" {
+				pkgs = append(pkgs, pkg)
+			}
+		case nested && strings.HasSuffix(f.Name, ".jar"):
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			content, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			inner, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+			if err != nil {
+				// Not every file named .jar is an archive.
+				continue
+			}
+			found, err := archiveArtifacts(inner, false)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", f.Name, err)
+			}
+			pkgs = append(pkgs, found...)
+		}
+	}
+	return pkgs, nil
+}
+
+// readPomProperties reads the coordinates of an artifact from its pom.properties.
+func readPomProperties(r io.Reader) (Package, error) {
+	props := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			props[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if props["groupId"] == "" || props["artifactId"] == "" {
+		return Package{}, sc.Err()
+	}
+	return Package{Maven, props["groupId"] + ":" + props["artifactId"], props["version"]}, sc.Err()
+}
@@ -0,0 +1,355 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var sortPackages = cmpopts.SortSlices(func(a, b Package) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.Version < b.Version
+})
+
+func TestLockfiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filename string
+		content  string
+		read     func(string) Dependencies
+		want     []Package
+	}{
+		{
+			name:     "package-lock.json v3",
+			filename: "package-lock.json",
+			read:     NPMLockfile,
+			content: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "version": "1.0.0"},
+    "node_modules/express": {"version": "4.18.2"},
+    "node_modules/@types/node": {"version": "20.1.0", "dev": true},
+    "node_modules/express/node_modules/debug": {"version": "2.6.9"},
+    "node_modules/lib": {"resolved": "packages/lib", "link": true},
+    "node_modules/legacy": {"name": "lodash", "version": "3.10.1"},
+    "packages/lib": {"name": "lib", "version": "0.1.0"}
+  }
+}`,
+			want: []Package{
+				{NPM, "express", "4.18.2"},
+				{NPM, "debug", "2.6.9"},
+				{NPM, "lodash", "3.10.1"},
+			},
+		},
+		{
+			name:     "package-lock.json v1",
+			filename: "package-lock.json",
+			read:     NPMLockfile,
+			content: `{
+  "lockfileVersion": 1,
+  "dependencies": {
+    "express": {
+      "version": "4.18.2",
+      "dependencies": {"debug": {"version": "2.6.9"}}
+    },
+    "mocha": {"version": "10.2.0", "dev": true},
+    "local": {"version": "file:../local"}
+  }
+}`,
+			want: []Package{
+				{NPM, "express", "4.18.2"},
+				{NPM, "debug", "2.6.9"},
+			},
+		},
+		{
+			name:     "yarn.lock v1",
+			filename: "yarn.lock",
+			read:     YarnLockfile,
+			content: `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.22.5":
+  version "7.22.5"
+  resolved "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.22.5.tgz"
+  dependencies:
+    "@babel/highlight" "^7.22.5"
+
+lodash@^4.17.20:
+  version "4.17.21"
+
+local@file:../local:
+  version "1.0.0"
+`,
+			want: []Package{
+				{NPM, "@babel/code-frame", "7.22.5"},
+				{NPM, "lodash", "4.17.21"},
+			},
+		},
+		{
+			name:     "yarn.lock v2",
+			filename: "yarn.lock",
+			read:     YarnLockfile,
+			content: `__metadata:
+  version: 6
+  cacheKey: 8
+
+"@babel/code-frame@npm:^7.0.0":
+  version: 7.22.5
+  resolution: "@babel/code-frame@npm:7.22.5"
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+
+"resolve@patch:resolve@^1.20.0#~builtin<compat/resolve>":
+  version: 1.22.2
+  resolution: "resolve@patch:resolve@npm%3A1.22.2#~builtin<compat/resolve>::version=1.22.2&hash=c3c19d"
+`,
+			want: []Package{
+				{NPM, "@babel/code-frame", "7.22.5"},
+				{NPM, "resolve", "1.22.2"},
+			},
+		},
+		{
+			name:     "pnpm-lock.yaml v5",
+			filename: "pnpm-lock.yaml",
+			read:     PNPMLockfile,
+			content: `lockfileVersion: 5.4
+packages:
+  /@babel/core/7.22.5:
+    resolution: {integrity: sha512-abc}
+    dev: false
+  /react-dom/18.2.0_react@18.2.0:
+    resolution: {integrity: sha512-def}
+  /typescript/5.1.3:
+    resolution: {integrity: sha512-ghi}
+    dev: true
+`,
+			want: []Package{
+				{NPM, "@babel/core", "7.22.5"},
+				{NPM, "react-dom", "18.2.0"},
+			},
+		},
+		{
+			name:     "pnpm-lock.yaml v6 and v9",
+			filename: "pnpm-lock.yaml",
+			read:     PNPMLockfile,
+			content: `lockfileVersion: '6.0'
+packages:
+  /@babel/core@7.22.5:
+    resolution: {integrity: sha512-abc}
+  /react-dom@18.2.0(react@18.2.0):
+    resolution: {integrity: sha512-def}
+  express@4.18.2:
+    resolution: {integrity: sha512-ghi}
+`,
+			want: []Package{
+				{NPM, "@babel/core", "7.22.5"},
+				{NPM, "react-dom", "18.2.0"},
+				{NPM, "express", "4.18.2"},
+			},
+		},
+		{
+			name:     "Gemfile.lock",
+			filename: "Gemfile.lock",
+			read:     GemfileLock,
+			content: `GIT
+  remote: https://github.com/example/forked.git
+  revision: abc123
+  specs:
+    forked (0.1.0)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    nokogiri (1.15.2-x86_64-linux)
+      racc (~> 1.4)
+    racc (1.7.1)
+    rack (2.2.7)
+
+PLATFORMS
+  x86_64-linux
+
+DEPENDENCIES
+  nokogiri
+  rack (~> 2.2)
+
+BUNDLED WITH
+   2.4.10
+`,
+			want: []Package{
+				{RubyGems, "nokogiri", "1.15.2"},
+				{RubyGems, "racc", "1.7.1"},
+				{RubyGems, "rack", "2.2.7"},
+			},
+		},
+		{
+			name:     "composer.lock",
+			filename: "composer.lock",
+			read:     ComposerLock,
+			content: `{
+  "packages": [
+    {"name": "guzzlehttp/guzzle", "version": "7.5.0"},
+    {"name": "symfony/http-kernel", "version": "v6.3.1"},
+    {"name": "acme/internal", "version": "dev-main"}
+  ],
+  "packages-dev": [
+    {"name": "phpunit/phpunit", "version": "10.2.2"}
+  ]
+}`,
+			want: []Package{
+				{Packagist, "guzzlehttp/guzzle", "7.5.0"},
+				{Packagist, "symfony/http-kernel", "6.3.1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.filename)
+			writeFile(t, path, tc.content)
+
+			got, err := tc.read(path)()
+			if err != nil {
+				t.Fatalf("reading %s got error: %v", tc.filename, err)
+			}
+			if diff := cmp.Diff(tc.want, got, sortPackages); diff != "" {
+				t.Errorf("reading %s mismatch (-want +got):\n%s", tc.filename, diff)
+			}
+		})
+	}
+}
+
+func TestLockfileErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid")
+	writeFile(t, invalid, "{")
+	missing := filepath.Join(dir, "missing")
+
+	for name, deps := range map[string]Dependencies{
+		"invalid package-lock.json": NPMLockfile(invalid),
+		"invalid composer.lock":     ComposerLock(invalid),
+		"missing yarn.lock":         YarnLockfile(missing),
+		"missing pnpm-lock.yaml":    PNPMLockfile(missing),
+		"missing Gemfile.lock":      GemfileLock(missing),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := deps(); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestPythonDists(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "lib/python3.12/site-packages/Flask-2.2.2.dist-info/METADATA"), `Metadata-Version: 2.1
+Name: Flask
+Version: 2.2.2
+Summary: A simple framework for building complex web applications.
+
+Version: 0.0.0 in the description is not a header.
+`)
+	writeFile(t, filepath.Join(dir, "lib/python3.12/site-packages/flask/__init__.py"), "")
+	writeFile(t, filepath.Join(dir, "lib/python3.12/site-packages/six-1.16.0.egg-info/PKG-INFO"), "Name: six\nVersion: 1.16.0\n")
+	// A dist-info without metadata is skipped.
+	if err := os.MkdirAll(filepath.Join(dir, "lib/python3.12/site-packages/broken-1.0.dist-info"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := PythonDists(dir)()
+	if err != nil {
+		t.Fatalf("PythonDists() got error: %v", err)
+	}
+	want := []Package{{PyPI, "Flask", "2.2.2"}, {PyPI, "six", "1.16.0"}}
+	if diff := cmp.Diff(want, got, sortPackages); diff != "" {
+		t.Errorf("PythonDists() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGoBinary(t *testing.T) {
+	// The test binary is built with module information, including its dependency on go-cmp.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := GoBinary(exe)()
+	if err != nil {
+		t.Fatalf("GoBinary() got error: %v", err)
+	}
+	stdlib := Package{Go, "stdlib", strings.TrimPrefix(runtime.Version(), "go")}
+	if len(got) == 0 || got[0] != stdlib {
+		t.Errorf("GoBinary() = %v, want first package %v", got, stdlib)
+	}
+	found := false
+	for _, p := range got {
+		if p.Name == "github.com/google/go-cmp" && p.Version != "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("GoBinary() = %v, want github.com/google/go-cmp", got)
+	}
+
+	notGo := filepath.Join(t.TempDir(), "app")
+	writeFile(t, notGo, "#!/bin/sh\n")
+	if _, err := GoBinary(notGo)(); err == nil {
+		t.Error("GoBinary() got no error for a file that is not a Go binary")
+	}
+}
+
+func TestJavaArchives(t *testing.T) {
+	dir := t.TempDir()
+	var nested bytes.Buffer
+	zw := zip.NewWriter(&nested)
+	w, err := zw.Create("META-INF/maven/org.yaml/snakeyaml/pom.properties")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("#Generated by Maven\ngroupId=org.yaml\nartifactId=snakeyaml\nversion=1.33\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, filepath.Join(dir, "target", "app-1.0.jar"), map[string]string{
+		"META-INF/maven/com.example/app/pom.properties": "groupId=com.example\nartifactId=app\nversion=1.0\n",
+		"BOOT-INF/lib/snakeyaml-1.33.jar":               nested.String(),
+		"BOOT-INF/lib/not-an-archive.jar":               "text",
+		"BOOT-INF/classes/application.properties":       "server.port=8080\n",
+	})
+	writeFile(t, filepath.Join(dir, "target", "app-1.0.jar.original"), "")
+
+	got, err := JavaArchives(filepath.Join(dir, "target", "*.jar"), filepath.Join(dir, "target", "*.war"))()
+	if err != nil {
+		t.Fatalf("JavaArchives() got error: %v", err)
+	}
+	want := []Package{{Maven, "com.example:app", "1.0"}, {Maven, "org.yaml:snakeyaml", "1.33"}}
+	if diff := cmp.Diff(want, got, sortPackages); diff != "" {
+		t.Errorf("JavaArchives() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,555 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OSV ecosystem names, see https://ossf.github.io/osv-schema/#affectedpackage-field.
+const (
+	// NPM is the ecosystem of npm, Yarn and pnpm packages.
+	NPM = "npm"
+	// PyPI is the ecosystem of Python packages.
+	PyPI = "PyPI"
+	// Go is the ecosystem of Go modules.
+	Go = "Go"
+	// RubyGems is the ecosystem of Ruby gems.
+	RubyGems = "RubyGems"
+	// Packagist is the ecosystem of Composer packages.
+	Packagist = "Packagist"
+	// Maven is the ecosystem of Java artifacts, named "groupId:artifactId".
+	Maven = "Maven"
+)
+
+var ecosystems = []string{NPM, PyPI, Go, RubyGems, Packagist, Maven}
+
+// advisory is an OSV vulnerability record, see https://ossf.github.io/osv-schema/. Only the fields
+// needed to match packages are decoded.
+type advisory struct {
+	ID               string          `json:"id"`
+	Aliases          []string        `json:"aliases"`
+	Summary          string          `json:"summary"`
+	Withdrawn        string          `json:"withdrawn"`
+	Severity         []severityScore `json:"severity"`
+	Affected         []affected      `json:"affected"`
+	DatabaseSpecific severityField   `json:"database_specific"`
+}
+
+type severityScore struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// severityField holds the severity that some databases, such as GitHub's, record outside of
+// CVSS scores.
+type severityField struct {
+	Severity string `json:"severity"`
+}
+
+type affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges            []versionRange  `json:"ranges"`
+	Versions          []string        `json:"versions"`
+	Severity          []severityScore `json:"severity"`
+	EcosystemSpecific severityField   `json:"ecosystem_specific"`
+	DatabaseSpecific  severityField   `json:"database_specific"`
+}
+
+type versionRange struct {
+	Type   string       `json:"type"`
+	Events []rangeEvent `json:"events"`
+}
+
+type rangeEvent struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+// packageKey identifies a package across advisories and lockfiles.
+type packageKey struct {
+	ecosystem, name string
+}
+
+func keyOf(ecosystem, name string) packageKey {
+	switch ecosystem {
+	case PyPI:
+		// https://packaging.python.org/en/latest/specifications/name-normalization/
+		name = pypiSeparators.ReplaceAllString(strings.ToLower(name), "-")
+	case Packagist:
+		name = strings.ToLower(name)
+	}
+	return packageKey{ecosystem, name}
+}
+
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+// database holds the advisories that affect a set of packages.
+type database struct {
+	advisories map[packageKey][]*advisory
+	// files is the number of advisory files that were read.
+	files int
+}
+
+// loadDatabase reads the OSV advisories in dir that affect any of the packages. dir holds
+// advisories as .json files or as the all.zip exports of https://osv.dev, at any depth. A top-level
+// directory or export named after an ecosystem, as in the osv.dev bucket layout, is only read if
+// packages of that ecosystem are audited.
+func loadDatabase(dir string, pkgs []Package) (*database, error) {
+	wanted := map[packageKey]bool{}
+	audited := map[string]bool{}
+	for _, p := range pkgs {
+		wanted[keyOf(p.Ecosystem, p.Name)] = true
+		audited[p.Ecosystem] = true
+	}
+	db := &database{advisories: map[packageKey][]*advisory{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Dir(path) == filepath.Clean(dir) && isOtherEcosystem(d.Name(), audited) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case d.IsDir():
+			return nil
+		case strings.HasSuffix(path, ".json"):
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return db.add(f, path, wanted)
+		case strings.HasSuffix(path, ".zip"):
+			return db.addZip(path, wanted)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// isOtherEcosystem returns whether name, without a .zip extension, is an ecosystem that is not
+// audited.
+func isOtherEcosystem(name string, audited map[string]bool) bool {
+	name = strings.TrimSuffix(name, ".zip")
+	for _, e := range ecosystems {
+		if strings.EqualFold(name, e) {
+			return !audited[e]
+		}
+	}
+	return false
+}
+
+// addZip adds the advisories in a zip export.
+func (db *database) addZip(path string, wanted map[packageKey]bool) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("opening %s in %s: %w", f.Name, path, err)
+		}
+		err = db.add(r, path+"!"+f.Name, wanted)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds the advisory read from r if it affects any of the wanted packages.
+func (db *database) add(r io.Reader, name string, wanted map[packageKey]bool) error {
+	var a advisory
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return fmt.Errorf("parsing advisory %s: %w", name, err)
+	}
+	db.files++
+	if a.Withdrawn != "" {
+		return nil
+	}
+	seen := map[packageKey]bool{}
+	for _, af := range a.Affected {
+		k := keyOf(af.Package.Ecosystem, af.Package.Name)
+		if wanted[k] && !seen[k] {
+			seen[k] = true
+			db.advisories[k] = append(db.advisories[k], &a)
+		}
+	}
+	return nil
+}
+
+// Finding is a package version affected by an advisory.
+type Finding struct {
+	Package
+	// ID is the advisory ID, such as GHSA-29mw-wpgm-hmr9.
+	ID string
+	// Aliases are other IDs of the advisory, such as CVE IDs.
+	Aliases  []string
+	Summary  string
+	Severity Severity
+	// Fixed are the versions that fix the vulnerability.
+	Fixed []string
+}
+
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s %s@%s: %s (%s severity)", f.ID, f.Name, f.Version, f.Summary, f.Severity)
+	if len(f.Fixed) > 0 {
+		s += fmt.Sprintf(", fixed in %s", strings.Join(f.Fixed, ", "))
+	}
+	return s
+}
+
+// match returns the findings for the packages, most severe first.
+func (db *database) match(pkgs []Package) []Finding {
+	var findings []Finding
+	for _, p := range pkgs {
+		k := keyOf(p.Ecosystem, p.Name)
+		for _, a := range db.advisories[k] {
+			for _, af := range a.Affected {
+				if keyOf(af.Package.Ecosystem, af.Package.Name) != k || !af.affects(p.Version) {
+					continue
+				}
+				findings = append(findings, Finding{
+					Package:  p,
+					ID:       a.ID,
+					Aliases:  a.Aliases,
+					Summary:  a.Summary,
+					Severity: a.severity(af),
+					Fixed:    af.fixed(),
+				})
+				break
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		return findings[i].Name < findings[j].Name
+	})
+	return findings
+}
+
+// affects returns whether version is listed in, or within a range of, the affected package.
+func (af affected) affects(version string) bool {
+	for _, v := range af.Versions {
+		if compareVersions(v, version) == 0 {
+			return true
+		}
+	}
+	for _, r := range af.Ranges {
+		if r.Type != "GIT" && r.affects(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// affects evaluates the range events in version order, as described by
+// https://ossf.github.io/osv-schema/#evaluation.
+func (r versionRange) affects(version string) bool {
+	events := append([]rangeEvent(nil), r.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return compareVersions(events[i].version(), events[j].version()) < 0
+	})
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || compareVersions(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if compareVersions(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if compareVersions(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func (e rangeEvent) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	}
+	return e.LastAffected
+}
+
+// fixed returns the versions that fix the affected package.
+func (af affected) fixed() []string {
+	var fixed []string
+	for _, r := range af.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		for _, e := range r.Events {
+			if e.Fixed != "" {
+				fixed = append(fixed, e.Fixed)
+			}
+		}
+	}
+	return fixed
+}
+
+// severity returns the highest severity that the advisory records for the affected package.
+func (a *advisory) severity(af affected) Severity {
+	s := SeverityUnknown
+	for _, label := range []string{af.EcosystemSpecific.Severity, af.DatabaseSpecific.Severity, a.DatabaseSpecific.Severity} {
+		if v, err := ParseSeverity(label); err == nil && v > s {
+			s = v
+		}
+	}
+	for _, score := range append(af.Severity, a.Severity...) {
+		if v, err := score.severity(); err == nil && v > s {
+			s = v
+		}
+	}
+	return s
+}
+
+// severity returns the qualitative rating of the score. CVSS v2 has no critical rating, so v2
+// scores are at most high.
+func (s severityScore) severity() (Severity, error) {
+	switch s.Type {
+	case "CVSS_V2":
+		base, err := cvss2BaseScore(s.Score)
+		if err != nil {
+			return SeverityUnknown, err
+		}
+		if sev := severityOfScore(base); sev < SeverityHigh {
+			return sev, nil
+		}
+		return SeverityHigh, nil
+	case "CVSS_V3":
+		base, err := cvss3BaseScore(s.Score)
+		if err != nil {
+			return SeverityUnknown, err
+		}
+		return severityOfScore(base), nil
+	case "CVSS_V4":
+		base, err := cvss4BaseScore(s.Score)
+		if err != nil {
+			return SeverityUnknown, err
+		}
+		return severityOfScore(base), nil
+	}
+	return SeverityUnknown, fmt.Errorf("unsupported severity type %q", s.Type)
+}
+
+// severityOfScore returns the qualitative rating of a CVSS score.
+func severityOfScore(score float64) Severity {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityModerate
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// cvss3Weights are the weights of the CVSS v3 base metrics, see
+// https://www.first.org/cvss/v3.1/specification-document#7-4-Metric-Values.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore returns the base score of a CVSS v3 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func cvss3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, fmt.Errorf("not a CVSS v3 vector: %q", vector)
+	}
+	return cvss3Score(cvssMetrics(parts[1:]), vector)
+}
+
+// cvssMetrics returns the values of the metrics in the parts of a CVSS vector, such as "AV:N".
+func cvssMetrics(parts []string) map[string]string {
+	metrics := map[string]string{}
+	for _, p := range parts {
+		if k, v, ok := strings.Cut(p, ":"); ok {
+			metrics[k] = v
+		}
+	}
+	return metrics
+}
+
+// cvss3Score returns the base score of the CVSS v3 base metrics of vector.
+func cvss3Score(metrics map[string]string, vector string) (float64, error) {
+	scopeChanged := metrics["S"] == "C"
+	if !scopeChanged && metrics["S"] != "U" {
+		return 0, fmt.Errorf("missing scope in CVSS vector %q", vector)
+	}
+	w := map[string]float64{}
+	for m, values := range cvss3Weights {
+		v, ok := values[metrics[m]]
+		if !ok {
+			return 0, fmt.Errorf("missing or invalid %s in CVSS vector %q", m, vector)
+		}
+		w[m] = v
+	}
+	if scopeChanged {
+		// Privileges carry more weight when the scope changes.
+		w["PR"] = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}[metrics["PR"]]
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if scopeChanged {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if scopeChanged {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp rounds up to one decimal place as defined by CVSS v3.1, avoiding floating point errors.
+func roundUp(x float64) float64 {
+	i := int(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return (math.Floor(float64(i)/10000) + 1) / 10
+}
+
+// cvss2Weights are the weights of the CVSS v2 base metrics, see
+// https://www.first.org/cvss/v2/guide#3-2-1-Base-Equation.
+var cvss2Weights = map[string]map[string]float64{
+	"AV": {"L": 0.395, "A": 0.646, "N": 1},
+	"AC": {"H": 0.35, "M": 0.61, "L": 0.71},
+	"Au": {"M": 0.45, "S": 0.56, "N": 0.704},
+	"C":  {"N": 0, "P": 0.275, "C": 0.66},
+	"I":  {"N": 0, "P": 0.275, "C": 0.66},
+	"A":  {"N": 0, "P": 0.275, "C": 0.66},
+}
+
+// cvss2BaseScore returns the base score of a CVSS v2 vector such as "AV:N/AC:L/Au:N/C:P/I:P/A:P".
+func cvss2BaseScore(vector string) (float64, error) {
+	trimmed := strings.TrimPrefix(strings.Trim(vector, "()"), "CVSS:2.0/")
+	metrics := cvssMetrics(strings.Split(trimmed, "/"))
+	w := map[string]float64{}
+	for m, values := range cvss2Weights {
+		v, ok := values[metrics[m]]
+		if !ok {
+			return 0, fmt.Errorf("missing or invalid %s in CVSS v2 vector %q", m, vector)
+		}
+		w[m] = v
+	}
+
+	impact := 10.41 * (1 - (1-w["C"])*(1-w["I"])*(1-w["A"]))
+	if impact == 0 {
+		return 0, nil
+	}
+	exploitability := 20 * w["AV"] * w["AC"] * w["Au"]
+	score := (0.6*impact + 0.4*exploitability - 1.5) * 1.176
+	return math.Min(math.Round(score*10)/10, 10), nil
+}
+
+// cvss4BaseScore returns an approximate base score of a CVSS v4 vector such as
+// "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N". The exact v4 score comes from
+// a lookup table of every combination of metrics; instead the base metrics are mapped onto their
+// v3 equivalents and scored with the v3 equations, which gives the same qualitative rating for
+// most vectors:
+//   - attack requirements (AT:P) make the attack complexity high;
+//   - passive and active user interaction are both required interaction;
+//   - an impact on subsequent systems changes the scope, and the impact is the higher of the
+//     impacts on the vulnerable and subsequent systems.
+func cvss4BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:4") {
+		return 0, fmt.Errorf("not a CVSS v4 vector: %q", vector)
+	}
+	v4 := cvssMetrics(parts[1:])
+	v3 := map[string]string{
+		"AV": v4["AV"],
+		"AC": v4["AC"],
+		"PR": v4["PR"],
+		"UI": v4["UI"],
+		"S":  "U",
+	}
+	if v4["AT"] == "P" {
+		v3["AC"] = "H"
+	}
+	if v4["UI"] == "P" || v4["UI"] == "A" {
+		v3["UI"] = "R"
+	}
+	rank := map[string]int{"N": 0, "L": 1, "H": 2}
+	for _, m := range []string{"C", "I", "A"} {
+		vulnerable, subsequent := v4["V"+m], v4["S"+m]
+		if _, ok := rank[vulnerable]; !ok {
+			return 0, fmt.Errorf("missing or invalid V%s in CVSS vector %q", m, vector)
+		}
+		if _, ok := rank[subsequent]; !ok {
+			return 0, fmt.Errorf("missing or invalid S%s in CVSS vector %q", m, vector)
+		}
+		v3[m] = vulnerable
+		if subsequent != "N" {
+			v3["S"] = "C"
+		}
+		if rank[subsequent] > rank[vulnerable] {
+			v3[m] = subsequent
+		}
+	}
+	return cvss3Score(v3, vector)
+}
@@ -0,0 +1,340 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.0.0", b: "1.0.0", want: 0},
+		{a: "1.0", b: "1.0.0", want: 0},
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2.3+build.5", b: "1.2.3", want: 0},
+		{a: "1.0.0.Final", b: "1.0.0", want: 0},
+		{a: "1.2.3", b: "1.2.4", want: -1},
+		{a: "1.10.0", b: "1.9.0", want: 1},
+		{a: "2.0.0", b: "10.0.0", want: -1},
+		{a: "1.0.0-rc1", b: "1.0.0", want: -1},
+		{a: "1.0.0-alpha", b: "1.0.0-beta", want: -1},
+		{a: "1.0.0-rc.1", b: "1.0.0-rc.2", want: -1},
+		{a: "1.0.0a1", b: "1.0.0", want: -1},
+		{a: "1.0.post1", b: "1.0", want: 1},
+		{a: "1.0-sp1", b: "1.0.1", want: -1},
+		{a: "0.0.0-20230101000000-abcdef123456", b: "0.1.0", want: -1},
+		{a: "5.2.8.1", b: "5.2.8", want: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			if got := compareVersions(tc.a, tc.b); got != tc.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+			if got := compareVersions(tc.b, tc.a); got != -tc.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+			}
+		})
+	}
+}
+
+func TestAffects(t *testing.T) {
+	ranges := []versionRange{{
+		Type: "ECOSYSTEM",
+		Events: []rangeEvent{
+			{Introduced: "0"},
+			{Fixed: "1.2.0"},
+			{Introduced: "2.0.0"},
+			{LastAffected: "2.3.1"},
+		},
+	}}
+	testCases := []struct {
+		name     string
+		affected affected
+		version  string
+		want     bool
+	}{
+		{name: "first range", affected: affected{Ranges: ranges}, version: "1.1.9", want: true},
+		{name: "fixed", affected: affected{Ranges: ranges}, version: "1.2.0", want: false},
+		{name: "between ranges", affected: affected{Ranges: ranges}, version: "1.9.0", want: false},
+		{name: "second range", affected: affected{Ranges: ranges}, version: "2.0.0", want: true},
+		{name: "last affected", affected: affected{Ranges: ranges}, version: "2.3.1", want: true},
+		{name: "after last affected", affected: affected{Ranges: ranges}, version: "2.3.2", want: false},
+		{name: "listed version", affected: affected{Versions: []string{"3.0.0"}}, version: "3.0.0", want: true},
+		{name: "unlisted version", affected: affected{Versions: []string{"3.0.0"}}, version: "3.0.1", want: false},
+		{
+			name:     "git ranges are ignored",
+			affected: affected{Ranges: []versionRange{{Type: "GIT", Events: []rangeEvent{{Introduced: "0"}}}}},
+			version:  "1.0.0",
+			want:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.affected.affects(tc.version); got != tc.want {
+				t.Errorf("affects(%q) = %v, want %v", tc.version, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	testCases := []struct {
+		vector    string
+		want      float64
+		wantError bool
+	}{
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", want: 9.8},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", want: 10},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", want: 6.1},
+		{vector: "CVSS:3.0/AV:L/AC:H/PR:L/UI:N/S:U/C:N/I:N/A:L", want: 2.5},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", want: 0},
+		{vector: "CVSS:2.0/AV:N/AC:L/Au:N/C:P/I:P/A:P", wantError: true},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/C:H/I:H/A:H", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.vector, func(t *testing.T) {
+			got, err := cvss3BaseScore(tc.vector)
+			if gotErr := err != nil; gotErr != tc.wantError {
+				t.Fatalf("cvss3BaseScore(%q) got error: %v, want error: %v", tc.vector, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("cvss3BaseScore(%q) = %v, want %v", tc.vector, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCVSS2BaseScore(t *testing.T) {
+	testCases := []struct {
+		vector    string
+		want      float64
+		wantError bool
+	}{
+		{vector: "AV:N/AC:L/Au:N/C:P/I:P/A:P", want: 7.5},
+		{vector: "AV:N/AC:L/Au:N/C:C/I:C/A:C", want: 10},
+		{vector: "AV:N/AC:M/Au:N/C:N/I:P/A:N", want: 4.3},
+		{vector: "(AV:L/AC:L/Au:N/C:P/I:N/A:N)", want: 2.1},
+		{vector: "AV:N/AC:L/Au:N/C:N/I:N/A:N", want: 0},
+		{vector: "AV:N/AC:L/C:P/I:P/A:P", wantError: true},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.vector, func(t *testing.T) {
+			got, err := cvss2BaseScore(tc.vector)
+			if gotErr := err != nil; gotErr != tc.wantError {
+				t.Fatalf("cvss2BaseScore(%q) got error: %v, want error: %v", tc.vector, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("cvss2BaseScore(%q) = %v, want %v", tc.vector, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCVSS4BaseScore(t *testing.T) {
+	testCases := []struct {
+		vector    string
+		want      float64
+		wantError bool
+	}{
+		{vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", want: 9.8},
+		{vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:L/VI:N/VA:N/SC:N/SI:N/SA:N", want: 5.3},
+		{vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:N/SC:H/SI:N/SA:N", want: 8.6},
+		{vector: "CVSS:4.0/AV:N/AC:L/AT:P/PR:N/UI:A/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N/E:P", want: 7.5},
+		{vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:N/SC:N/SI:N/SA:N", want: 0},
+		{vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H", wantError: true},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.vector, func(t *testing.T) {
+			got, err := cvss4BaseScore(tc.vector)
+			if gotErr := err != nil; gotErr != tc.wantError {
+				t.Fatalf("cvss4BaseScore(%q) got error: %v, want error: %v", tc.vector, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("cvss4BaseScore(%q) = %v, want %v", tc.vector, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdvisorySeverity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		advisory advisory
+		want     Severity
+	}{
+		{
+			name:     "none",
+			advisory: advisory{Affected: []affected{{}}},
+			want:     SeverityUnknown,
+		},
+		{
+			name: "ecosystem specific",
+			advisory: advisory{Affected: []affected{{
+				EcosystemSpecific: severityField{Severity: "MEDIUM"},
+			}}},
+			want: SeverityModerate,
+		},
+		{
+			name: "highest of label and score",
+			advisory: advisory{
+				DatabaseSpecific: severityField{Severity: "LOW"},
+				Severity:         []severityScore{{Type: "CVSS_V3", Score: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}},
+				Affected:         []affected{{}},
+			},
+			want: SeverityCritical,
+		},
+		{
+			name: "cvss v2 is at most high",
+			advisory: advisory{
+				Severity: []severityScore{{Type: "CVSS_V2", Score: "AV:N/AC:L/Au:N/C:C/I:C/A:C"}},
+				Affected: []affected{{}},
+			},
+			want: SeverityHigh,
+		},
+		{
+			name: "cvss v4",
+			advisory: advisory{Affected: []affected{{
+				Severity: []severityScore{{Type: "CVSS_V4", Score: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:L/VI:N/VA:N/SC:N/SI:N/SA:N"}},
+			}}},
+			want: SeverityModerate,
+		},
+		{
+			name: "unsupported score",
+			advisory: advisory{
+				Severity: []severityScore{{Type: "Ubuntu", Score: "high"}},
+				Affected: []affected{{}},
+			},
+			want: SeverityUnknown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.advisory.severity(tc.advisory.Affected[0]); got != tc.want {
+				t.Errorf("severity() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "npm", "GHSA-35jh-r3h4-6jhm.json"), lodashAdvisory)
+	writeFile(t, filepath.Join(dir, "withdrawn.json"), `{
+  "id": "GHSA-withdrawn",
+  "withdrawn": "2024-01-01T00:00:00Z",
+  "affected": [{"package": {"ecosystem": "npm", "name": "lodash"}, "versions": ["4.17.20"]}]
+}`)
+	writeZip(t, filepath.Join(dir, "PyPI", "all.zip"), map[string]string{
+		"PYSEC-2023-74.json": `{
+  "id": "PYSEC-2023-74",
+  "summary": "Flask session cookie disclosure",
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "flask"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.2.5"}]}]
+  }]
+}`,
+	})
+	// Advisories of ecosystems that are not audited are not read.
+	writeFile(t, filepath.Join(dir, "Maven", "invalid.json"), "{")
+
+	pkgs := []Package{{NPM, "lodash", "4.17.20"}, {PyPI, "Flask", "2.2.2"}}
+	db, err := loadDatabase(dir, pkgs)
+	if err != nil {
+		t.Fatalf("loadDatabase() got error: %v", err)
+	}
+	var got []string
+	for _, f := range db.match(pkgs) {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"GHSA-35jh-r3h4-6jhm lodash@4.17.20: Command Injection in lodash (HIGH severity), fixed in 4.17.21",
+		"PYSEC-2023-74 Flask@2.2.2: Flask session cookie disclosure (UNKNOWN severity), fixed in 2.2.5",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("match() mismatch (-want +got):\n%s", diff)
+	}
+	if db.files != 3 {
+		t.Errorf("loadDatabase() read %d advisories, want 3", db.files)
+	}
+}
+
+func TestLoadDatabaseInvalidAdvisory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "advisory.json"), "{")
+	if _, err := loadDatabase(dir, []Package{{NPM, "lodash", "4.17.20"}}); err == nil {
+		t.Error("loadDatabase() got no error for an invalid advisory")
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	testCases := []struct {
+		ecosystem, name string
+		want            packageKey
+	}{
+		{ecosystem: PyPI, name: "Zope.Interface", want: packageKey{PyPI, "zope-interface"}},
+		{ecosystem: PyPI, name: "typing__extensions", want: packageKey{PyPI, "typing-extensions"}},
+		{ecosystem: Packagist, name: "Symfony/HTTP-Kernel", want: packageKey{Packagist, "symfony/http-kernel"}},
+		{ecosystem: NPM, name: "JSONStream", want: packageKey{NPM, "JSONStream"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := keyOf(tc.ecosystem, tc.name); got != tc.want {
+				t.Errorf("keyOf(%q, %q) = %v, want %v", tc.ecosystem, tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// postReleaseTags are the alphabetic version segments that order after the release they follow,
+// such as 1.0.post1 in PyPI or 1.0-sp1 in Maven. Other alphabetic segments are pre-releases.
+var postReleaseTags = map[string]bool{"post": true, "p": true, "pl": true, "patch": true, "rev": true, "r": true, "sp": true}
+
+// releaseTags are the alphabetic version segments that mean the release itself, as in Maven.
+var releaseTags = map[string]bool{"final": true, "ga": true, "release": true}
+
+// compareVersions compares two versions, returning -1, 0 or 1. It approximates the ordering of
+// every ecosystem with a single scheme: versions are split into numeric and alphabetic segments,
+// numeric segments compare as numbers, missing numeric segments are zero, and an alphabetic segment
+// marks a pre-release (1.0.0-rc1 < 1.0.0) unless it is a post-release tag (1.0.post1 > 1.0). Build
+// metadata after "+" is ignored.
+func compareVersions(a, b string) int {
+	sa, sb := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(sa) || i < len(sb); i++ {
+		var x, y string
+		if i < len(sa) {
+			x = sa[i]
+		}
+		if i < len(sb) {
+			y = sb[i]
+		}
+		if c := compareSegments(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareSegments compares two version segments, either of which may be missing ("").
+func compareSegments(x, y string) int {
+	if x == y {
+		return 0
+	}
+	rx, ry := segmentRank(x), segmentRank(y)
+	if rx != ry {
+		if rx < ry {
+			return -1
+		}
+		return 1
+	}
+	if rx == 1 {
+		return 0
+	}
+	if isNumeric(x) && isNumeric(y) {
+		nx, ny := numericValue(x), numericValue(y)
+		switch {
+		case nx < ny:
+			return -1
+		case nx > ny:
+			return 1
+		}
+		return 0
+	}
+	if x < y {
+		return -1
+	}
+	return 1
+}
+
+// segmentRank orders the kinds of version segment: pre-release tags, then a missing segment or a
+// release tag, then post-release tags, then numbers. A missing segment and a zero are equal.
+func segmentRank(s string) int {
+	switch {
+	case s == "" || releaseTags[s]:
+		return 1
+	case isNumeric(s) && numericValue(s) == 0:
+		return 1
+	case isNumeric(s):
+		return 3
+	case postReleaseTags[s]:
+		return 2
+	}
+	return 0
+}
+
+// versionSegments splits a version into lowercase numeric and alphabetic segments.
+func versionSegments(v string) []string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	var segs []string
+	start := -1
+	for i, r := range v {
+		digit, letter := unicode.IsDigit(r), unicode.IsLetter(r)
+		if start >= 0 {
+			prevDigit := unicode.IsDigit(rune(v[start]))
+			if (digit && prevDigit) || (letter && !prevDigit) {
+				continue
+			}
+			segs = append(segs, v[start:i])
+			start = -1
+		}
+		if digit || letter {
+			start = i
+		}
+	}
+	if start >= 0 {
+		segs = append(segs, v[start:])
+	}
+	// Trailing zeros and release tags do not change a version: 1.0 == 1.0.0 == 1.0.0.final.
+	for len(segs) > 0 && segmentRank(segs[len(segs)-1]) == 1 {
+		segs = segs[:len(segs)-1]
+	}
+	return segs
+}
+
+func isNumeric(s string) bool {
+	return s != "" && unicode.IsDigit(rune(s[0]))
+}
+
+// numericValue returns the value of a numeric segment, saturating on overflow.
+func numericValue(s string) uint64 {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return ^uint64(0)
+	}
+	return n
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildererror
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// KnownFailure is the signature of a recognized build tool failure. A failure that matches one is
+// reported with a stable ID, so the same root cause always has the same ID, together with a fix.
+type KnownFailure struct {
+	// ID is the stable error ID reported for the failure.
+	ID ID
+	// Tools are the executables whose output is matched, e.g. "npm". Empty matches any command.
+	Tools []string
+	// Subcommand, if set, is the first argument of the commands whose output is matched, e.g.
+	// "build".
+	Subcommand string
+	// Pattern matches the output of a failed command.
+	Pattern *regexp.Regexp
+	// TargetGroup, if set, is the group of Pattern that names the package the failure is about. A
+	// match where the group takes part then only counts if that package is the target of the
+	// command, its last argument.
+	TargetGroup int
+	// Status is the canonical code reported for the failure.
+	Status Status
+	// Fix is a one-line description of how to resolve the failure.
+	Fix string
+	// DocsURL links to documentation about the failure.
+	DocsURL string
+}
+
+var (
+	npmTools    = []string{"npm", "npx"}
+	pythonTools = []string{"python", "python3", "pip", "pip3"}
+	mavenTools  = []string{"mvn", "mvnw"}
+	gradleTools = []string{"gradle", "gradlew"}
+)
+
+// knownFailures is the catalog of known failures. Entries are matched in order, so more specific
+// patterns must come before more general ones for the same tool.
+var knownFailures = []KnownFailure{
+	{
+		ID:      "npm-no-matching-version",
+		Tools:   npmTools,
+		Pattern: regexp.MustCompile(`No matching version found for \S+`),
+		Status:  StatusNotFound,
+		Fix:     "Change the version range in package.json to one that is published, then regenerate package-lock.json.",
+		DocsURL: "https://docs.npmjs.com/cli/configuring-npm/package-json#dependencies",
+	},
+	{
+		ID:      "npm-lockfile-out-of-sync",
+		Tools:   npmTools,
+		Pattern: regexp.MustCompile(`can only install packages when your package\.json and package-lock\.json (or npm-shrinkwrap\.json )?are in sync`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Run `npm install` locally and commit the updated package-lock.json.",
+		DocsURL: "https://docs.npmjs.com/cli/commands/npm-ci",
+	},
+	{
+		ID:      "npm-peer-dependency-conflict",
+		Tools:   npmTools,
+		Pattern: regexp.MustCompile(`ERESOLVE (unable to resolve dependency tree|could not resolve)`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Resolve the conflicting peer dependencies, or set legacy-peer-deps=true in .npmrc.",
+		DocsURL: "https://docs.npmjs.com/cli/using-npm/config#legacy-peer-deps",
+	},
+	{
+		ID:      "yarn-lockfile-out-of-sync",
+		Tools:   []string{"yarn"},
+		Pattern: regexp.MustCompile(`Your lockfile needs to be updated, but yarn was run with .--frozen-lockfile.|The lockfile would have been modified by this install, which is explicitly forbidden`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Run `yarn install` locally and commit the updated yarn.lock.",
+		DocsURL: "https://yarnpkg.com/cli/install",
+	},
+	{
+		ID:      "pnpm-lockfile-out-of-sync",
+		Tools:   []string{"pnpm"},
+		Pattern: regexp.MustCompile(`ERR_PNPM_OUTDATED_LOCKFILE`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Run `pnpm install` locally and commit the updated pnpm-lock.yaml.",
+		DocsURL: "https://pnpm.io/cli/install#--frozen-lockfile",
+	},
+	{
+		ID:      "pip-no-matching-distribution",
+		Tools:   pythonTools,
+		Pattern: regexp.MustCompile(`No matching distribution found for \S+`),
+		Status:  StatusNotFound,
+		Fix:     "Check the package name and version in requirements.txt, and that the package supports the Python version being used.",
+		DocsURL: "https://pip.pypa.io/en/stable/reference/requirements-file-format/",
+	},
+	{
+		ID:      "pip-resolution-impossible",
+		Tools:   pythonTools,
+		Pattern: regexp.MustCompile(`ResolutionImpossible`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Loosen the conflicting version pins in requirements.txt so that a single version satisfies all of them.",
+		DocsURL: "https://pip.pypa.io/en/stable/topics/dependency-resolution/",
+	},
+	{
+		ID:      "python-module-not-found",
+		Tools:   pythonTools,
+		Pattern: regexp.MustCompile(`ModuleNotFoundError: No module named '[^']+'`),
+		Status:  StatusNotFound,
+		Fix:     "Add the package that provides the missing module to requirements.txt.",
+		DocsURL: "https://pip.pypa.io/en/stable/reference/requirements-file-format/",
+	},
+	{
+		ID:      "maven-unresolved-dependencies",
+		Tools:   mavenTools,
+		Pattern: regexp.MustCompile(`Could not resolve dependencies for project`),
+		Status:  StatusNotFound,
+		Fix:     "Check that the dependency coordinates in pom.xml exist and that any private repositories are declared.",
+		DocsURL: "https://maven.apache.org/guides/introduction/introduction-to-repositories.html",
+	},
+	{
+		ID:      "maven-unsupported-java-release",
+		Tools:   mavenTools,
+		Pattern: regexp.MustCompile(`invalid target release: \S+|release version \S+ not supported|Source option \S+ is no longer supported`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Set GOOGLE_RUNTIME_VERSION to a JDK that supports the Java release configured in pom.xml.",
+		DocsURL: "https://cloud.google.com/docs/buildpacks/java",
+	},
+	{
+		ID:      "gradle-unresolved-dependencies",
+		Tools:   gradleTools,
+		Pattern: regexp.MustCompile(`Could not resolve all (files|dependencies|artifacts) for configuration`),
+		Status:  StatusNotFound,
+		Fix:     "Check that the dependency coordinates exist and that any private repositories are declared in the build script.",
+		DocsURL: "https://docs.gradle.org/current/userguide/declaring_repositories.html",
+	},
+	{
+		ID:         "go-no-buildable-package",
+		Tools:      []string{"go"},
+		Subcommand: "build",
+		// A dependency that cannot be found has the same message as the build target, so only the
+		// latter is matched.
+		Pattern:     regexp.MustCompile(`no Go files in |(?:cannot find module providing package|no required module provides package) ([^\s;:]+)`),
+		TargetGroup: 1,
+		Status:      StatusFailedPrecondition,
+		Fix:         "Set GOOGLE_BUILDABLE to the Go package that contains the main function, e.g. ./cmd/server.",
+		DocsURL:     "https://cloud.google.com/docs/buildpacks/go",
+	},
+	{
+		ID:      "go-missing-go-sum-entry",
+		Tools:   []string{"go"},
+		Pattern: regexp.MustCompile(`missing go\.sum entry`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Run `go mod tidy` locally and commit the updated go.sum.",
+		DocsURL: "https://go.dev/ref/mod#go-mod-tidy",
+	},
+	{
+		ID:      "dotnet-package-not-found",
+		Tools:   []string{"dotnet"},
+		Pattern: regexp.MustCompile(`error NU1101: Unable to find package`),
+		Status:  StatusNotFound,
+		Fix:     "Check the package ID in the project file and that its package source is listed in nuget.config.",
+		DocsURL: "https://learn.microsoft.com/nuget/reference/errors-and-warnings/nu1101",
+	},
+	{
+		ID:      "composer-unresolvable-requirements",
+		Tools:   []string{"composer"},
+		Pattern: regexp.MustCompile(`Your requirements could not be resolved to an installable set of packages`),
+		Status:  StatusFailedPrecondition,
+		Fix:     "Adjust the version constraints in composer.json, then run `composer update` and commit composer.lock.",
+		DocsURL: "https://getcomposer.org/doc/articles/troubleshooting.md",
+	},
+	{
+		ID:      "bundler-gem-not-found",
+		Tools:   []string{"bundle", "bundler"},
+		Pattern: regexp.MustCompile(`Could not find gem '[^']+'`),
+		Status:  StatusNotFound,
+		Fix:     "Check the gem name and version in the Gemfile, then run `bundle install` and commit Gemfile.lock.",
+		DocsURL: "https://bundler.io/guides/troubleshooting.html",
+	},
+}
+
+// MatchKnownFailure returns the first known failure that matches the command and its output, or nil
+// if the failure is not recognized.
+func MatchKnownFailure(cmd []string, output string) *KnownFailure {
+	fields := commandFields(cmd)
+	if len(fields) == 0 {
+		return nil
+	}
+	for i := range knownFailures {
+		kf := &knownFailures[i]
+		if len(kf.Tools) > 0 && !containsTool(kf.Tools, fields[0]) {
+			continue
+		}
+		if kf.Subcommand != "" && (len(fields) < 2 || fields[1] != kf.Subcommand) {
+			continue
+		}
+		if kf.matches(output, fields[len(fields)-1]) {
+			return kf
+		}
+	}
+	return nil
+}
+
+// matches returns whether the output of a command with the given target matches the failure.
+func (kf *KnownFailure) matches(output, target string) bool {
+	if kf.TargetGroup == 0 {
+		return kf.Pattern.MatchString(output)
+	}
+	for _, m := range kf.Pattern.FindAllStringSubmatch(output, -1) {
+		if m[kf.TargetGroup] == "" || m[kf.TargetGroup] == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply reports a user-attributed error as the known failure, appending its fix to the message. It
+// returns false, leaving the error unchanged, for other errors: a known failure of a command that
+// is not the user's responsibility is still a failure of the builder.
+func (kf *KnownFailure) Apply(e *Error) bool {
+	if e.Type != StatusUnknown {
+		return false
+	}
+	e.ID = kf.ID
+	e.Status = kf.Status
+	e.Fix = kf.Fix
+	e.DocsURL = kf.DocsURL
+	e.Message = strings.TrimRight(e.Message, "\n") + "\n" + kf.Remediation()
+	return true
+}
+
+// Remediation returns the one-line fix and documentation link for the failure.
+func (kf *KnownFailure) Remediation() string {
+	return kf.Fix + " See " + kf.DocsURL
+}
+
+// commandFields returns the executable name and arguments of a command, looking through "bash -c"
+// wrappers.
+func commandFields(cmd []string) []string {
+	if len(cmd) == 0 {
+		return nil
+	}
+	fields := append([]string{filepath.Base(cmd[0])}, cmd[1:]...)
+	if (fields[0] == "bash" || fields[0] == "sh") && len(cmd) > 2 && cmd[1] == "-c" {
+		if script := strings.Fields(cmd[2]); len(script) > 0 {
+			fields = append([]string{filepath.Base(script[0])}, script[1:]...)
+		}
+	}
+	return fields
+}
+
+func containsTool(tools []string, tool string) bool {
+	for _, t := range tools {
+		if t == tool {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildererror
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMatchKnownFailure(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cmd    []string
+		output string
+		want   ID
+	}{
+		{
+			name:   "npm no matching version",
+			cmd:    []string{"npm", "ci"},
+			output: "npm ERR! code ETARGET\nnpm ERR! notarget No matching version found for left-pad@^9.9.9.",
+			want:   "npm-no-matching-version",
+		},
+		{
+			name:   "npm lockfile out of sync",
+			cmd:    []string{"npm", "ci", "--quiet"},
+			output: "npm ERR! `npm ci` can only install packages when your package.json and package-lock.json or npm-shrinkwrap.json are in sync.",
+			want:   "npm-lockfile-out-of-sync",
+		},
+		{
+			name:   "npm peer dependency conflict",
+			cmd:    []string{"npm", "install"},
+			output: "npm ERR! code ERESOLVE\nnpm ERR! ERESOLVE unable to resolve dependency tree",
+			want:   "npm-peer-dependency-conflict",
+		},
+		{
+			name:   "yarn classic frozen lockfile",
+			cmd:    []string{"yarn", "install", "--frozen-lockfile"},
+			output: "error Your lockfile needs to be updated, but yarn was run with `--frozen-lockfile`.",
+			want:   "yarn-lockfile-out-of-sync",
+		},
+		{
+			name:   "yarn berry immutable",
+			cmd:    []string{"yarn", "install", "--immutable"},
+			output: "YN0028: The lockfile would have been modified by this install, which is explicitly forbidden.",
+			want:   "yarn-lockfile-out-of-sync",
+		},
+		{
+			name:   "pnpm outdated lockfile",
+			cmd:    []string{"pnpm", "install", "--frozen-lockfile"},
+			output: " ERR_PNPM_OUTDATED_LOCKFILE  Cannot install with \"frozen-lockfile\" because pnpm-lock.yaml is not up to date with package.json",
+			want:   "pnpm-lockfile-out-of-sync",
+		},
+		{
+			name:   "pip no matching distribution",
+			cmd:    []string{"python3", "-m", "pip", "install", "-r", "requirements.txt"},
+			output: "ERROR: Could not find a version that satisfies the requirement flask==99.0 (from versions: 0.1)\nERROR: No matching distribution found for flask==99.0",
+			want:   "pip-no-matching-distribution",
+		},
+		{
+			name:   "pip resolution impossible",
+			cmd:    []string{"python3", "-m", "pip", "install"},
+			output: "ERROR: Cannot install a==1.0 and b==2.0 because these package versions have conflicting dependencies.\nERROR: ResolutionImpossible: for help visit https://pip.pypa.io/en/latest/topics/dependency-resolution/",
+			want:   "pip-resolution-impossible",
+		},
+		{
+			name:   "python module not found",
+			cmd:    []string{"python3", "manage.py", "collectstatic"},
+			output: "Traceback (most recent call last):\nModuleNotFoundError: No module named 'whitenoise'",
+			want:   "python-module-not-found",
+		},
+		{
+			name:   "maven unresolved dependencies",
+			cmd:    []string{"./mvnw", "clean", "package"},
+			output: "[ERROR] Failed to execute goal on project app: Could not resolve dependencies for project com.example:app:jar:1.0",
+			want:   "maven-unresolved-dependencies",
+		},
+		{
+			name:   "maven unsupported release",
+			cmd:    []string{"mvn", "clean", "package"},
+			output: "[ERROR] Failed to execute goal org.apache.maven.plugins:maven-compiler-plugin:3.11.0:compile: Fatal error compiling: error: invalid target release: 21",
+			want:   "maven-unsupported-java-release",
+		},
+		{
+			name:   "gradle unresolved dependencies",
+			cmd:    []string{"./gradlew", "clean", "assemble"},
+			output: "> Could not resolve all files for configuration ':compileClasspath'.",
+			want:   "gradle-unresolved-dependencies",
+		},
+		{
+			name:   "go no buildable package",
+			cmd:    []string{"go", "build", "-o", "/layers/bin/main", "."},
+			output: "no Go files in /workspace",
+			want:   "go-no-buildable-package",
+		},
+		{
+			name:   "go build target not found",
+			cmd:    []string{"go", "build", "-o", "/layers/bin/main", "example.com/app/cmd/server"},
+			output: "no required module provides package example.com/app/cmd/server; to add it:\n\tgo get example.com/app/cmd/server",
+			want:   "go-no-buildable-package",
+		},
+		{
+			name:   "go build dependency not found",
+			cmd:    []string{"go", "build", "-o", "/layers/bin/main", "."},
+			output: "main.go:5:2: cannot find module providing package github.com/example/missing: module lookup disabled by GOPROXY=off",
+		},
+		{
+			name:   "go test target not found",
+			cmd:    []string{"go", "test", "./..."},
+			output: "no Go files in /workspace",
+		},
+		{
+			name:   "go missing go.sum entry",
+			cmd:    []string{"go", "build", "."},
+			output: "main.go:4:2: missing go.sum entry for module providing package github.com/google/uuid",
+			want:   "go-missing-go-sum-entry",
+		},
+		{
+			name:   "dotnet package not found",
+			cmd:    []string{"dotnet", "restore", "app.csproj"},
+			output: "/workspace/app.csproj : error NU1101: Unable to find package Foo.Bar. No packages exist with this id in source(s): nuget.org",
+			want:   "dotnet-package-not-found",
+		},
+		{
+			name:   "composer unresolvable",
+			cmd:    []string{"composer", "install"},
+			output: "Your requirements could not be resolved to an installable set of packages.",
+			want:   "composer-unresolvable-requirements",
+		},
+		{
+			name:   "bundler gem not found",
+			cmd:    []string{"bundle", "install"},
+			output: "Could not find gem 'railz' in rubygems repository https://rubygems.org/ or installed locally.",
+			want:   "bundler-gem-not-found",
+		},
+		{
+			name:   "tool wrapped in bash",
+			cmd:    []string{"/bin/bash", "-c", "npm ci && npm run build"},
+			output: "npm ERR! notarget No matching version found for left-pad@^9.9.9.",
+			want:   "npm-no-matching-version",
+		},
+		{
+			name:   "pattern from a different tool",
+			cmd:    []string{"yarn", "install"},
+			output: "No matching version found for left-pad@^9.9.9.",
+		},
+		{
+			name:   "unknown failure",
+			cmd:    []string{"npm", "ci"},
+			output: "npm ERR! code E500",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got ID
+			if kf := MatchKnownFailure(tc.cmd, tc.output); kf != nil {
+				got = kf.ID
+			}
+			if got != tc.want {
+				t.Errorf("MatchKnownFailure(%q) = %q, want %q", tc.cmd, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKnownFailuresAreValid(t *testing.T) {
+	seen := make(map[ID]bool)
+	for _, kf := range knownFailures {
+		if kf.ID == "" || strings.ToLower(string(kf.ID)) != string(kf.ID) || strings.ContainsAny(string(kf.ID), " _") {
+			t.Errorf("known failure ID %q must be a non-empty lowercase slug", kf.ID)
+		}
+		if seen[kf.ID] {
+			t.Errorf("known failure ID %q is not unique", kf.ID)
+		}
+		seen[kf.ID] = true
+		if kf.Pattern == nil {
+			t.Errorf("known failure %q has no pattern", kf.ID)
+		}
+		if kf.Status == StatusOk || kf.Status == StatusInternal {
+			t.Errorf("known failure %q has status %v, want a user-facing failure status", kf.ID, kf.Status)
+		}
+		if kf.Fix == "" || strings.Contains(kf.Fix, "\n") {
+			t.Errorf("known failure %q must have a one-line fix, got %q", kf.ID, kf.Fix)
+		}
+		if u, err := url.Parse(kf.DocsURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			t.Errorf("known failure %q has invalid docs URL %q", kf.ID, kf.DocsURL)
+		}
+	}
+}
+
+func TestKnownFailureApply(t *testing.T) {
+	e := UserErrorf("npm ERR! notarget No matching version found for left-pad@^9.9.9.\n")
+	kf := MatchKnownFailure([]string{"npm", "ci"}, e.Message)
+	if kf == nil {
+		t.Fatal("MatchKnownFailure() = nil, want a known failure")
+	}
+
+	if !kf.Apply(e) {
+		t.Error("Apply() = false, want user errors to be re-tagged")
+	}
+
+	if e.ID != "npm-no-matching-version" {
+		t.Errorf("ID = %q, want %q", e.ID, "npm-no-matching-version")
+	}
+	if e.Status != StatusNotFound {
+		t.Errorf("Status = %v, want %v", e.Status, StatusNotFound)
+	}
+	if e.Type != StatusUnknown {
+		t.Errorf("Type = %v, want the user attribution %v to be kept", e.Type, StatusUnknown)
+	}
+	want := "npm ERR! notarget No matching version found for left-pad@^9.9.9.\n" + kf.Fix + " See " + kf.DocsURL
+	if e.Message != want {
+		t.Errorf("Message = %q, want %q", e.Message, want)
+	}
+	if e.Fix != kf.Fix || e.DocsURL != kf.DocsURL {
+		t.Errorf("Fix, DocsURL = %q, %q, want %q, %q", e.Fix, e.DocsURL, kf.Fix, kf.DocsURL)
+	}
+}
+
+func TestKnownFailureApplyInternalError(t *testing.T) {
+	e := Errorf(StatusInternal, "npm ERR! notarget No matching version found for left-pad@^9.9.9.\n")
+	kf := MatchKnownFailure([]string{"npm", "ci"}, e.Message)
+	if kf == nil {
+		t.Fatal("MatchKnownFailure() = nil, want a known failure")
+	}
+	want := *e
+
+	if kf.Apply(e) {
+		t.Error("Apply() = true, want internal errors to be left unchanged")
+	}
+	if *e != want {
+		t.Errorf("Apply() changed the error to %#v, want %#v", *e, want)
+	}
+}
@@ -37,6 +37,8 @@ type Error struct {
 	Status           Status `json:"canonicalCode"`
 	ID               ID     `json:"errorId"`
 	Message          string `json:"errorMessage"`
+	Fix              string `json:"fix,omitempty"`
+	DocsURL          string `json:"docsUrl,omitempty"`
 	internalError    error  `json:"-"`
 }
 
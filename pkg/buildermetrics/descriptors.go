@@ -40,6 +40,19 @@ const (
 	NpmInstallLatencyID                   MetricID = "8"
 	ComposerInstallLatencyID              MetricID = "9"
 	PipInstallLatencyID                   MetricID = "10"
+	ExecCommandsCounterID                 MetricID = "11"
+	ExecUserCPUMsCounterID                MetricID = "12"
+	ExecSystemCPUMsCounterID              MetricID = "13"
+	ExecBlockInputOpsCounterID            MetricID = "14"
+	ExecBlockOutputOpsCounterID           MetricID = "15"
+	ExecPeakRSSMBID                       MetricID = "16"
+	DownloadsCounterID                    MetricID = "17"
+	DownloadBytesCounterID                MetricID = "18"
+	DownloadRetriesCounterID              MetricID = "19"
+	DownloadMsCounterID                   MetricID = "20"
+	PackageCacheRestoredBytesCounterID    MetricID = "21"
+	PackageCacheAddedBytesCounterID       MetricID = "22"
+	AuditFindingsCounterID                MetricID = "23"
 )
 
 var (
@@ -94,5 +107,70 @@ var (
 			"pip_install_latency",
 			"The latency for executions of `pip install`",
 		),
+		ExecCommandsCounterID: newDescriptor(
+			ExecCommandsCounterID,
+			"exec_commands",
+			"The number of commands executed by buildpacks",
+		),
+		ExecUserCPUMsCounterID: newDescriptor(
+			ExecUserCPUMsCounterID,
+			"exec_user_cpu_ms",
+			"The user CPU time in milliseconds used by commands executed by buildpacks",
+		),
+		ExecSystemCPUMsCounterID: newDescriptor(
+			ExecSystemCPUMsCounterID,
+			"exec_system_cpu_ms",
+			"The system CPU time in milliseconds used by commands executed by buildpacks",
+		),
+		ExecBlockInputOpsCounterID: newDescriptor(
+			ExecBlockInputOpsCounterID,
+			"exec_block_input_ops",
+			"The number of block input operations performed by commands executed by buildpacks",
+		),
+		ExecBlockOutputOpsCounterID: newDescriptor(
+			ExecBlockOutputOpsCounterID,
+			"exec_block_output_ops",
+			"The number of block output operations performed by commands executed by buildpacks",
+		),
+		ExecPeakRSSMBID: newDescriptor(
+			ExecPeakRSSMBID,
+			"exec_peak_rss_mb",
+			"The largest maximum resident set size in MB of any command executed by buildpacks",
+		),
+		DownloadsCounterID: newDescriptor(
+			DownloadsCounterID,
+			"downloads",
+			"The number of artifacts downloaded by buildpacks",
+		),
+		DownloadBytesCounterID: newDescriptor(
+			DownloadBytesCounterID,
+			"download_bytes",
+			"The number of bytes downloaded by buildpacks",
+		),
+		DownloadRetriesCounterID: newDescriptor(
+			DownloadRetriesCounterID,
+			"download_retries",
+			"The number of requests retried or resumed while downloading artifacts",
+		),
+		DownloadMsCounterID: newDescriptor(
+			DownloadMsCounterID,
+			"download_ms",
+			"The time in milliseconds spent downloading artifacts; download_bytes / download_ms is the throughput",
+		),
+		PackageCacheRestoredBytesCounterID: newDescriptor(
+			PackageCacheRestoredBytesCounterID,
+			"package_cache_restored_bytes",
+			"The size in bytes of the package manager caches restored from an earlier build",
+		),
+		PackageCacheAddedBytesCounterID: newDescriptor(
+			PackageCacheAddedBytesCounterID,
+			"package_cache_added_bytes",
+			"The size in bytes of the files added to package manager caches by dependency installs",
+		),
+		AuditFindingsCounterID: newDescriptor(
+			AuditFindingsCounterID,
+			"audit_findings",
+			"The number of known vulnerabilities found in dependencies by the offline audit",
+		),
 	}
 )
@@ -31,6 +31,13 @@ func (f *FloatDP) Add(addend float64) {
 	f.value += addend
 }
 
+// Max raises the value of a FloatDP to v if v is larger.  Not threadsafe.
+func (f *FloatDP) Max(v float64) {
+	if v > f.value {
+		f.value = v
+	}
+}
+
 // Value retrieves the value of a FloatDP.  Not threadsafe.
 func (f *FloatDP) Value() float64 {
 	return f.value
@@ -49,3 +49,14 @@ func TestFloatDPMarshalJSON(t *testing.T) {
 		t.Errorf("got %v, want %v", string(j), string(want))
 	}
 }
+
+func TestFloatDPMax(t *testing.T) {
+	var f FloatDP
+
+	f.Max(512)
+	f.Max(128)
+
+	if got, want := f.Value(), 512.0; got != want {
+		t.Errorf("FloatDP.Max() value got %v, want %v", got, want)
+	}
+}
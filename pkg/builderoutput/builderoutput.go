@@ -44,6 +44,7 @@ func (bo BuilderOutput) JSON() ([]byte, error) {
 // BuilderOutput contains data about the outcome of a build
 type BuilderOutput struct {
 	InstalledRuntimeVersions []string                      `json:"rtVersions,omitempty"`
+	RuntimeSupport           []RuntimeSupport              `json:"rtSupport,omitempty"`
 	Metrics                  buildermetrics.BuilderMetrics `json:"metrics"`
 	Error                    buildererror.Error            `json:"error"`
 	Stats                    []BuilderStat                 `json:"stats"`
@@ -51,6 +52,16 @@ type BuilderOutput struct {
 	CustomImage              bool                          `json:"customImage"`
 }
 
+// RuntimeSupport records the lifecycle status of an installed runtime version.
+type RuntimeSupport struct {
+	Runtime string `json:"runtime"`
+	Version string `json:"version"`
+	// Status is one of "supported", "deprecated", "decommissioned" or "unknown".
+	Status string `json:"status"`
+	// DecommissionDate is the date in YYYY-MM-DD format after which builds fail, if known.
+	DecommissionDate string `json:"decommissionDate,omitempty"`
+}
+
 // New constructs a BuilderOutput and returns a pointer.
 func New() *BuilderOutput {
 	return &BuilderOutput{
@@ -110,8 +110,11 @@ func TestJSON(t *testing.T) {
 	bm.GetCounter(buildermetrics.ArNpmCredsGenCounterID).Increment(3)
 	b := BuilderOutput{
 		InstalledRuntimeVersions: []string{"6.0.6"},
-		Metrics:                  bm,
-		Error:                    buildererror.Error{Status: buildererror.StatusInternal},
+		RuntimeSupport: []RuntimeSupport{
+			{Runtime: "nodejs", Version: "18.20.4", Status: "deprecated", DecommissionDate: "2025-10-30"},
+		},
+		Metrics: bm,
+		Error:   buildererror.Error{Status: buildererror.StatusInternal},
 	}
 
 	s, err := b.JSON()
@@ -122,6 +125,9 @@ func TestJSON(t *testing.T) {
 	if want := `"rtVersions":["6.0.6"]`; !strings.Contains(string(s), want) {
 		t.Errorf("Expected string %q not found in %s", want, s)
 	}
+	if want := `"rtSupport":[{"runtime":"nodejs","version":"18.20.4","status":"deprecated","decommissionDate":"2025-10-30"}]`; !strings.Contains(string(s), want) {
+		t.Errorf("Expected string %q not found in %s", want, s)
+	}
 	if want := "INTERNAL"; !strings.Contains(string(s), want) {
 		t.Errorf("Expected string %q not found in %s", want, s)
 	}
@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// defaultPackageCacheMaxMB is the default size limit of a package manager cache layer.
+const defaultPackageCacheMaxMB = 2048
+
+// PackageCache is a package manager's own download cache, kept in a cache-only layer. Unlike the
+// dependency layers, which are cleared whenever a lockfile changes, it survives lockfile changes so
+// that the package manager only downloads the packages that changed.
+type PackageCache struct {
+	// Path is the directory the package manager should use as its cache.
+	Path string
+	name string
+	// entries are the files in the cache before the install.
+	entries map[string]int64
+}
+
+// NewPackageCache returns the package manager cache kept in the cache-only layer name. The cache is
+// cleared if it has grown past the size limit set by GOOGLE_PACKAGE_CACHE_MAX_MB.
+func NewPackageCache(ctx *gcp.Context, name string) (*PackageCache, error) {
+	maxMB, err := packageCacheMaxMB()
+	if err != nil {
+		return nil, err
+	}
+	l, err := ctx.Layer(name, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", name, err)
+	}
+	entries, size, err := cacheEntries(l.Path)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s cache: %v", name, err)
+	}
+	if size > maxMB<<20 {
+		ctx.Logf("Clearing the %s cache, which has grown to %d MB.", name, size>>20)
+		if err := ctx.ClearLayer(l); err != nil {
+			return nil, fmt.Errorf("clearing layer %q: %w", name, err)
+		}
+		entries, size = map[string]int64{}, 0
+	}
+	if size > 0 {
+		ctx.Logf("Restored %.1f MB in the %s cache.", float64(size)/(1<<20), name)
+		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.PackageCacheRestoredBytesCounterID).Increment(size)
+	}
+	return &PackageCache{Path: l.Path, name: name, entries: entries}, nil
+}
+
+// Record logs the size of the files an install added to the cache and adds it to the package cache
+// metrics. Whether the package manager used the restored files is not known, so no hit rate is
+// reported.
+func (pc *PackageCache) Record(ctx *gcp.Context) error {
+	entries, _, err := cacheEntries(pc.Path)
+	if err != nil {
+		return gcp.InternalErrorf("reading %s cache: %v", pc.name, err)
+	}
+	var added int64
+	for e, size := range entries {
+		if _, ok := pc.entries[e]; !ok {
+			added += size
+		}
+	}
+	pc.entries = entries
+	if added == 0 {
+		return nil
+	}
+	ctx.Logf("Added %.1f MB to the %s cache.", float64(added)/(1<<20), pc.name)
+	buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.PackageCacheAddedBytesCounterID).Increment(added)
+	return nil
+}
+
+// cacheEntries returns the sizes of the regular files in dir, keyed by their paths relative to dir,
+// and their total size.
+func cacheEntries(dir string) (map[string]int64, int64, error) {
+	entries := map[string]int64{}
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entries[rel] = info.Size()
+		size += info.Size()
+		return nil
+	})
+	return entries, size, err
+}
+
+// packageCacheMaxMB returns the size limit of a package manager cache in MB.
+func packageCacheMaxMB() (int64, error) {
+	v := os.Getenv(env.PackageCacheMaxMB)
+	if v == "" {
+		return defaultPackageCacheMaxMB, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, gcp.UserErrorf("invalid %s %q: want a non-negative number of MB", env.PackageCacheMaxMB, v)
+	}
+	return n, nil
+}
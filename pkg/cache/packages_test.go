@@ -0,0 +1,113 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func writeCacheFiles(t *testing.T, dir string, files map[string]int) {
+	t.Helper()
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPackageCache(t *testing.T) {
+	testCases := []struct {
+		name         string
+		maxMB        string
+		prevFiles    map[string]int
+		newFiles     map[string]int
+		wantRestored int64
+		wantAdded    int64
+		wantError    bool
+	}{
+		{
+			name:      "empty cache",
+			newFiles:  map[string]int{"a.tgz": 10, "b.tgz": 20},
+			wantAdded: 30,
+		},
+		{
+			name:         "incremental install",
+			prevFiles:    map[string]int{"content/a.tgz": 10, "content/b.tgz": 10, "content/c.tgz": 10},
+			newFiles:     map[string]int{"content/d.tgz": 15},
+			wantRestored: 30,
+			wantAdded:    15,
+		},
+		{
+			name:      "cache over size limit is cleared",
+			maxMB:     "1",
+			prevFiles: map[string]int{"a.tgz": 1 << 20, "b.tgz": 10},
+			newFiles:  map[string]int{"a.tgz": 10},
+			wantAdded: 10,
+		},
+		{
+			name:      "invalid size limit",
+			maxMB:     "-1",
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildermetrics.Reset()
+			t.Setenv(env.PackageCacheMaxMB, tc.maxMB)
+			layers := t.TempDir()
+			writeCacheFiles(t, filepath.Join(layers, "npm_cache"), tc.prevFiles)
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			pc, err := NewPackageCache(ctx, "npm_cache")
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("NewPackageCache() got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPackageCache() got error: %v", err)
+			}
+			if want := filepath.Join(layers, "npm_cache"); pc.Path != want {
+				t.Errorf("NewPackageCache().Path = %q, want %q", pc.Path, want)
+			}
+			writeCacheFiles(t, pc.Path, tc.newFiles)
+			if err := pc.Record(ctx); err != nil {
+				t.Fatalf("Record() got error: %v", err)
+			}
+
+			bm := buildermetrics.GlobalBuilderMetrics()
+			if got := bm.GetCounter(buildermetrics.PackageCacheRestoredBytesCounterID).Value(); got != tc.wantRestored {
+				t.Errorf("package cache restored bytes = %d, want %d", got, tc.wantRestored)
+			}
+			if got := bm.GetCounter(buildermetrics.PackageCacheAddedBytesCounterID).Value(); got != tc.wantAdded {
+				t.Errorf("package cache added bytes = %d, want %d", got, tc.wantAdded)
+			}
+		})
+	}
+}
@@ -27,6 +27,13 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ignorefile"
+)
+
+const (
+	// KeepFile is the name of the file, relative to the application root, listing the paths to keep
+	// when clearing source. It uses .gcloudignore syntax, including "**" globs and "!" negation.
+	KeepFile = ".clearsourceignore"
 )
 
 var (
@@ -57,20 +64,34 @@ func DetectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 // BuildFn clears the workspace while leaving exclusion patterns untouched.
-// exclusions is a list of pattern strings relative to the user application directory.
+// exclusions is a list of pattern strings relative to the user application directory, which are
+// kept in addition to the paths listed in the application's KeepFile.
+// If GOOGLE_CLEAR_SOURCE_DRY_RUN is set, the paths are only logged.
 func BuildFn(ctx *gcp.Context, exclusions []string) error {
-	ctx.Logf("Clearing source")
+	dryRun, err := env.IsPresentAndTrue(env.ClearSourceDryRun)
+	if err != nil {
+		return gcp.UserErrorf("parsing %q: %v", env.ClearSourceDryRun, err)
+	}
+	if dryRun {
+		ctx.Logf("Clearing source (dry run)")
+	} else {
+		ctx.Logf("Clearing source")
+	}
 
 	defer func(now time.Time) {
 		ctx.Span("Clear source", now, buildererror.StatusOk)
 	}(time.Now())
 
-	exclusions = append(exclusions, defaultExclusions...)
-	paths, err := pathsToRemove(ctx, ctx.ApplicationRoot(), exclusions)
+	paths, err := pathsToRemove(ctx.ApplicationRoot(), exclusions)
 	if err != nil {
 		return fmt.Errorf("filtering paths: %w", err)
 	}
 	for _, path := range paths {
+		if dryRun {
+			ctx.Logf("Would remove %s", path)
+			continue
+		}
+		ctx.Debugf("Removing %s", path)
 		if err := ctx.RemoveAll(path); err != nil {
 			return err
 		}
@@ -79,26 +100,90 @@ func BuildFn(ctx *gcp.Context, exclusions []string) error {
 	return nil
 }
 
-// pathsToRemove returns a list of entries in dir, filtering entries that match any in exclusions. exclusions should be a partial path relative to dir.
-func pathsToRemove(ctx *gcp.Context, dir string, exclusions []string) ([]string, error) {
-	paths, err := ctx.Glob(filepath.Join(dir, "*"))
+// keepMatcher returns a matcher for the paths to keep in dir: exclusions anchored at dir, then the
+// patterns in dir's KeepFile, then the default exclusions, which cannot be overridden.
+func keepMatcher(dir string, exclusions []string) (*ignorefile.Matcher, error) {
+	m := &ignorefile.Matcher{}
+	if err := m.Add(anchored(exclusions)...); err != nil {
+		return nil, err
+	}
+	keep, err := os.ReadFile(filepath.Join(dir, KeepFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, buildererror.Errorf(buildererror.StatusInternal, "reading %s: %v", KeepFile, err)
+	}
+	if err == nil {
+		km, err := ignorefile.Parse(string(keep))
+		if err != nil {
+			return nil, gcp.UserErrorf("parsing %s: %v", KeepFile, err)
+		}
+		m.Extend(km)
+	}
+	if err := m.Add(anchored(defaultExclusions)...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// anchored converts paths relative to the application directory to patterns matching only that path.
+func anchored(paths []string) []string {
+	var patterns []string
+	for _, p := range paths {
+		patterns = append(patterns, "/"+filepath.ToSlash(filepath.Clean(p)))
+	}
+	return patterns
+}
+
+// pathsToRemove returns the paths below dir that are not kept by exclusions or the KeepFile.
+// exclusions should be partial paths relative to dir. Directories without any kept descendants
+// are returned as a single path; directories with kept descendants are descended into.
+func pathsToRemove(dir string, exclusions []string) ([]string, error) {
+	m, err := keepMatcher(dir, exclusions)
+	if err != nil {
+		return nil, err
+	}
+	paths, _, err := walkRemovals(dir, "", m)
 	if err != nil {
 		return nil, fmt.Errorf("finding paths: %w", err)
 	}
-	var filteredPaths []string
-	for _, path := range paths {
-		remove := true
-		for _, exclusion := range exclusions {
-			if match, err := filepath.Match(path, filepath.Join(dir, exclusion)); err != nil {
-				return nil, fmt.Errorf("matching pattern %q with path %q: %v", filepath.Join(dir, exclusion), path, err)
-			} else if match {
-				remove = false
-				break
+	return paths, nil
+}
+
+// walkRemovals returns the paths to remove below dir/rel and whether anything below it is kept.
+func walkRemovals(dir, rel string, m *ignorefile.Matcher) ([]string, bool, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, rel))
+	if err != nil {
+		return nil, false, err
+	}
+	var removals []string
+	keptAny := false
+	for _, e := range entries {
+		entryRel := filepath.Join(rel, e.Name())
+		path := filepath.Join(dir, entryRel)
+		// Symlinks are never followed; they are kept or removed like files.
+		isDir := e.IsDir()
+		kept := m.Match(filepath.ToSlash(entryRel), isDir)
+		if !isDir {
+			if kept {
+				keptAny = true
+			} else {
+				removals = append(removals, path)
 			}
+			continue
+		}
+		if kept && !m.HasNegations() {
+			keptAny = true
+			continue
+		}
+		sub, subKept, err := walkRemovals(dir, entryRel, m)
+		if err != nil {
+			return nil, false, err
 		}
-		if remove {
-			filteredPaths = append(filteredPaths, path)
+		if subKept || kept {
+			keptAny = true
+			removals = append(removals, sub...)
+		} else {
+			removals = append(removals, path)
 		}
 	}
-	return filteredPaths, nil
+	return removals, keptAny, nil
 }
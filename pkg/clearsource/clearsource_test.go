@@ -15,11 +15,13 @@ package clearsource
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"testing"
 
-	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestPathsToRemove(t *testing.T) {
@@ -75,9 +77,8 @@ func TestPathsToRemove(t *testing.T) {
 					t.Fatalf("writing to file %s: %v", path, err)
 				}
 			}
-			ctx := gcp.NewContext()
 
-			got, err := pathsToRemove(ctx, tDir, tc.exclusions)
+			got, err := pathsToRemove(tDir, tc.exclusions)
 			if err != nil {
 				t.Errorf("pathsToRemove() returned error: %v", err)
 			}
@@ -87,3 +88,93 @@ func TestPathsToRemove(t *testing.T) {
 		})
 	}
 }
+
+func TestPathsToRemoveRecursive(t *testing.T) {
+	files := []string{
+		".googleconfig/app_start.json",
+		"config/app.yaml",
+		"config/prod/db.yaml",
+		"config/prod/db.json",
+		"config/secret.yaml",
+		"src/main/java/App.java",
+		"target/app.jar",
+		"templates/index.html",
+		"templates/partials/header.html",
+		"pom.xml",
+	}
+	testCases := []struct {
+		name       string
+		keep       string
+		exclusions []string
+		want       []string
+	}{
+		{
+			name:       "no keep file",
+			exclusions: []string{"target"},
+			want:       []string{"config", "pom.xml", "src", "templates"},
+		},
+		{
+			name:       "recursive globs",
+			keep:       "config/**/*.yaml\ntemplates/",
+			exclusions: []string{"target"},
+			want:       []string{"config/prod/db.json", "pom.xml", "src"},
+		},
+		{
+			name:       "negation",
+			keep:       "# keep configs but not secrets\nconfig/\n!config/secret.yaml\n!templates/partials/",
+			exclusions: []string{"target"},
+			want:       []string{"config/secret.yaml", "pom.xml", "src", "templates"},
+		},
+		{
+			name:       "negated exclusion",
+			keep:       "!target/",
+			exclusions: []string{"target"},
+			want:       []string{"config", "pom.xml", "src", "target", "templates"},
+		},
+		{
+			name: "default exclusions cannot be negated",
+			keep: "!.googleconfig",
+			want: []string{"config", "pom.xml", "src", "target", "templates"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.keep != "" {
+				if err := os.WriteFile(filepath.Join(dir, KeepFile), []byte(tc.keep), 0644); err != nil {
+					t.Fatal(err)
+				}
+				// The keep file itself is source and is removed too.
+				tc.want = append(tc.want, KeepFile)
+			}
+
+			paths, err := pathsToRemove(dir, tc.exclusions)
+			if err != nil {
+				t.Fatalf("pathsToRemove() got error: %v", err)
+			}
+
+			var got []string
+			for _, p := range paths {
+				rel, err := filepath.Rel(dir, p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, rel)
+			}
+			sort.Strings(got)
+			sort.Strings(tc.want)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("pathsToRemove() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -42,7 +42,21 @@ func getConfig(ctx *gcp.Context, runtime string, eg appstart.EntrypointGenerator
 	}
 	c.Entrypoint = *ep
 
-	ctx.Logf("Using config %#v", c)
+	c.Env = appstart.DefaultEnv(c.Runtime)
+	for launch, build := range map[string]string{
+		env.FunctionTargetLaunch:        env.FunctionTarget,
+		env.FunctionSignatureTypeLaunch: env.FunctionSignatureType,
+		env.FunctionSourceLaunch:        env.FunctionSource,
+	} {
+		if val, ok := os.LookupEnv(build); ok {
+			c.Env[launch] = val
+		}
+	}
+	if len(c.Env) == 0 {
+		c.Env = nil
+	}
+
+	ctx.Logf("Using config %#v", c.Redacted())
 	return c, nil
 }
 
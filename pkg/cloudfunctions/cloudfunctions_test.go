@@ -27,6 +27,7 @@ func TestGetConfig(t *testing.T) {
 	testCases := []struct {
 		name       string
 		runtimeEnv string
+		targetEnv  string
 		want       appstart.Config
 	}{
 		{
@@ -50,6 +51,30 @@ func TestGetConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "language default env",
+			runtimeEnv: "nodejs20",
+			want: appstart.Config{
+				Runtime: "nodejs20",
+				Entrypoint: appstart.Entrypoint{
+					Type:    appstart.EntrypointGenerated.String(),
+					Command: "generated",
+				},
+				Env: map[string]string{"NODE_ENV": "production"},
+			},
+		},
+		{
+			name:      "function target env",
+			targetEnv: "helloWorld",
+			want: appstart.Config{
+				Runtime: "runtime",
+				Entrypoint: appstart.Entrypoint{
+					Type:    appstart.EntrypointGenerated.String(),
+					Command: "generated",
+				},
+				Env: map[string]string{"FUNCTION_TARGET": "helloWorld"},
+			},
+		},
 	}
 
 	ctx := gcp.NewContext()
@@ -63,6 +88,9 @@ func TestGetConfig(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			setEnv(t, "GOOGLE_RUNTIME", tc.runtimeEnv)
+			if tc.targetEnv != "" {
+				setEnv(t, "GOOGLE_FUNCTION_TARGET", tc.targetEnv)
+			}
 
 			got, err := getConfig(ctx, "runtime", eg)
 			if err != nil {
@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 )
@@ -153,8 +154,19 @@ func installFileWatcher(ctx *gcp.Context) error {
 		// Download and install watchexec in layer.
 		ctx.Logf("Installing watchexec v%s", watchexecVersion)
 		archiveURL := fmt.Sprintf(watchexecURL, watchexecVersion)
-		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xJ --directory %s --strip-components=1 --wildcards \"*watchexec\"", archiveURL, binDir)
-		if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+		tmpDir, err := ctx.TempDir(watchexecLayer)
+		if err != nil {
+			return err
+		}
+		defer ctx.RemoveAll(tmpDir)
+		if err := fetch.UseCache(ctx); err != nil {
+			return err
+		}
+		if err := fetch.Tarball(archiveURL, tmpDir, 1); err != nil {
+			return fmt.Errorf("downloading watchexec: %w", err)
+		}
+		// The archive also contains docs and shell completions; only the binary is installed.
+		if err := ctx.Rename(filepath.Join(tmpDir, "watchexec"), filepath.Join(binDir, "watchexec")); err != nil {
 			return err
 		}
 		ctx.SetMetadata(wxl, versionKey, watchexecVersion)
@@ -26,6 +26,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
 )
 
 const (
@@ -157,14 +158,16 @@ func ReadRuntimeConfigJSON(path string) (*RuntimeConfigJSON, error) {
 // globalJSON represents the contents of a global.json file.
 type globalJSON struct {
 	Sdk struct {
-		Version string `json:"version"`
+		Version     string `json:"version"`
+		RollForward string `json:"rollForward"`
 	} `json:"sdk"`
 }
 
 // GetSDKVersion returns the appropriate .NET SDK version to use, with the following heuristic:
 //  1. Return value of env variable GOOGLE_DOTNET_SDK_VERSION if present.
 //  2. Return value of env variable GOOGLE_RUNTIME_VERSION if present.
-//  3. Return SDK.Version from the .NET global.json file if present.
+//  3. Return SDK.Version from the .NET global.json file if present. If SDK.RollForward is also
+//     set, the version is returned as a constraint allowing the SDKs that policy permits.
 //  4. Return an empty string by default, which will cause us to use the latest version available
 //     on dl.google.com (see runtime.InstallTarballIfNotCached for details).
 func GetSDKVersion(ctx *gcp.Context) (string, error) {
@@ -182,8 +185,12 @@ func GetSDKVersion(ctx *gcp.Context) (string, error) {
 		return "", err
 	}
 	if gjs != nil && gjs.Sdk.Version != "" {
-		ctx.Logf("Using .NET Core SDK version from global.json: %s", gjs.Sdk.Version)
-		return gjs.Sdk.Version, nil
+		v, err := version.DotnetRollForward(gjs.Sdk.Version, gjs.Sdk.RollForward)
+		if err != nil {
+			return "", gcp.UserErrorf("invalid .NET SDK version in global.json: %v", err)
+		}
+		ctx.Logf("Using .NET Core SDK version from global.json: %s", v)
+		return v, nil
 	}
 	ctx.Logf("Using latest stable .NET Core SDK version")
 	return "", nil
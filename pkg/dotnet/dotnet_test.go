@@ -188,6 +188,11 @@ func TestGetSDKVersion(t *testing.T) {
 			ApplicationRoot:      testdata.MustGetPath("testdata/"),
 			ExpectedResult:       "3.1.100",
 		},
+		{
+			Name:            "Should apply global.json rollForward policy",
+			ApplicationRoot: testdata.MustGetPath("testdata/rollforward/"),
+			ExpectedResult:  ">=8.0.100, <8.1.0",
+		},
 	}
 
 	for _, tc := range testCases {
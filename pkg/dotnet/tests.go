@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
+)
+
+const (
+	// testLayerName is the layer that the test projects are built into and their packages restored
+	// into. It is neither cached nor launched, so they are not part of the application image.
+	testLayerName = "test_packages"
+	// testSDKPackage is the package that every test project references to be run by `dotnet test`.
+	testSDKPackage = "Microsoft.NET.Test.Sdk"
+	// junitLoggerPackage is the package that provides the JUnit XML logger for `dotnet test`.
+	junitLoggerPackage = "JunitXml.TestLogger"
+)
+
+// RunTests runs the application's test projects with `dotnet test` if GOOGLE_RUN_TESTS is set. A
+// test project is one that references Microsoft.NET.Test.Sdk.
+func RunTests(ctx *gcp.Context) error {
+	enabled, err := testrun.Enabled(ctx)
+	if err != nil || !enabled {
+		return err
+	}
+	projs, err := ProjectFiles(ctx, ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("finding project files: %w", err)
+	}
+
+	l, err := ctx.Layer(testLayerName)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", testLayerName, err)
+	}
+	var cfgs []testrun.Config
+	for _, proj := range projs {
+		p, err := ReadProjectFile(ctx, proj)
+		if err != nil {
+			return err
+		}
+		if cfg := testConfig(p, proj, l.Path); cfg != nil {
+			cfgs = append(cfgs, *cfg)
+		}
+	}
+	if len(cfgs) == 0 {
+		ctx.Logf("No tests found, no project references %s.", testSDKPackage)
+		return nil
+	}
+
+	for _, cfg := range cfgs {
+		cfg.Options = []gcp.ExecOption{gcp.WithEnv("NUGET_PACKAGES="+filepath.Join(l.Path, "packages"), "DOTNET_CLI_TELEMETRY_OPTOUT=true")}
+		if err := testrun.Run(ctx, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testConfig returns how to run the tests of the project p read from proj, writing its build output
+// and any report into dir, or nil if p is not a test project.
+func testConfig(p Project, proj, dir string) *testrun.Config {
+	if !p.references(testSDKPackage) {
+		return nil
+	}
+	name := strings.TrimSuffix(filepath.Base(proj), filepath.Ext(proj))
+	cfg := &testrun.Config{Command: []string{
+		"dotnet",
+		"test",
+		"-nologo",
+		"--verbosity", "minimal",
+		"--configuration", "Release",
+		"--output", filepath.Join(dir, "bin", name),
+	}}
+	if p.references(junitLoggerPackage) {
+		report := filepath.Join(dir, "results", name+".xml")
+		cfg.Command = append(cfg.Command, "--logger", "junit;LogFilePath="+report)
+		cfg.Reports = []string{report}
+	}
+	cfg.Command = append(cfg.Command, proj)
+	return cfg
+}
+
+// references reports whether the project references the package.
+func (p Project) references(pkg string) bool {
+	for _, ig := range p.ItemGroups {
+		for _, r := range ig.PackageReferences {
+			if strings.EqualFold(r.Include, pkg) {
+				return true
+			}
+		}
+	}
+	return false
+}
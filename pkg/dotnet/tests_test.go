@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTestConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		project     string
+		wantCommand string
+		wantReport  string
+	}{
+		{
+			name: "test project",
+			project: `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Microsoft.NET.Test.Sdk" Version="17.8.0" />
+    <PackageReference Include="xunit" Version="2.6.2" />
+  </ItemGroup>
+</Project>`,
+			wantCommand: "dotnet test -nologo --verbosity minimal --configuration Release --output /layers/test_packages/bin/app.Tests app.Tests.csproj",
+		},
+		{
+			name: "test project with junit logger",
+			project: `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Microsoft.NET.Test.Sdk" Version="17.8.0" />
+  </ItemGroup>
+  <ItemGroup>
+    <PackageReference Include="JunitXml.TestLogger" Version="3.1.12" />
+  </ItemGroup>
+</Project>`,
+			wantCommand: "dotnet test -nologo --verbosity minimal --configuration Release --output /layers/test_packages/bin/app.Tests --logger junit;LogFilePath=/layers/test_packages/results/app.Tests.xml app.Tests.csproj",
+			wantReport:  "/layers/test_packages/results/app.Tests.xml",
+		},
+		{
+			name: "application project",
+			project: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <ItemGroup>
+    <PackageReference Include="Google.Cloud.Storage.V1" Version="4.7.0" />
+  </ItemGroup>
+</Project>`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := readProjectFile([]byte(tc.project), "app.Tests.csproj")
+			if err != nil {
+				t.Fatalf("readProjectFile() got error: %v", err)
+			}
+
+			got := testConfig(p, "app.Tests.csproj", "/layers/test_packages")
+
+			if tc.wantCommand == "" {
+				if got != nil {
+					t.Errorf("testConfig() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("testConfig() = nil, want command %q", tc.wantCommand)
+			}
+			if cmd := strings.Join(got.Command, " "); cmd != tc.wantCommand {
+				t.Errorf("testConfig() command = %q, want %q", cmd, tc.wantCommand)
+			}
+			if gotReport := strings.Join(got.Reports, " "); gotReport != tc.wantReport {
+				t.Errorf("testConfig() reports = %q, want %q", gotReport, tc.wantReport)
+			}
+		})
+	}
+}
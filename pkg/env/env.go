@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 const (
@@ -51,6 +52,28 @@ const (
 	// Buildpacks for Go and Java support clearing the source.
 	ClearSource = "GOOGLE_CLEAR_SOURCE"
 
+	// ClearSourceDryRun is an env var used to only log the paths GOOGLE_CLEAR_SOURCE would remove.
+	// Example: `true`, `True`, `1` will enable the dry run.
+	ClearSourceDryRun = "GOOGLE_CLEAR_SOURCE_DRY_RUN"
+
+	// SourceArchiveFormat is an env var used to choose the compression of the archived source in Cloud Functions builds.
+	// Example: `gzip` (default) or `zstd`.
+	SourceArchiveFormat = "GOOGLE_SOURCE_ARCHIVE_FORMAT"
+
+	// SourceRevision is an env var used to set the commit the image is built from when the source
+	// is not a git checkout. It takes precedence over the revision read from .git.
+	// Example: `7d1c3a0f2b...`.
+	SourceRevision = "GOOGLE_SOURCE_REVISION"
+
+	// SourceURL is an env var used to set the repository the image is built from.
+	// Example: `https://github.com/GoogleCloudPlatform/buildpacks`.
+	SourceURL = "GOOGLE_SOURCE_URL"
+
+	// SourceVersion is an env var used to set the version of the source the image is built from.
+	// If unset, a git tag pointing at the built commit is used.
+	// Example: `v1.2.3`.
+	SourceVersion = "GOOGLE_SOURCE_VERSION"
+
 	// Buildable is an env var used to specify the buildable unit to build.
 	// Buildable should be respected by buildpacks that build source.
 	// Example: `./maindir` for Go will build the package rooted at maindir.
@@ -142,6 +165,63 @@ const (
 
 	// RuntimeImageRegion is the region to fetch runtime images.
 	RuntimeImageRegion = "GOOGLE_RUNTIME_IMAGE_REGION"
+
+	// RuntimeLifecycleFile is the path to a JSON file whose entries override the runtime lifecycle
+	// database shipped with the buildpacks.
+	RuntimeLifecycleFile = "GOOGLE_RUNTIME_LIFECYCLE_FILE"
+
+	// AllowDecommissionedRuntime allows builds to install a runtime version past its decommission date.
+	// Example: `true`, `True`, `1` will allow the build to continue with a warning.
+	AllowDecommissionedRuntime = "GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME"
+
+	// ExecTimeout is the default deadline for each command a buildpack runs without its own timeout.
+	// Example: `20m` stops any command still running after 20 minutes.
+	ExecTimeout = "GOOGLE_EXEC_TIMEOUT"
+
+	// BuildpackTimeout is the deadline for all commands run by a single buildpack's detect or build.
+	// Example: `45m`.
+	BuildpackTimeout = "GOOGLE_BUILDPACK_TIMEOUT"
+
+	// ExecHeartbeatInterval is how often a "still running" line is logged for a long, logged command.
+	// Example: `30s`; `0` disables the heartbeat.
+	ExecHeartbeatInterval = "GOOGLE_EXEC_HEARTBEAT_INTERVAL"
+
+	// DownloadSegments is the number of concurrent range requests used to download a large artifact.
+	// Example: `1` downloads every artifact with a single request.
+	DownloadSegments = "GOOGLE_DOWNLOAD_SEGMENTS"
+
+	// DownloadCacheMaxMB is the size limit in MB of a buildpack's download cache layer, 1024 by
+	// default. The least recently used artifacts are evicted first. Runtimes installed into cache
+	// layers are not kept in it. Example: `0` disables the download cache.
+	DownloadCacheMaxMB = "GOOGLE_DOWNLOAD_CACHE_MAX_MB"
+
+	// PackageCacheMaxMB is the size limit in MB of each package manager's download cache layer, such
+	// as the npm or pip cache. A larger cache is cleared before the next install. Example: `512`.
+	PackageCacheMaxMB = "GOOGLE_PACKAGE_CACHE_MAX_MB"
+
+	// RunTests runs the application's tests after its dependencies are installed and fails the build
+	// if they fail. Test reports are collected into BUILDER_OUTPUT. Example: `true`.
+	RunTests = "GOOGLE_RUN_TESTS"
+
+	// AdvisoryDB is a directory of OSV advisories, as .json files or osv.dev all.zip exports, that
+	// the resolved dependencies are audited against without network access. The audit is skipped if
+	// it is not set. Example: `/workspace/osv`.
+	AdvisoryDB = "GOOGLE_ADVISORY_DB"
+
+	// AuditFailSeverity fails the build if the dependency audit finds a vulnerability at or above
+	// this severity: LOW, MODERATE, HIGH or CRITICAL. Vulnerabilities of unknown severity also fail
+	// the build once it is set. Otherwise findings are warnings. Example: `HIGH`.
+	AuditFailSeverity = "GOOGLE_AUDIT_FAIL_SEVERITY"
+
+	// RedactEnvNames is a comma-separated list of environment variables whose values are secrets and
+	// are redacted from buildpack output, in addition to variables with secret-like names. It is set
+	// in the build environment only, for example from the App Hosting preparer's build env file.
+	// Example: `API_KEY,DATABASE_URL`.
+	RedactEnvNames = "X_GOOGLE_REDACT_ENV_NAMES"
+
+	// SourceDateEpoch is the conventional env var for overriding timestamps in reproducible builds,
+	// in seconds since the Unix epoch. Example: `1700000000`.
+	SourceDateEpoch = "SOURCE_DATE_EPOCH"
 )
 
 // IsGAE returns true if the buildpack target platform is gae.
@@ -180,6 +260,37 @@ func IsUsingNativeImage() (bool, error) {
 	return IsPresentAndTrue(UseNativeImage)
 }
 
+// Duration returns the environment variable parsed as a duration such as "90s" or "20m", or the
+// given default if the variable is not set.
+func Duration(varName string, def time.Duration) (time.Duration, error) {
+	varValue, present := os.LookupEnv(varName)
+	if !present || varValue == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(varValue)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %v", varName, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("parsing %s: negative duration %q", varName, varValue)
+	}
+	return d, nil
+}
+
+// SourceDate returns the time set by SOURCE_DATE_EPOCH in UTC, or the given default if the
+// variable is not set or empty.
+func SourceDate(def time.Time) (time.Time, error) {
+	varValue := os.Getenv(SourceDateEpoch)
+	if varValue == "" {
+		return def, nil
+	}
+	secs, err := strconv.ParseInt(varValue, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing %s: %v", SourceDateEpoch, err)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
 // IsPresentAndTrue returns true if the environment variable evaluates to True.
 func IsPresentAndTrue(varName string) (bool, error) {
 	varValue, present := os.LookupEnv(varName)
@@ -17,6 +17,7 @@ package env
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestIsDebugMode(t *testing.T) {
@@ -90,3 +91,110 @@ func TestIsDebugMode(t *testing.T) {
 		})
 	}
 }
+
+func TestDuration(t *testing.T) {
+	testCases := []struct {
+		name    string
+		notSet  bool
+		value   string
+		wantErr bool
+		want    time.Duration
+	}{
+		{
+			name:   "not set uses default",
+			notSet: true,
+			want:   time.Minute,
+		},
+		{
+			name:  "set to empty uses default",
+			value: "",
+			want:  time.Minute,
+		},
+		{
+			name:  "set to minutes",
+			value: "20m",
+			want:  20 * time.Minute,
+		},
+		{
+			name:  "set to zero",
+			value: "0",
+			want:  0,
+		},
+		{
+			name:    "set to bad value",
+			value:   "twenty minutes",
+			wantErr: true,
+		},
+		{
+			name:    "set to negative",
+			value:   "-5s",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.notSet {
+				t.Setenv(ExecTimeout, tc.value)
+			}
+
+			got, err := Duration(ExecTimeout, time.Minute)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Duration(%q) got error: %v, want error? %t", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Duration(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSourceDate(t *testing.T) {
+	def := time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+	testCases := []struct {
+		name    string
+		notSet  bool
+		value   string
+		wantErr bool
+		want    time.Time
+	}{
+		{
+			name:   "not set uses default",
+			notSet: true,
+			want:   def,
+		},
+		{
+			name:  "set to empty uses default",
+			value: "",
+			want:  def,
+		},
+		{
+			name:  "set to seconds",
+			value: "1700000000",
+			want:  time.Date(2023, time.November, 14, 22, 13, 20, 0, time.UTC),
+		},
+		{
+			name:    "set to bad value",
+			value:   "yesterday",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !tc.notSet {
+				t.Setenv(SourceDateEpoch, tc.value)
+			}
+
+			got, err := SourceDate(def)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SourceDate(%q) got error: %v, want error? %t", tc.value, err, tc.wantErr)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("SourceDate(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// zipCreatorUnix is the "version made by" host system of zip entries that carry Unix permissions.
+	zipCreatorUnix = 3
+	// tarMagicOffset is the offset of tarMagic in the header of POSIX and GNU tar files.
+	tarMagicOffset = 257
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+	zipMagic   = []byte("PK\x03\x04")
+	tarMagic   = []byte("ustar")
+)
+
+// Archive downloads an archive from a URL and extracts it into the provided directory. Zip files
+// and tarballs compressed with gzip, xz, zstd or bzip2 are recognized from their contents.
+func Archive(url, dir string, stripComponents int, opts ...Option) error {
+	o := newOptions(opts)
+	if path, release, ok := localCopy(url, o.sha256); ok {
+		defer release()
+		return ExtractArchive(path, dir, stripComponents)
+	}
+	stats := newDownloadStats()
+	defer stats.record()
+	r, err := openStream(url, o.sha256, stats)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	br := bufio.NewReader(r)
+	if !hasMagic(br, zipMagic) {
+		if err := untar(dir, br, stripComponents); err != nil {
+			return err
+		}
+		return drain(url, br)
+	}
+	// Zip files are read from their central directory at the end, so download the archive first.
+	f, err := os.CreateTemp("", "archive-*.zip")
+	if err != nil {
+		return gcp.InternalErrorf("creating temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := io.Copy(f, br); err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", url, err)
+	}
+	return ExtractArchive(f.Name(), dir, stripComponents)
+}
+
+// ExtractArchive extracts a zip file or a tarball compressed with gzip, xz, zstd or bzip2 into the
+// provided directory.
+func ExtractArchive(path, dir string, stripComponents int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return gcp.InternalErrorf("opening archive %q: %v", path, err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	if !hasMagic(br, zipMagic) {
+		return untar(dir, br, stripComponents)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return gcp.InternalErrorf("reading archive %q: %v", path, err)
+	}
+	return unzip(dir, f, fi.Size(), stripComponents)
+}
+
+// hasMagic reports whether the buffered content starts with magic.
+func hasMagic(br *bufio.Reader, magic []byte) bool {
+	b, err := br.Peek(len(magic))
+	return err == nil && bytes.Equal(b, magic)
+}
+
+// decompress returns a reader of the decompressed tarball, choosing the decompressor from the
+// content's magic number. Content without a recognized magic number must be an uncompressed tar.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	switch {
+	case hasMagic(br, gzipMagic):
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, gcp.InternalErrorf("creating gzip reader: %v", err)
+		}
+		return gzr, nil
+	case hasMagic(br, xzMagic):
+		xzr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, gcp.InternalErrorf("creating xz reader: %v", err)
+		}
+		return io.NopCloser(xzr), nil
+	case hasMagic(br, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, gcp.InternalErrorf("creating zstd reader: %v", err)
+		}
+		return zr.IOReadCloser(), nil
+	case hasMagic(br, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	}
+	if b, err := br.Peek(tarMagicOffset + len(tarMagic)); err != nil || !bytes.Equal(b[tarMagicOffset:], tarMagic) {
+		return nil, gcp.InternalErrorf("unrecognized archive format")
+	}
+	return io.NopCloser(br), nil
+}
+
+// unzip extracts a zip file into the given directory.
+func unzip(dir string, r io.ReaderAt, size int64, stripComponents int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return gcp.InternalErrorf("reading zip file: %v", err)
+	}
+
+	madeDir := map[string]bool{}
+	for _, f := range zr.File {
+		mode := f.Mode()
+		// Map the entry to its tar type so that zip and tar entries are placed by the same rules.
+		var typ byte = tar.TypeReg
+		switch {
+		case mode.IsDir():
+			typ = tar.TypeDir
+		case mode&os.ModeSymlink != 0:
+			typ = tar.TypeSymlink
+		}
+
+		target, err := tarDestination(f.Name, dir, typ, stripComponents)
+		if err != nil {
+			return err
+		}
+		if target, err = resolveEntry(dir, target, typ); err != nil {
+			return err
+		}
+
+		switch typ {
+		case tar.TypeDir:
+			if err := makeDir(target, mode.Perm()|0700); err != nil {
+				return err
+			}
+			madeDir[target] = true
+		case tar.TypeSymlink:
+			link, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			if err := makeSymlink(dir, target, string(link)); err != nil {
+				return err
+			}
+		default:
+			if d := filepath.Dir(target); !madeDir[d] {
+				if err := os.MkdirAll(d, 0755); err != nil {
+					return gcp.InternalErrorf("creating directory %q: %v", d, err)
+				}
+				madeDir[d] = true
+			}
+			perm := mode.Perm()
+			if f.CreatorVersion>>8 != zipCreatorUnix {
+				// Zip files created on other systems, such as Windows, have no Unix permissions.
+				perm = 0644
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return gcp.InternalErrorf("opening zip entry %q: %v", f.Name, err)
+			}
+			err = writeFile(target, perm, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readZipFile returns the contents of a zip entry.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, gcp.InternalErrorf("opening zip entry %q: %v", f.Name, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading zip entry %q: %v", f.Name, err)
+	}
+	return b, nil
+}
+
+// makeDir creates an extracted directory if it does not already exist.
+func makeDir(target string, perm os.FileMode) error {
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(target, perm); err != nil {
+		return gcp.InternalErrorf("creating directory %q: %v", target, err)
+	}
+	return nil
+}
+
+// writeFile writes an extracted file with exactly the given permissions, regardless of umask. A
+// symlink at target is replaced rather than written through.
+func writeFile(target string, perm os.FileMode, r io.Reader) error {
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return gcp.InternalErrorf("removing symlink %q: %v", target, err)
+		}
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, perm)
+	if err != nil {
+		return gcp.InternalErrorf("opening file %q: %v", target, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return gcp.InternalErrorf("copying file %q: %v", target, err)
+	}
+	if err := f.Close(); err != nil {
+		return gcp.InternalErrorf("closing file %q: %v", target, err)
+	}
+	if err := os.Chmod(target, perm); err != nil {
+		return gcp.InternalErrorf("setting mode of %q: %v", target, err)
+	}
+	return nil
+}
+
+// maxSymlinkHops is the number of symlinks resolveInRoot follows before giving up on a path.
+const maxSymlinkHops = 255
+
+// resolveEntry returns the path an extracted entry is written to. The path checks of
+// tarDestination are lexical, so symlinks extracted by earlier entries are followed with
+// resolveInRoot. Only directories are followed through their last element; other entries replace
+// an existing symlink, as tar does.
+func resolveEntry(rootDir, target string, typ byte) (string, error) {
+	if typ == tar.TypeDir {
+		return resolveInRoot(rootDir, target)
+	}
+	dir, err := resolveInRoot(rootDir, filepath.Dir(target))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(target)), nil
+}
+
+// resolveInRoot follows the symlinks in path, which must be within rootDir, as if rootDir were the
+// filesystem root: absolute link targets are resolved relative to rootDir, as they are in the image
+// or runtime being extracted. It returns an error if a relative link target escapes rootDir.
+func resolveInRoot(rootDir, path string) (string, error) {
+	rootDir = filepath.Clean(rootDir)
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil {
+		return "", gcp.InternalErrorf("resolving %q: %v", path, err)
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", gcp.InternalErrorf("resolving %q traverses out of root", path)
+	}
+	return resolveFrom(rootDir, rootDir, strings.Split(rel, string(filepath.Separator)))
+}
+
+// resolveFrom follows the path elements in rest, starting from cur within rootDir, as described
+// for resolveInRoot.
+func resolveFrom(rootDir, cur string, rest []string) (string, error) {
+	path := filepath.Join(append([]string{cur}, rest...)...)
+	for hops := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			// A ".." refers to the parent of where the preceding elements resolved to, which is only
+			// different from lexical cleaning after a symlink.
+			if cur == rootDir {
+				return "", gcp.InternalErrorf("resolving %q traverses out of root", path)
+			}
+			cur = filepath.Dir(cur)
+			continue
+		}
+		next := filepath.Join(cur, part)
+		fi, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			// The rest of the path does not exist yet, so there are no more symlinks to follow.
+			resolved := filepath.Join(append([]string{next}, rest...)...)
+			if !isValidTarDestination(resolved, rootDir, tar.TypeDir) {
+				return "", gcp.InternalErrorf("resolving %q traverses out of root", path)
+			}
+			return resolved, nil
+		}
+		if err != nil {
+			return "", gcp.InternalErrorf("resolving %q: %v", path, err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+		if hops++; hops > maxSymlinkHops {
+			return "", gcp.InternalErrorf("resolving %q: too many levels of symlinks", path)
+		}
+		link, err := os.Readlink(next)
+		if err != nil {
+			return "", gcp.InternalErrorf("reading symlink %q: %v", next, err)
+		}
+		// Resolve the link target element by element, since it may contain further symlinks and ".."
+		// elements that lexical cleaning would cancel out.
+		if filepath.IsAbs(link) {
+			cur = rootDir
+		}
+		rest = append(strings.Split(filepath.ToSlash(link), "/"), rest...)
+	}
+	return cur, nil
+}
+
+// makeSymlink creates an extracted symlink after checking that it resolves within rootDir. Absolute
+// link targets are resolved relative to rootDir.
+func makeSymlink(rootDir, target, linkname string) error {
+	rootDir = filepath.Clean(rootDir)
+	start := filepath.Dir(target)
+	if filepath.IsAbs(linkname) {
+		start = rootDir
+	}
+	if _, err := resolveFrom(rootDir, start, strings.Split(filepath.ToSlash(linkname), "/")); err != nil {
+		return err
+	}
+	if err := os.Symlink(linkname, target); err != nil {
+		return gcp.InternalErrorf("symlinking %q to %q: %v", target, linkname, err)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+)
+
+func TestArchive(t *testing.T) {
+	testCases := []struct {
+		name            string
+		responseFile    string
+		stripComponents int
+		wantFile        string
+	}{
+		{
+			name:         "zip",
+			responseFile: "testdata/test.zip",
+			wantFile:     "lib/foo.txt",
+		},
+		{
+			name:            "zip strip components",
+			responseFile:    "testdata/test.zip",
+			stripComponents: 1,
+			wantFile:        "foo.txt",
+		},
+		{
+			name:         "tar.gz",
+			responseFile: "testdata/test.tar.gz",
+			wantFile:     "lib/foo.txt",
+		},
+		{
+			name:            "tar.xz strip components",
+			responseFile:    "testdata/test.tar.xz",
+			stripComponents: 1,
+			wantFile:        "foo.txt",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testserver.New(t, testserver.WithFile(testdata.MustGetPath(tc.responseFile)))
+
+			dir := t.TempDir()
+			if err := Archive(server.URL, dir, tc.stripComponents); err != nil {
+				t.Fatalf("Archive(%q, %q, %v) got error: %v", server.URL, dir, tc.stripComponents, err)
+			}
+			fp := filepath.Join(dir, tc.wantFile)
+			if _, err := os.Stat(fp); err != nil {
+				t.Errorf("Failed to extract. Missing file: %s (%v)", fp, err)
+			}
+		})
+	}
+}
+
+type zipEntry struct {
+	name string
+	mode os.FileMode
+	body string
+}
+
+func TestExtractArchiveZip(t *testing.T) {
+	testCases := []struct {
+		name            string
+		entries         []zipEntry
+		stripComponents int
+		wantModes       map[string]os.FileMode
+		wantLinks       map[string]string
+		wantError       bool
+	}{
+		{
+			name: "preserves modes",
+			entries: []zipEntry{
+				{name: "pkg/", mode: os.ModeDir | 0755},
+				{name: "pkg/bin/tool", mode: 0755, body: "#!/bin/sh"},
+				{name: "pkg/README", mode: 0600, body: "readme"},
+			},
+			wantModes: map[string]os.FileMode{
+				"pkg/bin/tool": 0755,
+				"pkg/README":   0600,
+			},
+		},
+		{
+			name: "defaults missing permissions",
+			entries: []zipEntry{
+				{name: "file.txt", body: "content"},
+			},
+			wantModes: map[string]os.FileMode{
+				"file.txt": 0644,
+			},
+		},
+		{
+			name: "preserves symlinks",
+			entries: []zipEntry{
+				{name: "pkg/bin/tool", mode: 0755, body: "#!/bin/sh"},
+				{name: "pkg/tool", mode: os.ModeSymlink | 0777, body: "bin/tool"},
+			},
+			stripComponents: 1,
+			wantModes: map[string]os.FileMode{
+				"bin/tool": 0755,
+			},
+			wantLinks: map[string]string{
+				"tool": "bin/tool",
+			},
+		},
+		{
+			name: "entry traverses out of root",
+			entries: []zipEntry{
+				{name: "../evil.txt", mode: 0644, body: "evil"},
+			},
+			wantError: true,
+		},
+		{
+			name: "symlink traverses out of root",
+			entries: []zipEntry{
+				{name: "link", mode: os.ModeSymlink | 0777, body: "../../etc/passwd"},
+			},
+			wantError: true,
+		},
+		{
+			name: "strip too many components",
+			entries: []zipEntry{
+				{name: "pkg/file.txt", mode: 0644, body: "content"},
+			},
+			stripComponents: 2,
+			wantError:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeZip(t, tc.entries)
+			dir := t.TempDir()
+
+			err := ExtractArchive(path, dir, tc.stripComponents)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("ExtractArchive(%q, %q, %v) got error: %v, want error? %v", path, dir, tc.stripComponents, err, tc.wantError)
+			}
+
+			for name, want := range tc.wantModes {
+				fi, err := os.Lstat(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("Failed to extract %q: %v", name, err)
+				}
+				if got := fi.Mode().Perm(); got != want {
+					t.Errorf("Mode of %q = %v, want %v", name, got, want)
+				}
+			}
+			for name, want := range tc.wantLinks {
+				got, err := os.Readlink(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("Reading symlink %q: %v", name, err)
+				}
+				if got != want {
+					t.Errorf("Symlink %q = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// writeZip writes a zip file containing entries and returns its path.
+func writeZip(t *testing.T, entries []zipEntry) string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name}
+		if e.mode != 0 {
+			hdr.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestExtractArchiveZipThroughSymlinks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		entries   func(outside string) []zipEntry
+		wantFiles func(outside string) map[string]string
+		wantError bool
+	}{
+		{
+			name: "absolute symlink resolves within root",
+			entries: func(outside string) []zipEntry {
+				return []zipEntry{
+					{name: "link", mode: os.ModeSymlink | 0777, body: outside},
+					{name: "link/passwd", mode: 0644, body: "content"},
+				}
+			},
+			wantFiles: func(outside string) map[string]string {
+				return map[string]string{filepath.Join(outside, "passwd"): "content"}
+			},
+		},
+		{
+			name: "file through symlink within root",
+			entries: func(string) []zipEntry {
+				return []zipEntry{
+					{name: "sub/", mode: os.ModeDir | 0755},
+					{name: "link", mode: os.ModeSymlink | 0777, body: "sub"},
+					{name: "link/passwd", mode: 0644, body: "content"},
+				}
+			},
+			wantFiles: func(string) map[string]string {
+				return map[string]string{"sub/passwd": "content"}
+			},
+		},
+		{
+			name: "directory through symlink within root",
+			entries: func(string) []zipEntry {
+				return []zipEntry{
+					{name: "link", mode: os.ModeSymlink | 0777, body: "."},
+					{name: "link/dir/", mode: os.ModeDir | 0755},
+					{name: "link/dir/file.txt", mode: 0644, body: "content"},
+				}
+			},
+			wantFiles: func(string) map[string]string {
+				return map[string]string{"dir/file.txt": "content"}
+			},
+		},
+		{
+			name: "file replaces symlink",
+			entries: func(string) []zipEntry {
+				return []zipEntry{
+					{name: "file.txt", mode: 0644, body: "content"},
+					{name: "link", mode: os.ModeSymlink | 0777, body: "file.txt"},
+					{name: "link", mode: 0644, body: "replaced"},
+				}
+			},
+			wantFiles: func(string) map[string]string {
+				return map[string]string{"file.txt": "content", "link": "replaced"}
+			},
+		},
+		{
+			name: "relative symlink escapes through absolute symlink",
+			entries: func(string) []zipEntry {
+				return []zipEntry{
+					{name: "root", mode: os.ModeSymlink | 0777, body: "/"},
+					{name: "up", mode: os.ModeSymlink | 0777, body: "root/.."},
+				}
+			},
+			wantError: true,
+		},
+		{
+			name: "relative symlink escapes through relative symlink",
+			entries: func(string) []zipEntry {
+				return []zipEntry{
+					{name: "sub/", mode: os.ModeDir | 0755},
+					{name: "sub/up", mode: os.ModeSymlink | 0777, body: ".."},
+					{name: "out", mode: os.ModeSymlink | 0777, body: "sub/up/.."},
+				}
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outside := t.TempDir()
+			path := writeZip(t, tc.entries(outside))
+			dir := t.TempDir()
+
+			err := ExtractArchive(path, dir, 0)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("ExtractArchive(%q, %q, 0) got error: %v, want error? %v", path, dir, err, tc.wantError)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "passwd")); !os.IsNotExist(err) {
+				t.Errorf("passwd was written outside of the root, stat error: %v", err)
+			}
+			if tc.wantFiles == nil {
+				return
+			}
+			for name, want := range tc.wantFiles(outside) {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("Failed to extract %q: %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("%q = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
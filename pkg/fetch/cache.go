@@ -0,0 +1,515 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// downloadCacheLayer is the name of the cache layer holding downloaded artifacts.
+	downloadCacheLayer = "download-cache"
+	cacheIndexFile     = "index.json"
+	cacheBlobsDir      = "blobs"
+	// defaultCacheMaxMB is the default size limit of a download cache layer.
+	defaultCacheMaxMB = 1024
+)
+
+var (
+	cacheMu       sync.Mutex
+	downloadCache *Cache
+)
+
+// cacheEntry records the content downloaded from a URL.
+type cacheEntry struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+	// Validator is the strong ETag or Last-Modified date the server sent with the content, if any.
+	Validator string    `json:"validator,omitempty"`
+	LastUsed  time.Time `json:"lastUsed"`
+}
+
+// Cache is a content-addressed store of downloaded artifacts. Each artifact is stored once under its
+// SHA-256 digest, and an index maps the URLs it was downloaded from, together with the digest the
+// caller expected if any, to the digest. Artifacts cached without an expected digest are only used
+// while the server still reports the same validator or size for their URL. When the cache grows
+// past its size limit, the least recently used artifacts are evicted.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	logf     func(format string, args ...interface{})
+	// peers are the download caches of other buildpacks, which are read but never modified.
+	peers []string
+
+	mu    sync.Mutex
+	index map[string]*cacheEntry
+}
+
+// NewCache returns a cache stored in dir, which may hold the index and artifacts of an earlier build.
+func NewCache(dir string, maxBytes int64, logf func(format string, args ...interface{})) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, cacheBlobsDir), 0755); err != nil {
+		return nil, gcp.InternalErrorf("creating download cache: %v", err)
+	}
+	index, err := readCacheIndex(dir)
+	if err != nil {
+		logf("Ignoring unreadable download cache index: %v", err)
+		index = map[string]*cacheEntry{}
+	}
+	return &Cache{dir: dir, maxBytes: maxBytes, logf: logf, index: index}, nil
+}
+
+// UseCache makes Tarball, Archive, File and Prefetch keep downloads in a content-addressed cache
+// layer of the current buildpack, and reuse artifacts already in that layer or in the download cache
+// layers of other buildpacks. It does nothing outside of a build or when the cache size limit set by
+// GOOGLE_DOWNLOAD_CACHE_MAX_MB is 0.
+func UseCache(ctx *gcp.Context) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	layersDir := ctx.LayersDir()
+	if layersDir == "" {
+		return nil
+	}
+	dir := filepath.Join(layersDir, downloadCacheLayer)
+	if downloadCache != nil && downloadCache.dir == dir {
+		return nil
+	}
+	maxMB, err := cacheMaxMB()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if maxMB == 0 {
+		return nil
+	}
+
+	l, err := ctx.Layer(downloadCacheLayer, gcp.CacheLayer)
+	if err != nil {
+		return err
+	}
+	c, err := NewCache(l.Path, maxMB<<20, ctx.Logf)
+	if err != nil {
+		return err
+	}
+	// Buildpacks' layers are siblings, so earlier buildpacks' download caches are next to this one.
+	peers, err := filepath.Glob(filepath.Join(filepath.Dir(layersDir), "*", downloadCacheLayer))
+	if err != nil {
+		return gcp.InternalErrorf("finding download caches: %v", err)
+	}
+	for _, p := range peers {
+		if p != l.Path {
+			c.peers = append(c.peers, p)
+		}
+	}
+	downloadCache = c
+	return nil
+}
+
+// cacheMaxMB returns the size limit of the download cache in MB.
+func cacheMaxMB() (int64, error) {
+	v := os.Getenv(env.DownloadCacheMaxMB)
+	if v == "" {
+		return defaultCacheMaxMB, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, gcp.UserErrorf("invalid %s %q: want a non-negative number of MB", env.DownloadCacheMaxMB, v)
+	}
+	return n, nil
+}
+
+// activeCache returns the download cache enabled by UseCache, or nil.
+func activeCache() *Cache {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	return downloadCache
+}
+
+// localCopy returns the path of an already downloaded copy of url, from a prefetch or the download
+// cache, and a function to call once the copy has been read. If digest is set, only a copy with
+// that SHA-256 digest is returned.
+func localCopy(url, digest string) (string, func(), bool) {
+	if path, release, ok := takePrefetched(url); ok {
+		if digest == "" || hasDigest(path, digest) {
+			return path, release, true
+		}
+		release()
+	}
+	if c := activeCache(); c != nil {
+		if path, ok := c.lookup(url, digest); ok {
+			return path, func() {}, true
+		}
+	}
+	return "", nil, false
+}
+
+// cacheKey returns the index key of the content of url. It includes the expected digest, if known,
+// so that content cached without that expectation is never used in its place.
+func cacheKey(url, digest string) string {
+	if digest == "" {
+		return url
+	}
+	return url + "#sha256=" + digest
+}
+
+// hasDigest reports whether the file at path has the hex SHA-256 digest.
+func hasDigest(path, digest string) bool {
+	got, _, err := fileDigest(path)
+	return err == nil && got == digest
+}
+
+func readCacheIndex(dir string) (map[string]*cacheEntry, error) {
+	index := map[string]*cacheEntry{}
+	content, err := os.ReadFile(filepath.Join(dir, cacheIndexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, cacheBlobsDir, digest)
+}
+
+// lookup returns the path of the artifact downloaded from url, if it is in this cache or a peer's.
+// If digest is set, the artifact must have been stored with that expected digest, and is read to
+// verify that it still has it. Otherwise the server is asked whether the content of url changed.
+func (c *Cache) lookup(url, digest string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(url, digest)
+	if e, ok := c.index[key]; ok {
+		path := c.blobPath(e.Digest)
+		if fi, err := os.Stat(path); err == nil && fi.Size() == e.Size {
+			switch {
+			case digest != "" && !hasDigest(path, digest):
+				c.logf("Discarding cached download of %s, which does not have SHA-256 digest %s", url, digest)
+				os.Remove(path)
+			case digest == "" && !c.unchanged(url, e):
+			default:
+				e.LastUsed = time.Now()
+				c.save()
+				c.logf("Using cached download of %s", url)
+				return path, true
+			}
+		}
+		delete(c.index, key)
+		c.removeUnused(e.Digest)
+	}
+	for _, peer := range c.peers {
+		index, err := readCacheIndex(peer)
+		if err != nil {
+			continue
+		}
+		e, ok := index[key]
+		if !ok {
+			continue
+		}
+		src := filepath.Join(peer, cacheBlobsDir, e.Digest)
+		if fi, err := os.Stat(src); err != nil || fi.Size() != e.Size {
+			continue
+		}
+		if digest != "" && !hasDigest(src, digest) {
+			continue
+		}
+		if digest == "" && !c.unchanged(url, e) {
+			continue
+		}
+		path := c.blobPath(e.Digest)
+		if err := linkOrCopy(src, path); err != nil {
+			c.logf("Failed to reuse the cached download of %s from %s: %v", url, peer, err)
+			continue
+		}
+		c.put(key, e.Digest, e.Size, e.Validator)
+		c.logf("Using download of %s cached by another buildpack", url)
+		return path, true
+	}
+	return "", false
+}
+
+// unchanged reports whether the content of url is still the artifact of e, which was cached without
+// an expected digest. The server must report the same validator for the content, or the same size
+// if the artifact was cached without a validator.
+func (c *Cache) unchanged(url string, e *cacheEntry) bool {
+	validator, size, err := contentInfo(url)
+	if err != nil {
+		c.logf("Not using the cached download of %s, which could not be revalidated: %v", url, err)
+		return false
+	}
+	if e.Validator != "" && validator == e.Validator || e.Validator == "" && size >= 0 && size == e.Size {
+		return true
+	}
+	c.logf("Discarding cached download of %s, which changed since it was cached", url)
+	return false
+}
+
+// removeUnused removes the artifact with the given digest unless other URLs in the index share it.
+// c.mu must be held.
+func (c *Cache) removeUnused(digest string) {
+	for _, e := range c.index {
+		if e.Digest == digest {
+			return
+		}
+	}
+	if err := os.Remove(c.blobPath(digest)); err != nil && !os.IsNotExist(err) {
+		c.logf("Failed to remove %s from the download cache: %v", digest, err)
+	}
+}
+
+// create returns a temporary file in the cache to download an artifact into.
+func (c *Cache) create() (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Join(c.dir, cacheBlobsDir), ".download-*")
+	if err != nil {
+		return nil, gcp.InternalErrorf("creating file in download cache: %v", err)
+	}
+	return f, nil
+}
+
+// abort discards a file returned by create.
+func (c *Cache) abort(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// commit adds a file returned by create, holding the complete content of url, to the cache and
+// returns the path of the stored artifact. If want is set, the content must have that digest.
+// validator is the validator of the response the content was downloaded with, if any.
+func (c *Cache) commit(url, want, validator string, f *os.File) (string, error) {
+	defer os.Remove(f.Name())
+	if err := f.Close(); err != nil {
+		return "", gcp.InternalErrorf("closing %q: %v", f.Name(), err)
+	}
+	digest, size, err := fileDigest(f.Name())
+	if err != nil {
+		return "", err
+	}
+	if want != "" {
+		if err := checkDigest(url, digest, want); err != nil {
+			return "", err
+		}
+	}
+	path := c.blobPath(digest)
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", gcp.InternalErrorf("storing %s in download cache: %v", url, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(cacheKey(url, want), digest, size, validator)
+	return path, nil
+}
+
+// addFile copies the complete content of url, downloaded to path, into the cache. If digest is set,
+// the content must have that digest.
+func (c *Cache) addFile(url, digest, validator, path string) error {
+	f, err := c.create()
+	if err != nil {
+		return err
+	}
+	if err := copyFile(path, f); err != nil {
+		c.abort(f)
+		return err
+	}
+	_, err = c.commit(url, digest, validator, f)
+	return err
+}
+
+// put records that the content with the given index key is the artifact with the given digest.
+// c.mu must be held.
+func (c *Cache) put(key, digest string, size int64, validator string) {
+	c.index[key] = &cacheEntry{Digest: digest, Size: size, Validator: validator, LastUsed: time.Now()}
+	c.evict(digest)
+	c.save()
+}
+
+// evict removes the least recently used artifacts, except keep, until the cache fits its size limit.
+// c.mu must be held.
+func (c *Cache) evict(keep string) {
+	// Several URLs may share an artifact, which is used as recently as the most recent of them.
+	type artifact struct {
+		digest   string
+		size     int64
+		lastUsed time.Time
+		urls     []string
+	}
+	byDigest := map[string]*artifact{}
+	var total int64
+	for url, e := range c.index {
+		a, ok := byDigest[e.Digest]
+		if !ok {
+			a = &artifact{digest: e.Digest, size: e.Size}
+			byDigest[e.Digest] = a
+			total += e.Size
+		}
+		if e.LastUsed.After(a.lastUsed) {
+			a.lastUsed = e.LastUsed
+		}
+		a.urls = append(a.urls, url)
+	}
+	if total <= c.maxBytes {
+		return
+	}
+
+	var artifacts []*artifact
+	for _, a := range byDigest {
+		if a.digest != keep {
+			artifacts = append(artifacts, a)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].lastUsed.Before(artifacts[j].lastUsed)
+	})
+	for _, a := range artifacts {
+		if total <= c.maxBytes {
+			return
+		}
+		if err := os.Remove(c.blobPath(a.digest)); err != nil && !os.IsNotExist(err) {
+			c.logf("Failed to evict %s from the download cache: %v", a.digest, err)
+			continue
+		}
+		for _, url := range a.urls {
+			delete(c.index, url)
+		}
+		total -= a.size
+		c.logf("Evicted %s (%d MB, last used %s) from the download cache", a.urls[0], a.size>>20, a.lastUsed.Format(time.RFC3339))
+	}
+}
+
+// save writes the index. c.mu must be held.
+func (c *Cache) save() {
+	content, err := json.Marshal(c.index)
+	if err != nil {
+		c.logf("Failed to encode the download cache index: %v", err)
+		return
+	}
+	tmp := filepath.Join(c.dir, cacheIndexFile+".tmp")
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		c.logf("Failed to write the download cache index: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, cacheIndexFile)); err != nil {
+		c.logf("Failed to write the download cache index: %v", err)
+	}
+}
+
+// fileDigest returns the hex SHA-256 digest and size of a file.
+func fileDigest(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, gcp.InternalErrorf("opening %q: %v", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, gcp.InternalErrorf("reading %q: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// linkOrCopy hard links src to dst, copying it if the two are on different file systems.
+func linkOrCopy(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".copy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// cachingReader copies what it reads into a download cache file, which it adds to the cache once
+// the content has been read to the end.
+type cachingReader struct {
+	r   io.ReadCloser
+	c   *Cache
+	url string
+	// digest is the expected digest of the content, or "" if it is not known.
+	digest string
+	// validator is the validator of the response being read, if any.
+	validator string
+
+	// mu guards f, as Close may be called from another goroutine.
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Read implements io.Reader.
+func (cr *cachingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.f == nil {
+		return n, err
+	}
+	if _, werr := cr.f.Write(p[:n]); werr != nil {
+		cr.c.logf("Failed to cache the download of %s: %v", cr.url, werr)
+		cr.c.abort(cr.f)
+		cr.f = nil
+		return n, err
+	}
+	if err == io.EOF {
+		if _, cerr := cr.c.commit(cr.url, cr.digest, cr.validator, cr.f); cerr != nil {
+			cr.c.logf("Failed to cache the download of %s: %v", cr.url, cerr)
+		}
+		cr.f = nil
+	}
+	return n, err
+}
+
+// Close implements io.Closer. An incomplete download is discarded.
+func (cr *cachingReader) Close() error {
+	err := cr.r.Close()
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.f != nil {
+		cr.c.abort(cr.f)
+		cr.f = nil
+	}
+	return err
+}
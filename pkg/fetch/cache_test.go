@@ -0,0 +1,453 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/buildpacks/libcnb"
+)
+
+// testLog collects the lines logged by a cache.
+type testLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLog) logf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *testLog) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// useTestCache makes the package use a new cache in dir for the duration of a test.
+func useTestCache(t *testing.T, dir string, maxBytes int64) (*Cache, *testLog) {
+	t.Helper()
+	log := &testLog{}
+	c, err := NewCache(dir, maxBytes, log.logf)
+	if err != nil {
+		t.Fatalf("NewCache(%q) got error: %v", dir, err)
+	}
+	cacheMu.Lock()
+	orig := downloadCache
+	downloadCache = c
+	cacheMu.Unlock()
+	t.Cleanup(func() {
+		cacheMu.Lock()
+		downloadCache = orig
+		cacheMu.Unlock()
+	})
+	return c, log
+}
+
+// testValidator is the validator of the content of fake URLs in tests stubbed by stubContentInfo.
+const testValidator = `"test-etag"`
+
+// stubContentInfo makes the server of every URL report validator for the duration of a test.
+func stubContentInfo(t *testing.T, validator string) {
+	t.Helper()
+	orig := contentInfo
+	contentInfo = func(string) (string, int64, error) {
+		return validator, -1, nil
+	}
+	t.Cleanup(func() {
+		contentInfo = orig
+	})
+}
+
+func TestTarballUsesCache(t *testing.T) {
+	content, err := os.ReadFile(testdata.MustGetPath("testdata/test.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &contentServer{content: content, ranges: true}
+	url := newContentServer(t, s)
+	cacheDir := t.TempDir()
+	useTestCache(t, cacheDir, 1<<20)
+
+	for i := 0; i < 2; i++ {
+		dir := t.TempDir()
+		if err := Tarball(url, dir, 0); err != nil {
+			t.Fatalf("Tarball(%q, %q, 0) got error: %v", url, dir, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "lib/foo.txt")); err != nil {
+			t.Errorf("Failed to extract. Missing file: lib/foo.txt (%v)", err)
+		}
+	}
+	if got := s.requests - s.heads; got != 1 {
+		t.Errorf("Cached tarball was downloaded %d times, want 1", got)
+	}
+
+	// A new cache in the same layer, as in a later build, still has the tarball.
+	useTestCache(t, cacheDir, 1<<20)
+	if err := Tarball(url, t.TempDir(), 0); err != nil {
+		t.Fatalf("Tarball(%q) got error: %v", url, err)
+	}
+	if got := s.requests - s.heads; got != 1 {
+		t.Errorf("Tarball cached by an earlier build was downloaded %d times, want 1", got)
+	}
+}
+
+func TestFileUsesCache(t *testing.T) {
+	s := &contentServer{content: testContent(5000), ranges: true}
+	url := newContentServer(t, s)
+	useTestCache(t, t.TempDir(), 1<<20)
+
+	for i := 0; i < 2; i++ {
+		out := filepath.Join(t.TempDir(), "out")
+		if err := File(url, out); err != nil {
+			t.Fatalf("File(%q, %q) got error: %v", url, out, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(s.content) {
+			t.Errorf("File(%q, %q) wrote %d bytes, want %d", url, out, len(got), len(s.content))
+		}
+	}
+	if got := s.requests - s.heads; got != 1 {
+		t.Errorf("Cached file was downloaded %d times, want 1", got)
+	}
+}
+
+func TestCacheRevalidatesContent(t *testing.T) {
+	testCases := []struct {
+		name          string
+		etag          string
+		changed       []byte
+		wantDownloads int32
+	}{
+		{
+			name:          "same etag",
+			etag:          `"v1"`,
+			wantDownloads: 1,
+		},
+		{
+			name:          "changed etag",
+			etag:          `"v1"`,
+			changed:       testContent(4000),
+			wantDownloads: 2,
+		},
+		{
+			name:          "same size without etag",
+			wantDownloads: 1,
+		},
+		{
+			name:          "changed size without etag",
+			changed:       testContent(4000),
+			wantDownloads: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &contentServer{content: testContent(5000), ranges: true, etag: tc.etag, changed: tc.changed}
+			url := newContentServer(t, s)
+			_, log := useTestCache(t, t.TempDir(), 1<<20)
+
+			var got []byte
+			for i := 0; i < 2; i++ {
+				out := filepath.Join(t.TempDir(), "out")
+				if err := File(url, out); err != nil {
+					t.Fatalf("File(%q, %q) got error: %v", url, out, err)
+				}
+				var err error
+				if got, err = os.ReadFile(out); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if downloads := s.requests - s.heads; downloads != tc.wantDownloads {
+				t.Errorf("File(%q) downloaded %d times, want %d", url, downloads, tc.wantDownloads)
+			}
+			want := s.content
+			if tc.changed != nil {
+				want = tc.changed
+				if !log.contains("changed since it was cached") {
+					t.Errorf("cache logged %q, want the changed content discarded", log.lines)
+				}
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("File(%q) got %d bytes of content, want %d", url, len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestFailedDownloadIsNotCached(t *testing.T) {
+	setDownloadVars(t, 1000)
+	content, err := os.ReadFile(testdata.MustGetPath("testdata/test.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := newContentServer(t, &contentServer{content: content, failures: maxResumes + 1})
+	c, _ := useTestCache(t, t.TempDir(), 1<<20)
+
+	if err := Tarball(url, t.TempDir(), 0); err == nil {
+		t.Fatalf("Tarball(%q) got no error, want error", url)
+	}
+	if _, ok := c.lookup(url, ""); ok {
+		t.Errorf("Incomplete download of %q was cached", url)
+	}
+	blobs, err := os.ReadDir(filepath.Join(c.dir, cacheBlobsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("Download cache holds %d files after a failed download, want 0", len(blobs))
+	}
+}
+
+func TestTarballVerifiesDigest(t *testing.T) {
+	content, err := os.ReadFile(testdata.MustGetPath("testdata/test.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	testCases := []struct {
+		name      string
+		digest    string
+		wantError bool
+	}{
+		{
+			name:   "matching digest",
+			digest: digest,
+		},
+		{
+			name:      "other digest",
+			digest:    strings.Repeat("0", len(digest)),
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url := newContentServer(t, &contentServer{content: content, ranges: true})
+			c, _ := useTestCache(t, t.TempDir(), 1<<20)
+
+			err := Tarball(url, t.TempDir(), 0, WithSHA256(tc.digest))
+			if gotError := err != nil; gotError != tc.wantError {
+				t.Fatalf("Tarball(%q, WithSHA256(%q)) got error: %v, want error? %v", url, tc.digest, err, tc.wantError)
+			}
+			if _, ok := c.lookup(url, tc.digest); ok == tc.wantError {
+				t.Errorf("lookup(%q, %q) hit = %v, want %v", url, tc.digest, ok, !tc.wantError)
+			}
+			if _, ok := c.lookup(url, ""); ok {
+				t.Errorf("lookup(%q) without a digest hit, want the digest to be part of the key", url)
+			}
+		})
+	}
+}
+
+func TestCacheVerifiesDigestOnRead(t *testing.T) {
+	c, log := useTestCache(t, t.TempDir(), 1<<20)
+	content := []byte("cached content")
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	path := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	url := "https://example.com/content"
+	if err := c.addFile(url, digest, "", path); err != nil {
+		t.Fatalf("addFile(%q, %q) got error: %v", url, digest, err)
+	}
+	// Corrupt the stored artifact without changing its size.
+	if err := os.WriteFile(c.blobPath(digest), []byte("CACHED CONTENT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.lookup(url, digest); ok {
+		t.Errorf("lookup(%q, %q) hit a corrupted artifact, want miss", url, digest)
+	}
+	if !log.contains("Discarding cached download") {
+		t.Errorf("cache logged %q, want the corrupted artifact discarded", log.lines)
+	}
+	if _, err := os.Stat(c.blobPath(digest)); !os.IsNotExist(err) {
+		t.Errorf("corrupted artifact still exists, stat got %v", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, log := useTestCache(t, t.TempDir(), 2500)
+	stubContentInfo(t, testValidator)
+
+	add := func(url string, size int) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "content")
+		if err := os.WriteFile(path, []byte(strings.Repeat(url, size/len(url))), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.addFile(url, "", testValidator, path); err != nil {
+			t.Fatalf("addFile(%q) got error: %v", url, err)
+		}
+	}
+	add("https://a", 1000)
+	add("https://b", 1000)
+	// Using a makes b the least recently used artifact.
+	if _, ok := c.lookup("https://a", ""); !ok {
+		t.Fatalf("lookup(%q) missed, want hit", "https://a")
+	}
+	add("https://c", 1000)
+
+	for url, want := range map[string]bool{"https://a": true, "https://b": false, "https://c": true} {
+		if _, got := c.lookup(url, ""); got != want {
+			t.Errorf("lookup(%q) hit = %v, want %v", url, got, want)
+		}
+	}
+	if !log.contains("Evicted https://b") {
+		t.Errorf("Eviction of https://b was not logged, got logs: %v", log.lines)
+	}
+}
+
+func TestCacheSharesIdenticalContent(t *testing.T) {
+	c, _ := useTestCache(t, t.TempDir(), 1<<20)
+	stubContentInfo(t, testValidator)
+	path := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(path, []byte("same content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"https://mirror-1/x.tar.gz", "https://mirror-2/x.tar.gz"} {
+		if err := c.addFile(url, "", testValidator, path); err != nil {
+			t.Fatalf("addFile(%q) got error: %v", url, err)
+		}
+	}
+	blobs, err := os.ReadDir(filepath.Join(c.dir, cacheBlobsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 {
+		t.Errorf("Download cache holds %d files for identical content, want 1", len(blobs))
+	}
+}
+
+func TestCacheReadsPeers(t *testing.T) {
+	layersRoot := t.TempDir()
+	peerDir := filepath.Join(layersRoot, "google.nodejs.runtime", downloadCacheLayer)
+	peer, err := NewCache(peerDir, 1<<20, t.Logf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(path, []byte("node tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	url := "https://example.com/node.tar.gz"
+	if err := peer.addFile(url, "", testValidator, path); err != nil {
+		t.Fatal(err)
+	}
+
+	ownDir := filepath.Join(layersRoot, "google.nodejs.npm", downloadCacheLayer)
+	c, _ := useTestCache(t, ownDir, 1<<20)
+	c.peers = []string{peerDir}
+	stubContentInfo(t, testValidator)
+	got, ok := c.lookup(url, "")
+	if !ok {
+		t.Fatalf("lookup(%q) missed, want hit from peer cache", url)
+	}
+	if !strings.HasPrefix(got, ownDir) {
+		t.Errorf("lookup(%q) = %q, want a path in %q", url, got, ownDir)
+	}
+	if content, err := os.ReadFile(got); err != nil || string(content) != "node tarball" {
+		t.Errorf("Reading %q got %q, %v, want %q", got, content, err, "node tarball")
+	}
+}
+
+func TestUseCache(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxMB     string
+		wantCache bool
+		wantError bool
+	}{
+		{
+			name:      "default",
+			wantCache: true,
+		},
+		{
+			name:  "disabled",
+			maxMB: "0",
+		},
+		{
+			name:      "invalid size",
+			maxMB:     "lots",
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.DownloadCacheMaxMB, tc.maxMB)
+			cacheMu.Lock()
+			orig := downloadCache
+			downloadCache = nil
+			cacheMu.Unlock()
+			t.Cleanup(func() {
+				cacheMu.Lock()
+				downloadCache = orig
+				cacheMu.Unlock()
+			})
+
+			root := t.TempDir()
+			peerDir := filepath.Join(root, "other-buildpack", downloadCacheLayer)
+			if err := os.MkdirAll(peerDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			layersDir := filepath.Join(root, "this-buildpack")
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+
+			err := UseCache(ctx)
+			if tc.wantError != (err != nil) {
+				t.Fatalf("UseCache() got error: %v, want error? %v", err, tc.wantError)
+			}
+			c := activeCache()
+			if got := c != nil; got != tc.wantCache {
+				t.Fatalf("UseCache() enabled cache = %v, want %v", got, tc.wantCache)
+			}
+			if c == nil {
+				return
+			}
+			if want := filepath.Join(layersDir, downloadCacheLayer); c.dir != want {
+				t.Errorf("Cache dir = %q, want %q", c.dir, want)
+			}
+			if len(c.peers) != 1 || c.peers[0] != peerDir {
+				t.Errorf("Cache peers = %v, want [%s]", c.peers, peerDir)
+			}
+		})
+	}
+}
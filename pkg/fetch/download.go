@@ -0,0 +1,553 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// maxResumes is the number of times a download is resumed after failing mid-stream.
+	maxResumes = 3
+	// defaultSegments is the default number of concurrent range requests of a large download.
+	defaultSegments = 4
+	// readAheadChunkSize and readAheadChunks bound how far the network may run ahead of extraction.
+	readAheadChunkSize = 256 << 10
+	readAheadChunks    = 16
+)
+
+var (
+	// segmentSize is the size of each range request of a segmented download. Content no larger than
+	// one segment is downloaded with a single request.
+	segmentSize int64 = 16 << 20
+	// resumeDelay is the pause before resuming an interrupted download.
+	resumeDelay = time.Second
+)
+
+// errRangeNotSatisfiable is returned when a range request starts at or beyond the end of the content.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// downloadStats accumulates the bytes and retries of a download, which may span several requests.
+type downloadStats struct {
+	start   time.Time
+	bytes   int64
+	retries int64
+}
+
+func newDownloadStats() *downloadStats {
+	return &downloadStats{start: time.Now()}
+}
+
+// record adds the download to the builder metrics. Builder metrics are not safe for concurrent use,
+// so it must be called from the goroutine of the public function that requested the download.
+func (s *downloadStats) record() {
+	bm := buildermetrics.GlobalBuilderMetrics()
+	bm.GetCounter(buildermetrics.DownloadsCounterID).Increment(1)
+	bm.GetCounter(buildermetrics.DownloadBytesCounterID).Increment(atomic.LoadInt64(&s.bytes))
+	bm.GetCounter(buildermetrics.DownloadRetriesCounterID).Increment(atomic.LoadInt64(&s.retries))
+	bm.GetCounter(buildermetrics.DownloadMsCounterID).Increment(time.Since(s.start).Milliseconds())
+}
+
+// getRange performs an HTTP GET request for the bytes of a URL from offset to end inclusive. An end
+// of -1 requests the rest of the content, and offset 0 with end -1 requests the whole content. If
+// ifRange is set, it is sent as the If-Range validator of a range request, so that the server sends
+// the whole content instead of the range if the content has changed.
+func getRange(url string, offset, end int64, ifRange string, stats *downloadStats) (*http.Response, error) {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 3
+	if stats != nil {
+		retryClient.RequestLogHook = func(_ retryablehttp.Logger, _ *http.Request, attempt int) {
+			if attempt > 0 {
+				atomic.AddInt64(&stats.retries, 1)
+			}
+		}
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, gcp.UserErrorf("fetching %s: %v", url, err)
+	}
+
+	req.Header.Set("User-Agent", gcpUserAgent)
+	if end >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if ifRange != "" && req.Header.Get("Range") != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
+
+	response, err := retryClient.StandardClient().Do(req)
+	if err != nil {
+		return nil, gcp.UserErrorf("requesting %s: %v", url, err)
+	}
+	if response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		response.Body.Close()
+		return nil, gcp.UserErrorf("fetching %s from byte %d: %w", url, offset, errRangeNotSatisfiable)
+	}
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		defer response.Body.Close()
+		return nil, gcp.UserErrorf("fetching %s returned HTTP status: %d", url, response.StatusCode)
+	}
+	return response, err
+}
+
+// contentInfo performs an HTTP HEAD request for url and returns the validator of its content, see
+// responseValidator, and its size, or -1 if the server does not report it. It is a variable so that
+// tests can stub the request.
+var contentInfo = func(url string) (string, int64, error) {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 3
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return "", 0, gcp.UserErrorf("fetching %s: %v", url, err)
+	}
+	req.Header.Set("User-Agent", gcpUserAgent)
+	response, err := retryClient.StandardClient().Do(req)
+	if err != nil {
+		return "", 0, gcp.UserErrorf("requesting %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return "", 0, gcp.UserErrorf("fetching %s returned HTTP status: %d", url, response.StatusCode)
+	}
+	return responseValidator(response), response.ContentLength, nil
+}
+
+// resumableReader reads a byte range of a URL, reopening the connection from the last byte read with
+// a range request when it fails mid-stream.
+type resumableReader struct {
+	url   string
+	stats *downloadStats
+	// offset is the position of the next byte to read.
+	offset int64
+	// end is the position of the last byte requested, or -1 for the end of the content.
+	end int64
+	// last is the position of the last byte the server will send, or -1 if unknown.
+	last int64
+	// total is the size of the whole content, or -1 if unknown.
+	total int64
+	// ranges is whether the server answered with a partial response.
+	ranges bool
+	// validator is the strong ETag or Last-Modified date of the content, if the server sent one. It
+	// is sent as If-Range when resuming so that a change to the content is detected.
+	validator string
+	resumes   int
+
+	// mu guards body and closed, as Close may be called from another goroutine.
+	mu     sync.Mutex
+	body   io.ReadCloser
+	closed bool
+}
+
+// openResumable requests the bytes of url from offset to end inclusive; see getRange.
+func openResumable(url string, offset, end int64, stats *downloadStats) (*resumableReader, error) {
+	return openValidated(url, offset, end, "", stats)
+}
+
+// openValidated is like openResumable, but fails if the content no longer matches validator, the
+// validator of an earlier response for the same content.
+func openValidated(url string, offset, end int64, validator string, stats *downloadStats) (*resumableReader, error) {
+	r := &resumableReader{url: url, offset: offset, end: end, validator: validator, stats: stats}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *resumableReader) open() error {
+	response, err := getRange(r.url, r.offset, r.end, r.validator, r.stats)
+	if err != nil {
+		return err
+	}
+	v := responseValidator(response)
+	if r.validator == "" {
+		r.validator = v
+	} else if response.StatusCode != http.StatusPartialContent && r.offset > 0 && v != r.validator {
+		// The server sent the whole content because it no longer matches what was read so far.
+		response.Body.Close()
+		return gcp.UserErrorf("downloading %s: the content changed while it was being downloaded", r.url)
+	}
+	if response.StatusCode == http.StatusPartialContent {
+		start, last, total, err := parseContentRange(response.Header.Get("Content-Range"))
+		if err != nil || start != r.offset {
+			response.Body.Close()
+			return gcp.InternalErrorf("fetching %s: unexpected Content-Range %q for offset %d", r.url, response.Header.Get("Content-Range"), r.offset)
+		}
+		r.last, r.total, r.ranges = last, total, true
+		return r.setBody(response.Body)
+	}
+
+	// The server ignored the range and sent the whole content.
+	r.total, r.ranges = response.ContentLength, false
+	r.last = -1
+	if r.total >= 0 {
+		r.last = r.total - 1
+	}
+	if r.offset == 0 {
+		// A request from the start is satisfied by the whole content, even if it asked for less.
+		r.end = -1
+	} else {
+		if _, err := io.CopyN(io.Discard, response.Body, r.offset); err != nil {
+			response.Body.Close()
+			return gcp.UserErrorf("resuming %s at byte %d: %v", r.url, r.offset, err)
+		}
+		if r.end >= 0 {
+			r.last = r.end
+		}
+	}
+	return r.setBody(response.Body)
+}
+
+// setBody replaces the response body being read, unless the reader has been closed.
+func (r *resumableReader) setBody(body io.ReadCloser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		body.Close()
+		return gcp.InternalErrorf("downloading %s: reader closed", r.url)
+	}
+	r.body = body
+	return nil
+}
+
+// currentBody returns the response body being read.
+func (r *resumableReader) currentBody() io.ReadCloser {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.body
+}
+
+// responseValidator returns the validator that identifies the content of a response for If-Range:
+// its ETag, unless it is weak, or else its Last-Modified date. It returns "" if there is neither.
+func responseValidator(response *http.Response) string {
+	if etag := response.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return response.Header.Get("Last-Modified")
+}
+
+// Read implements io.Reader.
+func (r *resumableReader) Read(p []byte) (int, error) {
+	if r.last >= 0 {
+		if r.offset > r.last {
+			return 0, io.EOF
+		}
+		if remaining := r.last - r.offset + 1; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	for {
+		n, err := r.currentBody().Read(p)
+		r.offset += int64(n)
+		atomic.AddInt64(&r.stats.bytes, int64(n))
+		if err == io.EOF && r.last >= 0 && r.offset <= r.last {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		// The connection failed mid-stream; continue from the current offset.
+		if rerr := r.resume(err); rerr != nil {
+			return n, rerr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume reopens the connection from the current offset after cause interrupted it.
+func (r *resumableReader) resume(cause error) error {
+	r.currentBody().Close()
+	if r.resumes >= maxResumes {
+		return gcp.UserErrorf("downloading %s: %v", r.url, cause)
+	}
+	r.resumes++
+	atomic.AddInt64(&r.stats.retries, 1)
+	time.Sleep(resumeDelay)
+	return r.open()
+}
+
+// Close implements io.Closer. It may be called while another goroutine is blocked in Read.
+func (r *resumableReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.body.Close()
+}
+
+// parseContentRange parses a Content-Range header such as "bytes 0-99/1000". The total is -1 if the
+// server does not know it.
+func parseContentRange(h string) (start, last, total int64, err error) {
+	spec, ok := strings.CutPrefix(h, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unsupported unit in %q", h)
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("missing size in %q", h)
+	}
+	first, second, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid range in %q", h)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if last, err = strconv.ParseInt(second, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return start, last, total, nil
+}
+
+// downloadSegments returns the number of concurrent range requests to use for large content.
+func downloadSegments() int {
+	v := os.Getenv(env.DownloadSegments)
+	if v == "" {
+		return defaultSegments
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return defaultSegments
+	}
+	return n
+}
+
+// downloadFile writes the content of url to out and returns the validator of the content, if the
+// server sent one. Content larger than one segment is downloaded with concurrent range requests
+// when the server supports them.
+func downloadFile(url string, out *os.File, stats *downloadStats) (string, error) {
+	segments := downloadSegments()
+	end := int64(-1)
+	if segments > 1 {
+		// Probe with the first segment; the response tells whether ranges work and the total size.
+		end = segmentSize - 1
+	}
+	first, err := openResumable(url, 0, end, stats)
+	if err != nil {
+		return "", err
+	}
+	defer first.Close()
+
+	if first.ranges && first.total < 0 {
+		// The server does not know the size, so the content cannot be split into segments. Read the
+		// probed range, then the rest with a single request.
+		return first.validator, copyUnknownSize(url, out, first, stats)
+	}
+	if !first.ranges || first.total <= segmentSize {
+		if _, err := io.Copy(out, first); err != nil {
+			return "", gcp.InternalErrorf("downloading %s: %v", url, err)
+		}
+		return first.validator, nil
+	}
+
+	// first is read by another goroutine, which updates its fields when resuming.
+	total, validator := first.total, first.validator
+	s := NewScheduler(segments)
+	s.Go(func() error {
+		return copySegment(url, io.NewOffsetWriter(out, 0), first, segmentSize)
+	})
+	for start := segmentSize; start < total; start += segmentSize {
+		start, end := start, min(start+segmentSize, total)-1
+		s.Go(func() error {
+			r, err := openValidated(url, start, end, validator, stats)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return copySegment(url, io.NewOffsetWriter(out, start), r, end-start+1)
+		})
+	}
+	return validator, s.Wait()
+}
+
+// copyUnknownSize copies content of unknown size to out, starting with first, the response to a
+// range request from the start of the content.
+func copyUnknownSize(url string, out io.Writer, first *resumableReader, stats *downloadStats) error {
+	n, err := io.Copy(out, first)
+	if err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", url, err)
+	}
+	if first.end < 0 || n < first.end+1 {
+		// The content ended within the range.
+		return nil
+	}
+	rest, err := openValidated(url, n, -1, first.validator, stats)
+	if errors.Is(err, errRangeNotSatisfiable) {
+		// The content ended exactly at the end of the range.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer rest.Close()
+	if _, err := io.Copy(out, rest); err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", url, err)
+	}
+	return nil
+}
+
+// copySegment copies a segment of size bytes from r to w.
+func copySegment(url string, w io.Writer, r io.Reader, size int64) error {
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", url, err)
+	}
+	if n != size {
+		return gcp.InternalErrorf("downloading %s: got %d bytes of a %d byte segment", url, n, size)
+	}
+	return nil
+}
+
+// readAheadReader reads from an underlying reader in a separate goroutine, so that downloading and
+// extracting an archive overlap.
+type readAheadReader struct {
+	r      io.ReadCloser
+	chunks chan []byte
+	done   chan struct{}
+	exited chan struct{}
+	// err is the error that stopped the goroutine. It is set before chunks is closed.
+	err   error
+	cur   []byte
+	close sync.Once
+}
+
+// readAhead returns a reader of r that buffers up to readAheadChunks chunks ahead of its consumer.
+func readAhead(r io.ReadCloser) io.ReadCloser {
+	ra := &readAheadReader{
+		r:      r,
+		chunks: make(chan []byte, readAheadChunks),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go ra.fill()
+	return ra
+}
+
+func (ra *readAheadReader) fill() {
+	defer close(ra.exited)
+	defer close(ra.chunks)
+	for {
+		buf := make([]byte, readAheadChunkSize)
+		n, err := ra.r.Read(buf)
+		if n > 0 {
+			select {
+			case ra.chunks <- buf[:n]:
+			case <-ra.done:
+				return
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			ra.err = err
+			return
+		}
+	}
+}
+
+// Read implements io.Reader.
+func (ra *readAheadReader) Read(p []byte) (int, error) {
+	if len(ra.cur) == 0 {
+		c, ok := <-ra.chunks
+		if !ok {
+			if ra.err != nil {
+				return 0, ra.err
+			}
+			return 0, io.EOF
+		}
+		ra.cur = c
+	}
+	n := copy(p, ra.cur)
+	ra.cur = ra.cur[n:]
+	return n, nil
+}
+
+// Close stops reading ahead and closes the underlying reader.
+func (ra *readAheadReader) Close() error {
+	var err error
+	ra.close.Do(func() {
+		close(ra.done)
+		err = ra.r.Close()
+		<-ra.exited
+	})
+	return err
+}
+
+// openStream returns a reader of the content of url that reads ahead of its consumer and, if a
+// download cache is in use, adds the content to the cache once it has been read to the end. If
+// digest is set, reading to the end fails unless the content has that SHA-256 digest.
+func openStream(url, digest string, stats *downloadStats) (io.ReadCloser, error) {
+	r, err := openResumable(url, 0, -1, stats)
+	if err != nil {
+		return nil, err
+	}
+	var src io.ReadCloser = r
+	if digest != "" {
+		src = &verifyingReader{ReadCloser: r, url: url, want: digest, h: sha256.New()}
+	}
+	if c := activeCache(); c != nil {
+		if f, err := c.create(); err == nil {
+			src = &cachingReader{r: src, c: c, url: url, digest: digest, validator: r.validator, f: f}
+		}
+	}
+	return readAhead(src), nil
+}
+
+// verifyingReader computes the SHA-256 digest of the content it reads, and returns an error
+// instead of io.EOF if the content does not have the expected digest.
+type verifyingReader struct {
+	io.ReadCloser
+	url  string
+	want string
+	h    hash.Hash
+}
+
+// Read implements io.Reader.
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.ReadCloser.Read(p)
+	vr.h.Write(p[:n])
+	if err == io.EOF {
+		if derr := checkDigest(vr.url, hex.EncodeToString(vr.h.Sum(nil)), vr.want); derr != nil {
+			return n, derr
+		}
+	}
+	return n, err
+}
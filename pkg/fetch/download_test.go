@@ -0,0 +1,489 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+)
+
+// contentServer serves content, optionally supporting range requests and dropping the connection
+// part way through the first responses.
+type contentServer struct {
+	content []byte
+	// ranges is whether Range headers are honored.
+	ranges bool
+	// failures is the number of responses cut off after half of their bytes.
+	failures int32
+	// etag, if set, is sent as the ETag of content, which makes range requests honor If-Range.
+	etag string
+	// changed, if set, replaces content after the first request, with a different ETag.
+	changed []byte
+	// unknownSize hides the total size in the Content-Range of partial responses.
+	unknownSize bool
+
+	requests      int32
+	heads         int32
+	rangeRequests int32
+	// ifRanges counts the requests with an If-Range header matching etag.
+	ifRanges int32
+}
+
+func (s *contentServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+	if r.Method == http.MethodHead {
+		atomic.AddInt32(&s.heads, 1)
+	}
+	if r.Header.Get("Range") != "" {
+		atomic.AddInt32(&s.rangeRequests, 1)
+	}
+	if s.etag != "" && r.Header.Get("If-Range") == s.etag {
+		atomic.AddInt32(&s.ifRanges, 1)
+	}
+	if atomic.AddInt32(&s.failures, -1) < 0 {
+		s.serve(w, r)
+		return
+	}
+	rec := httptest.NewRecorder()
+	s.serve(rec, r)
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.Code)
+	body := rec.Body.Bytes()
+	w.Write(body[:len(body)/2])
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func (s *contentServer) serve(w http.ResponseWriter, r *http.Request) {
+	content, etag := s.content, s.etag
+	if s.changed != nil && atomic.LoadInt32(&s.requests) > 1 {
+		content, etag = s.changed, s.etag+"-changed"
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if s.unknownSize {
+		rec := httptest.NewRecorder()
+		http.ServeContent(rec, r, "", time.Time{}, bytes.NewReader(content))
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		if cr := rec.Header().Get("Content-Range"); cr != "" {
+			w.Header().Set("Content-Range", cr[:strings.LastIndex(cr, "/")]+"/*")
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+		return
+	}
+	if !s.ranges {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content)
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func newContentServer(t *testing.T, s *contentServer) string {
+	t.Helper()
+	svr := httptest.NewServer(s)
+	t.Cleanup(svr.Close)
+	return svr.URL
+}
+
+func testContent(size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+func setDownloadVars(t *testing.T, segment int64) {
+	t.Helper()
+	origSegment, origDelay := segmentSize, resumeDelay
+	segmentSize, resumeDelay = segment, time.Millisecond
+	t.Cleanup(func() {
+		segmentSize, resumeDelay = origSegment, origDelay
+	})
+}
+
+func TestGetURLResumes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ranges      bool
+		failures    int32
+		wantError   bool
+		wantRetries int64
+	}{
+		{
+			name: "no failures",
+		},
+		{
+			name:        "resumes with range request",
+			ranges:      true,
+			failures:    2,
+			wantRetries: 2,
+		},
+		{
+			name:        "restarts when server ignores ranges",
+			failures:    1,
+			wantRetries: 1,
+		},
+		{
+			name:      "too many failures",
+			ranges:    true,
+			failures:  maxResumes + 1,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildermetrics.Reset()
+			setDownloadVars(t, 1000)
+			content := testContent(10000)
+			url := newContentServer(t, &contentServer{content: content, ranges: tc.ranges, failures: tc.failures})
+
+			var got bytes.Buffer
+			err := GetURL(url, &got)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("GetURL(%q) got no error, want error", url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetURL(%q) got error: %v", url, err)
+			}
+			if !bytes.Equal(got.Bytes(), content) {
+				t.Errorf("GetURL(%q) got %d bytes, want %d bytes of content", url, got.Len(), len(content))
+			}
+			bm := buildermetrics.GlobalBuilderMetrics()
+			if got := bm.GetCounter(buildermetrics.DownloadRetriesCounterID).Value(); got != tc.wantRetries {
+				t.Errorf("download retries = %d, want %d", got, tc.wantRetries)
+			}
+			if got := bm.GetCounter(buildermetrics.DownloadsCounterID).Value(); got != 1 {
+				t.Errorf("downloads = %d, want 1", got)
+			}
+			if got := bm.GetCounter(buildermetrics.DownloadBytesCounterID).Value(); got < int64(len(content)) {
+				t.Errorf("download bytes = %d, want at least %d", got, len(content))
+			}
+		})
+	}
+}
+
+func TestFileSegments(t *testing.T) {
+	testCases := []struct {
+		name          string
+		size          int
+		ranges        bool
+		segments      string
+		failures      int32
+		unknownSize   bool
+		wantRequests  int32
+		wantRangeReqs int32
+	}{
+		{
+			name:          "small file in one request",
+			size:          500,
+			ranges:        true,
+			wantRequests:  1,
+			wantRangeReqs: 1,
+		},
+		{
+			name:          "large file in segments",
+			size:          10500,
+			ranges:        true,
+			wantRequests:  11,
+			wantRangeReqs: 11,
+		},
+		{
+			name:          "segmented download resumes",
+			size:          10500,
+			ranges:        true,
+			failures:      1,
+			wantRequests:  12,
+			wantRangeReqs: 12,
+		},
+		{
+			name:          "server without ranges",
+			size:          10500,
+			wantRequests:  1,
+			wantRangeReqs: 1,
+		},
+		{
+			name:          "unknown size",
+			size:          2500,
+			ranges:        true,
+			unknownSize:   true,
+			wantRequests:  2,
+			wantRangeReqs: 2,
+		},
+		{
+			name:          "unknown size ending at the first segment",
+			size:          1000,
+			ranges:        true,
+			unknownSize:   true,
+			wantRequests:  2,
+			wantRangeReqs: 2,
+		},
+		{
+			name:          "unknown size within the first segment",
+			size:          500,
+			ranges:        true,
+			unknownSize:   true,
+			wantRequests:  1,
+			wantRangeReqs: 1,
+		},
+		{
+			name:          "segments disabled",
+			size:          10500,
+			ranges:        true,
+			segments:      "1",
+			wantRequests:  1,
+			wantRangeReqs: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setDownloadVars(t, 1000)
+			t.Setenv(env.DownloadSegments, tc.segments)
+			content := testContent(tc.size)
+			s := &contentServer{content: content, ranges: tc.ranges, failures: tc.failures, unknownSize: tc.unknownSize}
+			url := newContentServer(t, s)
+
+			out := filepath.Join(t.TempDir(), "out")
+			if err := File(url, out); err != nil {
+				t.Fatalf("File(%q, %q) got error: %v", url, out, err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("File(%q, %q) wrote %d bytes, want %d bytes of content", url, out, len(got), len(content))
+			}
+			if s.requests != tc.wantRequests || s.rangeRequests != tc.wantRangeReqs {
+				t.Errorf("File(%q, %q) made %d requests (%d with ranges), want %d (%d with ranges)", url, out, s.requests, s.rangeRequests, tc.wantRequests, tc.wantRangeReqs)
+			}
+		})
+	}
+}
+
+func TestResumeIfRange(t *testing.T) {
+	testCases := []struct {
+		name         string
+		changed      bool
+		wantError    bool
+		wantIfRanges int32
+	}{
+		{
+			name:         "unchanged content",
+			wantIfRanges: 1,
+		},
+		{
+			name:      "content changed",
+			changed:   true,
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setDownloadVars(t, 1000)
+			content := testContent(500)
+			s := &contentServer{content: content, ranges: true, failures: 1, etag: `"v1"`}
+			if tc.changed {
+				s.changed = testContent(600)
+			}
+			url := newContentServer(t, s)
+
+			var got bytes.Buffer
+			err := GetURL(url, &got)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("GetURL(%q) got no error, want error", url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetURL(%q) got error: %v", url, err)
+			}
+			if !bytes.Equal(got.Bytes(), content) {
+				t.Errorf("GetURL(%q) got %d bytes, want %d bytes of content", url, got.Len(), len(content))
+			}
+			if s.ifRanges != tc.wantIfRanges {
+				t.Errorf("GetURL(%q) sent If-Range %d times, want %d", url, s.ifRanges, tc.wantIfRanges)
+			}
+		})
+	}
+}
+
+func TestTarballResumes(t *testing.T) {
+	setDownloadVars(t, 1000)
+	content, err := os.ReadFile(testdata.MustGetPath("testdata/test.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := newContentServer(t, &contentServer{content: content, ranges: true, failures: 1})
+
+	dir := t.TempDir()
+	if err := Tarball(url, dir, 0); err != nil {
+		t.Fatalf("Tarball(%q, %q, 0) got error: %v", url, dir, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lib/foo.txt")); err != nil {
+		t.Errorf("Failed to extract. Missing file: lib/foo.txt (%v)", err)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	content, err := os.ReadFile(testdata.MustGetPath("testdata/test.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball := &contentServer{content: content, ranges: true}
+	tarballURL := newContentServer(t, tarball)
+	file := &contentServer{content: testContent(100), ranges: true}
+	fileURL := newContentServer(t, file)
+
+	Prefetch(tarballURL, fileURL, tarballURL)
+
+	dir := t.TempDir()
+	if err := Tarball(tarballURL, dir, 0); err != nil {
+		t.Fatalf("Tarball(%q, %q, 0) got error: %v", tarballURL, dir, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lib/foo.txt")); err != nil {
+		t.Errorf("Failed to extract. Missing file: lib/foo.txt (%v)", err)
+	}
+	var got bytes.Buffer
+	if err := GetURL(fileURL, &got); err != nil {
+		t.Fatalf("GetURL(%q) got error: %v", fileURL, err)
+	}
+	if !bytes.Equal(got.Bytes(), file.content) {
+		t.Errorf("GetURL(%q) got %d bytes, want %d", fileURL, got.Len(), len(file.content))
+	}
+	if tarball.requests != 1 || file.requests != 1 {
+		t.Errorf("Prefetched URLs were requested %d and %d times, want once each", tarball.requests, file.requests)
+	}
+
+	// A prefetched download is consumed once; later calls download again.
+	if err := GetURL(fileURL, &got); err != nil {
+		t.Fatalf("GetURL(%q) got error: %v", fileURL, err)
+	}
+	if file.requests != 2 {
+		t.Errorf("Consumed prefetch was requested %d times, want 2", file.requests)
+	}
+}
+
+func TestPrefetchFailureFallsBack(t *testing.T) {
+	setDownloadVars(t, 1000)
+	var failed int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	t.Cleanup(svr.Close)
+
+	Prefetch(svr.URL)
+	var got bytes.Buffer
+	if err := GetURL(svr.URL, &got); err != nil {
+		t.Fatalf("GetURL(%q) got error: %v", svr.URL, err)
+	}
+	if got.String() != "content" {
+		t.Errorf("GetURL(%q) = %q, want %q", svr.URL, got.String(), "content")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	const concurrency = 2
+	s := NewScheduler(concurrency)
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	wantErr := errors.New("first failure")
+	for i := 0; i < 10; i++ {
+		i := i
+		s.Go(func() error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			if i == 3 {
+				return wantErr
+			}
+			return nil
+		})
+	}
+	if err := s.Wait(); err != wantErr {
+		t.Errorf("Wait() = %v, want %v", err, wantErr)
+	}
+	if maxRunning > concurrency {
+		t.Errorf("Scheduler ran %d functions at once, want at most %d", maxRunning, concurrency)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	testCases := []struct {
+		header    string
+		wantStart int64
+		wantLast  int64
+		wantTotal int64
+		wantError bool
+	}{
+		{header: "bytes 0-99/1000", wantStart: 0, wantLast: 99, wantTotal: 1000},
+		{header: "bytes 100-199/*", wantStart: 100, wantLast: 199, wantTotal: -1},
+		{header: "items 0-1/2", wantError: true},
+		{header: "bytes 0-99", wantError: true},
+		{header: "bytes x-99/100", wantError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			start, last, total, err := parseContentRange(tc.header)
+			if tc.wantError != (err != nil) {
+				t.Fatalf("parseContentRange(%q) got error: %v, want error? %v", tc.header, err, tc.wantError)
+			}
+			if err != nil {
+				return
+			}
+			if start != tc.wantStart || last != tc.wantLast || total != tc.wantTotal {
+				t.Errorf("parseContentRange(%q) = %d, %d, %d, want %d, %d, %d", tc.header, start, last, total, tc.wantStart, tc.wantLast, tc.wantTotal)
+			}
+		})
+	}
+}
@@ -17,7 +17,6 @@ package fetch
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -28,20 +27,55 @@ import (
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/google/go-containerregistry/pkg/crane"
-	"github.com/hashicorp/go-retryablehttp"
 )
 
 // gcpUserAgent is required for the Ruby runtime, but used for others for simplicity.
 const gcpUserAgent = "GCPBuildpacks"
 
-// Tarball downloads a tarball from a URL and extracts it into the provided directory.
-func Tarball(url, dir string, stripComponents int) error {
-	response, err := doGet(url)
+// Option configures a download by Tarball, Archive or File.
+type Option func(o *options)
+
+type options struct {
+	// sha256 is the expected hex SHA-256 digest of the content, or "" if it is not known.
+	sha256 string
+}
+
+// WithSHA256 makes the download fail unless the content has the given hex SHA-256 digest. A copy in
+// the download cache is only used if it was stored with, and still has, that digest.
+func WithSHA256(digest string) Option {
+	return func(o *options) {
+		o.sha256 = strings.ToLower(digest)
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Tarball downloads a tarball from a URL and extracts it into the provided directory. The tarball
+// may be uncompressed or compressed with gzip, xz, zstd or bzip2. Extraction starts while the
+// tarball is still downloading, and an interrupted download is resumed where it stopped.
+func Tarball(url, dir string, stripComponents int, opts ...Option) error {
+	o := newOptions(opts)
+	if path, release, ok := localCopy(url, o.sha256); ok {
+		defer release()
+		return ExtractArchive(path, dir, stripComponents)
+	}
+	stats := newDownloadStats()
+	defer stats.record()
+	r, err := openStream(url, o.sha256, stats)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-	return untar(dir, response.Body, stripComponents)
+	defer r.Close()
+	if err := untar(dir, r, stripComponents); err != nil {
+		return err
+	}
+	return drain(url, r)
 }
 
 // ARVersions downloads list of versions from artifact registry.
@@ -85,20 +119,40 @@ var ARImage = func(url, fallbackURL, dir string, stripComponents int, ctx *gcp.C
 	return untar(dir, rc, stripComponents)
 }
 
-// File downloads a file from a URL and writes it to the provided path.
-func File(url, outPath string) error {
+// File downloads a file from a URL and writes it to the provided path. Large files are downloaded
+// in concurrent segments when the server supports range requests.
+func File(url, outPath string, opts ...Option) error {
+	o := newOptions(opts)
 	out, err := os.Create(outPath)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
-	response, err := doGet(url)
+	if path, release, ok := localCopy(url, o.sha256); ok {
+		defer release()
+		return copyFile(path, out)
+	}
+	stats := newDownloadStats()
+	defer stats.record()
+	validator, err := downloadFile(url, out, stats)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-	_, err = io.Copy(out, response.Body)
-	return err
+	if o.sha256 != "" {
+		digest, _, err := fileDigest(outPath)
+		if err != nil {
+			return err
+		}
+		if err := checkDigest(url, digest, o.sha256); err != nil {
+			return err
+		}
+	}
+	if c := activeCache(); c != nil {
+		if err := c.addFile(url, o.sha256, validator, outPath); err != nil {
+			c.logf("Failed to cache the download of %s: %v", url, err)
+		}
+	}
+	return nil
 }
 
 // JSON fetches a JSON payload from a URL and unmarshals it into the value pointed to by v.
@@ -120,29 +174,66 @@ func JSON(url string, v interface{}) error {
 
 // GetURL makes an HTTP GET request to given URL and writes the body to the provided writer.
 func GetURL(url string, f io.Writer) error {
-	response, err := doGet(url)
+	if path, release, ok := takePrefetched(url); ok {
+		defer release()
+		return copyFile(path, f)
+	}
+	stats := newDownloadStats()
+	defer stats.record()
+	r, err := openResumable(url, 0, -1, stats)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
+	defer r.Close()
 
-	if _, err = io.Copy(f, response.Body); err != nil {
+	if _, err = io.Copy(f, r); err != nil {
 		return gcp.InternalErrorf("copying response body: %v", err)
 	}
 
 	return nil
 }
 
-// untar extracts a tarball from a reader and writes it to the given directory.
+// checkDigest returns an error if the content of url has digest got rather than want.
+func checkDigest(url, got, want string) error {
+	if got != want {
+		return gcp.UserErrorf("downloading %s: got content with SHA-256 digest %s, want %s", url, got, want)
+	}
+	return nil
+}
+
+// drain reads the rest of a download that an archive reader stopped short of, such as compression
+// trailers, so that it is complete when added to the download cache.
+func drain(url string, r io.Reader) error {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return gcp.InternalErrorf("downloading %s: %v", url, err)
+	}
+	return nil
+}
+
+// copyFile writes the contents of the file at path to w.
+func copyFile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return gcp.InternalErrorf("opening %q: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return gcp.InternalErrorf("copying %q: %v", path, err)
+	}
+	return nil
+}
+
+// untar extracts a tarball from a reader and writes it to the given directory. The tarball may be
+// uncompressed or compressed with gzip, xz, zstd or bzip2.
 func untar(dir string, r io.Reader, stripComponents int) error {
-	gzr, err := gzip.NewReader(r)
+	dr, err := decompress(r)
 	if err != nil {
-		return gcp.InternalErrorf("creating gzip reader: %v", err)
+		return err
 	}
-	defer gzr.Close()
+	defer dr.Close()
 
 	madeDir := map[string]bool{}
-	tr := tar.NewReader(gzr)
+	tr := tar.NewReader(dr)
 
 	for {
 		header, err := tr.Next()
@@ -160,6 +251,9 @@ func untar(dir string, r io.Reader, stripComponents int) error {
 		if err != nil {
 			return err
 		}
+		if target, err = resolveEntry(dir, target, header.Typeflag); err != nil {
+			return err
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -182,29 +276,21 @@ func untar(dir string, r io.Reader, stripComponents int) error {
 				madeDir[dir] = true
 			}
 
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return gcp.InternalErrorf("opening file %q: %v", target, err)
-			}
-			if _, err := io.Copy(f, tr); err != nil {
-				return gcp.InternalErrorf("copying file %q: %v", target, err)
-			}
-			if err := f.Close(); err != nil {
-				return gcp.InternalErrorf("closing file %q: %v", target, err)
+			if err := writeFile(target, header.FileInfo().Mode().Perm(), tr); err != nil {
+				return err
 			}
 		case tar.TypeSymlink:
-			targetPath := filepath.Join(filepath.Dir(target), header.Linkname)
-			if !isValidTarDestination(targetPath, dir, header.Typeflag) {
-				return gcp.InternalErrorf("symlink %q -> %q traverses out of root", target, header.Linkname)
-			}
-			if err := os.Symlink(header.Linkname, target); err != nil {
-				return gcp.InternalErrorf("symlinking %q to %q: %v", target, header.Linkname, err)
+			if err := makeSymlink(dir, target, header.Linkname); err != nil {
+				return err
 			}
 		case tar.TypeLink:
 			link, err := tarDestination(header.Linkname, dir, header.Typeflag, stripComponents)
 			if err != nil {
 				return err
 			}
+			if link, err = resolveEntry(dir, link, header.Typeflag); err != nil {
+				return err
+			}
 			if err := os.Link(link, target); err != nil {
 				return gcp.InternalErrorf("linking %q to %q: %v", target, link, err)
 			}
@@ -253,22 +339,5 @@ func isValidTarDestination(dest, rootDir string, tarType byte) bool {
 
 // doGet performs an HTTP GET request for a URL.
 func doGet(url string) (*http.Response, error) {
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, gcp.UserErrorf("fetching %s: %v", url, err)
-	}
-
-	req.Header.Set("User-Agent", gcpUserAgent)
-
-	response, err := retryClient.StandardClient().Do(req)
-	if err != nil {
-		return nil, gcp.UserErrorf("requesting %s: %v", url, err)
-	}
-	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
-		defer response.Body.Close()
-		return nil, gcp.UserErrorf("fetching %s returned HTTP status: %d", url, response.StatusCode)
-	}
-	return response, err
+	return getRange(url, 0, -1, "", nil)
 }
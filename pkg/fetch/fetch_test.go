@@ -41,6 +41,21 @@ func TestTarball(t *testing.T) {
 			responseFile: "testdata/test.tar.gz",
 			wantFile:     "lib/foo.txt",
 		},
+		{
+			name:         "xz untar",
+			responseFile: "testdata/test.tar.xz",
+			wantFile:     "lib/foo.txt",
+		},
+		{
+			name:         "zstd untar",
+			responseFile: "testdata/test.tar.zst",
+			wantFile:     "lib/foo.txt",
+		},
+		{
+			name:         "bzip2 untar",
+			responseFile: "testdata/test.tar.bz2",
+			wantFile:     "lib/foo.txt",
+		},
 		{
 			name:            "strip components",
 			responseFile:    "testdata/test.tar.gz",
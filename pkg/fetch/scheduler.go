@@ -0,0 +1,158 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"os"
+	"sync"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// prefetchConcurrency is the number of prefetched URLs downloaded at a time.
+const prefetchConcurrency = 4
+
+// Scheduler runs functions concurrently, a limited number at a time.
+type Scheduler struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewScheduler returns a Scheduler that runs up to concurrency functions at a time.
+func NewScheduler(concurrency int) *Scheduler {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Scheduler{sem: make(chan struct{}, concurrency)}
+}
+
+// Go runs fn in a new goroutine once fewer than the concurrency limit are running. It does not block.
+func (s *Scheduler) Go(fn func() error) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sem <- struct{}{}
+		defer func() { <-s.sem }()
+		if err := fn(); err != nil {
+			s.mu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until every function has returned and returns the first error, if any.
+func (s *Scheduler) Wait() error {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// prefetch is a background download of a URL into a temporary file or the download cache.
+type prefetch struct {
+	done  chan struct{}
+	path  string
+	stats *downloadStats
+	err   error
+	// temporary is whether path is a temporary file rather than a download cache artifact.
+	temporary bool
+}
+
+var (
+	prefetchMu sync.Mutex
+	prefetches = map[string]*prefetch{}
+	prefetcher = NewScheduler(prefetchConcurrency)
+)
+
+// Prefetch starts downloading URLs in the background, several at a time, so that a buildpack
+// installing several artifacts does not download them one after another. A later Tarball, Archive,
+// File or GetURL call for a prefetched URL waits for its download and reads it from disk. If the
+// prefetch failed, the later call downloads the URL again and reports any error.
+func Prefetch(urls ...string) {
+	prefetchMu.Lock()
+	defer prefetchMu.Unlock()
+	for _, url := range urls {
+		if _, ok := prefetches[url]; ok {
+			continue
+		}
+		p := &prefetch{done: make(chan struct{}), stats: newDownloadStats()}
+		prefetches[url] = p
+		url := url
+		prefetcher.Go(func() error {
+			defer close(p.done)
+			p.path, p.temporary, p.err = prefetchFile(url, p.stats)
+			return nil
+		})
+	}
+}
+
+// prefetchFile downloads url into the download cache, if one is in use, or else into a new
+// temporary file, and returns the file's path and whether it is temporary.
+func prefetchFile(url string, stats *downloadStats) (string, bool, error) {
+	if c := activeCache(); c != nil {
+		if path, ok := c.lookup(url, ""); ok {
+			return path, false, nil
+		}
+		f, err := c.create()
+		if err != nil {
+			return "", false, err
+		}
+		validator, err := downloadFile(url, f, stats)
+		if err != nil {
+			c.abort(f)
+			return "", false, err
+		}
+		path, err := c.commit(url, "", validator, f)
+		return path, false, err
+	}
+
+	f, err := os.CreateTemp("", "prefetch-*")
+	if err != nil {
+		return "", false, gcp.InternalErrorf("creating temp file: %v", err)
+	}
+	defer f.Close()
+	if _, err := downloadFile(url, f, stats); err != nil {
+		os.Remove(f.Name())
+		return "", false, err
+	}
+	return f.Name(), true, nil
+}
+
+// takePrefetched waits for a prefetch of url, if one was started, and returns the path of the
+// downloaded file and a function to call once the file has been read.
+func takePrefetched(url string) (string, func(), bool) {
+	prefetchMu.Lock()
+	p, ok := prefetches[url]
+	delete(prefetches, url)
+	prefetchMu.Unlock()
+	if !ok {
+		return "", nil, false
+	}
+	<-p.done
+	p.stats.record()
+	if p.err != nil {
+		return "", nil, false
+	}
+	if !p.temporary {
+		return p.path, func() {}, true
+	}
+	return p.path, func() { os.Remove(p.path) }, true
+}
@@ -0,0 +1,261 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Strategy is a way of making a directory tree available at another path.
+type Strategy int
+
+const (
+	// Copy copies every file, several at a time. It works on every filesystem.
+	Copy Strategy = iota
+	// Reflink clones every file, so that the clone shares storage with the original until either is
+	// modified. It needs a copy-on-write filesystem such as btrfs or XFS.
+	Reflink
+	// Hardlink links every file to the original. A file modified in place changes in both trees, so
+	// it is only safe for trees whose files are replaced rather than rewritten.
+	Hardlink
+	// Symlink makes the destination a symbolic link to the source. It is only safe for tools that
+	// follow the link and for trees that do not need to be in the application directory at run time.
+	Symlink
+)
+
+var strategyNames = map[Strategy]string{
+	Copy:     "copy",
+	Reflink:  "reflink",
+	Hardlink: "hardlink",
+	Symlink:  "symlink",
+}
+
+func (s Strategy) String() string {
+	if name, ok := strategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// errUnsupported is returned when a filesystem does not support a strategy.
+var errUnsupported = errors.New("not supported by the filesystem")
+
+// copyConcurrency is the number of files restored at a time.
+var copyConcurrency = 2 * runtime.NumCPU()
+
+// RestoreTree makes the directory tree at src available at dest, which must not exist. It uses the
+// first of strategies that the filesystems support and falls back to Copy, returning the strategy
+// that was used. File modes, modification times and symlinks are preserved.
+func RestoreTree(dest, src string, strategies ...Strategy) (Strategy, error) {
+	if _, err := os.Lstat(dest); err == nil {
+		return Copy, fmt.Errorf("%s already exists", dest)
+	} else if !os.IsNotExist(err) {
+		return Copy, err
+	}
+	for _, s := range strategies {
+		switch s {
+		case Symlink:
+			abs, err := filepath.Abs(src)
+			if err != nil {
+				return s, err
+			}
+			return s, os.Symlink(abs, dest)
+		case Reflink:
+			err := restoreFiles(dest, src, reflinkFile)
+			if !errors.Is(err, errUnsupported) {
+				return s, err
+			}
+		case Hardlink:
+			err := restoreFiles(dest, src, linkFile)
+			if !errors.Is(err, errUnsupported) {
+				return s, err
+			}
+		}
+		// Start again from scratch with the next strategy.
+		if err := os.RemoveAll(dest); err != nil {
+			return s, err
+		}
+	}
+	return Copy, restoreFiles(dest, src, copyFile)
+}
+
+// restoreFile creates dest from the regular file src.
+type restoreFile func(dest, src string, info fs.FileInfo) error
+
+// restoreFiles recreates the directories and symlinks of the tree at src under dest and restores
+// its regular files with restore, several at a time.
+func restoreFiles(dest, src string, restore restoreFile) error {
+	type job struct {
+		dest, src string
+		info      fs.FileInfo
+	}
+	jobs := make(chan job)
+	done := make(chan struct{})
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(done)
+		})
+	}
+	for i := 0; i < copyConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := restore(j.dest, j.src, j.info); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	// Directories are created writable and get their own mode and times once their files exist.
+	type dir struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []dir
+	walkErr := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, dir{target, info})
+			return os.Mkdir(target, 0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			select {
+			case jobs <- job{target, path, info}:
+				return nil
+			case <-done:
+				return filepath.SkipAll
+			}
+		}
+		// Sockets, devices and pipes are not part of dependency trees.
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.info.ModTime(), d.info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies the content, mode and modification time of src to dest.
+func copyFile(dest, src string, info fs.FileInfo) error {
+	return cloneFile(dest, src, info, func(out, in *os.File) error {
+		_, err := io.Copy(out, in)
+		return err
+	})
+}
+
+// reflinkFile clones src to dest with the FICLONE ioctl.
+func reflinkFile(dest, src string, info fs.FileInfo) error {
+	return cloneFile(dest, src, info, func(out, in *os.File) error {
+		err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+		if isUnsupported(err) {
+			return fmt.Errorf("cloning %s: %w", src, errUnsupported)
+		}
+		return err
+	})
+}
+
+// cloneFile creates dest with the mode and modification time of src and fills it with fill.
+func cloneFile(dest, src string, info fs.FileInfo, fill func(out, in *os.File) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := fill(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// linkFile hard links dest to src.
+func linkFile(dest, src string, _ fs.FileInfo) error {
+	err := os.Link(src, dest)
+	if isUnsupported(err) {
+		return fmt.Errorf("linking %s: %w", src, errUnsupported)
+	}
+	return err
+}
+
+// isUnsupported returns whether err means the filesystem does not support reflinks or hard links
+// between the two paths.
+func isUnsupported(err error) bool {
+	for _, errno := range []syscall.Errno{unix.EXDEV, unix.EOPNOTSUPP, unix.ENOTTY, unix.EINVAL, unix.EPERM, unix.EMLINK} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
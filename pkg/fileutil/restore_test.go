@@ -0,0 +1,202 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var restoreTestMtime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// writeRestoreTree writes a small dependency tree to dir.
+func writeRestoreTree(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]os.FileMode{
+		"pkg/index.js":         0644,
+		"pkg/lib/util.js":      0600,
+		"pkg/bin/cli.js":       0755,
+		"other/package.json":   0644,
+		"other/empty/.gitkeep": 0444,
+	}
+	for name, mode := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("content of "+name), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, restoreTestMtime, restoreTestMtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../pkg/bin/cli.js", filepath.Join(dir, ".bin", "cli")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "other"), 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chmod(filepath.Join(dir, "other"), 0755)
+	})
+}
+
+// checkRestoredTree checks that the tree written by writeRestoreTree is at dir.
+func checkRestoredTree(t *testing.T, dir string) {
+	t.Helper()
+	files := map[string]os.FileMode{
+		"pkg/index.js":         0644,
+		"pkg/lib/util.js":      0600,
+		"pkg/bin/cli.js":       0755,
+		"other/package.json":   0644,
+		"other/empty/.gitkeep": 0444,
+	}
+	for name, mode := range files {
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("Reading restored file: %v", err)
+			continue
+		}
+		if want := "content of " + name; string(content) != want {
+			t.Errorf("Restored %s = %q, want %q", name, content, want)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("Restored %s has mode %v, want %v", name, info.Mode().Perm(), mode)
+		}
+		if !info.ModTime().Equal(restoreTestMtime) {
+			t.Errorf("Restored %s has modification time %v, want %v", name, info.ModTime(), restoreTestMtime)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dir, ".bin", "cli")); err != nil || link != "../pkg/bin/cli.js" {
+		t.Errorf("Restored .bin/cli links to %q (%v), want %q", link, err, "../pkg/bin/cli.js")
+	}
+	if info, err := os.Stat(filepath.Join(dir, "other")); err != nil || info.Mode().Perm() != 0555 {
+		t.Errorf("Restored directory other has mode %v (%v), want %v", info.Mode().Perm(), err, os.FileMode(0555))
+	}
+}
+
+func TestRestoreTree(t *testing.T) {
+	testCases := []struct {
+		name       string
+		strategies []Strategy
+		// want are the acceptable strategies, as reflinks depend on the filesystem of the test.
+		want []Strategy
+	}{
+		{
+			name: "copy",
+			want: []Strategy{Copy},
+		},
+		{
+			name:       "reflink or copy",
+			strategies: []Strategy{Reflink},
+			want:       []Strategy{Reflink, Copy},
+		},
+		{
+			name:       "hardlink",
+			strategies: []Strategy{Hardlink},
+			want:       []Strategy{Hardlink},
+		},
+		{
+			name:       "symlink",
+			strategies: []Strategy{Symlink, Copy},
+			want:       []Strategy{Symlink},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "src")
+			writeRestoreTree(t, src)
+			dest := filepath.Join(t.TempDir(), "dest")
+			t.Cleanup(func() {
+				os.Chmod(filepath.Join(dest, "other"), 0755)
+			})
+
+			got, err := RestoreTree(dest, src, tc.strategies...)
+			if err != nil {
+				t.Fatalf("RestoreTree(%q, %q, %v) got error: %v", dest, src, tc.strategies, err)
+			}
+			if !containsStrategy(tc.want, got) {
+				t.Errorf("RestoreTree(%q, %q, %v) used %v, want one of %v", dest, src, tc.strategies, got, tc.want)
+			}
+			checkRestoredTree(t, dest)
+
+			srcInfo, err := os.Stat(filepath.Join(src, "pkg/index.js"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			destInfo, err := os.Stat(filepath.Join(dest, "pkg/index.js"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shared, want := os.SameFile(srcInfo, destInfo), got == Hardlink || got == Symlink; shared != want {
+				t.Errorf("RestoreTree() with %v shares files with the source = %v, want %v", got, shared, want)
+			}
+		})
+	}
+}
+
+func TestRestoreTreeDestinationExists(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	writeRestoreTree(t, src)
+	dest := t.TempDir()
+	if _, err := RestoreTree(dest, src); err == nil {
+		t.Errorf("RestoreTree(%q, %q) got no error, want error for an existing destination", dest, src)
+	}
+}
+
+func TestRestoreTreeManyFiles(t *testing.T) {
+	src := t.TempDir()
+	for i := 0; i < 500; i++ {
+		dir := filepath.Join(src, fmt.Sprintf("pkg-%d", i%20))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%d", i)), []byte(fmt.Sprint(i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dest := filepath.Join(t.TempDir(), "dest")
+	if _, err := RestoreTree(dest, src); err != nil {
+		t.Fatalf("RestoreTree(%q, %q) got error: %v", dest, src, err)
+	}
+	for i := 0; i < 500; i++ {
+		path := filepath.Join(dest, fmt.Sprintf("pkg-%d", i%20), fmt.Sprintf("file-%d", i))
+		if got, err := os.ReadFile(path); err != nil || string(got) != fmt.Sprint(i) {
+			t.Errorf("Restored %s = %q (%v), want %q", path, got, err, fmt.Sprint(i))
+		}
+	}
+}
+
+func containsStrategy(strategies []Strategy, s Strategy) bool {
+	for _, want := range strategies {
+		if want == s {
+			return true
+		}
+	}
+	return false
+}
@@ -18,18 +18,23 @@ package preparer
 
 import (
 	"fmt"
+	"strings"
 
+	bpenv "github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	env "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/env"
 	secrets "github.com/GoogleCloudPlatform/buildpacks/pkg/firebase/secrets"
 )
 
 // Prepare performs pre-build logic for App Hosting backends including:
 // * Reading, sanitizing, and writing user-defined environment variables in apphosting.env to a new file.
+// * Listing the dereferenced secrets in a separate build-only env file, if buildEnvOutputFilePath is
+// set, so that buildpacks redact their values from output without exposing the list at runtime.
 //
 // Prepare will always write a file to disk, even if there are no environment variables to write.
-func Prepare(apphostingEnvFilePath string, projectID string, envReferencedOutputFilePath string, envDereferencedOutputFilePath string) error {
+func Prepare(apphostingEnvFilePath string, projectID string, envReferencedOutputFilePath string, envDereferencedOutputFilePath string, buildEnvOutputFilePath string) error {
 	referencedEnvMap := map[string]string{}   // Env map with referenced secret material
 	dereferencedEnvMap := map[string]string{} // Env map with dereferenced secret material
+	buildEnvMap := map[string]string{}        // Env map only set while building
 
 	// Read statically defined env vars from apphosting.env.
 	if apphostingEnvFilePath != "" {
@@ -54,6 +59,9 @@ func Prepare(apphostingEnvFilePath string, projectID string, envReferencedOutput
 		if err != nil {
 			return fmt.Errorf("dereferencing secrets in apphosting.env: %w", err)
 		}
+		if names := secrets.SecretEnvNames(referencedEnvMap); len(names) > 0 {
+			buildEnvMap[bpenv.RedactEnvNames] = strings.Join(names, ",")
+		}
 	}
 
 	err := env.WriteEnv(referencedEnvMap, envReferencedOutputFilePath)
@@ -66,5 +74,12 @@ func Prepare(apphostingEnvFilePath string, projectID string, envReferencedOutput
 		return fmt.Errorf("writing final dereferenced environment variables to %v: %w", envDereferencedOutputFilePath, err)
 	}
 
+	if buildEnvOutputFilePath != "" {
+		err = env.WriteEnv(buildEnvMap, buildEnvOutputFilePath)
+		if err != nil {
+			return fmt.Errorf("writing build environment variables to %v: %w", buildEnvOutputFilePath, err)
+		}
+	}
+
 	return nil
 }
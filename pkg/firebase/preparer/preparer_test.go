@@ -16,6 +16,7 @@ func TestPrepare(t *testing.T) {
 	testDir := t.TempDir()
 	outputFilePathReferenced := testDir + "/outputReferenced"
 	outputFilePathDereferenced := testDir + "/outputDereferenced"
+	outputFilePathBuildEnv := testDir + "/outputBuildEnv"
 
 	testCases := []struct {
 		desc                   string
@@ -23,6 +24,7 @@ func TestPrepare(t *testing.T) {
 		projectID              string
 		wantEnvMapReferenced   map[string]string
 		wantEnvMapDereferenced map[string]string
+		wantBuildEnvMap        map[string]string
 	}{
 		{
 			desc:                  "apphosting.env",
@@ -40,18 +42,22 @@ func TestPrepare(t *testing.T) {
 				"MULTILINE_ENV_VAR": "line 1\nline 2",
 				"API_KEY":           "secretString",
 			},
+			wantBuildEnvMap: map[string]string{
+				"X_GOOGLE_REDACT_ENV_NAMES": "API_KEY",
+			},
 		},
 		{
 			desc:                   "nonexistent apphosting.env",
 			appHostingEnvFilePath:  "",
 			wantEnvMapReferenced:   map[string]string{},
 			wantEnvMapDereferenced: map[string]string{},
+			wantBuildEnvMap:        map[string]string{},
 		},
 	}
 
 	// Testing happy paths
 	for _, test := range testCases {
-		if err := Prepare(test.appHostingEnvFilePath, test.projectID, outputFilePathReferenced, outputFilePathDereferenced); err != nil {
+		if err := Prepare(test.appHostingEnvFilePath, test.projectID, outputFilePathReferenced, outputFilePathDereferenced, outputFilePathBuildEnv); err != nil {
 			t.Errorf("Error in test '%v'. Error was %v", test.desc, err)
 		}
 
@@ -74,5 +80,15 @@ func TestPrepare(t *testing.T) {
 		if diff := cmp.Diff(test.wantEnvMapDereferenced, actualEnvMapDereferenced); diff != "" {
 			t.Errorf("Unexpected YAML for test %v (+got, -want):\n%v", test.desc, diff)
 		}
+
+		// Check build-only env file
+		actualBuildEnvMap, err := env.ReadEnv(outputFilePathBuildEnv)
+		if err != nil {
+			t.Errorf("Error reading in temp file: %v", err)
+		}
+
+		if diff := cmp.Diff(test.wantBuildEnvMap, actualBuildEnvMap); diff != "" {
+			t.Errorf("Unexpected YAML for test %v (+got, -want):\n%v", test.desc, diff)
+		}
 	}
 }
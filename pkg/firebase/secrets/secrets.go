@@ -18,6 +18,7 @@ package secrets
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -102,6 +103,19 @@ func DereferenceSecrets(envMap map[string]string) (map[string]string, error) {
 	return dereferencedEnvMap, nil
 }
 
+// SecretEnvNames returns the sorted names that secrets in envMap have once dereferenced, e.g.
+// "API_KEY" for SECRET_API_KEY.
+func SecretEnvNames(envMap map[string]string) []string {
+	var names []string
+	for k := range envMap {
+		if strings.HasPrefix(k, secretKeyPrefix) {
+			names = append(names, strings.TrimPrefix(k, secretKeyPrefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Get secret version metadata. Does not include the secret's sensitive data.
 func getSecretVersion(name string) (string, error) {
 	// TODO (abhisun): Make call to SecretManager GetSecretVersion and extract name field
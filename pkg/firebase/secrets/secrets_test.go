@@ -114,6 +114,20 @@ func TestPinVersionSecrets(t *testing.T) {
 	}
 }
 
+func TestSecretEnvNames(t *testing.T) {
+	envVars := map[string]string{
+		"API_URL":       "api.service.com",
+		"SECRET_TOKEN":  "projects/test-project/secrets/token/versions/5",
+		"SECRET_DB_URL": "projects/test-project/secrets/db/versions/latest",
+	}
+
+	got := SecretEnvNames(envVars)
+
+	if diff := cmp.Diff([]string{"DB_URL", "TOKEN"}, got); diff != "" {
+		t.Errorf("SecretEnvNames(%v) unexpected names (+got, -want):\n%v", envVars, diff)
+	}
+}
+
 func TestDereferenceSecrets(t *testing.T) {
 	testCases := []struct {
 		desc         string
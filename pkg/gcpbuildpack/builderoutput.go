@@ -77,12 +77,13 @@ func (ctx *Context) saveErrorOutput(err error) {
 		return
 	}
 
+	be.Message = ctx.Redact(be.Message)
 	if len(be.Message) > maxMessageBytes {
 		be.Message = keepTail(be.Message)
 	}
 
 	be.BuildpackID, be.BuildpackVersion = ctx.BuildpackID(), ctx.BuildpackVersion()
-	bo := builderoutput.BuilderOutput{Error: *be}
+	bo := builderoutput.BuilderOutput{Error: *be, RuntimeSupport: ctx.RuntimeSupport()}
 	bm := buildermetrics.GlobalBuilderMetrics()
 	bo.Metrics = *bm
 	data, err := bo.JSON()
@@ -142,6 +143,11 @@ func keepHead(message string) string {
 	return message[:maxMessageBytes-3] + "..."
 }
 
+// BuilderOutputDir returns the BUILDER_OUTPUT directory, or "" if it is not set.
+func (ctx *Context) BuilderOutputDir() string {
+	return os.Getenv(builderOutputEnv)
+}
+
 // saveSuccessOutput saves information from the context into BUILDER_OUTPUT.
 func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 	outputDir := os.Getenv(builderOutputEnv)
@@ -176,6 +182,8 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		bo.InstalledRuntimeVersions = append(bo.InstalledRuntimeVersions, ctx.InstalledRuntimeVersions()...)
 	}
 
+	bo.RuntimeSupport = append(bo.RuntimeSupport, ctx.RuntimeSupport()...)
+
 	bo.Stats = append(bo.Stats, builderoutput.BuilderStat{
 		BuildpackID:      ctx.BuildpackID(),
 		BuildpackVersion: ctx.BuildpackVersion(),
@@ -189,6 +197,13 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		count := bo.Metrics.GetCounter(id)
 		count.Increment(c.Value())
 	})
+	bm.ForEachFloatDP(func(id buildermetrics.MetricID, f *buildermetrics.FloatDP) {
+		// The peak RSS is the only distribution point written to the builder output. A peak across
+		// buildpacks is the largest of their peaks, not their sum.
+		if id == buildermetrics.ExecPeakRSSMBID {
+			bo.Metrics.GetFloatDP(id).Max(f.Value())
+		}
+	})
 
 	var content []byte
 	// Make sure the message is smaller than the maximum allowed size.
@@ -227,3 +242,24 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		return
 	}
 }
+
+// BuildInstalledRuntimeVersions returns the runtime versions installed by previous buildpacks, as
+// recorded in BUILDER_OUTPUT, followed by the versions installed by this buildpack.
+func (ctx *Context) BuildInstalledRuntimeVersions() []string {
+	var versions []string
+	if outputDir := os.Getenv(builderOutputEnv); outputDir != "" {
+		fname := filepath.Join(outputDir, builderOutputFilename)
+		content, err := ioutil.ReadFile(fname)
+		if err != nil && !os.IsNotExist(err) {
+			ctx.Warnf("Failed to read %s, skipping previous runtime versions: %v", fname, err)
+		}
+		if err == nil {
+			bo, err := builderoutput.FromJSON(content)
+			if err != nil {
+				ctx.Warnf("Failed to unmarshal %s, skipping previous runtime versions: %v", fname, err)
+			}
+			versions = append(versions, bo.InstalledRuntimeVersions...)
+		}
+	}
+	return append(versions, ctx.InstalledRuntimeVersions()...)
+}
@@ -32,6 +32,7 @@ import (
 )
 
 func TestSaveErrorOutput(t *testing.T) {
+	buildermetrics.Reset()
 	t.Cleanup(buildermetrics.Reset)
 	tempDir, err := ioutil.TempDir("", "save-error-output-")
 	if err != nil {
@@ -270,6 +271,7 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 		name                     string
 		addMetrics               bool
 		installedRuntimeVersions []string
+		runtimeSupport           []builderoutput.RuntimeSupport
 		initial                  *builderoutput.BuilderOutput
 		warnings                 []string
 		want                     builderoutput.BuilderOutput
@@ -330,6 +332,23 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 				CustomImage: false,
 			},
 		},
+		{
+			name: "appends RuntimeSupport",
+			initial: &builderoutput.BuilderOutput{
+				RuntimeSupport: []builderoutput.RuntimeSupport{{Runtime: "python", Version: "3.12.4", Status: "supported", DecommissionDate: "2029-04-30"}},
+			},
+			runtimeSupport: []builderoutput.RuntimeSupport{{Runtime: "nodejs", Version: "18.20.4", Status: "decommissioned", DecommissionDate: "2025-10-30"}},
+			want: builderoutput.BuilderOutput{
+				RuntimeSupport: []builderoutput.RuntimeSupport{
+					{Runtime: "python", Version: "3.12.4", Status: "supported", DecommissionDate: "2029-04-30"},
+					{Runtime: "nodejs", Version: "18.20.4", Status: "decommissioned", DecommissionDate: "2025-10-30"},
+				},
+				Metrics: buildermetrics.NewBuilderMetrics(),
+				Stats: []builderoutput.BuilderStat{
+					{BuildpackID: buildpackID, BuildpackVersion: buildpackVersion, DurationMs: dur.Milliseconds(), UserDurationMs: userDur.Milliseconds()},
+				},
+			},
+		},
 		{
 			name: "existing file new warnings",
 			initial: &builderoutput.BuilderOutput{
@@ -452,6 +471,7 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			buildermetrics.Reset()
 			t.Cleanup(buildermetrics.Reset)
 			tempDir, err := ioutil.TempDir("", "save-success-output-")
 			if err != nil {
@@ -483,6 +503,9 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 			for _, version := range tc.installedRuntimeVersions {
 				ctx.AddInstalledRuntimeVersion(version)
 			}
+			for _, rs := range tc.runtimeSupport {
+				ctx.AddRuntimeSupport(rs)
+			}
 
 			ctx.saveSuccessOutput(dur)
 
@@ -502,3 +525,81 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveSuccessOutputFloatDPs(t *testing.T) {
+	buildermetrics.Reset()
+	t.Cleanup(buildermetrics.Reset)
+	tempDir := t.TempDir()
+	t.Setenv(builderOutputEnv, tempDir)
+	bm := buildermetrics.GlobalBuilderMetrics()
+	bm.GetFloatDP(buildermetrics.ExecPeakRSSMBID).Max(256)
+	bm.GetFloatDP(buildermetrics.NpmInstallLatencyID).Add(1500)
+	ctx := NewContext()
+
+	ctx.saveSuccessOutput(time.Second)
+
+	content, err := ioutil.ReadFile(filepath.Join(tempDir, builderOutputFilename))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", builderOutputFilename, err)
+	}
+	got, err := builderoutput.FromJSON(content)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	gotDPs := map[buildermetrics.MetricID]float64{}
+	got.Metrics.ForEachFloatDP(func(id buildermetrics.MetricID, f *buildermetrics.FloatDP) {
+		gotDPs[id] = f.Value()
+	})
+	want := map[buildermetrics.MetricID]float64{buildermetrics.ExecPeakRSSMBID: 256}
+	if diff := cmp.Diff(want, gotDPs); diff != "" {
+		t.Errorf("saveSuccessOutput() float distribution points mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildInstalledRuntimeVersions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		initial *builderoutput.BuilderOutput
+		added   []string
+		want    []string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:  "this buildpack only",
+			added: []string{"3.2.1"},
+			want:  []string{"3.2.1"},
+		},
+		{
+			name:    "previous buildpacks",
+			initial: &builderoutput.BuilderOutput{InstalledRuntimeVersions: []string{"1.0.0"}},
+			added:   []string{"3.2.1"},
+			want:    []string{"1.0.0", "3.2.1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv(builderOutputEnv, tempDir)
+			if tc.initial != nil {
+				content, err := json.Marshal(tc.initial)
+				if err != nil {
+					t.Fatalf("Failed to marshal output: %v", err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(tempDir, builderOutputFilename), content, 0644); err != nil {
+					t.Fatalf("Failed to write output: %v", err)
+				}
+			}
+			ctx := NewContext()
+			for _, v := range tc.added {
+				ctx.AddInstalledRuntimeVersion(v)
+			}
+
+			if diff := cmp.Diff(tc.want, ctx.BuildInstalledRuntimeVersions()); diff != "" {
+				t.Errorf("BuildInstalledRuntimeVersions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
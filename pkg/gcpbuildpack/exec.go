@@ -16,20 +16,37 @@ package gcpbuildpack
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"golang.org/x/sys/unix"
 )
 
+const (
+	// defaultHeartbeatInterval is how often a "still running" line is logged for a long command.
+	defaultHeartbeatInterval = time.Minute
+	// defaultGracePeriod is how long a command's process group has to exit after SIGTERM before it
+	// is sent SIGKILL.
+	defaultGracePeriod = 10 * time.Second
+)
+
 var (
 	divider = strings.Repeat("-", 80)
+
+	// outputCloseDelay is how long to wait for a command's output to be closed after it exits before
+	// closing it ourselves, which happens when a process it started outside its process group still
+	// holds stdout or stderr.
+	outputCloseDelay = 10 * time.Second
 )
 
 // ExecResult bundles exec results.
@@ -50,6 +67,11 @@ type execParams struct {
 	messageProducer    MessageProducer
 	logCommandOverride *bool
 	logOutputOverride  *bool
+
+	timeout           time.Duration
+	timeoutOption     bool
+	gracePeriod       time.Duration
+	heartbeatInterval *time.Duration
 }
 
 // ExecOption configures Exec functions.
@@ -103,6 +125,31 @@ func WithLogOutput(shouldLog bool) ExecOption {
 	}
 }
 
+// WithTimeout stops the command if it is still running after the given duration. This takes
+// precedence over GOOGLE_EXEC_TIMEOUT, but the command is still stopped at the buildpack deadline.
+func WithTimeout(timeout time.Duration) ExecOption {
+	return func(o *execParams) {
+		o.timeout = timeout
+		o.timeoutOption = true
+	}
+}
+
+// WithGracePeriod sets how long a stopped command has to exit after SIGTERM before it is killed.
+func WithGracePeriod(gracePeriod time.Duration) ExecOption {
+	return func(o *execParams) {
+		o.gracePeriod = gracePeriod
+	}
+}
+
+// WithHeartbeat sets how often a "still running" line is logged while the command runs; zero
+// disables it. This takes precedence over GOOGLE_EXEC_HEARTBEAT_INTERVAL. The line is only logged
+// for commands that are themselves logged.
+func WithHeartbeat(interval time.Duration) ExecOption {
+	return func(o *execParams) {
+		o.heartbeatInterval = &interval
+	}
+}
+
 // WithCombinedTail keeps the tail of the combined stdout/stderr for the error message.
 var WithCombinedTail = WithMessageProducer(KeepCombinedTail)
 
@@ -123,7 +170,7 @@ var WithStdoutHead = WithMessageProducer(KeepStdoutHead)
 
 // Exec runs the given command (with args) under the default configuration, allowing the caller to handle the error.
 func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error) {
-	params := execParams{cmd: cmd, messageProducer: KeepCombinedTail}
+	params := execParams{cmd: cmd, messageProducer: KeepCombinedTail, timeout: ctx.execTimeout, gracePeriod: defaultGracePeriod}
 	for _, o := range opts {
 		o(&params)
 	}
@@ -145,6 +192,21 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 		message = params.messageProducer(result)
 	}
 
+	message = ctx.Redact(message)
+
+	var ie *interruptedError
+	if errors.As(err, &ie) {
+		// The command did not finish, so report why along with whatever output it produced.
+		if result != nil && message != "" {
+			message = fmt.Sprintf("%s, output so far:\n%s", ctx.Redact(ie.Error()), message)
+		} else {
+			message = ctx.Redact(ie.Error())
+		}
+		be := buildererror.Errorf(ie.status, message)
+		be.ID = buildererror.GenerateErrorID(params.cmd...)
+		return result, be
+	}
+
 	var be *buildererror.Error
 	if params.userAttribution {
 		be = UserErrorf(message)
@@ -153,6 +215,11 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 	}
 
 	be.ID = buildererror.GenerateErrorID(params.cmd...)
+	if result != nil {
+		if kf := buildererror.MatchKnownFailure(params.cmd, result.Combined); kf != nil && kf.Apply(be) {
+			ctx.Logf("%s (error ID: %s)", kf.Remediation(), kf.ID)
+		}
+	}
 	return result, be
 }
 
@@ -170,6 +237,7 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		defaultShouldLog = false
 	}
 
+	ctx.registerSecretEnv(params.env)
 	readableCmd := strings.Join(params.cmd, " ")
 	if len(params.env) > 0 {
 		env := strings.Join(params.env, " ")
@@ -186,6 +254,7 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	}
 
 	status := buildererror.StatusInternal
+	var usage *commandUsage
 	defer func(start time.Time) {
 		truncated := readableCmd
 		if len(truncated) > 60 {
@@ -195,7 +264,11 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		if logCmd {
 			ctx.Logf("Done %q (%v)", truncated, time.Since(start))
 		}
-		ctx.Span(ctx.createSpanName(params.cmd), start, status)
+		var attributes map[string]interface{}
+		if usage != nil {
+			attributes = usage.attributes()
+		}
+		ctx.spanWithAttributes(ctx.createSpanName(params.cmd), start, status, attributes)
 	}(time.Now())
 
 	exitCode := 0
@@ -218,11 +291,22 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
 	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
 
-	if err := ecmd.Run(); err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			// The command returned a non-zero result.
-			exitCode = ee.ExitCode()
-		} else if pe, ok := err.(*os.PathError); ok && pe.Err == unix.ENOENT {
+	deadline, timedOut := ctx.commandDeadline(params, readableCmd)
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		status = buildererror.StatusDeadlineExceeded
+		return nil, timedOut
+	}
+	if ecmd.SysProcAttr == nil {
+		// Run the command in its own process group so that any children it spawns are stopped with it.
+		ecmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	// Without a delay, Wait blocks until every process holding the output pipes exits, even after the
+	// command itself has exited or been killed.
+	ecmd.WaitDelay = outputCloseDelay
+
+	started := time.Now()
+	if err := ecmd.Start(); err != nil {
+		if pe, ok := err.(*os.PathError); ok && pe.Err == unix.ENOENT {
 			// ENOENT normally occurs if the command cannot
 			// be found, but also occurs with scripts using
 			// CR-LF line endings.  Unix uses LF as its line
@@ -232,8 +316,33 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 			// CR. This search will almost certainly fail and
 			// otherwise results in an confusing ENOENT.
 			return nil, fmt.Errorf("executing command %q: %v: if %q is a script, ensure that it has Unix-style LF line endings", readableCmd, err, params.cmd[0])
-		} else {
-			return nil, fmt.Errorf("executing command %q: %v", readableCmd, err)
+		}
+		return nil, fmt.Errorf("executing command %q: %v", readableCmd, err)
+	}
+
+	heartbeatInterval := ctx.heartbeatInterval
+	if params.heartbeatInterval != nil {
+		heartbeatInterval = *params.heartbeatInterval
+	}
+	if !logCmd {
+		heartbeatInterval = 0
+	}
+	usage = &commandUsage{cmd: readableCmd, user: params.userAttribution, processes: 1}
+	waitErr, interrupted := ctx.wait(ecmd, &combinedb, readableCmd, deadline, timedOut, heartbeatInterval, params.gracePeriod, usage)
+	combinedb.flush()
+	usage.wall = time.Since(started)
+	usage.setRusage(ecmd.ProcessState)
+	ctx.recordUsage(usage)
+	if errors.Is(waitErr, exec.ErrWaitDelay) {
+		ctx.Warnf("Stopped reading the output of %q after it exited, a process it started is still holding it open", readableCmd)
+		waitErr = nil
+	}
+	if waitErr != nil {
+		if ee, ok := waitErr.(*exec.ExitError); ok {
+			// The command returned a non-zero result.
+			exitCode = ee.ExitCode()
+		} else if interrupted == nil {
+			return nil, fmt.Errorf("executing command %q: %v", readableCmd, waitErr)
 		}
 	}
 
@@ -244,6 +353,10 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		Combined: strings.TrimSpace(string(combinedb.Bytes())),
 	}
 
+	if interrupted != nil {
+		status = interrupted.status
+		return result, interrupted
+	}
 	if exitCode != 0 {
 		return result, fmt.Errorf("executing command %q: exit code %d", readableCmd, exitCode)
 	}
@@ -252,6 +365,125 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	return result, nil
 }
 
+// interruptedError reports a command that was stopped before it finished.
+type interruptedError struct {
+	status  buildererror.Status
+	message string
+}
+
+func (e *interruptedError) Error() string {
+	return e.message
+}
+
+// commandDeadline returns the time at which the command must be stopped, which is the earlier of
+// its own timeout and the buildpack deadline, along with the error reported if it is reached. A
+// zero time means the command has no deadline.
+func (ctx *Context) commandDeadline(params execParams, readableCmd string) (time.Time, *interruptedError) {
+	var deadline time.Time
+	var err *interruptedError
+	if params.timeout > 0 {
+		deadline = time.Now().Add(params.timeout)
+		message := fmt.Sprintf("command %q did not complete within %v, set %s to allow more time", readableCmd, params.timeout, env.ExecTimeout)
+		if params.timeoutOption {
+			// The timeout was chosen by the buildpack for this command, so GOOGLE_EXEC_TIMEOUT has no effect.
+			message = fmt.Sprintf("command %q did not complete within the %v timeout set for it by the buildpack", readableCmd, params.timeout)
+		}
+		err = &interruptedError{
+			status:  buildererror.StatusDeadlineExceeded,
+			message: message,
+		}
+	}
+	if !ctx.deadline.IsZero() && (deadline.IsZero() || ctx.deadline.Before(deadline)) {
+		deadline = ctx.deadline
+		err = &interruptedError{
+			status:  buildererror.StatusDeadlineExceeded,
+			message: fmt.Sprintf("command %q was stopped at the buildpack deadline, set %s to allow more time", readableCmd, env.BuildpackTimeout),
+		}
+	}
+	return deadline, err
+}
+
+// wait waits for a started command to exit, logging a heartbeat every heartbeatInterval and
+// sampling the size of its process group into usage. If the deadline passes or the buildpack is
+// asked to stop, the command's process group is terminated and the reason is returned as an
+// interruptedError.
+func (ctx *Context) wait(ecmd *exec.Cmd, output *lockingBuffer, readableCmd string, deadline time.Time, timedOut *interruptedError, heartbeatInterval, gracePeriod time.Duration, usage *commandUsage) (error, *interruptedError) {
+	done := make(chan error, 1)
+	go func() {
+		done <- ecmd.Wait()
+	}()
+
+	var deadlineC <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		deadlineC = timer.C
+	}
+	var heartbeatC <-chan time.Time
+	if heartbeatInterval > 0 {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		heartbeatC = ticker.C
+	}
+	var sampleC <-chan time.Time
+	if ecmd.SysProcAttr != nil && ecmd.SysProcAttr.Setpgid {
+		ticker := time.NewTicker(processSampleInterval)
+		defer ticker.Stop()
+		sampleC = ticker.C
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, unix.SIGINT, unix.SIGTERM)
+	defer signal.Stop(signals)
+
+	start := time.Now()
+	for {
+		select {
+		case err := <-done:
+			return err, nil
+		case <-sampleC:
+			if n := countProcessGroup(ecmd.Process.Pid); n > usage.processes {
+				usage.processes = n
+			}
+		case <-heartbeatC:
+			ctx.Logf("Still running %q (%v elapsed, last output %v ago)", readableCmd, time.Since(start).Round(time.Second), time.Since(output.lastWrite(start)).Round(time.Second))
+		case <-deadlineC:
+			ctx.Warnf("Stopping %q after %v", readableCmd, time.Since(start).Round(time.Second))
+			return ctx.terminate(ecmd, readableCmd, done, gracePeriod), timedOut
+		case sig := <-signals:
+			ctx.Warnf("Stopping %q after receiving %v", readableCmd, sig)
+			return ctx.terminate(ecmd, readableCmd, done, gracePeriod), &interruptedError{
+				status:  buildererror.StatusCancelled,
+				message: fmt.Sprintf("command %q was cancelled by %v", readableCmd, sig),
+			}
+		}
+	}
+}
+
+// terminate sends SIGTERM to the command's process group, then SIGKILL if it is still running after
+// the grace period, and returns the result of waiting for it.
+func (ctx *Context) terminate(ecmd *exec.Cmd, readableCmd string, done <-chan error, gracePeriod time.Duration) error {
+	pid := ecmd.Process.Pid
+	if ecmd.SysProcAttr != nil && ecmd.SysProcAttr.Setpgid {
+		pid = -pid
+	}
+	ctx.kill(pid, unix.SIGTERM, readableCmd)
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(gracePeriod):
+	}
+	ctx.kill(pid, unix.SIGKILL, readableCmd)
+	return <-done
+}
+
+// kill sends sig to pid, logging a warning if it fails for any reason other than the process having
+// already exited.
+func (ctx *Context) kill(pid int, sig unix.Signal, readableCmd string) {
+	if err := unix.Kill(pid, sig); err != nil && err != unix.ESRCH {
+		ctx.Warnf("Sending %v to %q: %v", sig, readableCmd, err)
+	}
+}
+
 type lockingBuffer struct {
 	buf bytes.Buffer
 	sync.Mutex
@@ -259,17 +491,64 @@ type lockingBuffer struct {
 	// log tells the buffer to also log the output to stderr.
 	log bool
 	ctx *Context
+	// written is the time of the most recent write.
+	written time.Time
+	// pending is logged output held back in case it ends with the start of a secret.
+	pending string
 }
 
 func (lb *lockingBuffer) Write(p []byte) (int, error) {
 	lb.Lock()
 	defer lb.Unlock()
 	if lb.log {
-		lb.ctx.Logf(string(p))
+		lb.logRedacted(string(p))
 	}
+	lb.written = time.Now()
 	return lb.buf.Write(p)
 }
 
+// logRedacted logs output with registered secrets redacted. Up to one byte less than the longest
+// secret is held back until the next write or flush, so that a secret split across writes is still
+// redacted. While output is held back, it is logged up to the last complete line where possible.
+func (lb *lockingBuffer) logRedacted(s string) {
+	text := lb.ctx.Redact(lb.pending + s)
+	keep := lb.ctx.redactor.maxSecretLength() - 1
+	if keep <= 0 {
+		lb.ctx.Logf("%s", text)
+		return
+	}
+	cut := len(text) - keep
+	if cut <= 0 {
+		lb.pending = text
+		return
+	}
+	if i := strings.LastIndexByte(text[:cut], '\n'); i >= 0 {
+		cut = i + 1
+	}
+	lb.pending = text[cut:]
+	lb.ctx.Logf("%s", text[:cut])
+}
+
+// flush logs any output held back by logRedacted.
+func (lb *lockingBuffer) flush() {
+	lb.Lock()
+	defer lb.Unlock()
+	if lb.pending != "" {
+		lb.ctx.Logf("%s", lb.ctx.Redact(lb.pending))
+		lb.pending = ""
+	}
+}
+
+// lastWrite returns the time of the most recent write, or since if nothing has been written.
+func (lb *lockingBuffer) lastWrite(since time.Time) time.Time {
+	lb.Lock()
+	defer lb.Unlock()
+	if lb.written.IsZero() {
+		return since
+	}
+	return lb.written
+}
+
 func (lb *lockingBuffer) Bytes() []byte {
 	return lb.buf.Bytes()
 }
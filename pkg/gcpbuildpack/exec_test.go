@@ -662,6 +662,226 @@ func TestExecWithCRLF(t *testing.T) {
 	}
 }
 
+func TestExecKnownFailure(t *testing.T) {
+	npm := filepath.Join(t.TempDir(), "npm")
+	script := "#!/bin/sh\necho 'npm ERR! notarget No matching version found for left-pad@^9.9.9.' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(npm, []byte(script), 0555); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	_, err := ctx.execWithErrCastToBuildError([]string{npm, "ci"}, WithUserAttribution)
+
+	if err == nil {
+		t.Fatal("Exec() got nil error, want known failure")
+	}
+	if got, want := err.ID, buildererror.ID("npm-no-matching-version"); got != want {
+		t.Errorf("error ID got %q want %q", got, want)
+	}
+	if got, want := err.Status, buildererror.StatusNotFound; got != want {
+		t.Errorf("error status got %v want %v", got, want)
+	}
+	if err.Fix == "" || !strings.Contains(err.Message, err.Fix) {
+		t.Errorf("error message %q does not contain fix %q", err.Message, err.Fix)
+	}
+}
+
+func TestExecKnownFailureNotUserAttributed(t *testing.T) {
+	npm := filepath.Join(t.TempDir(), "npm")
+	script := "#!/bin/sh\necho 'npm ERR! notarget No matching version found for left-pad@^9.9.9.' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(npm, []byte(script), 0555); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	_, err := ctx.execWithErrCastToBuildError([]string{npm, "ci"})
+
+	if err == nil {
+		t.Fatal("Exec() got nil error, want internal error")
+	}
+	if err.ID == "npm-no-matching-version" || err.Status != buildererror.StatusInternal || err.Fix != "" {
+		t.Errorf("Exec() got error %#v, want an internal error that is not re-tagged", err)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	testCases := []struct {
+		name        string
+		deadlineIn  time.Duration
+		execTimeout time.Duration
+		opts        []ExecOption
+		wantMessage string
+	}{
+		{
+			name:        "command timeout",
+			opts:        []ExecOption{WithTimeout(200 * time.Millisecond)},
+			wantMessage: "did not complete within the 200ms timeout set for it by the buildpack",
+		},
+		{
+			name:        "exec timeout",
+			execTimeout: 200 * time.Millisecond,
+			wantMessage: "did not complete within 200ms, set GOOGLE_EXEC_TIMEOUT to allow more time",
+		},
+		{
+			name:        "buildpack deadline",
+			deadlineIn:  200 * time.Millisecond,
+			wantMessage: "stopped at the buildpack deadline",
+		},
+		{
+			name:        "buildpack deadline shortens command timeout",
+			deadlineIn:  200 * time.Millisecond,
+			opts:        []ExecOption{WithTimeout(time.Hour)},
+			wantMessage: "stopped at the buildpack deadline",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ctxOpts []ContextOption
+			if tc.deadlineIn > 0 {
+				ctxOpts = append(ctxOpts, WithDeadline(time.Now().Add(tc.deadlineIn)))
+			}
+			ctx := NewContext(ctxOpts...)
+			ctx.execTimeout = tc.execTimeout
+			cmd := []string{"/bin/sh", "-c", "echo downloading; sleep 30"}
+			start := time.Now()
+
+			result, err := ctx.execWithErrCastToBuildError(cmd, tc.opts...)
+
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("Exec(%v) took %v, want it stopped at the deadline", cmd, elapsed)
+			}
+			if err == nil {
+				t.Fatalf("Exec(%v) got nil error, want deadline exceeded", cmd)
+			}
+			if err.Status != buildererror.StatusDeadlineExceeded {
+				t.Errorf("Exec(%v) got status %v, want %v", cmd, err.Status, buildererror.StatusDeadlineExceeded)
+			}
+			if !strings.Contains(err.Message, tc.wantMessage) {
+				t.Errorf("Exec(%v) got message %q, want it to contain %q", cmd, err.Message, tc.wantMessage)
+			}
+			if !strings.Contains(err.Message, "downloading") {
+				t.Errorf("Exec(%v) got message %q, want it to contain the output so far", cmd, err.Message)
+			}
+			if result == nil || result.Stdout != "downloading" {
+				t.Errorf("Exec(%v) got result %v, want stdout %q", cmd, result, "downloading")
+			}
+			if len(ctx.stats.spans) != 1 || ctx.stats.spans[0].status != buildererror.StatusDeadlineExceeded {
+				t.Errorf("Exec(%v) got spans %v, want one with status %v", cmd, ctx.stats.spans, buildererror.StatusDeadlineExceeded)
+			}
+		})
+	}
+}
+
+func TestExecDeadlineAlreadyPassed(t *testing.T) {
+	ctx := NewContext(WithDeadline(time.Now().Add(-time.Second)))
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	_, err := ctx.execWithErrCastToBuildError([]string{"touch", marker})
+
+	if err == nil || err.Status != buildererror.StatusDeadlineExceeded {
+		t.Fatalf("Exec() got error %v, want status %v", err, buildererror.StatusDeadlineExceeded)
+	}
+	if _, statErr := os.Stat(marker); !os.IsNotExist(statErr) {
+		t.Errorf("command ran after the buildpack deadline, stat(%q) got %v", marker, statErr)
+	}
+}
+
+func TestExecTimeoutStopsProcessGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only applicable for Linux")
+	}
+	marker := filepath.Join(t.TempDir(), "marker")
+	// The child ignores SIGTERM, so it is only stopped by the SIGKILL sent to its process group
+	// after the grace period.
+	cmd := []string{"/bin/sh", "-c", "(trap '' TERM; sleep 1; touch " + marker + ") & wait"}
+	ctx := NewContext()
+
+	_, err := ctx.execWithErrCastToBuildError(cmd, WithTimeout(100*time.Millisecond), WithGracePeriod(100*time.Millisecond))
+
+	if err == nil || err.Status != buildererror.StatusDeadlineExceeded {
+		t.Fatalf("Exec(%v) got error %v, want status %v", cmd, err, buildererror.StatusDeadlineExceeded)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, statErr := os.Stat(marker); !os.IsNotExist(statErr) {
+		t.Errorf("child process outlived the command, stat(%q) got %v", marker, statErr)
+	}
+}
+
+func TestExecTimeoutWithOutputHeldOpen(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only applicable for Linux")
+	}
+	defer func(d time.Duration) { outputCloseDelay = d }(outputCloseDelay)
+	outputCloseDelay = 100 * time.Millisecond
+	// The child starts its own session, so it is not killed with the process group and keeps stdout
+	// open after the command is stopped.
+	cmd := []string{"/bin/sh", "-c", "setsid sleep 30 & sleep 30"}
+	ctx := NewContext()
+	start := time.Now()
+
+	_, err := ctx.execWithErrCastToBuildError(cmd, WithTimeout(100*time.Millisecond), WithGracePeriod(100*time.Millisecond))
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Exec(%v) took %v, want it to return once the output close delay passed", cmd, elapsed)
+	}
+	if err == nil || err.Status != buildererror.StatusDeadlineExceeded {
+		t.Fatalf("Exec(%v) got error %v, want status %v", cmd, err, buildererror.StatusDeadlineExceeded)
+	}
+}
+
+func TestExecWithOutputHeldOpen(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only applicable for Linux")
+	}
+	defer func(d time.Duration) { outputCloseDelay = d }(outputCloseDelay)
+	outputCloseDelay = 100 * time.Millisecond
+	buf := new(bytes.Buffer)
+	ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+	cmd := []string{"/bin/sh", "-c", "echo started; setsid sleep 30 &"}
+
+	result, err := ctx.Exec(cmd)
+
+	if err != nil {
+		t.Fatalf("Exec(%v) got error: %v", cmd, err)
+	}
+	if result.Stdout != "started" {
+		t.Errorf("Exec(%v) got stdout %q, want %q", cmd, result.Stdout, "started")
+	}
+	if !strings.Contains(buf.String(), "a process it started is still holding it open") {
+		t.Errorf("Exec(%v) logged %q, want a warning about the output held open", cmd, buf.String())
+	}
+}
+
+func TestExecHeartbeat(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+	cmd := []string{"/bin/sh", "-c", "sleep 0.5"}
+
+	if _, err := ctx.Exec(cmd, WithHeartbeat(100*time.Millisecond), WithLogCommand(true)); err != nil {
+		t.Fatalf("Exec(%v) got error: %v", cmd, err)
+	}
+
+	if !strings.Contains(buf.String(), `Still running "/bin/sh -c sleep 0.5"`) {
+		t.Errorf("Exec(%v) logged %q, want a heartbeat line", cmd, buf.String())
+	}
+}
+
+func TestExecHeartbeatNotLoggedCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+	cmd := []string{"/bin/sh", "-c", "sleep 0.5"}
+
+	if _, err := ctx.Exec(cmd, WithHeartbeat(100*time.Millisecond), WithLogCommand(false)); err != nil {
+		t.Fatalf("Exec(%v) got error: %v", cmd, err)
+	}
+
+	if strings.Contains(buf.String(), "Still running") {
+		t.Errorf("Exec(%v) logged %q, want no heartbeat for a command that is not logged", cmd, buf.String())
+	}
+}
+
 type fakeExiter struct {
 	called bool
 	code   int
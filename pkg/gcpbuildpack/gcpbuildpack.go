@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/buildpacks/libcnb"
 )
@@ -51,6 +52,10 @@ const (
 	// specification does.
 	labelKeyRegexpStr = `\A[A-Za-z][A-Za-z0-9-_]*\z`
 
+	// namespacedLabelKeyRegexpStr are valid label names for AddNamespacedLabel(): lowercase,
+	// reverse-DNS notation with at least one namespace component.
+	namespacedLabelKeyRegexpStr = `\A[a-z0-9]+(-[a-z0-9]+)*(\.[a-z0-9]+(-[a-z0-9]+)*)+\z`
+
 	// WebProcess is the name of the default web process.
 	WebProcess = "web"
 )
@@ -58,6 +63,10 @@ const (
 var (
 	defaultLogger  = log.New(os.Stderr, "", 0)
 	labelKeyRegexp = regexp.MustCompile(labelKeyRegexpStr)
+
+	namespacedLabelKeyRegexp = regexp.MustCompile(namespacedLabelKeyRegexpStr)
+	// reservedLabelNamespaces are reserved for internal use by Docker.
+	reservedLabelNamespaces = []string{"com.docker.", "io.docker.", "org.dockerproject."}
 )
 
 // DetectFn is the callback signature for Detect()
@@ -69,6 +78,7 @@ type BuildFn func(*Context) error
 type stats struct {
 	spans []*spanInfo
 	user  time.Duration
+	usage []*commandUsage
 }
 
 // Context provides contextually aware functions for buildpack authors.
@@ -79,6 +89,7 @@ type Context struct {
 	debug                    bool
 	logger                   *log.Logger
 	installedRuntimeVersions []string
+	runtimeSupport           []builderoutput.RuntimeSupport
 	stats                    stats
 	exiter                   Exiter
 	warnings                 []string
@@ -91,6 +102,14 @@ type Context struct {
 	buildResult  libcnb.BuildResult
 
 	execCmd func(name string, arg ...string) *exec.Cmd
+
+	// redactor scrubs registered secrets from everything the buildpack writes.
+	redactor redactor
+
+	// deadline, if set, stops every command still running at that time.
+	deadline          time.Time
+	execTimeout       time.Duration
+	heartbeatInterval time.Duration
 }
 
 // ContextOption configures NewContext functions.
@@ -132,6 +151,13 @@ func WithExecCmd(execCmd func(name string, args ...string) *exec.Cmd) ContextOpt
 	}
 }
 
+// WithDeadline stops any command run through the Context that is still running at the given time.
+func WithDeadline(deadline time.Time) ContextOption {
+	return func(ctx *Context) {
+		ctx.deadline = deadline
+	}
+}
+
 // WithLogger override the logger implementation, this is useful for unit tests
 // which want to verify logging output.
 func WithLogger(logger *log.Logger) ContextOption {
@@ -154,11 +180,32 @@ func NewContext(opts ...ContextOption) *Context {
 		defaultLogger.Printf("Failed to parse debug mode: %v", err)
 		os.Exit(1)
 	}
+	execTimeout, err := env.Duration(env.ExecTimeout, 0)
+	if err != nil {
+		defaultLogger.Printf("Failed to parse exec timeout: %v", err)
+		os.Exit(1)
+	}
+	heartbeatInterval, err := env.Duration(env.ExecHeartbeatInterval, defaultHeartbeatInterval)
+	if err != nil {
+		defaultLogger.Printf("Failed to parse exec heartbeat interval: %v", err)
+		os.Exit(1)
+	}
+	buildpackTimeout, err := env.Duration(env.BuildpackTimeout, 0)
+	if err != nil {
+		defaultLogger.Printf("Failed to parse buildpack timeout: %v", err)
+		os.Exit(1)
+	}
 	ctx := &Context{
-		debug:   debug,
-		execCmd: exec.Command,
-		logger:  defaultLogger,
+		debug:             debug,
+		execCmd:           exec.Command,
+		logger:            defaultLogger,
+		execTimeout:       execTimeout,
+		heartbeatInterval: heartbeatInterval,
+	}
+	if buildpackTimeout > 0 {
+		ctx.deadline = time.Now().Add(buildpackTimeout)
 	}
+	ctx.registerSecretEnv(os.Environ())
 	ctx.exiter = defaultExiter{ctx: ctx}
 	for _, o := range opts {
 		o(ctx)
@@ -209,6 +256,11 @@ func (ctx *Context) BuildpackRoot() string {
 	return ctx.buildpackRoot
 }
 
+// LayersDir returns the folder holding the buildpack's layers, or "" outside of a build.
+func (ctx *Context) LayersDir() string {
+	return ctx.buildContext.Layers.Path
+}
+
 // StackID returns the stack id.
 func (ctx *Context) StackID() string {
 	return ctx.buildContext.StackID
@@ -288,7 +340,9 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 		ctx.Span(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()), now, status)
 	}(time.Now())
 
-	if err := gcpb.buildFn(ctx); err != nil {
+	err := gcpb.buildFn(ctx)
+	ctx.logUsageSummary()
+	if err != nil {
 		var be *buildererror.Error
 		if errors.As(err, &be) {
 			status = be.Status
@@ -319,7 +373,7 @@ func (ctx *Context) Exit(exitCode int, err error) {
 
 // Logf emits a structured logging line.
 func (ctx *Context) Logf(format string, args ...interface{}) {
-	ctx.logger.Printf(format, args...)
+	ctx.logger.Print(ctx.Redact(fmt.Sprintf(format, args...)))
 }
 
 // Debugf emits a structured logging line if the debug flag is set.
@@ -332,7 +386,7 @@ func (ctx *Context) Debugf(format string, args ...interface{}) {
 
 // Warnf emits a structured logging line for warnings.
 func (ctx *Context) Warnf(format string, args ...interface{}) {
-	ctx.warnings = append(ctx.warnings, fmt.Sprintf(format, args...))
+	ctx.warnings = append(ctx.warnings, ctx.Redact(fmt.Sprintf(format, args...)))
 	ctx.Logf("WARNING: "+format, args...)
 }
 
@@ -356,13 +410,21 @@ func (ctx *Context) CacheMiss(tag string) {
 
 // Span emits a structured Stackdriver span.
 func (ctx *Context) Span(label string, start time.Time, status buildererror.Status) {
+	ctx.spanWithAttributes(label, start, status, nil)
+}
+
+// spanWithAttributes emits a span with the given attributes in addition to the buildpack ones.
+func (ctx *Context) spanWithAttributes(label string, start time.Time, status buildererror.Status, extra map[string]interface{}) {
 	now := time.Now()
 	attributes := map[string]interface{}{
 		"/buildpack_id":      ctx.BuildpackID(),
 		"/buildpack_name":    ctx.BuildpackName(),
 		"/buildpack_version": ctx.BuildpackVersion(),
 	}
-	si, err := newSpanInfo(label, start, now, attributes, status)
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	si, err := newSpanInfo(ctx.Redact(label), start, now, attributes, status)
 	if err != nil {
 		ctx.Warnf("Invalid span dropped: %v", err)
 	}
@@ -380,6 +442,17 @@ func (ctx *Context) AddInstalledRuntimeVersion(version string) {
 	ctx.installedRuntimeVersions = append(ctx.installedRuntimeVersions, version)
 }
 
+// RuntimeSupport returns the lifecycle status of the runtimes installed during build time.
+func (ctx *Context) RuntimeSupport() []builderoutput.RuntimeSupport {
+	return ctx.runtimeSupport
+}
+
+// AddRuntimeSupport records the lifecycle status of an installed runtime so that it is reported in
+// the builder output.
+func (ctx *Context) AddRuntimeSupport(rs builderoutput.RuntimeSupport) {
+	ctx.runtimeSupport = append(ctx.runtimeSupport, rs)
+}
+
 // AddBOMEntry adds an entry to the bill of materials.
 func (ctx *Context) AddBOMEntry(entry libcnb.BOMEntry) {
 	if ctx.buildResult.BOM == nil {
@@ -453,3 +526,21 @@ func (ctx *Context) AddLabel(key, value string) {
 	ctx.Logf("Adding image label %s: %s", key, value)
 	ctx.buildResult.Labels = append(ctx.buildResult.Labels, libcnb.Label{Key: key, Value: value})
 }
+
+// AddNamespacedLabel adds a label with a fully-qualified key, such as
+// "org.opencontainers.image.revision", to the user's application container. Unlike AddLabel(),
+// the key is used as-is and must already be in lowercase reverse-DNS notation.
+func (ctx *Context) AddNamespacedLabel(key, value string) {
+	if !namespacedLabelKeyRegexp.MatchString(key) {
+		ctx.Warnf("Label %q does not match %s, skipping.", key, namespacedLabelKeyRegexpStr)
+		return
+	}
+	for _, ns := range reservedLabelNamespaces {
+		if strings.HasPrefix(key, ns) {
+			ctx.Warnf("Label %q uses the reserved namespace %q, skipping.", key, ns)
+			return
+		}
+	}
+	ctx.Logf("Adding image label %s: %s", key, value)
+	ctx.buildResult.Labels = append(ctx.buildResult.Labels, libcnb.Label{Key: key, Value: value})
+}
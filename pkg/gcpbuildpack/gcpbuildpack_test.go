@@ -441,6 +441,63 @@ func TestAddLabelErrors(t *testing.T) {
 	}
 }
 
+func TestAddNamespacedLabel(t *testing.T) {
+	testCases := []struct {
+		name  string
+		key   string
+		value string
+		want  []libcnb.Label
+	}{
+		{
+			name:  "oci annotation",
+			key:   "org.opencontainers.image.revision",
+			value: "abc123",
+			want:  []libcnb.Label{{Key: "org.opencontainers.image.revision", Value: "abc123"}},
+		},
+		{
+			name:  "google namespace",
+			key:   "google.source-branch",
+			value: "main",
+			want:  []libcnb.Label{{Key: "google.source-branch", Value: "main"}},
+		},
+		{
+			name: "no namespace",
+			key:  "revision",
+		},
+		{
+			name: "uppercase",
+			key:  "org.opencontainers.image.Revision",
+		},
+		{
+			name: "underscore",
+			key:  "org.example.my_key",
+		},
+		{
+			name: "empty component",
+			key:  "org..example",
+		},
+		{
+			name: "trailing dash",
+			key:  "org.example-",
+		},
+		{
+			name: "reserved namespace",
+			key:  "com.docker.compose.project",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext()
+			ctx.AddNamespacedLabel(tc.key, tc.value)
+
+			if !reflect.DeepEqual(ctx.buildResult.Labels, tc.want) {
+				t.Errorf("Labels not equal got %#v, want %#v", ctx.buildResult.Labels, tc.want)
+			}
+		})
+	}
+}
+
 func TestHasAtLeastOne(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -15,10 +15,13 @@
 package gcpbuildpack
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 )
 
 // Rename is a pass through for os.Rename(...) and logs an informational statement and returns any error with proper user / system attribution
@@ -64,6 +67,28 @@ func (ctx *Context) Symlink(oldname string, newname string) error {
 	return nil
 }
 
+// RestoreTree makes the directory tree at src available at dest, which must not exist, using the
+// first of strategies the filesystems support or else a parallel copy. Its time is attributed to the
+// user and recorded as a span.
+func (ctx *Context) RestoreTree(dest, src string, strategies ...fileutil.Strategy) error {
+	start := time.Now()
+	strategy, err := fileutil.RestoreTree(dest, src, strategies...)
+	ctx.stats.user += time.Since(start)
+	status := buildererror.StatusOk
+	if err != nil {
+		status = buildererror.StatusInternal
+	}
+	ctx.spanWithAttributes(fmt.Sprintf("Restore %q", dest), start, status, map[string]interface{}{
+		"/restore/source":   src,
+		"/restore/strategy": strategy.String(),
+	})
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "restoring %s from %s: %v", dest, src, err)
+	}
+	ctx.Debugf("Restored %q from %q using %s in %v", dest, src, strategy, time.Since(start))
+	return nil
+}
+
 // FileExists returns true if a file exists at the path joined by elem
 func (ctx *Context) FileExists(elem ...string) (bool, error) {
 	path := filepath.Join(elem...)
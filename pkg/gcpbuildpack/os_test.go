@@ -19,6 +19,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 )
 
 func TestIsWritable(t *testing.T) {
@@ -76,3 +79,45 @@ func TestIsWritable(t *testing.T) {
 		})
 	}
 }
+
+func TestRestoreTreeEmitsSpan(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	src := filepath.Join(t.TempDir(), "node_modules")
+	if err := os.MkdirAll(filepath.Join(src, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "pkg", "index.js"), []byte("module.exports = {};"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "node_modules")
+
+	if err := ctx.RestoreTree(dest, src, fileutil.Symlink); err != nil {
+		t.Fatalf("RestoreTree(%q, %q) got error: %v", dest, src, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "pkg", "index.js")); err != nil {
+		t.Errorf("Restored tree is missing pkg/index.js: %v", err)
+	}
+	if len(ctx.stats.spans) != 1 {
+		t.Fatalf("Unexpected number of spans, got %d want 1", len(ctx.stats.spans))
+	}
+	span := ctx.stats.spans[0]
+	if want := fmt.Sprintf("Restore %q", dest); span.name != want {
+		t.Errorf("Unexpected span name got %q want %q", span.name, want)
+	}
+	if span.status != buildererror.StatusOk {
+		t.Errorf("Unexpected span status got %d want %d", span.status, buildererror.StatusOk)
+	}
+	if got := span.attributes["/restore/strategy"]; got != "symlink" {
+		t.Errorf("Unexpected restore strategy got %v want %q", got, "symlink")
+	}
+
+	// The destination now exists, so restoring again fails.
+	if err := ctx.RestoreTree(dest, src); err == nil {
+		t.Errorf("RestoreTree(%q, %q) got no error, want error", dest, src)
+	}
+	if len(ctx.stats.spans) != 2 || ctx.stats.spans[1].status != buildererror.StatusInternal {
+		t.Errorf("RestoreTree(%q, %q) got spans %v, want a second one with status %v", dest, src, ctx.stats.spans, buildererror.StatusInternal)
+	}
+}
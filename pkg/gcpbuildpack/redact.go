@@ -0,0 +1,183 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// redactedText replaces secret values in output.
+	redactedText = "[REDACTED]"
+	// minSecretLength is the shortest value that is redacted. Shorter values such as "1" or "true"
+	// would mangle unrelated output and are unlikely to be credentials.
+	minSecretLength = 6
+	// minLowEntropySecretLength is the shortest value of a secret-looking environment variable that
+	// is redacted even though it uses a single class of characters. Settings such as "production"
+	// are short, while generated credentials such as 32 lowercase letters are not.
+	minLowEntropySecretLength = 20
+)
+
+var (
+	// secretEnvNameRegexp matches the names of environment variables whose values are treated as
+	// secrets. Only whole "_"-separated segments match, so AUTH_TOKEN is a secret but AUTHOR and
+	// OAUTH_REDIRECT_URI are not.
+	secretEnvNameRegexp = regexp.MustCompile(`(?i)(^|_)(SECRETS?|TOKENS?|PASSWORDS?|PASSWD|API_?KEYS?|PRIVATE_?KEYS?|CREDENTIALS?|AUTH)(_|$)`)
+	// nonSecretEnvNames are names that match secretEnvNameRegexp but hold file paths rather than
+	// secrets.
+	nonSecretEnvNames = map[string]bool{
+		"GOOGLE_APPLICATION_CREDENTIALS": true,
+	}
+)
+
+// redactor replaces registered secret values in text.
+type redactor struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+	// longest is the length of the longest registered secret.
+	longest int
+}
+
+// add registers values to be redacted.
+func (r *redactor) add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLength || r.secrets[v] {
+			continue
+		}
+		if r.secrets == nil {
+			r.secrets = map[string]bool{}
+		}
+		r.secrets[v] = true
+		if len(v) > r.longest {
+			r.longest = len(v)
+		}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	// Replace longer secrets first so that a secret containing another is redacted whole.
+	var sorted []string
+	for s := range r.secrets {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	var oldnew []string
+	for _, s := range sorted {
+		oldnew = append(oldnew, s, redactedText)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// redact returns s with every registered secret replaced.
+func (r *redactor) redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// maxSecretLength returns the length of the longest registered secret, or 0 if there are none.
+func (r *redactor) maxSecretLength() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.longest
+}
+
+// RegisterSecret marks values, such as access tokens, that must not appear in logs, warnings, error
+// messages or BUILDER_OUTPUT. Values shorter than six characters are ignored.
+func (ctx *Context) RegisterSecret(values ...string) {
+	ctx.redactor.add(values...)
+}
+
+// Redact returns s with every registered secret replaced by "[REDACTED]".
+func (ctx *Context) Redact(s string) string {
+	return ctx.redactor.redact(s)
+}
+
+// registerSecretEnv registers the values of "KEY=value" environment variables that are listed in
+// X_GOOGLE_REDACT_ENV_NAMES, or whose names look like secrets and whose values look like
+// credentials rather than settings such as AUTH_MODE=production.
+func (ctx *Context) registerSecretEnv(environ []string) {
+	named := map[string]bool{}
+	for _, n := range strings.Split(os.Getenv(env.RedactEnvNames), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			named[n] = true
+		}
+	}
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == env.RedactEnvNames {
+			continue
+		}
+		if named[k] || looksLikeSecretEnv(k, v) {
+			ctx.RegisterSecret(v)
+		}
+	}
+}
+
+// looksLikeSecretEnv reports whether an environment variable that is not listed in
+// X_GOOGLE_REDACT_ENV_NAMES holds a secret. Only these auto-detected candidates are filtered by
+// lowEntropy; listed variables and values passed to RegisterSecret are always redacted.
+func looksLikeSecretEnv(name, value string) bool {
+	if !secretEnvNameRegexp.MatchString(name) || nonSecretEnvNames[name] {
+		return false
+	}
+	return len(strings.TrimSpace(value)) >= minLowEntropySecretLength || !lowEntropy(value)
+}
+
+// lowEntropy reports whether v uses a single class of characters, such as a lowercase word like
+// "production". Generated credentials mix letters with digits, case or punctuation.
+func lowEntropy(v string) bool {
+	var lower, upper, digit, other bool
+	for _, r := range strings.TrimSpace(v) {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, c := range []bool{lower, upper, digit, other} {
+		if c {
+			classes++
+		}
+	}
+	return classes < 2
+}
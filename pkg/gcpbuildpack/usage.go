@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+)
+
+const (
+	// processSampleInterval is how often a running command's process group is counted.
+	processSampleInterval = time.Second
+	// usageSummarySize is the number of commands listed in the summary logged after a build.
+	usageSummarySize = 5
+)
+
+// commandUsage is the resource usage of a finished command and the children it waited for.
+type commandUsage struct {
+	cmd         string
+	wall        time.Duration
+	userCPU     time.Duration
+	systemCPU   time.Duration
+	maxRSSKB    int64
+	blockInput  int64
+	blockOutput int64
+	// processes is the largest number of processes seen in the command's process group. It is
+	// sampled, so very short-lived children may not be counted.
+	processes int
+	// user is whether the command is attributed to the user, such as their own build script.
+	user bool
+}
+
+// cpu returns the total CPU time used by the command.
+func (u *commandUsage) cpu() time.Duration {
+	return u.userCPU + u.systemCPU
+}
+
+// setRusage fills in the usage reported by the kernel for a finished process.
+func (u *commandUsage) setRusage(ps *os.ProcessState) {
+	if ps == nil {
+		return
+	}
+	u.userCPU = ps.UserTime()
+	u.systemCPU = ps.SystemTime()
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		// Linux reports ru_maxrss in kilobytes.
+		u.maxRSSKB = int64(ru.Maxrss)
+		u.blockInput = int64(ru.Inblock)
+		u.blockOutput = int64(ru.Oublock)
+	}
+}
+
+// attributes returns the usage as span attributes.
+func (u *commandUsage) attributes() map[string]interface{} {
+	return map[string]interface{}{
+		"/exec/user_cpu_ms":      u.userCPU.Milliseconds(),
+		"/exec/system_cpu_ms":    u.systemCPU.Milliseconds(),
+		"/exec/max_rss_kb":       u.maxRSSKB,
+		"/exec/block_input_ops":  u.blockInput,
+		"/exec/block_output_ops": u.blockOutput,
+		"/exec/processes":        int64(u.processes),
+	}
+}
+
+// recordUsage keeps the usage for the summary and adds it to the builder metrics.
+func (ctx *Context) recordUsage(u *commandUsage) {
+	ctx.stats.usage = append(ctx.stats.usage, u)
+
+	bm := buildermetrics.GlobalBuilderMetrics()
+	bm.GetCounter(buildermetrics.ExecCommandsCounterID).Increment(1)
+	bm.GetCounter(buildermetrics.ExecUserCPUMsCounterID).Increment(u.userCPU.Milliseconds())
+	bm.GetCounter(buildermetrics.ExecSystemCPUMsCounterID).Increment(u.systemCPU.Milliseconds())
+	bm.GetCounter(buildermetrics.ExecBlockInputOpsCounterID).Increment(u.blockInput)
+	bm.GetCounter(buildermetrics.ExecBlockOutputOpsCounterID).Increment(u.blockOutput)
+	bm.GetFloatDP(buildermetrics.ExecPeakRSSMBID).Max(float64(u.maxRSSKB) / 1024)
+}
+
+// logUsageSummary logs a table of the user-attributed commands that used the most CPU time. With
+// debug logging, system commands are listed as well.
+func (ctx *Context) logUsageSummary() {
+	var usage []*commandUsage
+	for _, u := range ctx.stats.usage {
+		if u.user || ctx.debug {
+			usage = append(usage, u)
+		}
+	}
+	if len(usage) == 0 {
+		return
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		return usage[i].cpu() > usage[j].cpu()
+	})
+	if len(usage) > usageSummarySize {
+		usage = usage[:usageSummarySize]
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WALL\tCPU\tMAX RSS\tPROCS\tCOMMAND")
+	for _, u := range usage {
+		cmd := u.cmd
+		if len(cmd) > 60 {
+			cmd = cmd[:60] + "..."
+		}
+		fmt.Fprintf(w, "%v\t%v\t%dMB\t%d\t%s\n", u.wall.Round(time.Millisecond), u.cpu().Round(time.Millisecond), u.maxRSSKB/1024, u.processes, cmd)
+	}
+	w.Flush()
+	ctx.Logf("Most expensive commands:\n%s", strings.TrimRight(buf.String(), "\n"))
+}
+
+// countProcessGroup returns the number of running processes in the given process group, or 0 if
+// /proc is unavailable.
+func countProcessGroup(pgid int) int {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, stat := range stats {
+		content, err := os.ReadFile(stat)
+		if err != nil {
+			// The process exited since the glob.
+			continue
+		}
+		// The command name in field 2 is parenthesized and may contain spaces, so the remaining
+		// fields are split after its closing parenthesis: state, ppid, pgrp, ...
+		s := string(content)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) < 3 {
+			continue
+		}
+		if g, err := strconv.Atoi(fields[2]); err == nil && g == pgid {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+)
+
+func TestExecRecordsUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only applicable for Linux")
+	}
+	buildermetrics.Reset()
+	t.Cleanup(buildermetrics.Reset)
+	ctx := NewContext()
+	// Burn some CPU in one child and keep another alive past the first process group sample.
+	cmd := []string{"/bin/sh", "-c", "(i=0; while [ $i -lt 200000 ]; do i=$((i+1)); done) & sleep 1.5 & wait"}
+
+	if _, err := ctx.Exec(cmd); err != nil {
+		t.Fatalf("Exec(%v) got error: %v", cmd, err)
+	}
+
+	if len(ctx.stats.usage) != 1 {
+		t.Fatalf("got %d usage records, want 1", len(ctx.stats.usage))
+	}
+	u := ctx.stats.usage[0]
+	if u.cpu() <= 0 {
+		t.Errorf("usage CPU got %v, want > 0", u.cpu())
+	}
+	if u.maxRSSKB <= 0 {
+		t.Errorf("usage max RSS got %dKB, want > 0", u.maxRSSKB)
+	}
+	if u.processes < 2 {
+		t.Errorf("usage processes got %d, want >= 2", u.processes)
+	}
+	span := ctx.stats.spans[0]
+	for _, k := range []string{"/exec/user_cpu_ms", "/exec/system_cpu_ms", "/exec/max_rss_kb", "/exec/block_input_ops", "/exec/block_output_ops", "/exec/processes"} {
+		if _, ok := span.attributes[k]; !ok {
+			t.Errorf("span attributes %v missing %q", span.attributes, k)
+		}
+	}
+	if got := span.attributes["/buildpack_id"]; got != ctx.BuildpackID() {
+		t.Errorf("span attribute /buildpack_id got %v, want %q", got, ctx.BuildpackID())
+	}
+	bm := buildermetrics.GlobalBuilderMetrics()
+	if got := bm.GetCounter(buildermetrics.ExecCommandsCounterID).Value(); got != 1 {
+		t.Errorf("exec commands counter got %d, want 1", got)
+	}
+	if got, want := bm.GetFloatDP(buildermetrics.ExecPeakRSSMBID).Value(), float64(u.maxRSSKB)/1024; got != want {
+		t.Errorf("peak RSS metric got %v, want %v", got, want)
+	}
+}
+
+func TestLogUsageSummary(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+	for i, cpu := range []time.Duration{time.Second, 5 * time.Second, 0, 2 * time.Second, 3 * time.Second, 4 * time.Second} {
+		ctx.stats.usage = append(ctx.stats.usage, &commandUsage{
+			cmd:      "cmd" + string(rune('a'+i)),
+			userCPU:  cpu,
+			maxRSSKB: 2048,
+			user:     true,
+		})
+	}
+	ctx.stats.usage = append(ctx.stats.usage, &commandUsage{cmd: "system", userCPU: time.Minute})
+
+	ctx.logUsageSummary()
+
+	got := buf.String()
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 2+usageSummarySize {
+		t.Fatalf("logUsageSummary() logged %d lines, want %d:\n%s", len(lines), 2+usageSummarySize, got)
+	}
+	if !strings.HasSuffix(lines[2], "cmdb") {
+		t.Errorf("logUsageSummary() first command line %q, want the most CPU-intensive command cmdb", lines[2])
+	}
+	if !strings.Contains(lines[2], "2MB") {
+		t.Errorf("logUsageSummary() line %q, want max RSS 2MB", lines[2])
+	}
+	if strings.Contains(got, "cmdc") {
+		t.Errorf("logUsageSummary() logged cmdc, want only the top %d commands:\n%s", usageSummarySize, got)
+	}
+}
+
+func TestLogUsageSummarySystemCommands(t *testing.T) {
+	testCases := []struct {
+		name    string
+		debug   bool
+		wantLog bool
+	}{
+		{
+			name: "system commands are not listed",
+		},
+		{
+			name:    "system commands are listed in debug mode",
+			debug:   true,
+			wantLog: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+			ctx.debug = tc.debug
+			ctx.stats.usage = append(ctx.stats.usage, &commandUsage{cmd: "system", userCPU: time.Second})
+
+			ctx.logUsageSummary()
+
+			if got := strings.Contains(buf.String(), "system"); got != tc.wantLog {
+				t.Errorf("logUsageSummary() logged %q, want system command listed=%t", buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
@@ -254,7 +254,8 @@ func ExecWithGoproxyFallback(ctx *gcp.Context, cmd []string, opts ...gcp.ExecOpt
 		return nil, fmt.Errorf("checking for go proxy support: %w", err)
 	}
 	if supportsGoProxy {
-		opts = append(opts, gcp.WithEnv("GOPROXY=https://proxy.golang.org|direct"))
+		// The fallback comes first so a GOPROXY passed by the caller, e.g. for Artifact Registry, wins.
+		opts = append([]gcp.ExecOption{gcp.WithEnv("GOPROXY=https://proxy.golang.org|direct")}, opts...)
 		return ctx.Exec(cmd, opts...)
 	}
 
@@ -0,0 +1,211 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ignorefile matches paths against .gcloudignore/.dockerignore-style pattern files.
+//
+// Each non-empty line that does not start with "#" is a pattern. Patterns are evaluated in order
+// and the last matching pattern wins:
+//   - "*" and "?" match within a single path segment, "**" matches any number of segments;
+//   - a leading "!" negates the pattern;
+//   - a trailing "/" only matches directories;
+//   - a pattern containing a "/" other than a trailing one is anchored to the root, otherwise it
+//     matches at any depth;
+//   - a pattern matching a directory also matches everything below it.
+package ignorefile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches relative, slash-separated paths against a list of patterns.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	raw     string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Parse returns a Matcher for the patterns in the given ignore file contents.
+func Parse(content string) (*Matcher, error) {
+	var lines []string
+	s := bufio.NewScanner(strings.NewReader(content))
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return New(lines...)
+}
+
+// ReadFile returns a Matcher for the patterns in the file at path. A missing file yields a
+// Matcher that matches nothing.
+func ReadFile(path string) (*Matcher, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Matcher{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// New returns a Matcher for the given patterns, using the same syntax as the lines of an ignore file.
+func New(patterns ...string) (*Matcher, error) {
+	m := &Matcher{}
+	if err := m.Add(patterns...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Add appends patterns to the matcher. They take precedence over the existing patterns.
+func (m *Matcher) Add(patterns ...string) error {
+	for _, raw := range patterns {
+		p, ok, err := compile(raw)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", raw, err)
+		}
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return nil
+}
+
+// Extend appends the patterns of other to the matcher. They take precedence over the existing patterns.
+func (m *Matcher) Extend(other *Matcher) {
+	m.patterns = append(m.patterns, other.patterns...)
+}
+
+// Match returns true if the relative, slash-separated path is matched by the patterns.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return false
+	}
+	// The path itself and each of its parent directories are candidates; parents are always directories.
+	segments := strings.Split(rel, "/")
+	matched := false
+	for _, p := range m.patterns {
+		for i := range segments {
+			candidate := strings.Join(segments[:i+1], "/")
+			candidateIsDir := isDir || i < len(segments)-1
+			if p.dirOnly && !candidateIsDir {
+				continue
+			}
+			if p.re.MatchString(candidate) {
+				matched = !p.negate
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// HasNegations returns true if any pattern is negated. Without negations, everything below a
+// matched directory is matched too, so callers can skip walking it.
+func (m *Matcher) HasNegations() bool {
+	for _, p := range m.patterns {
+		if p.negate {
+			return true
+		}
+	}
+	return false
+}
+
+// compile converts a single ignore-file line to a pattern. It returns false for blank lines and comments.
+func compile(raw string) (pattern, bool, error) {
+	line := strings.TrimRight(raw, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false, nil
+	}
+	p := pattern{raw: raw}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern{}, false, fmt.Errorf("empty pattern")
+	}
+	if !anchored && !strings.HasPrefix(line, "**") {
+		line = "**/" + line
+	}
+	expr, err := translate(line)
+	if err != nil {
+		return pattern{}, false, err
+	}
+	p.re, err = regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return pattern{}, false, err
+	}
+	return p, true, nil
+}
+
+// translate converts a glob to a regular expression.
+func translate(glob string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			sb.WriteString(regexp.QuoteMeta(string(glob[i+1])))
+			i++
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ignorefile
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patterns string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "no patterns", patterns: "", path: "foo", want: false},
+		{name: "comment", patterns: "# foo", path: "foo", want: false},
+		{name: "basename at root", patterns: "foo", path: "foo", want: true},
+		{name: "basename at depth", patterns: "foo", path: "a/b/foo", want: true},
+		{name: "basename prefix", patterns: "foo", path: "foo.bar", want: false},
+		{name: "anchored at root", patterns: "/foo", path: "foo", want: true},
+		{name: "anchored not at depth", patterns: "/foo", path: "a/foo", want: false},
+		{name: "anchored with middle slash", patterns: "a/foo", path: "b/a/foo", want: false},
+		{name: "star within segment", patterns: "*.yaml", path: "config/app.yaml", want: true},
+		{name: "star does not cross segments", patterns: "config/*.yaml", path: "config/x/app.yaml", want: false},
+		{name: "double star", patterns: "config/**/*.yaml", path: "config/x/y/app.yaml", want: true},
+		{name: "double star zero dirs", patterns: "config/**/*.yaml", path: "config/app.yaml", want: true},
+		{name: "double star no match", patterns: "config/**/*.yaml", path: "config/x/app.json", want: false},
+		{name: "trailing double star", patterns: "templates/**", path: "templates/a/b.html", want: true},
+		{name: "question mark", patterns: "file?.txt", path: "file1.txt", want: true},
+		{name: "character class", patterns: "file[0-9].txt", path: "filea.txt", want: false},
+		{name: "negated character class", patterns: "file[!0-9].txt", path: "filea.txt", want: true},
+		{name: "directory matches descendants", patterns: "templates", path: "templates/a/b.html", want: true},
+		{name: "dir only matches dir", patterns: "build/", path: "build", isDir: true, want: true},
+		{name: "dir only skips file", patterns: "build/", path: "build", want: false},
+		{name: "dir only matches descendants", patterns: "build/", path: "build/out.o", want: true},
+		{name: "negation", patterns: "*.yaml\n!secret.yaml", path: "config/secret.yaml", want: false},
+		{name: "negation then rematch", patterns: "*.yaml\n!secret.yaml\nconfig/secret.yaml", path: "config/secret.yaml", want: true},
+		{name: "negation of descendant", patterns: "config/\n!config/local/", path: "config/local/a.yaml", want: false},
+		{name: "escaped", patterns: `\!important`, path: "!important", want: true},
+		{name: "leading dot slash path", patterns: "foo", path: "./foo", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := Parse(tc.patterns)
+			if err != nil {
+				t.Fatalf("Parse(%q) got error: %v", tc.patterns, err)
+			}
+			if got := m.Match(tc.path, tc.isDir); got != tc.want {
+				t.Errorf("Match(%q, %t) with patterns %q = %t, want %t", tc.path, tc.isDir, tc.patterns, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, patterns := range []string{"[abc", "!", "/"} {
+		if _, err := Parse(patterns); err == nil {
+			t.Errorf("Parse(%q) got no error, want error", patterns)
+		}
+	}
+}
+
+func TestReadFileMissing(t *testing.T) {
+	m, err := ReadFile("/does/not/exist")
+	if err != nil {
+		t.Fatalf("ReadFile() got error: %v", err)
+	}
+	if m.Match("foo", false) {
+		t.Errorf("Match() on empty matcher = true, want false")
+	}
+}
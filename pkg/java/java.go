@@ -52,6 +52,10 @@ const (
 	// MavenBuildArgs is an env var used to append arguments to the mvn build command.
 	// Example: `clean package` for Maven apps run "mvn clean package" command.
 	MavenBuildArgs = "GOOGLE_MAVEN_BUILD_ARGS"
+
+	// LayeredJarEnv is an env var used to disable splitting the executable jar into launch layers.
+	// Example: `false` keeps launching the jar with "java -jar".
+	LayeredJarEnv = "GOOGLE_JAVA_LAYERED_JAR"
 )
 
 var (
@@ -0,0 +1,424 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Launch layers of an exploded jar, ordered from least to most likely to change between builds.
+const (
+	// DependenciesLayer contains released dependencies.
+	DependenciesLayer = "dependencies"
+	// SpringBootLoaderLayer contains the Spring Boot jar launcher.
+	SpringBootLoaderLayer = "spring-boot-loader"
+	// SnapshotDependenciesLayer contains -SNAPSHOT dependencies.
+	SnapshotDependenciesLayer = "snapshot-dependencies"
+	// ApplicationLayer contains the application classes and resources.
+	ApplicationLayer = "application"
+)
+
+const (
+	bootInfPath        = "BOOT-INF/"
+	bootClassesPath    = "BOOT-INF/classes/"
+	bootLibPath        = "BOOT-INF/lib/"
+	bootLoaderPath     = "org/springframework/boot/loader/"
+	layersIndexPath    = "BOOT-INF/layers.idx"
+	classpathIndexPath = "BOOT-INF/classpath.idx"
+	startClassKey      = "Start-Class"
+	classPathKey       = "Class-Path"
+	snapshotMarker     = "-SNAPSHOT"
+)
+
+var (
+	// layerIndexRegexp matches a layer name line in layers.idx, e.g. `- "dependencies":`.
+	layerIndexRegexp = regexp.MustCompile(`^- "(.+)":$`)
+	// entryIndexRegexp matches an entry line in layers.idx or classpath.idx, e.g. `  - "BOOT-INF/lib/"`.
+	entryIndexRegexp = regexp.MustCompile(`^\s*- "(.+)"$`)
+)
+
+// JarLayout describes how to split an executable jar into launch layers.
+type JarLayout struct {
+	// MainClass is the class to launch from the exploded classpath.
+	MainClass string
+	// Layers are the groups of files to extract, ordered from least to most likely to change.
+	Layers []JarLayer
+	// Classpath is the launch classpath, in order.
+	Classpath []ClasspathEntry
+}
+
+// JarLayer is a group of files extracted to the same launch layer.
+type JarLayer struct {
+	Name string
+	// Entries are the jar entries in the layer.
+	Entries []string
+	// Files are paths outside the jar, relative to the jar's directory, to copy into the layer.
+	Files []string
+	// Digest identifies the contents of the layer, so that unchanged layers can be reused.
+	Digest string
+}
+
+// ClasspathEntry is a path relative to the root of one of the layers.
+type ClasspathEntry struct {
+	Layer string
+	Path  string
+}
+
+// ReadJarLayout returns the layout of the executable jar at jarPath. It returns nil if the jar is
+// neither a Spring Boot jar nor a jar referencing its dependencies through a Class-Path entry.
+func ReadJarLayout(jarPath string) (*JarLayout, error) {
+	r, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return nil, gcp.UserErrorf("unzipping jar %s: %v", jarPath, err)
+	}
+	defer r.Close()
+
+	entries := make(map[string]*zip.File, len(r.File))
+	isBoot := false
+	for _, f := range r.File {
+		entries[f.Name] = f
+		isBoot = isBoot || strings.HasPrefix(f.Name, bootInfPath)
+	}
+	var manifest map[string]string
+	if f, ok := entries[ManifestPath]; ok {
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s in jar %s: %w", ManifestPath, jarPath, err)
+		}
+		manifest = parseManifest(content)
+	}
+
+	var layout *JarLayout
+	if isBoot {
+		layout, err = bootLayout(r.File, entries, manifest)
+	} else {
+		layout, err = classPathLayout(filepath.Dir(jarPath), r.File, manifest)
+	}
+	if err != nil || layout == nil {
+		return nil, err
+	}
+	for i := range layout.Layers {
+		if layout.Layers[i].Digest, err = layerDigest(filepath.Dir(jarPath), entries, layout.Layers[i]); err != nil {
+			return nil, err
+		}
+	}
+	return layout, nil
+}
+
+// bootLayout splits a Spring Boot jar according to its layers.idx, or the default Spring Boot
+// layers if it has none, and launches the Start-Class directly.
+func bootLayout(files []*zip.File, entries map[string]*zip.File, manifest map[string]string) (*JarLayout, error) {
+	main := manifest[startClassKey]
+	if main == "" {
+		return nil, nil
+	}
+
+	var names []string
+	var assign func(name string) string
+	if f, ok := entries[layersIndexPath]; ok {
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", layersIndexPath, err)
+		}
+		idx, err := parseLayersIndex(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range idx {
+			names = append(names, l.name)
+		}
+		assign = func(name string) string {
+			for _, l := range idx {
+				for _, p := range l.prefixes {
+					if name == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(name, p)) {
+						return l.name
+					}
+				}
+			}
+			// Spring Boot assigns unlisted entries to the last layer.
+			return idx[len(idx)-1].name
+		}
+	} else {
+		names = []string{DependenciesLayer, SpringBootLoaderLayer, SnapshotDependenciesLayer, ApplicationLayer}
+		assign = func(name string) string {
+			switch {
+			case strings.HasPrefix(name, bootLoaderPath):
+				return SpringBootLoaderLayer
+			case strings.HasPrefix(name, bootLibPath) && strings.Contains(path.Base(name), snapshotMarker):
+				return SnapshotDependenciesLayer
+			case strings.HasPrefix(name, bootLibPath):
+				return DependenciesLayer
+			default:
+				return ApplicationLayer
+			}
+		}
+	}
+
+	layers := make(map[string]*JarLayer, len(names))
+	layout := &JarLayout{MainClass: main}
+	for _, n := range names {
+		layout.Layers = append(layout.Layers, JarLayer{Name: n})
+	}
+	for i := range layout.Layers {
+		layers[layout.Layers[i].Name] = &layout.Layers[i]
+	}
+	location := map[string]string{}
+	var libs []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		l := assign(f.Name)
+		layers[l].Entries = append(layers[l].Entries, f.Name)
+		location[f.Name] = l
+		if path.Dir(f.Name)+"/" == bootLibPath {
+			libs = append(libs, f.Name)
+		}
+	}
+
+	// Classes come first, followed by the libraries in the order of classpath.idx if present.
+	layout.Classpath = append(layout.Classpath, ClasspathEntry{Layer: assign(bootClassesPath), Path: bootClassesPath})
+	if f, ok := entries[classpathIndexPath]; ok {
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", classpathIndexPath, err)
+		}
+		libs = nil
+		for _, e := range parseIndexEntries(content) {
+			if !strings.Contains(e, "/") {
+				// Spring Boot 2.3 lists the file names only.
+				e = bootLibPath + e
+			}
+			libs = append(libs, e)
+		}
+	} else {
+		sort.Strings(libs)
+	}
+	for _, lib := range libs {
+		if l, ok := location[lib]; ok {
+			layout.Classpath = append(layout.Classpath, ClasspathEntry{Layer: l, Path: lib})
+		}
+	}
+	return layout, nil
+}
+
+// classPathLayout keeps the jar contents in the application layer and moves the dependencies its
+// Class-Path references into dependency layers.
+func classPathLayout(jarDir string, files []*zip.File, manifest map[string]string) (*JarLayout, error) {
+	main := manifest[mainClassKey]
+	if main == "" || manifest[classPathKey] == "" {
+		return nil, nil
+	}
+	deps := JarLayer{Name: DependenciesLayer}
+	snapshots := JarLayer{Name: SnapshotDependenciesLayer}
+	app := JarLayer{Name: ApplicationLayer}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name, "/") {
+			app.Entries = append(app.Entries, f.Name)
+		}
+	}
+
+	layout := &JarLayout{MainClass: main}
+	layout.Classpath = append(layout.Classpath, ClasspathEntry{Layer: ApplicationLayer, Path: "."})
+	for _, cp := range strings.Fields(manifest[classPathKey]) {
+		// Class-Path entries are URLs relative to the jar; only local files next to it can be moved.
+		if strings.Contains(cp, ":") || !filepath.IsLocal(filepath.FromSlash(cp)) {
+			continue
+		}
+		p := filepath.FromSlash(cp)
+		if _, err := os.Stat(filepath.Join(jarDir, p)); err != nil {
+			continue
+		}
+		l := &deps
+		if strings.Contains(filepath.Base(p), snapshotMarker) {
+			l = &snapshots
+		}
+		l.Files = append(l.Files, p)
+		layout.Classpath = append(layout.Classpath, ClasspathEntry{Layer: l.Name, Path: p})
+	}
+	if len(deps.Files) == 0 && len(snapshots.Files) == 0 {
+		return nil, nil
+	}
+	layout.Layers = []JarLayer{deps, snapshots, app}
+	return layout, nil
+}
+
+// ExtractJarLayer writes the contents of the layer from the jar at jarPath to dest.
+func ExtractJarLayer(jarPath string, l JarLayer, dest string) error {
+	r, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return gcp.UserErrorf("unzipping jar %s: %v", jarPath, err)
+	}
+	defer r.Close()
+	want := make(map[string]bool, len(l.Entries))
+	for _, e := range l.Entries {
+		want[e] = true
+	}
+	for _, f := range r.File {
+		if !want[f.Name] {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(f.Name)) {
+			return gcp.UserErrorf("jar %s contains entry %q outside of the jar root", jarPath, f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("opening %s in jar %s: %w", f.Name, jarPath, err)
+		}
+		err = writeFile(filepath.Join(dest, filepath.FromSlash(f.Name)), rc, f.Mode().Perm()|0444)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range l.Files {
+		src, err := os.Open(filepath.Join(filepath.Dir(jarPath), p))
+		if err != nil {
+			return err
+		}
+		err = writeFile(filepath.Join(dest, p), src, 0644)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(p string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", p, err)
+	}
+	return f.Close()
+}
+
+// layerDigest hashes the names, checksums and sizes of the layer's jar entries and the contents
+// of its external files.
+func layerDigest(jarDir string, entries map[string]*zip.File, l JarLayer) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "layer %s\n", l.Name)
+	for _, e := range l.Entries {
+		f := entries[e]
+		fmt.Fprintf(h, "entry %s %08x %d %o\n", f.Name, f.CRC32, f.UncompressedSize64, f.Mode())
+	}
+	for _, p := range l.Files {
+		fmt.Fprintf(h, "file %s\n", filepath.ToSlash(p))
+		f, err := os.Open(filepath.Join(jarDir, p))
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("hashing %s: %w", p, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type indexedLayer struct {
+	name     string
+	prefixes []string
+}
+
+// parseLayersIndex parses a Spring Boot layers.idx file.
+func parseLayersIndex(content []byte) ([]indexedLayer, error) {
+	var layers []indexedLayer
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := layerIndexRegexp.FindStringSubmatch(line); m != nil {
+			layers = append(layers, indexedLayer{name: strings.ReplaceAll(m[1], "/", "-")})
+		} else if m := entryIndexRegexp.FindStringSubmatch(line); m != nil && len(layers) > 0 {
+			layers[len(layers)-1].prefixes = append(layers[len(layers)-1].prefixes, m[1])
+		}
+	}
+	if len(layers) == 0 {
+		return nil, gcp.UserErrorf("%s does not define any layers", layersIndexPath)
+	}
+	return layers, nil
+}
+
+// parseIndexEntries returns the entries of a Spring Boot classpath.idx file.
+func parseIndexEntries(content []byte) []string {
+	var entries []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if m := entryIndexRegexp.FindStringSubmatch(strings.TrimRight(line, "\r")); m != nil {
+			entries = append(entries, m[1])
+		}
+	}
+	return entries
+}
+
+// parseManifest returns the main attributes of a jar manifest, joining continuation lines.
+func parseManifest(content []byte) map[string]string {
+	attrs := map[string]string{}
+	var key string
+	sc := bufio.NewScanner(bytes.NewReader(content))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			// The main section ends at the first blank line.
+			break
+		}
+		if strings.HasPrefix(line, " ") {
+			if key != "" {
+				attrs[key] += line[1:]
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			key = ""
+			continue
+		}
+		key = k
+		attrs[key] = strings.TrimPrefix(v, " ")
+	}
+	for k, v := range attrs {
+		attrs[k] = strings.TrimSpace(v)
+	}
+	return attrs
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
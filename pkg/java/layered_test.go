@@ -0,0 +1,283 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadJarLayout(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries map[string]string
+		files   map[string]string
+		want    *JarLayout
+	}{
+		{
+			name: "spring boot default layers",
+			entries: map[string]string{
+				"META-INF/MANIFEST.MF":                                     "Main-Class: org.springframework.boot.loader.launch.JarLauncher\nStart-Class: com.example.App\n",
+				"BOOT-INF/classes/com/example/App.class":                   "app",
+				"BOOT-INF/lib/spring-core-6.1.0.jar":                       "core",
+				"BOOT-INF/lib/acme-1.0-SNAPSHOT.jar":                       "acme",
+				"org/springframework/boot/loader/launch/JarLauncher.class": "loader",
+			},
+			want: &JarLayout{
+				MainClass: "com.example.App",
+				Layers: []JarLayer{
+					{Name: DependenciesLayer, Entries: []string{"BOOT-INF/lib/spring-core-6.1.0.jar"}},
+					{Name: SpringBootLoaderLayer, Entries: []string{"org/springframework/boot/loader/launch/JarLauncher.class"}},
+					{Name: SnapshotDependenciesLayer, Entries: []string{"BOOT-INF/lib/acme-1.0-SNAPSHOT.jar"}},
+					{Name: ApplicationLayer, Entries: []string{"BOOT-INF/classes/com/example/App.class", "META-INF/MANIFEST.MF"}},
+				},
+				Classpath: []ClasspathEntry{
+					{Layer: ApplicationLayer, Path: "BOOT-INF/classes/"},
+					{Layer: SnapshotDependenciesLayer, Path: "BOOT-INF/lib/acme-1.0-SNAPSHOT.jar"},
+					{Layer: DependenciesLayer, Path: "BOOT-INF/lib/spring-core-6.1.0.jar"},
+				},
+			},
+		},
+		{
+			name: "spring boot layers index",
+			entries: map[string]string{
+				"META-INF/MANIFEST.MF":                   "Start-Class: com.example.App\n",
+				"BOOT-INF/classes/com/example/App.class": "app",
+				"BOOT-INF/lib/a.jar":                     "a",
+				"BOOT-INF/lib/b.jar":                     "b",
+				"BOOT-INF/layers.idx":                    "- \"company\":\n  - \"BOOT-INF/lib/b.jar\"\n- \"dependencies\":\n  - \"BOOT-INF/lib/\"\n- \"application\":\n  - \"BOOT-INF/classes/\"\n",
+				"BOOT-INF/classpath.idx":                 "- \"BOOT-INF/lib/b.jar\"\n- \"BOOT-INF/lib/a.jar\"\n",
+			},
+			want: &JarLayout{
+				MainClass: "com.example.App",
+				Layers: []JarLayer{
+					{Name: "company", Entries: []string{"BOOT-INF/lib/b.jar"}},
+					{Name: "dependencies", Entries: []string{"BOOT-INF/lib/a.jar"}},
+					{Name: "application", Entries: []string{"BOOT-INF/classes/com/example/App.class", "BOOT-INF/classpath.idx", "BOOT-INF/layers.idx", "META-INF/MANIFEST.MF"}},
+				},
+				Classpath: []ClasspathEntry{
+					{Layer: "application", Path: "BOOT-INF/classes/"},
+					{Layer: "company", Path: "BOOT-INF/lib/b.jar"},
+					{Layer: "dependencies", Path: "BOOT-INF/lib/a.jar"},
+				},
+			},
+		},
+		{
+			name: "class path layout",
+			entries: map[string]string{
+				"META-INF/MANIFEST.MF":   "Main-Class: com.example.Main\nClass-Path: lib/guava-33.0.jar lib/acme-1.0-SNAPSHOT.ja\n r lib/missing.jar ../outside.jar\n",
+				"com/example/Main.class": "main",
+			},
+			files: map[string]string{
+				"lib/guava-33.0.jar":        "guava",
+				"lib/acme-1.0-SNAPSHOT.jar": "acme",
+				"../outside.jar":            "outside",
+			},
+			want: &JarLayout{
+				MainClass: "com.example.Main",
+				Layers: []JarLayer{
+					{Name: DependenciesLayer, Files: []string{"lib/guava-33.0.jar"}},
+					{Name: SnapshotDependenciesLayer, Files: []string{"lib/acme-1.0-SNAPSHOT.jar"}},
+					{Name: ApplicationLayer, Entries: []string{"META-INF/MANIFEST.MF", "com/example/Main.class"}},
+				},
+				Classpath: []ClasspathEntry{
+					{Layer: ApplicationLayer, Path: "."},
+					{Layer: DependenciesLayer, Path: "lib/guava-33.0.jar"},
+					{Layer: SnapshotDependenciesLayer, Path: "lib/acme-1.0-SNAPSHOT.jar"},
+				},
+			},
+		},
+		{
+			name: "spring boot without start class",
+			entries: map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: com.example.Main\n",
+				"BOOT-INF/lib/a.jar":   "a",
+			},
+		},
+		{
+			name: "shaded jar",
+			entries: map[string]string{
+				"META-INF/MANIFEST.MF":   "Main-Class: com.example.Main\n",
+				"com/example/Main.class": "main",
+				"com/google/Lib.class":   "lib",
+			},
+		},
+		{
+			name: "class path without local dependencies",
+			entries: map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: com.example.Main\nClass-Path: lib/missing.jar\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "target")
+			writeTestFiles(t, dir, tc.files)
+			jarPath := filepath.Join(dir, "app.jar")
+			writeTestJar(t, jarPath, tc.entries)
+
+			got, err := ReadJarLayout(jarPath)
+			if err != nil {
+				t.Fatalf("ReadJarLayout() got error: %v", err)
+			}
+			if got != nil {
+				for i := range got.Layers {
+					if got.Layers[i].Digest == "" {
+						t.Errorf("ReadJarLayout() layer %q has no digest", got.Layers[i].Name)
+					}
+					got.Layers[i].Digest = ""
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ReadJarLayout() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadJarLayoutDigest(t *testing.T) {
+	entries := map[string]string{
+		"META-INF/MANIFEST.MF":                   "Start-Class: com.example.App\n",
+		"BOOT-INF/classes/com/example/App.class": "app",
+		"BOOT-INF/lib/a.jar":                     "a",
+	}
+	digests := func(entries map[string]string) map[string]string {
+		t.Helper()
+		jarPath := filepath.Join(t.TempDir(), "app.jar")
+		writeTestJar(t, jarPath, entries)
+		layout, err := ReadJarLayout(jarPath)
+		if err != nil {
+			t.Fatalf("ReadJarLayout() got error: %v", err)
+		}
+		d := map[string]string{}
+		for _, l := range layout.Layers {
+			d[l.Name] = l.Digest
+		}
+		return d
+	}
+
+	before := digests(entries)
+	entries["BOOT-INF/classes/com/example/App.class"] = "changed"
+	after := digests(entries)
+
+	if before[DependenciesLayer] != after[DependenciesLayer] {
+		t.Errorf("%s digest changed after changing application classes: %q != %q", DependenciesLayer, before[DependenciesLayer], after[DependenciesLayer])
+	}
+	if before[ApplicationLayer] == after[ApplicationLayer] {
+		t.Errorf("%s digest did not change after changing application classes", ApplicationLayer)
+	}
+}
+
+func TestExtractJarLayer(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"lib/dep.jar": "dep"})
+	jarPath := filepath.Join(dir, "app.jar")
+	writeTestJar(t, jarPath, map[string]string{
+		"BOOT-INF/lib/a.jar": "a",
+		"BOOT-INF/lib/b.jar": "b",
+	})
+	dest := t.TempDir()
+
+	l := JarLayer{Name: DependenciesLayer, Entries: []string{"BOOT-INF/lib/a.jar"}, Files: []string{"lib/dep.jar"}}
+	if err := ExtractJarLayer(jarPath, l, dest); err != nil {
+		t.Fatalf("ExtractJarLayer() got error: %v", err)
+	}
+
+	want := map[string]string{
+		"BOOT-INF/lib/a.jar": "a",
+		"lib/dep.jar":        "dep",
+	}
+	got := map[string]string{}
+	err := filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		got[filepath.ToSlash(rel)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walking %s: %v", dest, err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ExtractJarLayer() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	content := "Manifest-Version: 1.0\r\nClass-Path: a.jar b\r\n .jar\r\nMain-Class: com.example.Main\r\n\r\nName: ignored\r\nMain-Class: other\r\n"
+	want := map[string]string{
+		"Manifest-Version": "1.0",
+		"Class-Path":       "a.jar b.jar",
+		"Main-Class":       "com.example.Main",
+	}
+	if diff := cmp.Diff(want, parseManifest([]byte(content))); diff != "" {
+		t.Errorf("parseManifest() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", p, err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", p, err)
+		}
+	}
+}
+
+// writeTestJar writes a jar with the given entries, in lexical order, to jarPath.
+func writeTestJar(t *testing.T, jarPath string, entries map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(jarPath), 0755); err != nil {
+		t.Fatalf("creating directory for %s: %v", jarPath, err)
+	}
+	f, err := os.Create(jarPath)
+	if err != nil {
+		t.Fatalf("creating %s: %v", jarPath, err)
+	}
+	defer f.Close()
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := zip.NewWriter(f)
+	for _, name := range names {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("creating %s in jar: %v", name, err)
+		}
+		if _, err := fw.Write([]byte(entries[name])); err != nil {
+			t.Fatalf("writing %s in jar: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing jar: %v", err)
+	}
+}
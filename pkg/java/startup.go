@@ -0,0 +1,337 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// AppCDSEnv is an env var used to enable training an AppCDS archive at build time.
+	// Example: `true` runs the application briefly and archives the classes it loads.
+	AppCDSEnv = "GOOGLE_JAVA_APPCDS"
+
+	// AppCDSTrainingSecondsEnv is an env var used to limit the AppCDS training run.
+	// Example: `10` stops the application after 10 seconds. Defaults to 30.
+	AppCDSTrainingSecondsEnv = "GOOGLE_JAVA_APPCDS_TRAINING_SECONDS"
+
+	// JlinkEnv is an env var used to replace the JDK in the application image with a minimal
+	// runtime assembled by jlink.
+	// Example: `true`.
+	JlinkEnv = "GOOGLE_JAVA_JLINK"
+
+	// JlinkModulesEnv is an env var used to add modules that jdeps cannot detect, such as those
+	// only loaded through reflection, to the jlink runtime.
+	// Example: `java.scripting,jdk.httpserver`.
+	JlinkModulesEnv = "GOOGLE_JAVA_JLINK_MODULES"
+
+	appCDSLayer            = "appcds"
+	appCDSArchive          = "app.jsa"
+	jreLayer               = "jre"
+	defaultTrainingSeconds = 30
+	// timeoutExitCode is the exit code of timeout(1) when the command timed out.
+	timeoutExitCode = 124
+	startupCacheKey = "startup-key"
+)
+
+var (
+	// baseModules are added to every jlink runtime. jdeps cannot see into nested jars or
+	// reflective and service loader usage, so these cover what common frameworks rely on.
+	baseModules = []string{
+		"java.base",
+		"java.instrument",
+		"java.logging",
+		"java.management",
+		"java.naming",
+		"java.net.http",
+		"java.security.jgss",
+		"java.sql",
+		"jdk.charsets",
+		"jdk.crypto.ec",
+		"jdk.localedata",
+		"jdk.management",
+		"jdk.unsupported",
+		"jdk.zipfs",
+	}
+
+	// layerModTime is the timestamp the lifecycle gives files in the exported image. The JVM
+	// rejects a CDS archive if the jars on the classpath have different timestamps than at dump time.
+	layerModTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+	jdkVersionRegexp = regexp.MustCompile(`^(\d+)`)
+	// javaVersionRegexp matches the version in the output of "java -version", e.g. `version "17.0.9"`.
+	javaVersionRegexp = regexp.MustCompile(`version "(\d+)`)
+)
+
+// AppCDSEnabled returns true if AppCDS training was requested with AppCDSEnv.
+func AppCDSEnabled() (bool, error) {
+	enabled, err := env.IsPresentAndTrue(AppCDSEnv)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	return enabled, nil
+}
+
+// JlinkEnabled returns true if a jlink runtime was requested with JlinkEnv and the application
+// is launched by the java/entrypoint buildpack, which assembles it. Other entrypoints, such as
+// GOOGLE_ENTRYPOINT, a Procfile, an exploded jar or the functions framework, keep the full JDK.
+func JlinkEnabled(ctx *gcp.Context) (bool, error) {
+	enabled, err := env.IsPresentAndTrue(JlinkEnv)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	if !enabled {
+		return false, nil
+	}
+	if !env.IsGCP() || os.Getenv(env.Entrypoint) != "" || os.Getenv(env.FunctionTarget) != "" {
+		return false, nil
+	}
+	if devMode, err := env.IsDevMode(); err != nil || devMode {
+		return false, nil
+	}
+	for _, f := range []string{"Procfile", ManifestPath} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return false, nil
+		}
+	}
+	if entrypoint, _ := appyaml.EntrypointIfExists(ctx.ApplicationRoot()); entrypoint != "" {
+		return false, nil
+	}
+	return true, nil
+}
+
+// CreateJRE assembles a minimal Java runtime containing the modules used by the jar into a launch
+// layer and returns its path. The layer's bin directory takes the place of the JDK on the PATH.
+func CreateJRE(ctx *gcp.Context, jar string) (string, error) {
+	version, err := jdkVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	modules, err := jreModules(ctx, jar, version)
+	if err != nil {
+		return "", err
+	}
+
+	l, err := ctx.Layer(jreLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %s layer: %w", jreLayer, err)
+	}
+	l.LaunchEnvironment.Override("JAVA_HOME", l.Path)
+	key := strings.Join(append([]string{version}, modules...), ",")
+	if ctx.GetMetadata(l, startupCacheKey) == key {
+		ctx.CacheHit(jreLayer)
+		return l.Path, nil
+	}
+	ctx.CacheMiss(jreLayer)
+
+	// jlink refuses to write to an existing directory.
+	if err := ctx.RemoveAll(l.Path); err != nil {
+		return "", err
+	}
+	l.Metadata = map[string]interface{}{}
+	cmd := []string{"jlink",
+		"--add-modules", strings.Join(modules, ","),
+		"--strip-debug", "--no-header-files", "--no-man-pages",
+		"--output", l.Path}
+	feature := featureVersion(version)
+	if feature >= 21 {
+		cmd = append(cmd, "--compress=zip-6")
+	} else {
+		cmd = append(cmd, "--compress=2")
+	}
+	if feature >= 17 {
+		// Regenerate the default CDS archive to match the trimmed module image.
+		cmd = append(cmd, "--generate-cds-archive")
+	}
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+		return "", err
+	}
+	ctx.SetMetadata(l, startupCacheKey, key)
+	ctx.Logf("Created Java runtime with modules: %s", strings.Join(modules, ","))
+	return l.Path, nil
+}
+
+// jreModules returns the sorted modules the jar needs, as reported by jdeps, plus baseModules
+// and the modules requested in JlinkModulesEnv. Modules not provided by the JDK are dropped.
+func jreModules(ctx *gcp.Context, jar, version string) ([]string, error) {
+	set := map[string]bool{}
+	for _, m := range baseModules {
+		set[m] = true
+	}
+	result, err := ctx.Exec([]string{"jdeps",
+		"--ignore-missing-deps", "-q",
+		"--multi-release", strconv.Itoa(featureVersion(version)),
+		"--print-module-deps", jar})
+	if err != nil {
+		// Spring Boot and other nested jars are only partially visible to jdeps.
+		ctx.Warnf("Failed to determine the modules used by %s, including all Java SE modules: %v", jar, err)
+		set["java.se"] = true
+	} else {
+		addModules(set, result.Stdout)
+	}
+	addModules(set, os.Getenv(JlinkModulesEnv))
+
+	result, err = ctx.Exec([]string{"java", "--list-modules"})
+	if err != nil {
+		return nil, err
+	}
+	available := map[string]bool{}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		// Lines have the form "java.base@17.0.9".
+		name, _, _ := strings.Cut(strings.TrimSpace(line), "@")
+		available[name] = true
+	}
+	var modules []string
+	for m := range set {
+		if available[m] {
+			modules = append(modules, m)
+		} else {
+			ctx.Debugf("Skipping module %s, it is not provided by the JDK", m)
+		}
+	}
+	sort.Strings(modules)
+	return modules, nil
+}
+
+func addModules(set map[string]bool, list string) {
+	for _, m := range strings.Split(list, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			set[m] = true
+		}
+	}
+}
+
+// CreateAppCDSArchive runs the executable jar with javaCmd until it exits or the training time
+// runs out, archives the classes it loaded into a launch layer, and adds the archive to
+// JAVA_TOOL_OPTIONS. A failed training run only skips the archive.
+func CreateAppCDSArchive(ctx *gcp.Context, javaCmd, jar string) error {
+	seconds := defaultTrainingSeconds
+	if v := os.Getenv(AppCDSTrainingSecondsEnv); v != "" {
+		s, err := strconv.Atoi(v)
+		if err != nil || s <= 0 {
+			return gcp.UserErrorf("%s=%q must be a positive number of seconds", AppCDSTrainingSecondsEnv, v)
+		}
+		seconds = s
+	}
+
+	if err := os.Chtimes(jar, layerModTime, layerModTime); err != nil {
+		return fmt.Errorf("setting timestamps of %s: %w", jar, err)
+	}
+	jarDigest, err := fileDigest(jar)
+	if err != nil {
+		return err
+	}
+	version, err := ctx.Exec([]string{javaCmd, "-version"})
+	if err != nil {
+		return err
+	}
+	if m := javaVersionRegexp.FindStringSubmatch(version.Combined); m == nil || featureVersion(m[1]) < 13 {
+		ctx.Warnf("AppCDS archives of application classes require Java 13 or later, skipping the archive.")
+		return nil
+	}
+
+	// Training takes a full application start, so the archive is cached and only retrained when the jar
+	// or the JVM changes.
+	l, err := ctx.Layer(appCDSLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %s layer: %w", appCDSLayer, err)
+	}
+	archive := filepath.Join(l.Path, appCDSArchive)
+	key := jarDigest + "," + version.Combined
+	restored, err := ctx.FileExists(archive)
+	if err != nil {
+		return err
+	}
+	if restored && ctx.GetMetadata(l, startupCacheKey) == key {
+		ctx.CacheHit(appCDSLayer)
+		l.LaunchEnvironment.Append("JAVA_TOOL_OPTIONS", " ", "-XX:SharedArchiveFile="+archive)
+		return nil
+	}
+	ctx.CacheMiss(appCDSLayer)
+	if err := ctx.ClearLayer(l); err != nil {
+		return err
+	}
+
+	ctx.Logf("Training the AppCDS archive for up to %ds.", seconds)
+	cmd := []string{"timeout", "--signal=TERM", "--kill-after=10", strconv.Itoa(seconds),
+		javaCmd,
+		"-XX:ArchiveClassesAtExit=" + archive,
+		// Spring Boot 3.3+ exits once the application context is refreshed.
+		"-Dspring.context.exit=onRefresh",
+		"-jar", jar}
+	result, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv("PORT=8080"))
+	if err != nil && (result == nil || result.ExitCode != timeoutExitCode) {
+		ctx.Warnf("The AppCDS training run failed, skipping the archive: %v", err)
+		return nil
+	}
+	exists, err := ctx.FileExists(archive)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		ctx.Warnf("The AppCDS training run did not produce %s, skipping the archive.", archive)
+		return nil
+	}
+	l.LaunchEnvironment.Append("JAVA_TOOL_OPTIONS", " ", "-XX:SharedArchiveFile="+archive)
+	ctx.SetMetadata(l, startupCacheKey, key)
+	return nil
+}
+
+// jdkVersion returns the version of the installed JDK, e.g. "17.0.9".
+func jdkVersion(ctx *gcp.Context) (string, error) {
+	result, err := ctx.Exec([]string{"jlink", "--version"})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// featureVersion returns the feature release of a JDK version, e.g. 17 for "17.0.9".
+func featureVersion(version string) int {
+	m := jdkVersionRegexp.FindString(version)
+	v, _ := strconv.Atoi(m)
+	return v
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
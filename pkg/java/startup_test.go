@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestJlinkEnabled(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     []string
+		files   map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "not requested",
+		},
+		{
+			name: "requested",
+			env:  []string{"GOOGLE_JAVA_JLINK=true"},
+			want: true,
+		},
+		{
+			name:    "invalid",
+			env:     []string{"GOOGLE_JAVA_JLINK=yes please"},
+			wantErr: true,
+		},
+		{
+			name: "custom entrypoint",
+			env:  []string{"GOOGLE_JAVA_JLINK=true", "GOOGLE_ENTRYPOINT=java -jar app.jar"},
+		},
+		{
+			name:  "procfile",
+			env:   []string{"GOOGLE_JAVA_JLINK=true"},
+			files: map[string]string{"Procfile": "web: java -jar app.jar"},
+		},
+		{
+			name:  "exploded jar",
+			env:   []string{"GOOGLE_JAVA_JLINK=true"},
+			files: map[string]string{"META-INF/MANIFEST.MF": "Main-Class: Main"},
+		},
+		{
+			name: "functions",
+			env:  []string{"GOOGLE_JAVA_JLINK=true", "X_GOOGLE_TARGET_PLATFORM=gcf"},
+		},
+		{
+			name: "function target",
+			env:  []string{"GOOGLE_JAVA_JLINK=true", "GOOGLE_FUNCTION_TARGET=com.example.HelloWorld"},
+		},
+		{
+			name: "dev mode",
+			env:  []string{"GOOGLE_JAVA_JLINK=true", "GOOGLE_DEVMODE=true"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFiles(t, root, tc.files)
+			for _, e := range tc.env {
+				k, v, _ := strings.Cut(e, "=")
+				t.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := JlinkEnabled(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("JlinkEnabled() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("JlinkEnabled() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    int
+	}{
+		{version: "17.0.9", want: 17},
+		{version: "21", want: 21},
+		{version: "11.0.21+9", want: 11},
+		{version: "", want: 0},
+	}
+	for _, tc := range testCases {
+		if got := featureVersion(tc.version); got != tc.want {
+			t.Errorf("featureVersion(%q) = %d, want %d", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestCreateAppCDSArchiveInvalidTrainingSeconds(t *testing.T) {
+	t.Setenv(AppCDSTrainingSecondsEnv, "-1")
+	jar := filepath.Join(t.TempDir(), "app.jar")
+	if err := os.WriteFile(jar, nil, 0644); err != nil {
+		t.Fatalf("writing %s: %v", jar, err)
+	}
+	if err := CreateAppCDSArchive(gcp.NewContext(), "java", jar); err == nil {
+		t.Errorf("CreateAppCDSArchive() got no error, want error for %s=-1", AppCDSTrainingSecondsEnv)
+	}
+}
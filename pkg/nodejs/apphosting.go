@@ -0,0 +1,316 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
+	"gopkg.in/yaml.v2"
+)
+
+// BundleYAML represents the contents of the .apphosting/bundle.yaml file that framework adapters
+// produce for the nodejs/firebasebundle buildpack.
+type BundleYAML struct {
+	RunCommand   string   `yaml:"runCommand"`
+	NeededDirs   []string `yaml:"neededDirs"`
+	StaticAssets []string `yaml:"staticAssets"`
+}
+
+// BundleYAMLPath returns the path of the output bundle description in the application root.
+func BundleYAMLPath(ctx *gcp.Context) string {
+	return filepath.Join(ctx.ApplicationRoot(), ".apphosting", "bundle.yaml")
+}
+
+// WriteBundleYAML writes the output bundle description for a framework to .apphosting/bundle.yaml
+// unless the application already provides one.
+func WriteBundleYAML(ctx *gcp.Context, bundle BundleYAML) error {
+	bundlePath := BundleYAMLPath(ctx)
+	exists, err := ctx.FileExists(bundlePath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		ctx.Logf("Using the output bundle configuration in %s", bundlePath)
+		return nil
+	}
+	raw, err := yaml.Marshal(&bundle)
+	if err != nil {
+		return gcp.InternalErrorf("marshalling bundle.yaml: %w", err)
+	}
+	if err := ctx.MkdirAll(filepath.Dir(bundlePath), 0755); err != nil {
+		return err
+	}
+	return ctx.WriteFile(bundlePath, raw, 0644)
+}
+
+// OverrideAppHostingBuildScript sets the command the package manager buildpacks run in place of
+// the build script in package.json.
+func OverrideAppHostingBuildScript(l *libcnb.Layer, cmd string) {
+	l.BuildEnvironment.Override(AppHostingBuildEnv, cmd)
+}
+
+// DeclaredVersion returns the version range of the named package in the dependencies or
+// devDependencies of package.json, or "" if it is not declared.
+func DeclaredVersion(pjs *PackageJSON, name string) string {
+	if pjs == nil {
+		return ""
+	}
+	if v, ok := pjs.Dependencies[name]; ok {
+		return v
+	}
+	return pjs.DevDependencies[name]
+}
+
+// ValidateFrameworkVersion returns a user error if the framework version is lower than the minimum
+// supported version. Versions that cannot be parsed, such as ranges, only log a warning.
+func ValidateFrameworkVersion(ctx *gcp.Context, framework, depVersion string, minVersion *semver.Version) error {
+	version, err := semver.NewVersion(depVersion)
+	if err != nil {
+		ctx.Warnf("Unrecognized version of %s: %q", framework, depVersion)
+		ctx.Warnf("Consider updating your %s dependencies to >=%s", framework, minVersion.String())
+		return nil
+	}
+	if version.LessThan(minVersion) {
+		ctx.Warnf("Unsupported version of %s: %s", framework, depVersion)
+		ctx.Warnf("Update the %s dependencies to >=%s", framework, minVersion.String())
+		return gcp.UserErrorf("unsupported version of %s %s", framework, depVersion)
+	}
+	return nil
+}
+
+// PackageVersion tries to get the concrete version of the named package from the lock file,
+// returns error if no lock file is found or it is misshapen.
+func PackageVersion(ctx *gcp.Context, pjs *PackageJSON, name string) (string, error) {
+	for _, filename := range possibleLockfileFilenames {
+		filePath := filepath.Join(ctx.ApplicationRoot(), filename)
+		rawPackageLock, err := os.ReadFile(filePath)
+		if err != nil {
+			continue
+		}
+		if filename == "pnpm-lock.yaml" {
+			var lockfile PnpmLockfile
+			if err := yaml.Unmarshal(rawPackageLock, &lockfile); err != nil {
+				return "", gcp.InternalErrorf("parsing pnpm lock file: %w", err)
+			}
+			dep, ok := lockfile.Dependencies[name]
+			if !ok {
+				dep = lockfile.DevDependencies[name]
+			}
+			return strings.Split(dep.Version, "(")[0], nil
+		}
+
+		if filename == "yarn.lock" {
+			// yarn requires custom parsing since it has a custom format
+			// this logic works for both yarn classic and berry
+			declared := DeclaredVersion(pjs, name)
+			for _, dependency := range strings.Split(string(rawPackageLock), "\n\n") {
+				if !yarnEntryMatches(dependency, name, declared) {
+					continue
+				}
+				for _, line := range strings.Split(dependency, "\n") {
+					if fields := strings.Fields(line); len(fields) > 1 && strings.TrimSuffix(fields[0], ":") == "version" {
+						return strings.Trim(fields[1], `"`), nil
+					}
+				}
+			}
+			return "", gcp.InternalErrorf("parsing yarn file")
+		}
+
+		if filename == "npm-shrinkwrap.json" || filename == "package-lock.json" {
+			var lockfile NpmLockfile
+			if err := json.Unmarshal(rawPackageLock, &lockfile); err != nil {
+				return "", gcp.InternalErrorf("parsing lock file: %w", err)
+			}
+			return lockfile.Packages["node_modules/"+name].Version, nil
+		}
+	}
+
+	return "", gcp.UserErrorf("No lock file found, please run npm install to generate one")
+}
+
+// yarnEntryMatches reports whether the yarn.lock entry resolves the named package for the declared
+// version range. The first line of an entry lists the descriptors it resolves, for example
+// `next@^13.1.0, next@^13.2.0:` in yarn classic or `"next@npm:^13.1.0":` in yarn berry.
+func yarnEntryMatches(entry, name, declared string) bool {
+	header := strings.SplitN(strings.TrimLeft(entry, "\r\n"), "\n", 2)[0]
+	for _, descriptor := range strings.Split(strings.TrimSuffix(strings.TrimSpace(header), ":"), ",") {
+		descriptor = strings.Trim(strings.TrimSpace(descriptor), `"`)
+		if strings.HasPrefix(descriptor, name+"@") && strings.Contains(descriptor, declared) {
+			return true
+		}
+	}
+	return false
+}
+
+// FrameworkVersion returns the concrete version of the framework package from the lock file, or
+// the version declared in package.json if the lock file does not resolve it.
+func FrameworkVersion(ctx *gcp.Context, pjs *PackageJSON, name string) string {
+	version, err := PackageVersion(ctx, pjs, name)
+	if err != nil || version == "" {
+		ctx.Debugf("Resolving %s version from lock file: %v", name, err)
+		return DeclaredVersion(pjs, name)
+	}
+	return version
+}
+
+// AppHostingPackageManager returns the package manager that installs and builds the application
+// in the App Hosting builder, whose groups try yarn, pnpm and npm in that order.
+func AppHostingPackageManager(ctx *gcp.Context) (string, error) {
+	for _, pm := range []struct{ lockfile, tool string }{{YarnLock, "yarn"}, {PNPMLock, "pnpm"}} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), pm.lockfile)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return pm.tool, nil
+		}
+	}
+	return "npm", nil
+}
+
+// FrameworkBuildCommand returns the command that runs a framework CLI command, such as
+// "nuxt build", with the CLI installed in the application's node_modules by pkgTool.
+func FrameworkBuildCommand(pkgTool, command string) string {
+	switch pkgTool {
+	case "yarn":
+		return "yarn run " + command
+	case "pnpm":
+		return "pnpm exec " + command
+	}
+	return "npm exec --no -- " + command
+}
+
+// FrameworkAdapter holds what differs between the App Hosting framework buildpacks. They all check
+// the framework version, set the build command if package.json has no build script and describe
+// the output bundle for the nodejs/firebasebundle buildpack.
+type FrameworkAdapter struct {
+	// Name is the framework name used in messages, such as "nuxt".
+	Name string
+	// Package is the npm package that carries the framework version, such as "@sveltejs/kit".
+	Package string
+	// MinVersion is the lowest supported version of Package.
+	MinVersion *semver.Version
+	// ConfigFiles are the framework config files, any of which opts the buildpack in.
+	ConfigFiles []string
+	// Detect, if set, decides whether to opt in instead, given the config file that was found or ""
+	// if there is none.
+	Detect func(ctx *gcp.Context, pjs *PackageJSON, configFile string) (gcp.DetectResult, error)
+	// Validate, if set, returns an error if the application cannot run on App Hosting, such as when
+	// it lacks a required adapter package.
+	Validate func(pjs *PackageJSON) error
+	// Build returns how the application is built and what the build outputs.
+	Build func(ctx *gcp.Context, pjs *PackageJSON) (FrameworkBuild, error)
+}
+
+// FrameworkBuild describes how an application is built by its framework.
+type FrameworkBuild struct {
+	// Command is the framework CLI command run if package.json has no build script, such as
+	// "nuxt build".
+	Command string
+	// OtherScripts are build scripts other than Command that produce the expected output.
+	OtherScripts []string
+	// Bundle describes the build output.
+	Bundle BundleYAML
+}
+
+// DetectFn opts in if the application has one of the framework config files, unless Detect
+// decides otherwise.
+func (f FrameworkAdapter) DetectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	configFile := ""
+	for _, c := range f.ConfigFiles {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), c)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			configFile = c
+			break
+		}
+	}
+	if f.Detect != nil {
+		pjs, err := ReadPackageJSONIfExists(ctx.ApplicationRoot())
+		if err != nil {
+			return nil, err
+		}
+		return f.Detect(ctx, pjs, configFile)
+	}
+	if configFile == "" {
+		return gcp.OptOut(f.Name + " config not found"), nil
+	}
+	return gcp.OptInFileFound(configFile), nil
+}
+
+// BuildFn validates the framework version, sets the build command for the package manager
+// buildpack if package.json has no build script and writes the output bundle description.
+func (f FrameworkAdapter) BuildFn(ctx *gcp.Context) error {
+	pjs, err := ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if pjs == nil {
+		return gcp.UserErrorf("package.json not found, %s applications require a package.json", f.Name)
+	}
+
+	version := FrameworkVersion(ctx, pjs, f.Package)
+	if err := ValidateFrameworkVersion(ctx, f.Name, version, f.MinVersion); err != nil {
+		return err
+	}
+	if f.Validate != nil {
+		if err := f.Validate(pjs); err != nil {
+			return err
+		}
+	}
+	build, err := f.Build(ctx, pjs)
+	if err != nil {
+		return err
+	}
+
+	buildScript, exists := pjs.Scripts[ScriptBuild]
+	if !exists {
+		pkgTool, err := AppHostingPackageManager(ctx)
+		if err != nil {
+			return err
+		}
+		l, err := ctx.Layer("build_env", gcp.BuildLayer)
+		if err != nil {
+			return err
+		}
+		// This env var indicates to the package manager buildpack that a different command needs to be run
+		OverrideAppHostingBuildScript(l, FrameworkBuildCommand(pkgTool, build.Command))
+	} else if !build.expects(buildScript) {
+		ctx.Warnf("*** You are using a custom build command (your build command is NOT '%s'), we will accept it as is but will error if output structure is not as expected ***", build.Command)
+	}
+
+	return WriteBundleYAML(ctx, build.Bundle)
+}
+
+// expects returns whether a build script produces the output described by the bundle.
+func (b FrameworkBuild) expects(script string) bool {
+	if script == b.Command {
+		return true
+	}
+	for _, s := range b.OtherScripts {
+		if script == s {
+			return true
+		}
+	}
+	return false
+}
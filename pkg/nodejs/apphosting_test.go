@@ -0,0 +1,259 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWriteBundleYAML(t *testing.T) {
+	testCases := []struct {
+		name     string
+		existing string
+		bundle   BundleYAML
+		want     BundleYAML
+	}{
+		{
+			name: "writes bundle",
+			bundle: BundleYAML{
+				RunCommand:   "node .output/server/index.mjs",
+				NeededDirs:   []string{".output"},
+				StaticAssets: []string{".output/public"},
+			},
+			want: BundleYAML{
+				RunCommand:   "node .output/server/index.mjs",
+				NeededDirs:   []string{".output"},
+				StaticAssets: []string{".output/public"},
+			},
+		},
+		{
+			name:     "keeps existing bundle",
+			existing: "runCommand: node server.js\nneededDirs: [dist]\n",
+			bundle: BundleYAML{
+				RunCommand: "node .output/server/index.mjs",
+			},
+			want: BundleYAML{
+				RunCommand: "node server.js",
+				NeededDirs: []string{"dist"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			bundlePath := BundleYAMLPath(ctx)
+			if tc.existing != "" {
+				if err := os.MkdirAll(filepath.Dir(bundlePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(bundlePath, []byte(tc.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteBundleYAML(ctx, tc.bundle); err != nil {
+				t.Fatalf("WriteBundleYAML() got error: %v", err)
+			}
+
+			raw, err := os.ReadFile(bundlePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got BundleYAML
+			if err := yaml.Unmarshal(raw, &got); err != nil {
+				t.Fatalf("unmarshalling %s: %v", bundlePath, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("WriteBundleYAML() unexpected bundle.yaml (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPackageVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pkg     string
+		pjs     PackageJSON
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no lock file",
+			pkg:     "nuxt",
+			files:   map[string]string{},
+			wantErr: true,
+		},
+		{
+			name: "package-lock",
+			pkg:  "@sveltejs/kit",
+			files: map[string]string{
+				"package-lock.json": `{
+					"packages": {
+						"node_modules/@sveltejs/kit": {
+							"version": "2.5.0"
+						}
+					}
+				}`,
+			},
+			want: "2.5.0",
+		},
+		{
+			name: "yarn classic dev dependency",
+			pkg:  "nuxt",
+			pjs: PackageJSON{
+				DevDependencies: map[string]string{
+					"nuxt": "^3.10.0",
+				},
+			},
+			files: map[string]string{
+				"yarn.lock": `
+eslint-plugin-nuxt@^4.0.0:
+  version "4.0.0"
+
+nuxt@^3.10.0:
+  version "3.10.3"
+`,
+			},
+			want: "3.10.3",
+		},
+		{
+			name: "yarn berry scoped package",
+			pkg:  "@remix-run/dev",
+			pjs: PackageJSON{
+				DevDependencies: map[string]string{
+					"@remix-run/dev": "^2.8.0",
+				},
+			},
+			files: map[string]string{
+				"yarn.lock": `
+"@remix-run/dev@npm:^2.8.0":
+  version: 2.8.1
+`,
+			},
+			want: "2.8.1",
+		},
+		{
+			name: "pnpm dev dependency",
+			pkg:  "astro",
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+devDependencies:
+  astro:
+    version: 4.4.0(typescript@5.3.3)
+`,
+			},
+			want: "4.4.0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			for file, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(ctx.ApplicationRoot(), file), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := PackageVersion(ctx, &tc.pjs, tc.pkg)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PackageVersion(%q) got error: %v, want error: %t", tc.pkg, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PackageVersion(%q) = %q, want %q", tc.pkg, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateFrameworkVersion(t *testing.T) {
+	minVersion := semver.MustParse("3.0.0")
+	testCases := []struct {
+		version string
+		wantErr bool
+	}{
+		{version: "3.0.0"},
+		{version: "3.10.3"},
+		{version: "^3.0.0"},
+		{version: "2.16.0", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			ctx := gcp.NewContext()
+			err := ValidateFrameworkVersion(ctx, "nuxt", tc.version, minVersion)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateFrameworkVersion(%q) got error: %v, want error: %t", tc.version, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppHostingPackageManager(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{name: "no lockfile", want: "npm"},
+		{name: "package-lock.json", files: []string{"package-lock.json"}, want: "npm"},
+		{name: "yarn.lock", files: []string{YarnLock}, want: "yarn"},
+		{name: "pnpm-lock.yaml", files: []string{PNPMLock}, want: "pnpm"},
+		{name: "yarn before pnpm", files: []string{PNPMLock, YarnLock}, want: "yarn"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := AppHostingPackageManager(ctx)
+			if err != nil {
+				t.Fatalf("AppHostingPackageManager() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("AppHostingPackageManager() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFrameworkBuildCommand(t *testing.T) {
+	testCases := []struct {
+		pkgTool string
+		want    string
+	}{
+		{pkgTool: "npm", want: "npm exec --no -- nuxt build"},
+		{pkgTool: "yarn", want: "yarn run nuxt build"},
+		{pkgTool: "pnpm", want: "pnpm exec nuxt build"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pkgTool, func(t *testing.T) {
+			if got := FrameworkBuildCommand(tc.pkgTool, "nuxt build"); got != tc.want {
+				t.Errorf("FrameworkBuildCommand(%q, %q) = %q, want %q", tc.pkgTool, "nuxt build", got, tc.want)
+			}
+		})
+	}
+}
@@ -15,17 +15,12 @@
 package nodejs
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 	"github.com/Masterminds/semver"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -46,6 +41,9 @@ type PnpmLockfile struct {
 	Dependencies map[string]struct {
 		Version string `yaml:"version"`
 	} `yaml:"dependencies"`
+	DevDependencies map[string]struct {
+		Version string `yaml:"version"`
+	} `yaml:"devDependencies"`
 }
 
 // InstallNextJsBuildAdaptor installs the nextjs build adaptor in the given layer if it is not already cached.
@@ -108,43 +106,5 @@ func OverrideNextjsBuildScript(njsl *libcnb.Layer) {
 
 // Version tries to get the concrete nextjs version used based on lock file, returns error if no lock file is found or is mishappen
 func Version(ctx *gcp.Context, pjs *PackageJSON) (string, error) {
-	for _, filename := range possibleLockfileFilenames {
-		filePath := filepath.Join(ctx.ApplicationRoot(), filename)
-		rawPackageLock, err := os.ReadFile(filePath)
-		if err != nil {
-			continue
-		}
-		if filename == "pnpm-lock.yaml" {
-			var lockfile PnpmLockfile
-			if err := yaml.Unmarshal(rawPackageLock, &lockfile); err != nil {
-				return "", gcp.InternalErrorf("parsing pnpm lock file: %w", err)
-			}
-			return strings.Split(lockfile.Dependencies["next"].Version, "(")[0], nil
-		}
-
-		if filename == "yarn.lock" {
-			// yarn requires custom parsing since it has a custom format
-			// this logic works for both yarn classic and berry
-			for _, dependency := range strings.Split(string(rawPackageLock[:]), "\n\n") {
-				if strings.Contains(dependency, "next@") && strings.Contains(dependency, pjs.Dependencies["next"]) {
-					for _, line := range strings.Split(dependency, "\n") {
-						if strings.Contains(line, "version") {
-							return strings.Trim(strings.Fields(line)[1], `"`), nil
-						}
-					}
-				}
-			}
-			return "", gcp.InternalErrorf("parsing yarn file")
-		}
-
-		if filename == "npm-shrinkwrap.json" || filename == "package-lock.json" {
-			var lockfile NpmLockfile
-			if err := json.Unmarshal(rawPackageLock, &lockfile); err != nil {
-				return "", gcp.InternalErrorf("parsing lock file: %w", err)
-			}
-			return lockfile.Packages["node_modules/next"].Version, nil
-		}
-	}
-
-	return "", gcp.UserErrorf("No lock file found, please run npm install to generate one")
+	return PackageVersion(ctx, pjs, "next")
 }
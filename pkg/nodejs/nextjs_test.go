@@ -15,7 +15,6 @@ package nodejs
 
 import (
 	"io/ioutil"
-	"os"
 	"path/filepath"
 	"testing"
 
@@ -178,6 +177,40 @@ dependencies:
   next:
     version: 13.5.6(@babel/core@7.23.9)
 
+`,
+			},
+			expectedVersion: "13.5.6",
+		},
+		{
+			name: "Parses yarn.lock version of next rather than packages named like it",
+			pjs: PackageJSON{
+				Dependencies: map[string]string{
+					"next": "^13.1.0",
+				},
+			},
+			files: map[string]string{
+				"yarn.lock": `
+eslint-config-next@^13.1.0:
+  version "13.1.0"
+
+next@^13.1.0:
+  version "13.5.6"
+`,
+			},
+			expectedVersion: "13.5.6",
+		},
+		{
+			name: "Parses pnpm-lock dev dependency version",
+			pjs: PackageJSON{
+				DevDependencies: map[string]string{
+					"next": "^13.1.0",
+				},
+			},
+			files: map[string]string{
+				"pnpm-lock.yaml": `
+devDependencies:
+  next:
+    version: 13.5.6(@babel/core@7.23.9)
 `,
 			},
 			expectedVersion: "13.5.6",
@@ -186,7 +219,7 @@ dependencies:
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			tmpDir := os.TempDir()
+			tmpDir := t.TempDir()
 
 			ctx := gcp.NewContext(append(getContextOpts(t, tc.mocks), gcp.WithApplicationRoot(tmpDir))...)
 
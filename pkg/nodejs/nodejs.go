@@ -63,6 +63,10 @@ const (
 	ScriptBuild = "build"
 	// ScriptGCPBuild is the name of "gcp-build" scripts.
 	ScriptGCPBuild = "gcp-build"
+	// ScriptTest is the name of npm test scripts.
+	ScriptTest = "test"
+	// ScriptStart is the name of npm start scripts.
+	ScriptStart = "start"
 )
 
 // PackageJSON represents the contents of a package.json file.
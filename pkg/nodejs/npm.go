@@ -194,6 +194,25 @@ func IsUsingVendoredDependencies() bool {
 	return val
 }
 
+// defaultTestScript is the test script written by `npm init`, which always fails.
+const defaultTestScript = `echo "Error: no test specified" && exit 1`
+
+// TestReports are the default paths of the JUnit XML reports written by jest-junit and
+// mocha-junit-reporter.
+var TestReports = []string{"junit.xml", "test-results.xml"}
+
+// TestCommand returns the command that runs the "test" script in package.json, or nil if there is
+// no test script or it is the placeholder written by `npm init`.
+func TestCommand(pjs *PackageJSON, pkgTool string) []string {
+	if !HasScript(pjs, ScriptTest) {
+		return nil
+	}
+	if script := strings.TrimSpace(pjs.Scripts[ScriptTest]); script == "" || script == defaultTestScript {
+		return nil
+	}
+	return strings.Fields(runCommand(pkgTool, ScriptTest))
+}
+
 func runCommand(pkgTool, command string) string {
 	return fmt.Sprintf("%s run %s", pkgTool, strings.TrimSpace(command))
 }
@@ -204,7 +223,8 @@ func runCommand(pkgTool, command string) string {
 // 1. if script.start is specified return `npm run start`
 // 2. if the project contains server.js `npm run start`
 // 3. if main is specified `node ${pjs.main}`
-// 4. otherwise `node index.js“
+// 4. if the project is compiled from index.ts or src/index.ts `node ${compiled index.js}`
+// 5. otherwise `node index.js“
 func DefaultStartCommand(ctx *gcp.Context, pjs *PackageJSON) ([]string, error) {
 	if pjs == nil {
 		return []string{"node", "index.js"}, nil
@@ -223,7 +243,28 @@ func DefaultStartCommand(ctx *gcp.Context, pjs *PackageJSON) ([]string, error) {
 		return []string{"npm", "run", "start"}, nil
 	}
 	if pjs.Main != "" {
-		return []string{"node", pjs.Main}, nil
+		main, err := CompiledFile(ctx, pjs.Main)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"node", main}, nil
+	}
+	index, err := TypeScriptEntrypoint(ctx, "index.js", "index.ts", "src/index.ts")
+	if err != nil {
+		return nil, err
+	}
+	if index != "" {
+		return []string{"node", index}, nil
 	}
 	return []string{"node", "index.js"}, nil
 }
+
+// StartCommand returns the command that starts an application whose dependencies pkgTool
+// installed: the start script of package.json run by pkgTool if there is one, or else the
+// DefaultStartCommand.
+func StartCommand(ctx *gcp.Context, pjs *PackageJSON, pkgTool string) ([]string, error) {
+	if HasScript(pjs, ScriptStart) {
+		return []string{pkgTool, "run", ScriptStart}, nil
+	}
+	return DefaultStartCommand(ctx, pjs)
+}
@@ -332,6 +332,60 @@ func TestDetermineBuildCommands(t *testing.T) {
 	}
 }
 
+func TestTestCommand(t *testing.T) {
+	testsCases := []struct {
+		name    string
+		pjs     string
+		pkgTool string
+		want    []string
+	}{
+		{
+			name:    "no package.json",
+			pkgTool: "npm",
+		},
+		{
+			name:    "no test script",
+			pjs:     `{"scripts": {"build": "tsc"}}`,
+			pkgTool: "npm",
+		},
+		{
+			name:    "npm init placeholder",
+			pjs:     `{"scripts": {"test": "echo \"Error: no test specified\" && exit 1"}}`,
+			pkgTool: "npm",
+		},
+		{
+			name:    "empty test script",
+			pjs:     `{"scripts": {"test": " "}}`,
+			pkgTool: "npm",
+		},
+		{
+			name:    "npm",
+			pjs:     `{"scripts": {"test": "jest"}}`,
+			pkgTool: "npm",
+			want:    []string{"npm", "run", "test"},
+		},
+		{
+			name:    "yarn",
+			pjs:     `{"scripts": {"test": "mocha"}}`,
+			pkgTool: "yarn",
+			want:    []string{"yarn", "run", "test"},
+		},
+	}
+	for _, tc := range testsCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var pjs *PackageJSON
+			if tc.pjs != "" {
+				if err := json.Unmarshal([]byte(tc.pjs), &pjs); err != nil {
+					t.Fatalf("failed to unmarshal package.json: %s, error: %v", tc.pjs, err)
+				}
+			}
+			if diff := cmp.Diff(tc.want, TestCommand(pjs, tc.pkgTool)); diff != "" {
+				t.Errorf("TestCommand() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestDefaultStartCommand(t *testing.T) {
 	testsCases := []struct {
 		name        string
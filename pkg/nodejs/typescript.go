@@ -0,0 +1,233 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// TSConfig is the name of the TypeScript project configuration file.
+	TSConfig = "tsconfig.json"
+
+	typescriptPackage = "typescript"
+)
+
+var (
+	// typescriptExtensions maps TypeScript source extensions to the extension tsc emits.
+	typescriptExtensions = map[string]string{
+		".ts":  ".js",
+		".tsx": ".js",
+		".mts": ".mjs",
+		".cts": ".cjs",
+	}
+
+	// esModuleKinds are the tsconfig.json "module" values that always emit ES modules, regardless
+	// of the "type" field in package.json.
+	esModuleKinds = map[string]bool{
+		"es6":    true,
+		"es2015": true,
+		"es2020": true,
+		"es2022": true,
+		"esnext": true,
+	}
+)
+
+// TSConfigJSON represents the compiler options of a tsconfig.json file that determine what tsc
+// emits and where. Options inherited through "extends" are not resolved.
+type TSConfigJSON struct {
+	CompilerOptions struct {
+		OutDir  string `json:"outDir"`
+		RootDir string `json:"rootDir"`
+		Module  string `json:"module"`
+	} `json:"compilerOptions"`
+}
+
+// ReadTSConfigIfExists returns the deserialized tsconfig.json from the given dir, or nil if the dir
+// does not contain one.
+func ReadTSConfigIfExists(dir string) (*TSConfigJSON, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, TSConfig))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", TSConfig, err)
+	}
+	var cfg TSConfigJSON
+	if err := json.Unmarshal(stripJSONC(raw), &cfg); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", TSConfig, err)
+	}
+	return &cfg, nil
+}
+
+// EmitsESModules returns true if tsc emits ES modules for the project, which Node.js only loads
+// from .js files when package.json sets "type": "module".
+func (c *TSConfigJSON) EmitsESModules() bool {
+	return esModuleKinds[strings.ToLower(c.CompilerOptions.Module)]
+}
+
+// TypeScriptBuildCommand returns the command that compiles a TypeScript project with the
+// project's own typescript dependency, or "" if the project does not need to be compiled by the
+// buildpacks. Projects are only compiled if they have a tsconfig.json and none of the build
+// commands returned by DetermineBuildCommands.
+func TypeScriptBuildCommand(ctx *gcp.Context, pjs *PackageJSON, pkgTool string) (string, error) {
+	if _, ok := os.LookupEnv(AppHostingBuildEnv); ok {
+		return "", nil
+	}
+	if _, ok := os.LookupEnv(GoogleNodeRunScriptsEnv); ok {
+		return "", nil
+	}
+	if HasGCPBuild(pjs) || HasScript(pjs, ScriptBuild) {
+		return "", nil
+	}
+	cfg, err := ReadTSConfigIfExists(ctx.ApplicationRoot())
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	if DeclaredVersion(pjs, typescriptPackage) == "" {
+		ctx.Warnf("Found %s but %q is not a dependency in package.json, skipping TypeScript compilation. Add it to devDependencies or add a %q script to compile the application.", TSConfig, typescriptPackage, ScriptBuild)
+		return "", nil
+	}
+	if cfg.EmitsESModules() && (pjs == nil || pjs.Type != "module") {
+		ctx.Warnf("%s sets \"module\": %q which emits ES modules, but package.json does not set \"type\": \"module\". Node.js may fail to load the compiled application.", TSConfig, cfg.CompilerOptions.Module)
+	}
+	return FrameworkBuildCommand(pkgTool, "tsc --project "+TSConfig), nil
+}
+
+// CompiledFile returns the path of the JavaScript file that tsc emitted for the given source file,
+// relative to the application root. Paths that are not TypeScript sources are returned unchanged.
+func CompiledFile(ctx *gcp.Context, source string) (string, error) {
+	jsExt, ok := typescriptExtensions[path.Ext(source)]
+	if !ok {
+		return source, nil
+	}
+	cfg, err := ReadTSConfigIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return "", err
+	}
+	if cfg == nil {
+		return "", gcp.UserErrorf("%s is a TypeScript file but %s was not found", source, TSConfig)
+	}
+	emitted := strings.TrimSuffix(path.Clean(filepath.ToSlash(source)), path.Ext(source)) + jsExt
+
+	var candidates []string
+	outDir := cfg.CompilerOptions.OutDir
+	switch {
+	case outDir == "":
+		candidates = []string{emitted}
+	case cfg.CompilerOptions.RootDir != "":
+		rel, err := filepath.Rel(cfg.CompilerOptions.RootDir, emitted)
+		if err != nil {
+			return "", gcp.UserErrorf("%s is not under rootDir %q: %v", source, cfg.CompilerOptions.RootDir, err)
+		}
+		candidates = []string{path.Join(outDir, filepath.ToSlash(rel))}
+	default:
+		// Without rootDir, tsc mirrors the sources relative to their longest common directory, which
+		// depends on the whole program. Try each possible suffix of the source path, longest first.
+		parts := strings.Split(emitted, "/")
+		for i := range parts {
+			candidates = append(candidates, path.Join(outDir, path.Join(parts[i:]...)))
+		}
+	}
+	for _, c := range candidates {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), c)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return c, nil
+		}
+	}
+	return "", gcp.UserErrorf("compiled output for %s not found, looked for %s", source, strings.Join(candidates, ", "))
+}
+
+// TypeScriptEntrypoint returns the JavaScript file tsc emitted for the first of the TypeScript
+// sources that exists, or "" if the JavaScript file jsFile exists or none of the sources do.
+func TypeScriptEntrypoint(ctx *gcp.Context, jsFile string, sources ...string) (string, error) {
+	jsExists, err := ctx.FileExists(ctx.ApplicationRoot(), jsFile)
+	if err != nil || jsExists {
+		return "", err
+	}
+	tsconfigExists, err := ctx.FileExists(ctx.ApplicationRoot(), TSConfig)
+	if err != nil || !tsconfigExists {
+		return "", err
+	}
+	for _, source := range sources {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), source)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return CompiledFile(ctx, source)
+		}
+	}
+	return "", nil
+}
+
+// stripJSONC removes comments and trailing commas, which tsconfig.json allows, from raw JSON.
+func stripJSONC(raw []byte) []byte {
+	out := make([]byte, 0, len(raw))
+	inString := false
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(raw) {
+				i++
+				out = append(out, raw[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(raw) && raw[i+1] == '/':
+			for i < len(raw) && raw[i] != '\n' {
+				i++
+			}
+			if i < len(raw) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(raw) && raw[i+1] == '*':
+			i += 2
+			for i+1 < len(raw) && !(raw[i] == '*' && raw[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// Drop a trailing comma before the closing bracket.
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
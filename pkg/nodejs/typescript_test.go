@@ -0,0 +1,339 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadTSConfigIfExists(t *testing.T) {
+	testCases := []struct {
+		name         string
+		tsconfig     string
+		wantOutDir   string
+		wantModule   string
+		wantESModule bool
+	}{
+		{
+			name: "plain json",
+			tsconfig: `{
+				"compilerOptions": {"outDir": "dist", "module": "commonjs"}
+			}`,
+			wantOutDir: "dist",
+			wantModule: "commonjs",
+		},
+		{
+			name: "comments and trailing commas",
+			tsconfig: `{
+				// Emit to build/.
+				"compilerOptions": {
+					/* "outDir": "dist", */
+					"outDir": "build", // trailing comment
+					"module": "ESNext",
+					"paths": {"@app/*": ["src/*",],},
+				},
+			}`,
+			wantOutDir:   "build",
+			wantModule:   "ESNext",
+			wantESModule: true,
+		},
+		{
+			name:     "comment markers in strings",
+			tsconfig: `{"compilerOptions": {"outDir": "out//*dir", "module": "NodeNext"}}`,
+			// Node16 and NodeNext follow the "type" field in package.json.
+			wantOutDir: "out//*dir",
+			wantModule: "NodeNext",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, TSConfig), []byte(tc.tsconfig), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := ReadTSConfigIfExists(dir)
+			if err != nil {
+				t.Fatalf("ReadTSConfigIfExists() got error: %v", err)
+			}
+			if cfg.CompilerOptions.OutDir != tc.wantOutDir {
+				t.Errorf("ReadTSConfigIfExists() outDir = %q, want %q", cfg.CompilerOptions.OutDir, tc.wantOutDir)
+			}
+			if cfg.CompilerOptions.Module != tc.wantModule {
+				t.Errorf("ReadTSConfigIfExists() module = %q, want %q", cfg.CompilerOptions.Module, tc.wantModule)
+			}
+			if got := cfg.EmitsESModules(); got != tc.wantESModule {
+				t.Errorf("EmitsESModules() = %t, want %t", got, tc.wantESModule)
+			}
+		})
+	}
+}
+
+func TestTypeScriptBuildCommand(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pjs     *PackageJSON
+		files   map[string]string
+		envs    map[string]string
+		pkgTool string
+		want    string
+	}{
+		{
+			name:    "compiles with npm",
+			pjs:     &PackageJSON{DevDependencies: map[string]string{"typescript": "^5.3.0"}},
+			files:   map[string]string{TSConfig: "{}"},
+			pkgTool: "npm",
+			want:    "npm exec --no -- tsc --project tsconfig.json",
+		},
+		{
+			name:    "compiles with yarn",
+			pjs:     &PackageJSON{DevDependencies: map[string]string{"typescript": "^5.3.0"}},
+			files:   map[string]string{TSConfig: "{}"},
+			pkgTool: "yarn",
+			want:    "yarn run tsc --project tsconfig.json",
+		},
+		{
+			name:    "compiles with pnpm",
+			pjs:     &PackageJSON{DevDependencies: map[string]string{"typescript": "^5.3.0"}},
+			files:   map[string]string{TSConfig: "{}"},
+			pkgTool: "pnpm",
+			want:    "pnpm exec tsc --project tsconfig.json",
+		},
+		{
+			name:    "no tsconfig",
+			pjs:     &PackageJSON{DevDependencies: map[string]string{"typescript": "^5.3.0"}},
+			pkgTool: "npm",
+		},
+		{
+			name: "build script",
+			pjs: &PackageJSON{
+				Scripts:         map[string]string{"build": "tsc -p tsconfig.build.json"},
+				DevDependencies: map[string]string{"typescript": "^5.3.0"},
+			},
+			files:   map[string]string{TSConfig: "{}"},
+			pkgTool: "npm",
+		},
+		{
+			name: "gcp-build script",
+			pjs: &PackageJSON{
+				Scripts:         map[string]string{"gcp-build": ""},
+				DevDependencies: map[string]string{"typescript": "^5.3.0"},
+			},
+			files:   map[string]string{TSConfig: "{}"},
+			pkgTool: "npm",
+		},
+		{
+			name:    "run scripts disabled",
+			pjs:     &PackageJSON{DevDependencies: map[string]string{"typescript": "^5.3.0"}},
+			files:   map[string]string{TSConfig: "{}"},
+			envs:    map[string]string{GoogleNodeRunScriptsEnv: ""},
+			pkgTool: "npm",
+		},
+		{
+			name:    "typescript not a dependency",
+			pjs:     &PackageJSON{},
+			files:   map[string]string{TSConfig: "{}"},
+			pkgTool: "npm",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			writeFiles(t, ctx.ApplicationRoot(), tc.files)
+
+			got, err := TypeScriptBuildCommand(ctx, tc.pjs, tc.pkgTool)
+			if err != nil {
+				t.Fatalf("TypeScriptBuildCommand() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("TypeScriptBuildCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompiledFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		source  string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "javascript source",
+			source: "dist/index.js",
+			want:   "dist/index.js",
+		},
+		{
+			name:   "no outDir",
+			source: "index.ts",
+			files:  map[string]string{TSConfig: "{}", "index.js": ""},
+			want:   "index.js",
+		},
+		{
+			name:   "outDir and rootDir",
+			source: "src/index.ts",
+			files: map[string]string{
+				TSConfig:        `{"compilerOptions": {"outDir": "dist", "rootDir": "./src"}}`,
+				"dist/index.js": "",
+			},
+			want: "dist/index.js",
+		},
+		{
+			name:   "outDir mirrors source tree",
+			source: "src/function.ts",
+			files: map[string]string{
+				TSConfig:                `{"compilerOptions": {"outDir": "build"}, "include": ["src", "test"]}`,
+				"build/src/function.js": "",
+				"build/function.js":     "",
+			},
+			want: "build/src/function.js",
+		},
+		{
+			name:   "outDir from common source directory",
+			source: "src/function.ts",
+			files: map[string]string{
+				TSConfig:            `{"compilerOptions": {"outDir": "build"}}`,
+				"build/function.js": "",
+			},
+			want: "build/function.js",
+		},
+		{
+			name:   "module extension",
+			source: "src/index.mts",
+			files: map[string]string{
+				TSConfig:         `{"compilerOptions": {"outDir": "dist", "rootDir": "src"}}`,
+				"dist/index.mjs": "",
+			},
+			want: "dist/index.mjs",
+		},
+		{
+			name:    "not compiled",
+			source:  "index.ts",
+			files:   map[string]string{TSConfig: `{"compilerOptions": {"outDir": "dist"}}`},
+			wantErr: true,
+		},
+		{
+			name:    "no tsconfig",
+			source:  "index.ts",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			writeFiles(t, ctx.ApplicationRoot(), tc.files)
+
+			got, err := CompiledFile(ctx, tc.source)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("CompiledFile(%q) got error: %v, want error: %t", tc.source, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("CompiledFile(%q) = %q, want %q", tc.source, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDefaultStartCommandTypeScript(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+	writeFiles(t, ctx.ApplicationRoot(), map[string]string{
+		TSConfig:        `{"compilerOptions": {"outDir": "dist"}}`,
+		"src/index.ts":  "",
+		"dist/index.js": "",
+	})
+
+	got, err := DefaultStartCommand(ctx, &PackageJSON{})
+	if err != nil {
+		t.Fatalf("DefaultStartCommand() got error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"node", "dist/index.js"}, got); diff != "" {
+		t.Errorf("DefaultStartCommand() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStartCommandTypeScript(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pjs     *PackageJSON
+		pkgTool string
+		want    []string
+	}{
+		{
+			name:    "yarn without start script",
+			pjs:     &PackageJSON{},
+			pkgTool: "yarn",
+			want:    []string{"node", "dist/index.js"},
+		},
+		{
+			name:    "pnpm without start script",
+			pjs:     &PackageJSON{},
+			pkgTool: "pnpm",
+			want:    []string{"node", "dist/index.js"},
+		},
+		{
+			name:    "yarn with start script",
+			pjs:     &PackageJSON{Scripts: map[string]string{"start": "node dist/index.js"}},
+			pkgTool: "yarn",
+			want:    []string{"yarn", "run", "start"},
+		},
+		{
+			name:    "pnpm with start script",
+			pjs:     &PackageJSON{Scripts: map[string]string{"start": "node dist/index.js"}},
+			pkgTool: "pnpm",
+			want:    []string{"pnpm", "run", "start"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+			writeFiles(t, ctx.ApplicationRoot(), map[string]string{
+				TSConfig:        `{"compilerOptions": {"outDir": "dist"}}`,
+				"src/index.ts":  "",
+				"dist/index.js": "",
+			})
+
+			got, err := StartCommand(ctx, tc.pjs, tc.pkgTool)
+			if err != nil {
+				t.Fatalf("StartCommand(%q) got error: %v", tc.pkgTool, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("StartCommand(%q) mismatch (-want +got):\n%s", tc.pkgTool, diff)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
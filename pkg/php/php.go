@@ -24,8 +24,10 @@ import (
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/buildpacks/libcnb"
@@ -38,6 +40,8 @@ const (
 	composerLock = "composer.lock"
 	// Vendor is the name of the Composer vendor directory.
 	Vendor = "vendor"
+	// composerCacheLayer holds Composer's own package cache, which survives changes to the lock file.
+	composerCacheLayer = "composer_cache"
 
 	phpVersionKey     = "php_version"
 	dependencyHashKey = "dependency_hash"
@@ -98,8 +102,9 @@ type composerScriptsJSON struct {
 
 // ComposerJSON represents the contents of a composer.json file.
 type ComposerJSON struct {
-	Require map[string]string   `json:"require"`
-	Scripts composerScriptsJSON `json:"scripts"`
+	Require    map[string]string   `json:"require"`
+	RequireDev map[string]string   `json:"require-dev"`
+	Scripts    composerScriptsJSON `json:"scripts"`
 }
 
 // SupportsAppEngineApis is a function that returns true if App Engine API access is enabled
@@ -135,13 +140,22 @@ func version(ctx *gcp.Context) (string, error) {
 	return result.Stdout, nil
 }
 
-// composerInstall runs `composer install` with the given flags.
-func composerInstall(ctx *gcp.Context, flags []string) error {
+// composerInstall runs `composer install` with the given flags, keeping Composer's package cache in
+// the composer cache layer.
+func composerInstall(ctx *gcp.Context, flags []string, opts ...gcp.ExecOption) error {
+	if err := ar.GenerateComposerConfig(ctx); err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	composerCache, err := cache.NewPackageCache(ctx, composerCacheLayer)
+	if err != nil {
+		return err
+	}
 	cmd := append([]string{"composer", "install"}, flags...)
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+	opts = append([]gcp.ExecOption{gcp.WithEnv("COMPOSER_CACHE_DIR=" + composerCache.Path), gcp.WithUserAttribution}, opts...)
+	if _, err := ctx.Exec(cmd, opts...); err != nil {
 		return err
 	}
-	return nil
+	return composerCache.Record(ctx)
 }
 
 // ComposerInstall runs `composer install`, using the cache iff a lock file is present.
@@ -194,8 +208,9 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 	}
 
 	if cached {
-		// PHP expects the vendor/ directory to be in the application directory.
-		if _, err := ctx.Exec([]string{"cp", "--archive", layerVendor, Vendor}, gcp.WithUserTimingAttribution); err != nil {
+		// PHP expects the vendor/ directory to be in the application directory. It is not linked to
+		// the layer because gcp-build scripts may modify it.
+		if err := ctx.RestoreTree(Vendor, layerVendor, fileutil.Reflink); err != nil {
 			return nil, err
 		}
 	} else {
@@ -215,7 +230,7 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 		if err := ctx.MkdirAll(Vendor, 0755); err != nil {
 			return nil, err
 		}
-		if _, err := ctx.Exec([]string{"cp", "--archive", Vendor, layerVendor}, gcp.WithUserTimingAttribution); err != nil {
+		if err := ctx.RestoreTree(layerVendor, Vendor, fileutil.Reflink); err != nil {
 			return nil, err
 		}
 	}
@@ -39,6 +39,9 @@ func TestReadComposerJSON(t *testing.T) {
     "myorg/mypackage": "^0.7",
     "php": "7.4"
   },
+  "require-dev": {
+    "phpunit/phpunit": "^10.5"
+  },
   "scripts": {
     "gcp-build": "my-script"
   }
@@ -54,6 +57,9 @@ func TestReadComposerJSON(t *testing.T) {
 			"myorg/mypackage": "^0.7",
 			"php":             "7.4",
 		},
+		RequireDev: map[string]string{
+			"phpunit/phpunit": "^10.5",
+		},
 		Scripts: composerScriptsJSON{
 			GCPBuild: "my-script",
 		},
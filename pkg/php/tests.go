@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"fmt"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
+)
+
+const (
+	// testLayerName is the layer that the application's dependencies, including the development
+	// dependencies, are installed into to run its tests. It is neither cached nor launched, so the
+	// development dependencies are not part of the application image.
+	testLayerName = "composer_dev"
+	// phpunitPackage is the Composer package that provides PHPUnit.
+	phpunitPackage = "phpunit/phpunit"
+	// phpunitReport is the name of the JUnit XML report written by PHPUnit.
+	phpunitReport = "phpunit.xml"
+	// prodVendor is where the application's vendor directory is moved while the tests run.
+	prodVendor = Vendor + ".prod"
+)
+
+// RunTests runs the application's tests with PHPUnit if GOOGLE_RUN_TESTS is set and the
+// application requires phpunit/phpunit. It must be called after the application's dependencies
+// have been installed.
+func RunTests(ctx *gcp.Context) error {
+	enabled, err := testrun.Enabled(ctx)
+	if err != nil || !enabled {
+		return err
+	}
+	cjs, err := ReadComposerJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if !cjs.requires(phpunitPackage) {
+		ctx.Logf("No tests found, %s does not require %s.", composerJSON, phpunitPackage)
+		return nil
+	}
+
+	l, err := ctx.Layer(testLayerName)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", testLayerName, err)
+	}
+	devVendor := filepath.Join(l.Path, Vendor)
+	ctx.Logf("Installing development dependencies.")
+	if err := composerInstall(ctx, []string{"--no-progress", "--no-interaction"}, gcp.WithEnv("COMPOSER_VENDOR_DIR="+devVendor)); err != nil {
+		return err
+	}
+
+	restore, err := useVendor(ctx, devVendor)
+	if err != nil {
+		return err
+	}
+	report := filepath.Join(l.Path, phpunitReport)
+	runErr := testrun.Run(ctx, testrun.Config{
+		Command: []string{filepath.Join(Vendor, "bin", "phpunit"), "--log-junit", report},
+		Reports: []string{report},
+	})
+	if err := restore(); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// requires reports whether composer.json requires the package, in production or development.
+func (cjs *ComposerJSON) requires(pkg string) bool {
+	if _, ok := cjs.Require[pkg]; ok {
+		return true
+	}
+	_, ok := cjs.RequireDev[pkg]
+	return ok
+}
+
+// useVendor links the application's vendor directory to dir, because test bootstrap files load
+// vendor/autoload.php, and returns a function that puts the application's own vendor directory
+// back.
+func useVendor(ctx *gcp.Context, dir string) (func() error, error) {
+	vendor := filepath.Join(ctx.ApplicationRoot(), Vendor)
+	prod := filepath.Join(ctx.ApplicationRoot(), prodVendor)
+	exists, err := ctx.FileExists(vendor)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if err := ctx.Rename(vendor, prod); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Symlink(dir, vendor); err != nil {
+		return nil, err
+	}
+	return func() error {
+		if err := ctx.RemoveAll(vendor); err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+		return ctx.Rename(prod, vendor)
+	}, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestRequires(t *testing.T) {
+	testCases := []struct {
+		name string
+		cjs  ComposerJSON
+		want bool
+	}{
+		{
+			name: "require-dev",
+			cjs:  ComposerJSON{RequireDev: map[string]string{"phpunit/phpunit": "^10.5"}},
+			want: true,
+		},
+		{
+			name: "require",
+			cjs:  ComposerJSON{Require: map[string]string{"phpunit/phpunit": "^10.5"}},
+			want: true,
+		},
+		{
+			name: "not required",
+			cjs:  ComposerJSON{Require: map[string]string{"monolog/monolog": "^3.0"}},
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.cjs.requires(phpunitPackage); got != tc.want {
+				t.Errorf("requires(%q) = %v, want %v", phpunitPackage, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUseVendor(t *testing.T) {
+	testCases := []struct {
+		name   string
+		vendor bool
+	}{
+		{
+			name:   "with vendor",
+			vendor: true,
+		},
+		{
+			name: "without vendor",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := t.TempDir()
+			dev := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dev, "dev.php"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tc.vendor {
+				if err := os.Mkdir(filepath.Join(app, Vendor), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(app, Vendor, "prod.php"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(app))
+
+			restore, err := useVendor(ctx, dev)
+			if err != nil {
+				t.Fatalf("useVendor() got error: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(app, Vendor, "dev.php")); err != nil {
+				t.Errorf("development dependencies not in %s: %v", Vendor, err)
+			}
+
+			if err := restore(); err != nil {
+				t.Fatalf("restore() got error: %v", err)
+			}
+			_, err = os.Stat(filepath.Join(app, Vendor, "prod.php"))
+			if gotVendor := err == nil; gotVendor != tc.vendor {
+				t.Errorf("after restore, %s/prod.php exists? %v, want %v", Vendor, gotVendor, tc.vendor)
+			}
+			if _, err := os.Stat(filepath.Join(dev, "dev.php")); err != nil {
+				t.Errorf("restore() removed the development dependencies: %v", err)
+			}
+			if _, err := os.Lstat(filepath.Join(app, prodVendor)); !os.IsNotExist(err) {
+				t.Errorf("%s left behind: %v", prodVendor, err)
+			}
+		})
+	}
+}
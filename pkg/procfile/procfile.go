@@ -0,0 +1,41 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package procfile parses Procfiles, which declare the process types of an application.
+package procfile
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	processRe = regexp.MustCompile(`(?m)^(\w+):\s*(.+)$`)
+)
+
+// Process is a single process type declared in a Procfile.
+type Process struct {
+	Name    string
+	Command string
+}
+
+// Parse returns the processes declared in the given Procfile contents, in declaration order.
+// Duplicate process types are returned as-is; callers decide which one wins.
+func Parse(content string) []Process {
+	var ps []Process
+	for _, match := range processRe.FindAllStringSubmatch(content, -1) {
+		ps = append(ps, Process{Name: match[1], Command: strings.TrimSpace(match[2])})
+	}
+	return ps
+}
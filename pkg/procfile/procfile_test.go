@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfile
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    []Process
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "web only",
+			content: "web: python main.py",
+			want:    []Process{{Name: "web", Command: "python main.py"}},
+		},
+		{
+			name:    "multiple processes",
+			content: "web: gunicorn main:app\nworker:   celery -A tasks worker  \n",
+			want: []Process{
+				{Name: "web", Command: "gunicorn main:app"},
+				{Name: "worker", Command: "celery -A tasks worker"},
+			},
+		},
+		{
+			name:    "duplicates are kept",
+			content: "web: a\nweb: b",
+			want:    []Process{{Name: "web", Command: "a"}, {Name: "web", Command: "b"}},
+		},
+		{
+			name:    "invalid lines are skipped",
+			content: "# comment\n web: indented\nweb-2: dashed\nweb:",
+			want:    nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Parse(tc.content)); diff != "" {
+				t.Errorf("Parse(%q) mismatch (-want +got):\n%s", tc.content, diff)
+			}
+		})
+	}
+}
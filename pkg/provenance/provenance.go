@@ -0,0 +1,258 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package provenance describes the source revision an application image was built from.
+package provenance
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Standard OCI image annotations, see
+// https://github.com/opencontainers/image-spec/blob/main/annotations.md.
+const (
+	LabelCreated  = "org.opencontainers.image.created"
+	LabelRevision = "org.opencontainers.image.revision"
+	LabelSource   = "org.opencontainers.image.source"
+	LabelVersion  = "org.opencontainers.image.version"
+)
+
+var (
+	commitRegexp = regexp.MustCompile(`\A[0-9a-f]{40}([0-9a-f]{24})?\z`)
+	// scpRegexp matches scp-like git remotes such as "git@github.com:owner/repo.git".
+	scpRegexp = regexp.MustCompile(`\A(?:[^@/]+@)?([^:/]+):(.+)\z`)
+)
+
+// Source describes a revision of the application source.
+type Source struct {
+	// Revision is the full commit hash.
+	Revision string
+	// Branch is the checked out branch, if any.
+	Branch string
+	// Tag is a tag pointing at Revision, if any.
+	Tag string
+	// URL is the remote repository, without credentials.
+	URL string
+	// Dirty is true if the working tree has uncommitted changes.
+	Dirty bool
+}
+
+// FromGit reads the source revision from the git repository at root without invoking git. It
+// returns nil if root is not a git checkout. Dirty is never set, since it requires comparing the
+// working tree against the index.
+func FromGit(root string) (*Source, error) {
+	gitDir, err := findGitDir(root)
+	if err != nil || gitDir == "" {
+		return nil, err
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return nil, fmt.Errorf("reading HEAD: %w", err)
+	}
+	refs, err := readRefs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Source{}
+	h := strings.TrimSpace(string(head))
+	if ref, ok := strings.CutPrefix(h, "ref: "); ok {
+		s.Branch = strings.TrimPrefix(ref, "refs/heads/")
+		s.Revision = refs[ref]
+	} else if commitRegexp.MatchString(h) {
+		s.Revision = h
+	}
+	if s.Revision == "" {
+		// An unborn branch or unrecognized HEAD, there is no revision to describe.
+		return nil, nil
+	}
+	s.Tag = tagAt(refs, s.Revision)
+	if s.URL, err = remoteURL(gitDir); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// findGitDir returns the git directory of the checkout at root, following a ".git" file as
+// written for worktrees and submodules. It returns "" if there is none.
+func findGitDir(root string) (string, error) {
+	p := filepath.Join(root, ".git")
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("stat %s: %w", p, err)
+	}
+	if fi.IsDir() {
+		return p, nil
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", p, err)
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("%s does not point to a git directory", p)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// readRefs returns the commit of each ref in packed-refs and below refs/. Annotated tags resolve
+// to the commit they point at.
+func readRefs(gitDir string) (map[string]string, error) {
+	refs := map[string]string{}
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("opening packed-refs: %w", err)
+	}
+	if err == nil {
+		defer f.Close()
+		var last string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case strings.HasPrefix(line, "#"):
+			case strings.HasPrefix(line, "^"):
+				// The peeled commit of the preceding annotated tag.
+				if last != "" {
+					refs[last] = strings.TrimPrefix(line, "^")
+				}
+			default:
+				if hash, ref, ok := strings.Cut(line, " "); ok {
+					refs[ref] = hash
+					last = ref
+				}
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("reading packed-refs: %w", err)
+		}
+	}
+
+	// Loose refs take precedence over packed ones.
+	refsDir := filepath.Join(gitDir, "refs")
+	err = filepath.WalkDir(refsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(gitDir, path)
+		if err != nil {
+			return err
+		}
+		hash := strings.TrimSpace(string(content))
+		if commitRegexp.MatchString(hash) {
+			refs[filepath.ToSlash(rel)] = hash
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading refs: %w", err)
+	}
+	return refs, nil
+}
+
+// tagAt returns the lexically first tag pointing at commit, or "" if there is none. Loose
+// annotated tags point at the tag object rather than the commit and are not found.
+func tagAt(refs map[string]string, commit string) string {
+	var tags []string
+	for ref, hash := range refs {
+		if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok && hash == commit {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	sort.Strings(tags)
+	return tags[0]
+}
+
+// remoteURL returns the URL of the "origin" remote, or of the only remote if there is no origin.
+func remoteURL(gitDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(gitDir, "config"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading git config: %w", err)
+	}
+	urls := map[string]string{}
+	var remote string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			remote = ""
+			if name, ok := strings.CutPrefix(line, `[remote "`); ok {
+				remote = strings.TrimSuffix(name, `"]`)
+			}
+			continue
+		}
+		if remote == "" {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == "url" {
+			urls[remote] = strings.TrimSpace(v)
+		}
+	}
+	if u, ok := urls["origin"]; ok {
+		return SanitizeURL(u), nil
+	}
+	if len(urls) == 1 {
+		for _, u := range urls {
+			return SanitizeURL(u), nil
+		}
+	}
+	return "", nil
+}
+
+// SanitizeURL returns the repository URL without credentials. scp-like remotes such as
+// "git@github.com:owner/repo.git" are converted to "https://github.com/owner/repo.git" and local
+// paths yield "".
+func SanitizeURL(raw string) string {
+	if !strings.Contains(raw, "://") {
+		if m := scpRegexp.FindStringSubmatch(raw); m != nil {
+			return "https://" + m[1] + "/" + strings.TrimPrefix(m[2], "/")
+		}
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if u.Scheme == "file" {
+		return ""
+	}
+	u.User = nil
+	if u.Scheme == "ssh" || u.Scheme == "git" {
+		u.Scheme = "https"
+		u.Host = u.Hostname()
+	}
+	return u.String()
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// DjangoSkipMigrationCheckEnv is an env var used to disable failing the build on pending Django
+	// model changes.
+	// Example: `true` only warns when "manage.py makemigrations --check" reports missing migrations.
+	DjangoSkipMigrationCheckEnv = "GOOGLE_DJANGO_SKIP_MIGRATION_CHECK"
+
+	// djangoSettingsModuleEnv is the env var Django reads the settings module from.
+	djangoSettingsModuleEnv = "DJANGO_SETTINGS_MODULE"
+)
+
+var (
+	// settingsModuleRegexp matches the default settings module set by a generated manage.py, e.g.
+	// os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings").
+	settingsModuleRegexp = regexp.MustCompile(`DJANGO_SETTINGS_MODULE["']\s*,\s*["']([\w.]+)["']`)
+	// checkWarningRegexp matches a warning reported by the Django system check framework, e.g.
+	// "?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.".
+	checkWarningRegexp = regexp.MustCompile(`(?m)^.*\(\w+\.W\d+\).*$`)
+)
+
+// DjangoSettingsModule returns the settings module of a Django project, taken from the
+// DJANGO_SETTINGS_MODULE env var or the default set in manage.py. It returns "" if neither is set.
+func DjangoSettingsModule(ctx *gcp.Context) (string, error) {
+	if m := os.Getenv(djangoSettingsModuleEnv); m != "" {
+		return m, nil
+	}
+	content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), "manage.py"))
+	if err != nil {
+		return "", err
+	}
+	if match := settingsModuleRegexp.FindSubmatch(content); match != nil {
+		return string(match[1]), nil
+	}
+	return "", nil
+}
+
+// DjangoSettingsOverlay returns the source of a settings module that extends settingsModule and
+// collects static files into staticRoot.
+func DjangoSettingsOverlay(settingsModule, staticRoot string) string {
+	return fmt.Sprintf(`# Generated by the google.python.django buildpack.
+from %s import *  # noqa: F401,F403
+
+STATIC_ROOT = %q
+`, settingsModule, staticRoot)
+}
+
+// DjangoCheckWarnings returns the warnings reported in the output of "manage.py check".
+func DjangoCheckWarnings(output string) []string {
+	var warnings []string
+	for _, w := range checkWarningRegexp.FindAllString(output, -1) {
+		warnings = append(warnings, strings.TrimSpace(w))
+	}
+	return warnings
+}
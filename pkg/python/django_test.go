@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDjangoSettingsModule(t *testing.T) {
+	testCases := []struct {
+		name   string
+		manage string
+		env    string
+		want   string
+	}{
+		{
+			name:   "manage.py default",
+			manage: "os.environ.setdefault('DJANGO_SETTINGS_MODULE', 'mysite.settings')\n",
+			want:   "mysite.settings",
+		},
+		{
+			name:   "env overrides manage.py",
+			manage: "os.environ.setdefault(\"DJANGO_SETTINGS_MODULE\", \"mysite.settings\")\n",
+			env:    "mysite.settings.production",
+			want:   "mysite.settings.production",
+		},
+		{
+			name:   "not set",
+			manage: "from django.core.management import execute_from_command_line\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "manage.py"), []byte(tc.manage), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("DJANGO_SETTINGS_MODULE", tc.env)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := DjangoSettingsModule(ctx)
+			if err != nil {
+				t.Fatalf("DjangoSettingsModule() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("DjangoSettingsModule() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDjangoSettingsOverlay(t *testing.T) {
+	want := `# Generated by the google.python.django buildpack.
+from mysite.settings import *  # noqa: F401,F403
+
+STATIC_ROOT = "/layers/google.python.django/django/static"
+`
+	if got := DjangoSettingsOverlay("mysite.settings", "/layers/google.python.django/django/static"); got != want {
+		t.Errorf("DjangoSettingsOverlay() = %q, want %q", got, want)
+	}
+}
+
+func TestDjangoCheckWarnings(t *testing.T) {
+	output := `System check identified some issues:
+
+WARNINGS:
+?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.
+?: (security.W008) Your SECURE_SSL_REDIRECT setting is not set to True.
+
+System check identified 2 issues (0 silenced).
+`
+	want := []string{
+		"?: (security.W004) You have not set a value for the SECURE_HSTS_SECONDS setting.",
+		"?: (security.W008) Your SECURE_SSL_REDIRECT setting is not set to True.",
+	}
+	if diff := cmp.Diff(want, DjangoCheckWarnings(output)); diff != "" {
+		t.Errorf("DjangoCheckWarnings() mismatch (-want +got):\n%s", diff)
+	}
+	if got := DjangoCheckWarnings("System check identified no issues (0 silenced).\n"); got != nil {
+		t.Errorf("DjangoCheckWarnings() = %v, want nil", got)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
@@ -39,6 +40,8 @@ const (
 	dependencyHashKey  = "dependency_hash"
 	expiryTimestampKey = "expiry_timestamp"
 
+	// cacheName is the layer holding pip's HTTP and wheel caches, which survive changes to the
+	// requirements files.
 	cacheName = "pipcache"
 
 	// RequirementsFilesEnv is an environment variable containg os-path-separator-separated list of paths to pip requirements files.
@@ -48,9 +51,10 @@ const (
 	// VendorPipDepsEnv is the envar used to opt using vendored pip dependencies
 	VendorPipDepsEnv = "GOOGLE_VENDOR_PIP_DEPENDENCIES"
 
-	versionFile = ".python-version"
-	versionKey  = "version"
-	versionEnv  = "GOOGLE_PYTHON_VERSION"
+	versionFile   = ".python-version"
+	pyprojectFile = "pyproject.toml"
+	versionKey    = "version"
+	versionEnv    = "GOOGLE_PYTHON_VERSION"
 
 	// python37SharedLibDir is the location of the shared Python library when building the python37 runtime.
 	python37SharedLibDir = "/layers/google.python.runtime/python/lib/python3.7/config-3.7m-x86_64-linux-gnu"
@@ -79,7 +83,7 @@ func Version(ctx *gcp.Context) (string, error) {
 }
 
 // RuntimeVersion validate and returns the customer requested Python version by inspecting the
-// environment variables and .python-version file.
+// environment variables, .python-version file and requires-python in pyproject.toml.
 func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 	if v := os.Getenv(env.Runtime); v != "" && !strings.HasPrefix(v, "python") {
 		return "*", nil
@@ -100,6 +104,13 @@ func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 	if v != "" {
 		return v, nil
 	}
+	v, err = versionFromPyproject(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		return v, nil
+	}
 
 	// This will use the highest listed at https://dl.google.com/runtimes/python/version.json.
 	ctx.Logf("Python version not specified, using the latest available version.")
@@ -127,6 +138,33 @@ func versionFromFile(ctx *gcp.Context, dir string) (string, error) {
 	return "", nil
 }
 
+// versionFromPyproject returns the requires-python constraint of the project in pyproject.toml, a
+// PEP 440 specifier such as ">=3.10".
+func versionFromPyproject(ctx *gcp.Context, dir string) (string, error) {
+	path := filepath.Join(dir, pyprojectFile)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	raw, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var pyproject struct {
+		Project struct {
+			RequiresPython string `toml:"requires-python"`
+		} `toml:"project"`
+	}
+	if err := toml.Unmarshal(raw, &pyproject); err != nil {
+		return "", gcp.UserErrorf("parsing %s: %v", path, err)
+	}
+	v := strings.TrimSpace(pyproject.Project.RequiresPython)
+	if v != "" {
+		ctx.Logf("Using Python version from requires-python in %s: %s", path, v)
+	}
+	return v, nil
+}
+
 // InstallRequirements installs dependencies from the given requirements files in a virtual env.
 // It will install the files in order in which they are specified, so that dependencies specified
 // in later requirements files can override later ones.
@@ -181,6 +219,10 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	if err := ar.GeneratePythonConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	pipCache, err := cache.NewPackageCache(ctx, cacheName)
+	if err != nil {
+		return err
+	}
 
 	// History of the logic below:
 	//
@@ -237,7 +279,7 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 			"--force-reinstall",           // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
 			"--no-compile",                // Prevent default timestamp-based bytecode compilation. Deterministic pycs are generated in a second step below.
 			"--disable-pip-version-check", // If we were going to upgrade pip, we would have done it already in the runtime buildpack.
-			"--no-cache-dir",              // We used to save this to a layer, but it made builds slower because it includes http caching of pypi requests.
+			"--cache-dir", pipCache.Path,  // Downloaded packages and wheels built from source are reused across builds.
 		}
 		vendorDir, isVendored := os.LookupEnv(VendorPipDepsEnv)
 		if isVendored {
@@ -252,6 +294,9 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 			return err
 		}
 	}
+	if err := pipCache.Record(ctx); err != nil {
+		return err
+	}
 
 	// Generate deterministic hash-based pycs (https://www.python.org/dev/peps/pep-0552/).
 	// Use the unchecked version to skip hash validation at run time (for faster startup).
@@ -27,6 +27,7 @@ func TestRuntimeVersion(t *testing.T) {
 		version        string
 		runtimeVersion string
 		versionFile    string
+		pyproject      string
 		want           string
 		wantErr        bool
 	}{
@@ -66,6 +67,27 @@ func TestRuntimeVersion(t *testing.T) {
 			versionFile:    "3.8.1",
 			want:           "3.8.0",
 		},
+		{
+			name:      "version from requires-python",
+			pyproject: "[project]\nname = \"app\"\nrequires-python = \">=3.10,<3.13\"\n",
+			want:      ">=3.10,<3.13",
+		},
+		{
+			name:      "pyproject.toml without requires-python",
+			pyproject: "[tool.poetry]\nname = \"app\"\n",
+			want:      "*",
+		},
+		{
+			name:        ".python-version takes precedence over requires-python",
+			versionFile: "3.12.1",
+			pyproject:   "[project]\nrequires-python = \">=3.10\"\n",
+			want:        "3.12.1",
+		},
+		{
+			name:      "invalid pyproject.toml",
+			pyproject: "[project\n",
+			wantErr:   true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -86,6 +108,12 @@ func TestRuntimeVersion(t *testing.T) {
 				}
 			}
 
+			if tc.pyproject != "" {
+				if err := os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte(tc.pyproject), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
 			got, err := RuntimeVersion(ctx, dir)
 			if tc.wantErr == (err == nil) {
 				t.Errorf("RuntimeVersion(ctx, %q) got error: %v, want err? %t", dir, err, tc.wantErr)
@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
+)
+
+const (
+	// testLayerName is the layer that pytest and the test requirements are installed into. It is
+	// neither cached nor launched, so they are not part of the application image.
+	testLayerName = "pytest"
+	// pytestReport is the name of the JUnit XML report written by pytest.
+	pytestReport = "pytest.xml"
+	// appConstraints is the name of the pip constraints file pinning the application's packages.
+	appConstraints = "constraints.txt"
+	// pytestNoTestsExitCode is the exit code of pytest when it collects no tests.
+	pytestNoTestsExitCode = 5
+)
+
+// testRequirementsFiles are the files that list the requirements of an application's tests, in
+// order of preference.
+var testRequirementsFiles = []string{"requirements-test.txt", "requirements-dev.txt"}
+
+// pinnedRequirementRegexp matches the lines of `pip freeze` that pin a package to a version. Other
+// lines, such as editable installs and direct URL references, are not allowed in constraints files.
+var pinnedRequirementRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*==\S+$`)
+
+// RunTests runs the application's tests with pytest if GOOGLE_RUN_TESTS is set. It must be called
+// after the application's requirements have been installed.
+func RunTests(ctx *gcp.Context) error {
+	enabled, err := testrun.Enabled(ctx)
+	if err != nil || !enabled {
+		return err
+	}
+	req, err := testRequirementsFile(ctx)
+	if err != nil {
+		return err
+	}
+
+	l, err := ctx.Layer(testLayerName)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", testLayerName, err)
+	}
+	// The layer is on PYTHONPATH, which precedes the application's site-packages, so the test
+	// requirements are constrained to the installed versions of the application's packages rather
+	// than shadowing them with other versions.
+	freeze, err := ctx.Exec([]string{"python3", "-m", "pip", "freeze", "--disable-pip-version-check"}, gcp.WithLogOutput(false))
+	if err != nil {
+		return err
+	}
+	constraints := filepath.Join(l.Path, appConstraints)
+	if err := os.WriteFile(constraints, []byte(freezeConstraints(freeze.Stdout)), 0644); err != nil {
+		return gcp.InternalErrorf("writing %s: %v", constraints, err)
+	}
+	ctx.Logf("Installing pytest and test requirements.")
+	cmd := []string{
+		"python3", "-m", "pip", "install",
+		"--target", l.Path,
+		"--constraint", constraints,
+		"--no-warn-script-location",
+		"--disable-pip-version-check",
+		"pytest",
+	}
+	if req != "" {
+		cmd = append(cmd, "--requirement", req)
+	}
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+
+	report := filepath.Join(l.Path, pytestReport)
+	return testrun.Run(ctx, testrun.Config{
+		// The pytest cache is disabled so that it does not end up in the application image.
+		Command:         []string{"python3", "-m", "pytest", "-p", "no:cacheprovider", "--junitxml", report},
+		Reports:         []string{report},
+		NoTestsExitCode: pytestNoTestsExitCode,
+		Options: []gcp.ExecOption{gcp.WithEnv(
+			"PYTHONPATH="+l.Path+string(os.PathListSeparator)+os.Getenv("PYTHONPATH"),
+			"PATH="+filepath.Join(l.Path, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"),
+			"PYTHONDONTWRITEBYTECODE=1",
+		)},
+	})
+}
+
+// freezeConstraints returns the lines of `pip freeze` output that can be used as pip constraints.
+func freezeConstraints(freeze string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(freeze, "\n") {
+		if line = strings.TrimSpace(line); pinnedRequirementRegexp.MatchString(line) {
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// testRequirementsFile returns the first of testRequirementsFiles that exists, or "" if none do.
+func testRequirementsFile(ctx *gcp.Context) (string, error) {
+	for _, f := range testRequirementsFiles {
+		exists, err := ctx.FileExists(f)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return f, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+)
+
+func TestFreezeConstraints(t *testing.T) {
+	freeze := `Flask==3.0.2
+-e git+https://github.com/owner/repo.git@0123456789abcdef#egg=local_pkg
+requests @ https://example.com/requests-2.31.0-py3-none-any.whl
+zope.interface==6.2
+# Editable install with no version control (local-pkg==0.1)
+`
+	want := "Flask==3.0.2\nzope.interface==6.2\n"
+
+	if got := freezeConstraints(freeze); got != want {
+		t.Errorf("freezeConstraints(%q) = %q, want %q", freeze, got, want)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Framework is a Python web framework that an application object is created with.
+type Framework string
+
+const (
+	// FrameworkFlask is the Flask WSGI framework.
+	FrameworkFlask Framework = "flask"
+	// FrameworkDjango is the Django framework, served through its WSGI or ASGI module.
+	FrameworkDjango Framework = "django"
+	// FrameworkFastAPI is the FastAPI ASGI framework.
+	FrameworkFastAPI Framework = "fastapi"
+	// FrameworkStarlette is the Starlette ASGI framework.
+	FrameworkStarlette Framework = "starlette"
+)
+
+// Server is a Python web server that can run an application object.
+type Server string
+
+const (
+	// ServerGunicorn is the gunicorn WSGI server, which also runs ASGI apps with uvicorn workers.
+	ServerGunicorn Server = "gunicorn"
+	// ServerUvicorn is the uvicorn ASGI server.
+	ServerUvicorn Server = "uvicorn"
+	// ServerHypercorn is the hypercorn ASGI server.
+	ServerHypercorn Server = "hypercorn"
+
+	// webThreads is the number of threads per gunicorn worker for WSGI apps.
+	webThreads = 8
+)
+
+var (
+	// appPatterns match the module level assignment of an application object.
+	appPatterns = []struct {
+		framework Framework
+		asgi      bool
+		re        *regexp.Regexp
+	}{
+		{FrameworkFastAPI, true, regexp.MustCompile(`(?m)^(\w+)\s*(?::[^=]+)?=\s*(?:fastapi\.)?FastAPI\(`)},
+		{FrameworkStarlette, true, regexp.MustCompile(`(?m)^(\w+)\s*(?::[^=]+)?=\s*(?:starlette\.applications\.|applications\.)?Starlette\(`)},
+		{FrameworkFlask, false, regexp.MustCompile(`(?m)^(\w+)\s*(?::[^=]+)?=\s*(?:flask\.)?Flask\(`)},
+		{FrameworkDjango, false, regexp.MustCompile(`(?m)^(application)\s*=\s*get_wsgi_application\(`)},
+		{FrameworkDjango, true, regexp.MustCompile(`(?m)^(application)\s*=\s*get_asgi_application\(`)},
+	}
+
+	// preferredAppFiles are scanned before any other source files, in order.
+	preferredAppFiles = []string{"main.py", "app.py", "application.py", "server.py", "api.py", "wsgi.py", "asgi.py"}
+
+	// skippedAppDirs are directories that never contain the application object.
+	skippedAppDirs = map[string]bool{
+		"venv": true, ".venv": true, "env": true, "site-packages": true, "node_modules": true,
+		"tests": true, "test": true, "migrations": true, "__pycache__": true,
+	}
+
+	pipRequirementNameRegexp = regexp.MustCompile(`[-_.]+`)
+)
+
+// AppTarget is the application object a Python web server loads, written `module:attr`.
+type AppTarget struct {
+	Module    string
+	Attr      string
+	Framework Framework
+	ASGI      bool
+}
+
+// String returns the target in the `module:attr` form web servers accept.
+func (t AppTarget) String() string {
+	return t.Module + ":" + t.Attr
+}
+
+// FindAppTarget scans the Python sources in dir and its immediate subdirectories for a Flask,
+// Django, FastAPI or Starlette application object. It returns nil if none is found. Files named
+// like main.py and app.py are scanned first; a Django WSGI module is preferred over its ASGI one.
+func FindAppTarget(ctx *gcp.Context, dir string) (*AppTarget, error) {
+	files, err := appSourceFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		content, err := ctx.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range appPatterns {
+			m := p.re.FindSubmatch(content)
+			if m == nil {
+				continue
+			}
+			module := strings.ReplaceAll(strings.TrimSuffix(filepath.ToSlash(f), ".py"), "/", ".")
+			return &AppTarget{Module: module, Attr: string(m[1]), Framework: p.framework, ASGI: p.asgi}, nil
+		}
+	}
+	return nil, nil
+}
+
+// appSourceFiles returns the .py files in dir and its immediate subdirectories, relative to dir,
+// in the order they should be scanned for an application object.
+func appSourceFiles(dir string) ([]string, error) {
+	var files []string
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			if strings.HasSuffix(e.Name(), ".py") {
+				files = append(files, e.Name())
+			}
+			continue
+		}
+		if skippedAppDirs[e.Name()] || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		sub, err := os.ReadDir(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, gcp.InternalErrorf("reading %s: %v", e.Name(), err)
+		}
+		for _, s := range sub {
+			if !s.IsDir() && strings.HasSuffix(s.Name(), ".py") {
+				files = append(files, filepath.Join(e.Name(), s.Name()))
+			}
+		}
+	}
+	rank := func(f string) int {
+		for i, p := range preferredAppFiles {
+			if filepath.Base(f) == p {
+				// Top level files before files in packages.
+				return i*2 + strings.Count(f, string(filepath.Separator))
+			}
+		}
+		return len(preferredAppFiles)*2 + strings.Count(f, string(filepath.Separator))
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		ri, rj := rank(files[i]), rank(files[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
+
+// RequirementPresent returns true if the requirements file content lists the named package,
+// either as a requirement specifier or as a VCS requirement's egg name.
+func RequirementPresent(requirements, name string) bool {
+	quoted := regexp.QuoteMeta(name)
+	specifier := regexp.MustCompile(`(?mi)^` + quoted + `\b(\[[^\]]*\])?([^-\w.]|$)`)
+	egg := regexp.MustCompile(`(?mi)#egg=` + quoted + `$`)
+	return specifier.MatchString(requirements) || egg.MatchString(requirements)
+}
+
+// InstalledPackages returns the names of the packages installed in the Python environment,
+// normalized as described in PEP 503.
+func InstalledPackages(ctx *gcp.Context) (map[string]bool, error) {
+	result, err := ctx.Exec([]string{"python3", "-m", "pip", "list", "--format=json", "--disable-pip-version-check"}, gcp.WithUserTimingAttribution)
+	if err != nil {
+		return nil, fmt.Errorf("listing installed packages: %w", err)
+	}
+	var pkgs []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &pkgs); err != nil {
+		return nil, gcp.InternalErrorf("parsing pip list output %q: %v", result.Stdout, err)
+	}
+	installed := make(map[string]bool)
+	for _, p := range pkgs {
+		installed[normalizePackageName(p.Name)] = true
+	}
+	return installed, nil
+}
+
+func normalizePackageName(name string) string {
+	return strings.ToLower(pipRequirementNameRegexp.ReplaceAllString(name, "-"))
+}
+
+// ChooseServer returns the web server to run the target with given the installed packages.
+// ASGI apps run on gunicorn with uvicorn workers if both are installed, otherwise on uvicorn or
+// hypercorn. WSGI apps always run on gunicorn.
+func ChooseServer(target AppTarget, installed map[string]bool) Server {
+	if !target.ASGI {
+		return ServerGunicorn
+	}
+	switch {
+	case installed["gunicorn"] && installed["uvicorn"]:
+		return ServerGunicorn
+	case installed["uvicorn"]:
+		return ServerUvicorn
+	case installed["hypercorn"]:
+		return ServerHypercorn
+	}
+	return ServerGunicorn
+}
+
+// WebCommand returns the shell command that serves the target with the server on $PORT. The
+// number of worker processes defaults to 1 and can be changed at runtime with WEB_CONCURRENCY.
+func WebCommand(server Server, target AppTarget) string {
+	const bind, workers = "${PORT:-8080}", "${WEB_CONCURRENCY:-1}"
+	switch server {
+	case ServerUvicorn:
+		return fmt.Sprintf("exec uvicorn --host 0.0.0.0 --port %s --workers %s %s", bind, workers, target)
+	case ServerHypercorn:
+		return fmt.Sprintf("exec hypercorn --bind 0.0.0.0:%s --workers %s %s", bind, workers, target)
+	}
+	if target.ASGI {
+		return fmt.Sprintf("exec gunicorn --bind :%s --workers %s --worker-class uvicorn.workers.UvicornWorker --timeout 0 %s", bind, workers, target)
+	}
+	return fmt.Sprintf("exec gunicorn --bind :%s --workers %s --threads %d --timeout 0 %s", bind, workers, webThreads, target)
+}
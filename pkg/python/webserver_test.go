@@ -0,0 +1,192 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindAppTarget(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  *AppTarget
+	}{
+		{
+			name:  "no app",
+			files: map[string]string{"main.py": "print('hello')\n"},
+		},
+		{
+			name:  "flask",
+			files: map[string]string{"main.py": "from flask import Flask\n\napp = Flask(__name__)\n"},
+			want:  &AppTarget{Module: "main", Attr: "app", Framework: FrameworkFlask},
+		},
+		{
+			name:  "fastapi with annotation",
+			files: map[string]string{"app.py": "import fastapi\n\napi: fastapi.FastAPI = fastapi.FastAPI(title='x')\n"},
+			want:  &AppTarget{Module: "app", Attr: "api", Framework: FrameworkFastAPI, ASGI: true},
+		},
+		{
+			name:  "starlette in package",
+			files: map[string]string{"service/server.py": "from starlette.applications import Starlette\napplication = Starlette(routes=[])\n"},
+			want:  &AppTarget{Module: "service.server", Attr: "application", Framework: FrameworkStarlette, ASGI: true},
+		},
+		{
+			name: "django prefers wsgi",
+			files: map[string]string{
+				"manage.py":        "import os\n",
+				"mysite/asgi.py":   "from django.core.asgi import get_asgi_application\napplication = get_asgi_application()\n",
+				"mysite/wsgi.py":   "from django.core.wsgi import get_wsgi_application\napplication = get_wsgi_application()\n",
+				"mysite/models.py": "",
+			},
+			want: &AppTarget{Module: "mysite.wsgi", Attr: "application", Framework: FrameworkDjango},
+		},
+		{
+			name: "main.py before other files",
+			files: map[string]string{
+				"admin.py": "from flask import Flask\nadmin = Flask(__name__)\n",
+				"main.py":  "from fastapi import FastAPI\napp = FastAPI()\n",
+			},
+			want: &AppTarget{Module: "main", Attr: "app", Framework: FrameworkFastAPI, ASGI: true},
+		},
+		{
+			name: "skips virtualenv and tests",
+			files: map[string]string{
+				".venv/app.py":     "from flask import Flask\napp = Flask(__name__)\n",
+				"tests/app.py":     "from flask import Flask\napp = Flask(__name__)\n",
+				"src/web.py":       "from flask import Flask\nweb = Flask(__name__)\n",
+				"requirements.txt": "flask\n",
+			},
+			want: &AppTarget{Module: "src.web", Attr: "web", Framework: FrameworkFlask},
+		},
+		{
+			name:  "indented assignment is not a module attribute",
+			files: map[string]string{"main.py": "def create_app():\n    app = Flask(__name__)\n    return app\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				fn := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := FindAppTarget(ctx, dir)
+			if err != nil {
+				t.Fatalf("FindAppTarget() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FindAppTarget() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRequirementPresent(t *testing.T) {
+	testCases := []struct {
+		requirements string
+		name         string
+		want         bool
+	}{
+		{requirements: "flask\nuvicorn==0.22.0\n", name: "uvicorn", want: true},
+		{requirements: "uvicorn[standard]>=0.20\n", name: "uvicorn", want: true},
+		{requirements: "Uvicorn\n", name: "uvicorn", want: true},
+		{requirements: "uvicorn-worker==0.1\n", name: "uvicorn"},
+		{requirements: "git+https://github.com/encode/uvicorn#egg=uvicorn\n", name: "uvicorn", want: true},
+		{requirements: "# uvicorn\n", name: "uvicorn"},
+	}
+	for _, tc := range testCases {
+		if got := RequirementPresent(tc.requirements, tc.name); got != tc.want {
+			t.Errorf("RequirementPresent(%q, %q) = %t, want %t", tc.requirements, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestChooseServer(t *testing.T) {
+	wsgi := AppTarget{Module: "main", Attr: "app"}
+	asgi := AppTarget{Module: "main", Attr: "app", ASGI: true}
+	testCases := []struct {
+		name      string
+		target    AppTarget
+		installed map[string]bool
+		want      Server
+	}{
+		{name: "wsgi", target: wsgi, installed: map[string]bool{"gunicorn": true, "uvicorn": true}, want: ServerGunicorn},
+		{name: "asgi gunicorn and uvicorn", target: asgi, installed: map[string]bool{"gunicorn": true, "uvicorn": true, "hypercorn": true}, want: ServerGunicorn},
+		{name: "asgi uvicorn", target: asgi, installed: map[string]bool{"uvicorn": true}, want: ServerUvicorn},
+		{name: "asgi gunicorn and hypercorn", target: asgi, installed: map[string]bool{"gunicorn": true, "hypercorn": true}, want: ServerHypercorn},
+		{name: "asgi nothing installed", target: asgi, installed: map[string]bool{}, want: ServerGunicorn},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ChooseServer(tc.target, tc.installed); got != tc.want {
+				t.Errorf("ChooseServer() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebCommand(t *testing.T) {
+	testCases := []struct {
+		name   string
+		server Server
+		target AppTarget
+		want   string
+	}{
+		{
+			name:   "gunicorn wsgi",
+			server: ServerGunicorn,
+			target: AppTarget{Module: "main", Attr: "app"},
+			want:   "exec gunicorn --bind :${PORT:-8080} --workers ${WEB_CONCURRENCY:-1} --threads 8 --timeout 0 main:app",
+		},
+		{
+			name:   "gunicorn asgi",
+			server: ServerGunicorn,
+			target: AppTarget{Module: "main", Attr: "app", ASGI: true},
+			want:   "exec gunicorn --bind :${PORT:-8080} --workers ${WEB_CONCURRENCY:-1} --worker-class uvicorn.workers.UvicornWorker --timeout 0 main:app",
+		},
+		{
+			name:   "uvicorn",
+			server: ServerUvicorn,
+			target: AppTarget{Module: "api.server", Attr: "api", ASGI: true},
+			want:   "exec uvicorn --host 0.0.0.0 --port ${PORT:-8080} --workers ${WEB_CONCURRENCY:-1} api.server:api",
+		},
+		{
+			name:   "hypercorn",
+			server: ServerHypercorn,
+			target: AppTarget{Module: "main", Attr: "app", ASGI: true},
+			want:   "exec hypercorn --bind 0.0.0.0:${PORT:-8080} --workers ${WEB_CONCURRENCY:-1} main:app",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := WebCommand(tc.server, tc.target); got != tc.want {
+				t.Errorf("WebCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testrun"
+)
+
+const (
+	// testLayerName is the layer that the bundle including the development and test groups is
+	// installed into. It is neither cached nor launched, so those gems are not part of the
+	// application image.
+	testLayerName = "test_gems"
+	// rspecReport is the name of the JUnit XML report written by rspec_junit_formatter.
+	rspecReport = "rspec.xml"
+)
+
+// RunTests runs the application's tests if GOOGLE_RUN_TESTS is set: with RSpec if the bundle
+// includes it and the application has a spec directory, or else with `rake test` if the
+// application has a Rakefile and a test directory. It must be called after the application's gems
+// have been installed.
+func RunTests(ctx *gcp.Context, lockFile string) error {
+	enabled, err := testrun.Enabled(ctx)
+	if err != nil || !enabled {
+		return err
+	}
+	lock, err := ctx.ReadFile(lockFile)
+	if err != nil {
+		return err
+	}
+
+	l, err := ctx.Layer(testLayerName)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", testLayerName, err)
+	}
+	cfg, err := testConfig(ctx, string(lock), l.Path)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		ctx.Logf("No tests found.")
+		return nil
+	}
+
+	// Bundler reads its local config from BUNDLE_APP_CONFIG instead of .bundle, whose config leaves
+	// out the development and test groups and points at the application's gems.
+	bundleEnv := gcp.WithEnv(
+		"BUNDLE_APP_CONFIG="+filepath.Join(l.Path, "config"),
+		"BUNDLE_PATH="+filepath.Join(l.Path, "bundle"),
+		"BUNDLE_FROZEN=true",
+		"RACK_ENV=test",
+		"RAILS_ENV=test",
+	)
+	if err := ar.GenerateBundlerConfig(ctx); err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	ctx.Logf("Installing development and test gems.")
+	if _, err := ctx.Exec([]string{"bundle", "install"},
+		bundleEnv,
+		gcp.WithEnv("NOKOGIRI_USE_SYSTEM_LIBRARIES=1", "MALLOC_ARENA_MAX=2", "LANG=C.utf8"),
+		gcp.WithUserAttribution); err != nil {
+		return err
+	}
+
+	cfg.Options = []gcp.ExecOption{bundleEnv}
+	return testrun.Run(ctx, *cfg)
+}
+
+// testConfig returns how to run the application's tests, writing any report into dir, or nil if
+// the application has no tests that can be run.
+func testConfig(ctx *gcp.Context, lock, dir string) (*testrun.Config, error) {
+	specExists, err := ctx.FileExists(ctx.ApplicationRoot(), "spec")
+	if err != nil {
+		return nil, err
+	}
+	if specExists && hasGem(lock, "rspec-core") {
+		cfg := &testrun.Config{Command: []string{"bundle", "exec", "rspec"}}
+		if hasGem(lock, "rspec_junit_formatter") {
+			report := filepath.Join(dir, rspecReport)
+			cfg.Command = append(cfg.Command, "--format", "progress", "--format", "RspecJunitFormatter", "--out", report)
+			cfg.Reports = []string{report}
+		}
+		return cfg, nil
+	}
+
+	rakefileExists, err := ctx.FileExists(ctx.ApplicationRoot(), "Rakefile")
+	if err != nil {
+		return nil, err
+	}
+	testExists, err := ctx.FileExists(ctx.ApplicationRoot(), "test")
+	if err != nil {
+		return nil, err
+	}
+	if rakefileExists && testExists {
+		return &testrun.Config{Command: []string{"bundle", "exec", "rake", "test"}}, nil
+	}
+	return nil, nil
+}
+
+// hasGem reports whether the lockfile content includes the named gem.
+func hasGem(lock, name string) bool {
+	return regexp.MustCompile(`(?m)^ {4}` + regexp.QuoteMeta(name) + ` \(`).MatchString(lock)
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const rspecLock = `GEM
+  remote: https://rubygems.org/
+  specs:
+    rspec-core (3.12.2)
+      rspec-support (~> 3.12.0)
+    rspec-support (3.12.1)
+`
+
+func TestTestConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		files       []string
+		lock        string
+		wantCommand string
+		wantReport  bool
+	}{
+		{
+			name:        "rspec",
+			files:       []string{"spec/app_spec.rb"},
+			lock:        rspecLock,
+			wantCommand: "bundle exec rspec",
+		},
+		{
+			name:        "rspec with junit formatter",
+			files:       []string{"spec/app_spec.rb"},
+			lock:        rspecLock + "    rspec_junit_formatter (0.6.0)\n",
+			wantCommand: "bundle exec rspec --format progress --format RspecJunitFormatter --out",
+			wantReport:  true,
+		},
+		{
+			name:        "rspec gem without spec directory uses rake",
+			files:       []string{"Rakefile", "test/app_test.rb"},
+			lock:        rspecLock,
+			wantCommand: "bundle exec rake test",
+		},
+		{
+			name:        "rake",
+			files:       []string{"Rakefile", "test/app_test.rb"},
+			wantCommand: "bundle exec rake test",
+		},
+		{
+			name:  "rakefile without tests",
+			files: []string{"Rakefile"},
+		},
+		{
+			name:  "spec directory without rspec",
+			files: []string{"spec/app_spec.rb"},
+			lock:  "    rspec-support (3.12.1)\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := testConfig(ctx, tc.lock, "/layers/test_gems")
+			if err != nil {
+				t.Fatalf("testConfig() got error: %v", err)
+			}
+
+			if tc.wantCommand == "" {
+				if got != nil {
+					t.Errorf("testConfig() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("testConfig() = nil, want command %q", tc.wantCommand)
+			}
+			if cmd := strings.Join(got.Command, " "); !strings.HasPrefix(cmd, tc.wantCommand) {
+				t.Errorf("testConfig() command = %q, want %q", cmd, tc.wantCommand)
+			}
+			if gotReport := len(got.Reports) > 0; gotReport != tc.wantReport {
+				t.Errorf("testConfig() reports = %v, want a report? %v", got.Reports, tc.wantReport)
+			}
+		})
+	}
+}
@@ -16,9 +16,7 @@ package runtime
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
@@ -76,6 +74,14 @@ var runtimeNames = map[InstallableRuntime]string{
 	Go:        "Go",
 }
 
+// constraintDialects maps runtimes to the syntax their users write version constraints in. Runtimes
+// not listed use semver.
+var constraintDialects = map[InstallableRuntime]version.Dialect{
+	Python: version.PEP440,
+	Ruby:   version.RubyGems,
+	PHP:    version.Composer,
+}
+
 // stackToOS contains the mapping of Stack to OS.
 var stackToOS = map[string]string{
 	"google":                 ubuntu1804,
@@ -117,35 +123,16 @@ func InstallDartSDK(ctx *gcp.Context, layer *libcnb.Layer, version string) error
 	}
 	sdkURL := fmt.Sprintf(dartSdkURL, version)
 
-	zip, err := ioutil.TempFile(layer.Path, "dart-sdk-*.zip")
-	if err != nil {
+	if err := fetch.UseCache(ctx); err != nil {
 		return err
 	}
-	defer os.Remove(zip.Name())
-
-	if err := fetch.GetURL(sdkURL, zip); err != nil {
+	// The SDK contents are in a subdirectory called "dart-sdk". Strip it so "bin" and "lib" end up
+	// in the layer path.
+	if err := fetch.Archive(sdkURL, layer.Path, 1); err != nil {
 		ctx.Warnf("Failed to download Dart SDK from %s. You can specify the verison by setting the GOOGLE_RUNTIME_VERSION environment variable", sdkURL)
 		return err
 	}
 
-	if _, err := ctx.Exec([]string{"unzip", "-q", zip.Name(), "-d", layer.Path}); err != nil {
-		return fmt.Errorf("extracting Dart SDK: %v", err)
-	}
-
-	// Once extracted the SDK contents are in a subdirectory called "dart-sdk". We move everything up
-	// one level so "bin" and "lib" end up in the layer path.
-	files, err := ioutil.ReadDir(path.Join(layer.Path, "dart-sdk"))
-	if err != nil {
-		return err
-	}
-	for _, file := range files {
-		op := path.Join(layer.Path, "dart-sdk", file.Name())
-		np := path.Join(layer.Path, file.Name())
-		if err := os.Rename(op, np); err != nil {
-			return err
-		}
-	}
-
 	ctx.SetMetadata(layer, stackKey, ctx.StackID())
 	ctx.SetMetadata(layer, versionKey, version)
 
@@ -169,6 +156,10 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 		return false, err
 	}
 
+	if err = EnforceLifecycle(ctx, runtime, version); err != nil {
+		return false, err
+	}
+
 	if layer.Cache {
 		if IsCached(ctx, layer, version) {
 			ctx.CacheHit(runtimeID)
@@ -198,6 +189,9 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 			return false, err
 		}
 	} else {
+		if err := fetch.UseCache(ctx); err != nil {
+			return false, err
+		}
 		if err := fetch.Tarball(runtimeURL, layer.Path, stripComponents); err != nil {
 			ctx.Warnf("Failed to download %s version %s osName %s from lorry. You can specify the version by setting the GOOGLE_RUNTIME_VERSION environment variable", runtimeName, version, osName)
 			return false, err
@@ -210,6 +204,29 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 	return false, nil
 }
 
+// PrefetchTarball starts downloading in the background the runtime tarball that
+// InstallTarballIfNotCached would install into the provided layer, so that a buildpack installing
+// several runtimes downloads them concurrently. It does nothing if the layer already holds the
+// requested version or the runtime is installed from an image.
+func PrefetchTarball(ctx *gcp.Context, runtime InstallableRuntime, versionConstraint string, layer *libcnb.Layer) error {
+	if _, present := os.LookupEnv(env.RuntimeImageRegion); present && runtime != Go {
+		return nil
+	}
+	osName := OSForStack(ctx)
+	version, err := ResolveVersion(ctx, runtime, versionConstraint, osName)
+	if err != nil {
+		return err
+	}
+	if layer.Cache && IsCached(ctx, layer, version) {
+		return nil
+	}
+	if err := fetch.UseCache(ctx); err != nil {
+		return err
+	}
+	fetch.Prefetch(tarballDownloadURL(runtime, osName, version))
+	return nil
+}
+
 func runtimeImageURL(runtime InstallableRuntime, osName, version, region string) string {
 	return fmt.Sprintf(runtimeImageARURL, region, osName, runtime, version)
 }
@@ -293,6 +310,10 @@ func ResolveVersion(ctx *gcp.Context, runtime InstallableRuntime, verConstraint,
 	if IsReleaseCandidate(verConstraint) || version.IsExactSemver(verConstraint) {
 		return verConstraint, nil
 	}
+	dialect := constraintDialects[runtime]
+	if _, err := version.TranslateConstraint(dialect, verConstraint); err != nil {
+		return "", gcp.UserErrorf("invalid %s version constraint: %v", runtimeNames[runtime], err)
+	}
 
 	var versions []string
 	var err error
@@ -315,7 +336,7 @@ func ResolveVersion(ctx *gcp.Context, runtime InstallableRuntime, verConstraint,
 			versions[i] = strings.ReplaceAll(v, "_", "+")
 		}
 	}
-	v, err := version.ResolveVersion(verConstraint, versions)
+	v, err := version.ResolveVersion(verConstraint, versions, version.WithDialect(dialect))
 	if err != nil {
 		return "", gcp.UserErrorf("invalid %s version specified: %v. You may need to use a different builder. Please check if the language version specified is supported by the os: %v. You can refer to https://cloud.google.com/docs/buildpacks/builders for a list of compatible runtime languages per builder", runtimeNames[runtime], err, osName)
 	}
@@ -181,6 +181,8 @@ func TestInstallRuby(t *testing.T) {
 				tc.stackID = "google.gae.18"
 			}
 			ctx := gcp.NewContext(gcp.WithStackID(tc.stackID))
+			// The test versions are older than every version line with a support schedule.
+			t.Setenv(env.AllowDecommissionedRuntime, "true")
 			if tc.wantCached {
 				ctx.SetMetadata(layer, versionKey, "2.2.2")
 				ctx.SetMetadata(layer, stackKey, tc.stackID)
@@ -319,6 +321,8 @@ func TestInstallSource(t *testing.T) {
 				tc.stackID = "google.gae.18"
 			}
 			ctx := gcp.NewContext(gcp.WithStackID(tc.stackID))
+			// Some of the test versions are past their decommission date.
+			t.Setenv(env.AllowDecommissionedRuntime, "true")
 			if tc.runtimeImageRegion != "" {
 				t.Setenv(env.RuntimeImageRegion, tc.runtimeImageRegion)
 			}
@@ -555,3 +559,81 @@ func TestValidateMinFlexVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveVersionDialects(t *testing.T) {
+	testCases := []struct {
+		name       string
+		runtime    InstallableRuntime
+		constraint string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "python pep 440",
+			runtime:    Python,
+			constraint: ">=3.10,<3.12",
+			want:       "3.11.9",
+		},
+		{
+			name:       "ruby pessimistic",
+			runtime:    Ruby,
+			constraint: "~> 3.2.0",
+			want:       "3.2.4",
+		},
+		{
+			name:       "php composer",
+			runtime:    PHP,
+			constraint: "^8.1|^8.2",
+			want:       "8.3.8",
+		},
+		{
+			name:       "nodejs semver",
+			runtime:    Nodejs,
+			constraint: "~3.11",
+			want:       "3.11.9",
+		},
+		{
+			name:       "python semver",
+			runtime:    Python,
+			constraint: "^3.10",
+			want:       "3.11.9",
+		},
+		{
+			name:       "ruby semver",
+			runtime:    Ruby,
+			constraint: "^3.2",
+			want:       "3.11.9",
+		},
+		{
+			name:       "invalid constraint names dialect",
+			runtime:    Python,
+			constraint: "%3.11",
+			wantErr:    "PEP 440",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testserver.New(
+				t,
+				testserver.WithStatus(http.StatusOK),
+				testserver.WithJSON(`["3.10.14","3.11.9","3.2.4","3.3.1","8.1.29","8.3.8"]`),
+				testserver.WithMockURL(&runtimeVersionsURL),
+			)
+
+			got, err := ResolveVersion(gcp.NewContext(), tc.runtime, tc.constraint, ubuntu2204)
+
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ResolveVersion(%q, %q) got error %v, want error containing %q", tc.runtime, tc.constraint, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveVersion(%q, %q) got error: %v", tc.runtime, tc.constraint, err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveVersion(%q, %q) = %q, want %q", tc.runtime, tc.constraint, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// SupportStatus is the lifecycle status of a runtime version.
+type SupportStatus string
+
+// Lifecycle statuses reported in the builder output and the image label.
+const (
+	StatusSupported      SupportStatus = "supported"
+	StatusDeprecated     SupportStatus = "deprecated"
+	StatusDecommissioned SupportStatus = "decommissioned"
+	StatusUnknown        SupportStatus = "unknown"
+
+	lifecycleDateLayout = "2006-01-02"
+	runtimeSupportURL   = "https://cloud.google.com/run/docs/runtime-support"
+)
+
+var (
+	//go:embed lifecycle.json
+	builtinLifecycle []byte
+
+	// lifecycleNow is the time used to evaluate lifecycle dates, overridden in tests.
+	lifecycleNow = time.Now
+
+	// lifecycleAliases maps runtimes that share the support schedule of another runtime.
+	lifecycleAliases = map[InstallableRuntime]InstallableRuntime{
+		CanonicalJDK: OpenJDK,
+		AspNetCore:   DotnetSDK,
+	}
+)
+
+// LifecycleEntry describes the support window of a runtime version line.
+type LifecycleEntry struct {
+	// Version is the version line, for example "20" for Node.js 20 or "3.12" for Python 3.12. It
+	// covers every version that starts with the same components.
+	Version string `json:"version"`
+	// GA, Deprecation and Decommission are dates in YYYY-MM-DD format.
+	GA           string `json:"ga,omitempty"`
+	Deprecation  string `json:"deprecation,omitempty"`
+	Decommission string `json:"decommission,omitempty"`
+	// Source links to the published support schedule the dates are taken from.
+	Source string `json:"source,omitempty"`
+}
+
+// Lifecycle maps a runtime to the support windows of its version lines.
+type Lifecycle map[InstallableRuntime][]LifecycleEntry
+
+// LoadLifecycle returns the lifecycle database shipped with the buildpacks, with entries from the
+// file named by GOOGLE_RUNTIME_LIFECYCLE_FILE taking precedence over built-in entries for the same
+// version line. A relative file path is resolved against the application root.
+func LoadLifecycle(ctx *gcp.Context) (Lifecycle, error) {
+	lc, err := parseLifecycle(builtinLifecycle)
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing built-in runtime lifecycle: %v", err)
+	}
+	path := os.Getenv(env.RuntimeLifecycleFile)
+	if path == "" {
+		return lc, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.ApplicationRoot(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, gcp.UserErrorf("reading %s %q: %v", env.RuntimeLifecycleFile, path, err)
+	}
+	override, err := parseLifecycle(data)
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s %q: %v", env.RuntimeLifecycleFile, path, err)
+	}
+	lc.merge(override)
+	return lc, nil
+}
+
+func parseLifecycle(data []byte) (Lifecycle, error) {
+	var lc Lifecycle
+	if err := json.Unmarshal(data, &lc); err != nil {
+		return nil, err
+	}
+	for runtime, entries := range lc {
+		for _, e := range entries {
+			if e.Version == "" {
+				return nil, fmt.Errorf("%s entry is missing a version", runtime)
+			}
+			for _, d := range []string{e.GA, e.Deprecation, e.Decommission} {
+				if _, err := parseLifecycleDate(d); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", runtime, e.Version, err)
+				}
+			}
+			if e.Deprecation != "" && e.Decommission != "" && e.Deprecation > e.Decommission {
+				return nil, fmt.Errorf("%s %s: deprecation date %s is after decommission date %s", runtime, e.Version, e.Deprecation, e.Decommission)
+			}
+		}
+	}
+	return lc, nil
+}
+
+// parseLifecycleDate parses a YYYY-MM-DD date, returning the zero time for an empty string.
+func parseLifecycleDate(d string) (time.Time, error) {
+	if d == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(lifecycleDateLayout, d)
+}
+
+func (lc Lifecycle) merge(override Lifecycle) {
+	for runtime, entries := range override {
+		for _, e := range entries {
+			replaced := false
+			for i, existing := range lc[runtime] {
+				if existing.Version == e.Version {
+					lc[runtime][i] = e
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				lc[runtime] = append(lc[runtime], e)
+			}
+		}
+	}
+}
+
+// Entry returns the most specific version line of runtime that covers version.
+func (lc Lifecycle) Entry(runtime InstallableRuntime, version string) (LifecycleEntry, bool) {
+	if alias, ok := lifecycleAliases[runtime]; ok {
+		runtime = alias
+	}
+	version = normalizeLifecycleVersion(version)
+	var best LifecycleEntry
+	found := false
+	for _, e := range lc[runtime] {
+		if !coversVersion(e.Version, version) {
+			continue
+		}
+		if !found || len(e.Version) > len(best.Version) {
+			best, found = e, true
+		}
+	}
+	return best, found
+}
+
+// normalizeLifecycleVersion returns version without a "v" prefix and with Artifact Registry's
+// encoding of build metadata (eg. 17.0.9_9) undone.
+func normalizeLifecycleVersion(version string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(version, "v"), "_", "+")
+}
+
+// coversVersion reports whether version belongs to the version line, e.g. "3.1" covers "3.1.4"
+// but not "3.12.0".
+func coversVersion(line, version string) bool {
+	if version == line {
+		return true
+	}
+	if !strings.HasPrefix(version, line) {
+		return false
+	}
+	next := version[len(line)]
+	return next == '.' || next == '+' || next == '-'
+}
+
+// oldest returns the oldest version line of runtime, which has no GA date after it.
+func (lc Lifecycle) oldest(runtime InstallableRuntime) (LifecycleEntry, bool) {
+	if alias, ok := lifecycleAliases[runtime]; ok {
+		runtime = alias
+	}
+	var oldest LifecycleEntry
+	found := false
+	for _, e := range lc[runtime] {
+		if !found || compareVersionLines(e.Version, oldest.Version) < 0 {
+			oldest, found = e, true
+		}
+	}
+	return oldest, found
+}
+
+// compareVersionLines compares the numeric components of two versions, e.g. "3.9" is before
+// "3.10". Components that are not numbers end the comparison.
+func compareVersionLines(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.Atoi(as[i])
+		y, errY := strconv.Atoi(bs[i])
+		if errX != nil || errY != nil {
+			return 0
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Status returns the lifecycle status of a runtime version at the given time, along with the
+// matching entry. A version older than every version line of the runtime is reported as
+// decommissioned with the entry of the oldest line, since it has been out of support for at least
+// as long.
+func (lc Lifecycle) Status(runtime InstallableRuntime, version string, now time.Time) (SupportStatus, LifecycleEntry) {
+	e, ok := lc.Entry(runtime, version)
+	if !ok {
+		oldest, found := lc.oldest(runtime)
+		if found && compareVersionLines(normalizeLifecycleVersion(version), oldest.Version) < 0 {
+			return StatusDecommissioned, oldest
+		}
+		return StatusUnknown, e
+	}
+	// Dates were validated when the lifecycle was parsed.
+	if decommission, _ := parseLifecycleDate(e.Decommission); !decommission.IsZero() && !now.Before(decommission) {
+		return StatusDecommissioned, e
+	}
+	if deprecation, _ := parseLifecycleDate(e.Deprecation); !deprecation.IsZero() && !now.Before(deprecation) {
+		return StatusDeprecated, e
+	}
+	return StatusSupported, e
+}
+
+// EnforceLifecycle checks a runtime version against the lifecycle database. It records the support
+// status in the builder output and as an image label and warns about deprecated and decommissioned
+// versions. Decommissioned versions fail the build unless GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME is set.
+//
+// It is called by InstallTarballIfNotCached, which installs every language runtime with a support
+// schedule. Runtimes installed any other way, such as the Dart SDK or runtimes preinstalled in the
+// run image, are not checked.
+func EnforceLifecycle(ctx *gcp.Context, runtime InstallableRuntime, version string) error {
+	lc, err := LoadLifecycle(ctx)
+	if err != nil {
+		return err
+	}
+	status, entry := lc.Status(runtime, version, lifecycleNow())
+	name, ok := runtimeNames[runtime]
+	if !ok {
+		name = string(runtime)
+	}
+	decommission, ended := entry.Decommission, "was decommissioned on "+entry.Decommission
+	if entry.Version != "" && !coversVersion(entry.Version, normalizeLifecycleVersion(version)) {
+		// The version is older than every version line.
+		decommission, ended = "", fmt.Sprintf("is older than %s %s, the oldest version with a support schedule,", name, entry.Version)
+	}
+	ctx.AddRuntimeSupport(builderoutput.RuntimeSupport{
+		Runtime:          string(runtime),
+		Version:          version,
+		Status:           string(status),
+		DecommissionDate: decommission,
+	})
+	ctx.AddLabel(string(runtime)+"_support_status", string(status))
+
+	if status != StatusDeprecated && status != StatusDecommissioned {
+		return nil
+	}
+	allow, err := env.IsPresentAndTrue(env.AllowDecommissionedRuntime)
+	if err != nil {
+		ctx.Warnf("Failed to parse %s: %v", env.AllowDecommissionedRuntime, err)
+	}
+	switch status {
+	case StatusDeprecated:
+		failNote := fmt.Sprintf(" Builds will fail from then on unless %s is set.", env.AllowDecommissionedRuntime)
+		if allow {
+			failNote = ""
+		}
+		ctx.Warnf("%s %s is deprecated and stops receiving security updates when it is decommissioned on %s.%s Upgrade to a supported version, see %s.", name, version, entry.Decommission, failNote, runtimeSupportURL)
+	case StatusDecommissioned:
+		if !allow {
+			return gcp.UserErrorf("%s %s %s and is no longer supported. Upgrade to a supported version, see %s, or set %s=true to build with it anyway", name, version, ended, runtimeSupportURL, env.AllowDecommissionedRuntime)
+		}
+		ctx.Warnf("%s %s %s and receives no security updates. Building anyway because %s is set.", name, version, ended, env.AllowDecommissionedRuntime)
+	}
+	return nil
+}
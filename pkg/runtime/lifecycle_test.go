@@ -0,0 +1,319 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuiltinLifecycleIsValid(t *testing.T) {
+	lc, err := parseLifecycle(builtinLifecycle)
+	if err != nil {
+		t.Fatalf("parseLifecycle(lifecycle.json) got error: %v", err)
+	}
+	for runtime, entries := range lc {
+		if _, ok := runtimeNames[runtime]; !ok && runtime != OpenJDK {
+			t.Errorf("lifecycle.json has entries for unknown runtime %q", runtime)
+		}
+		seen := map[string]bool{}
+		for _, e := range entries {
+			if seen[e.Version] {
+				t.Errorf("lifecycle.json has duplicate entries for %s %s", runtime, e.Version)
+			}
+			seen[e.Version] = true
+			if e.Deprecation == "" || e.Decommission == "" {
+				t.Errorf("lifecycle.json entry %s %s must have deprecation and decommission dates", runtime, e.Version)
+			}
+			if !strings.HasPrefix(e.Source, "https://") {
+				t.Errorf("lifecycle.json entry %s %s must link to the source of its dates, got %q", runtime, e.Version, e.Source)
+			}
+		}
+	}
+}
+
+func TestLifecycleStatus(t *testing.T) {
+	lc := Lifecycle{
+		Python: {
+			{Version: "3.1", Deprecation: "2012-04-09", Decommission: "2013-04-09"},
+			{Version: "3.12", GA: "2023-10-02", Deprecation: "2028-10-02", Decommission: "2029-04-02"},
+		},
+		OpenJDK: {
+			{Version: "17", Deprecation: "2029-10-31", Decommission: "2030-10-31"},
+		},
+		Nodejs: {
+			{Version: "20", Deprecation: "2026-04-30", Decommission: "2026-10-31"},
+		},
+	}
+	testCases := []struct {
+		name        string
+		runtime     InstallableRuntime
+		version     string
+		now         string
+		want        SupportStatus
+		wantVersion string
+	}{
+		{
+			name:        "supported",
+			runtime:     Python,
+			version:     "3.12.4",
+			now:         "2026-01-01",
+			want:        StatusSupported,
+			wantVersion: "3.12",
+		},
+		{
+			name:        "deprecated",
+			runtime:     Nodejs,
+			version:     "20.11.1",
+			now:         "2026-05-01",
+			want:        StatusDeprecated,
+			wantVersion: "20",
+		},
+		{
+			name:        "deprecated on deprecation date",
+			runtime:     Nodejs,
+			version:     "20.11.1",
+			now:         "2026-04-30",
+			want:        StatusDeprecated,
+			wantVersion: "20",
+		},
+		{
+			name:        "decommissioned on decommission date",
+			runtime:     Nodejs,
+			version:     "20.11.1",
+			now:         "2026-10-31",
+			want:        StatusDecommissioned,
+			wantVersion: "20",
+		},
+		{
+			name:        "version line does not match longer minor",
+			runtime:     Python,
+			version:     "3.1.4",
+			now:         "2026-01-01",
+			want:        StatusDecommissioned,
+			wantVersion: "3.1",
+		},
+		{
+			name:        "alias and artifact registry encoding",
+			runtime:     CanonicalJDK,
+			version:     "17.0.9_9",
+			now:         "2026-01-01",
+			want:        StatusSupported,
+			wantVersion: "17",
+		},
+		{
+			name:        "older than every version line",
+			runtime:     Python,
+			version:     "2.7.18",
+			now:         "2026-01-01",
+			want:        StatusDecommissioned,
+			wantVersion: "3.1",
+		},
+		{
+			name:        "older than every version line with alias",
+			runtime:     CanonicalJDK,
+			version:     "11.0.2",
+			now:         "2026-01-01",
+			want:        StatusDecommissioned,
+			wantVersion: "17",
+		},
+		{
+			name:    "unknown version",
+			runtime: Python,
+			version: "3.14.0",
+			now:     "2026-01-01",
+			want:    StatusUnknown,
+		},
+		{
+			name:    "unknown runtime",
+			runtime: Ruby,
+			version: "3.3.0",
+			now:     "2026-01-01",
+			want:    StatusUnknown,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now, err := time.Parse(lifecycleDateLayout, tc.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, entry := lc.Status(tc.runtime, tc.version, now)
+
+			if got != tc.want {
+				t.Errorf("Status(%q, %q) = %q, want %q", tc.runtime, tc.version, got, tc.want)
+			}
+			if entry.Version != tc.wantVersion {
+				t.Errorf("Status(%q, %q) matched version line %q, want %q", tc.runtime, tc.version, entry.Version, tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestParseLifecycleErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid json", data: `{"python": `},
+		{name: "missing version", data: `{"python": [{"deprecation": "2024-01-01"}]}`},
+		{name: "invalid date", data: `{"python": [{"version": "3.8", "deprecation": "01/01/2024"}]}`},
+		{name: "deprecation after decommission", data: `{"python": [{"version": "3.8", "deprecation": "2025-01-01", "decommission": "2024-01-01"}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseLifecycle([]byte(tc.data)); err == nil {
+				t.Errorf("parseLifecycle(%s) got nil error, want error", tc.data)
+			}
+		})
+	}
+}
+
+func TestLoadLifecycleOverride(t *testing.T) {
+	appDir := t.TempDir()
+	override := `{
+  "python": [{"version": "3.12", "deprecation": "2020-01-01", "decommission": "2021-01-01"}],
+  "ruby": [{"version": "9.9", "deprecation": "2090-01-01", "decommission": "2091-01-01"}]
+}`
+	if err := os.WriteFile(filepath.Join(appDir, "lifecycle.json"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(env.RuntimeLifecycleFile, "lifecycle.json")
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir))
+
+	lc, err := LoadLifecycle(ctx)
+	if err != nil {
+		t.Fatalf("LoadLifecycle() got error: %v", err)
+	}
+
+	if got, _ := lc.Entry(Python, "3.12.1"); got.Decommission != "2021-01-01" {
+		t.Errorf("Entry(python, 3.12.1) = %+v, want the override entry", got)
+	}
+	if _, ok := lc.Entry(Ruby, "9.9.0"); !ok {
+		t.Errorf("Entry(ruby, 9.9.0) not found, want the added override entry")
+	}
+	if _, ok := lc.Entry(Python, "3.11.0"); !ok {
+		t.Errorf("Entry(python, 3.11.0) not found, want the built-in entry to be kept")
+	}
+}
+
+func TestEnforceLifecycle(t *testing.T) {
+	testCases := []struct {
+		name        string
+		version     string
+		allow       bool
+		wantErr     bool
+		wantStatus  SupportStatus
+		wantWarning string
+	}{
+		{
+			name:       "supported",
+			version:    "22.1.0",
+			wantStatus: StatusSupported,
+		},
+		{
+			name:        "deprecated warns",
+			version:     "20.1.0",
+			wantStatus:  StatusDeprecated,
+			wantWarning: "Node.js 20.1.0 is deprecated and stops receiving security updates when it is decommissioned on 2026-10-31. Builds will fail from then on unless GOOGLE_ALLOW_DECOMMISSIONED_RUNTIME is set.",
+		},
+		{
+			name:        "deprecated allowed",
+			version:     "20.1.0",
+			allow:       true,
+			wantStatus:  StatusDeprecated,
+			wantWarning: "Node.js 20.1.0 is deprecated and stops receiving security updates when it is decommissioned on 2026-10-31. Upgrade",
+		},
+		{
+			name:       "decommissioned fails",
+			version:    "16.20.0",
+			wantStatus: StatusDecommissioned,
+			wantErr:    true,
+		},
+		{
+			name:        "decommissioned allowed",
+			version:     "16.20.0",
+			allow:       true,
+			wantStatus:  StatusDecommissioned,
+			wantWarning: "Node.js 16.20.0 was decommissioned on 2025-01-30",
+		},
+		{
+			name:       "older than every version line fails",
+			version:    "12.22.12",
+			wantStatus: StatusDecommissioned,
+			wantErr:    true,
+		},
+		{
+			name:        "older than every version line allowed",
+			version:     "12.22.12",
+			allow:       true,
+			wantStatus:  StatusDecommissioned,
+			wantWarning: "Node.js 12.22.12 is older than Node.js 14, the oldest version with a support schedule, and receives no security updates",
+		},
+		{
+			name:       "unknown",
+			version:    "99.0.0",
+			wantStatus: StatusUnknown,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func() time.Time) { lifecycleNow = fn }(lifecycleNow)
+			lifecycleNow = func() time.Time { return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) }
+			if tc.allow {
+				t.Setenv(env.AllowDecommissionedRuntime, "true")
+			}
+			var logs bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&logs, "", 0)))
+
+			err := EnforceLifecycle(ctx, Nodejs, tc.version)
+
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("EnforceLifecycle(%q) got error: %v, want error? %v", tc.version, err, tc.wantErr)
+			}
+			want := []builderoutput.RuntimeSupport{{Runtime: "nodejs", Version: tc.version, Status: string(tc.wantStatus)}}
+			if tc.wantStatus != StatusUnknown {
+				entry, _ := mustLoadLifecycle(t, ctx).Entry(Nodejs, tc.version)
+				want[0].DecommissionDate = entry.Decommission
+			}
+			if diff := cmp.Diff(want, ctx.RuntimeSupport()); diff != "" {
+				t.Errorf("RuntimeSupport() mismatch (-want +got):\n%s", diff)
+			}
+			if tc.wantWarning != "" && !strings.Contains(logs.String(), "WARNING: "+tc.wantWarning) {
+				t.Errorf("EnforceLifecycle(%q) logs = %q, want warning %q", tc.version, logs.String(), tc.wantWarning)
+			}
+		})
+	}
+}
+
+func mustLoadLifecycle(t *testing.T, ctx *gcp.Context) Lifecycle {
+	t.Helper()
+	lc, err := LoadLifecycle(ctx)
+	if err != nil {
+		t.Fatalf("LoadLifecycle() got error: %v", err)
+	}
+	return lc
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package supervisor runs several processes in one container. The buildpack side parses and
+// validates the declared processes at build time, and the launch side starts them in dependency
+// order, gates dependents on readiness probes, restarts them according to their policy and
+// prefixes their output with the process name.
+package supervisor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ConfigFile is the name of the file, relative to the application root, declaring the processes to supervise.
+	ConfigFile = "supervisor.yaml"
+
+	// ProcessesEnv is an env var listing the Procfile process types to run together under the supervisor.
+	// Example: `web,worker` runs both the web and the worker process types in the web process.
+	ProcessesEnv = "GOOGLE_SUPERVISE_PROCESSES"
+
+	defaultGracePeriodSeconds  = 10
+	defaultReadyTimeoutSeconds = 60
+)
+
+// RestartPolicy determines whether a process is restarted after it exits.
+type RestartPolicy string
+
+const (
+	// RestartAlways restarts the process whenever it exits.
+	RestartAlways RestartPolicy = "always"
+	// RestartOnFailure restarts the process only if it exits with a non-zero code.
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartNever never restarts the process.
+	RestartNever RestartPolicy = "never"
+)
+
+var (
+	// processNameRe accepts every process type that pkg/procfile parses, the same as the worker
+	// names of app_start.json.
+	processNameRe = regexp.MustCompile(`^\w[\w-]*$`)
+)
+
+// Config is the set of processes to supervise.
+type Config struct {
+	Processes []Process `yaml:"processes" json:"processes"`
+	// GracePeriodSeconds is how long processes are given to exit after SIGTERM before they are killed.
+	GracePeriodSeconds int `yaml:"gracePeriodSeconds,omitempty" json:"gracePeriodSeconds,omitempty"`
+}
+
+// Process is a single supervised process.
+type Process struct {
+	Name    string        `yaml:"name" json:"name"`
+	Command string        `yaml:"command" json:"command"`
+	Restart RestartPolicy `yaml:"restart,omitempty" json:"restart,omitempty"`
+	// After lists the processes that must be ready before this process is started.
+	After     []string   `yaml:"after,omitempty" json:"after,omitempty"`
+	Readiness *Readiness `yaml:"readiness,omitempty" json:"readiness,omitempty"`
+	// LogPrefix controls whether output lines are prefixed with "[name] ", defaults to true.
+	LogPrefix *bool `yaml:"logPrefix,omitempty" json:"logPrefix,omitempty"`
+}
+
+// Readiness is a probe determining when a process is ready. Exactly one of TCP, HTTP and Command must be set.
+type Readiness struct {
+	// TCP is a host:port that accepts connections once the process is ready.
+	TCP string `yaml:"tcp,omitempty" json:"tcp,omitempty"`
+	// HTTP is a URL that returns a 2xx status once the process is ready.
+	HTTP string `yaml:"http,omitempty" json:"http,omitempty"`
+	// Command is a shell command that exits with code 0 once the process is ready.
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+	// TimeoutSeconds is how long to wait for the process to become ready.
+	TimeoutSeconds int `yaml:"timeoutSeconds,omitempty" json:"timeoutSeconds,omitempty"`
+}
+
+// ParseConfig parses and validates a supervisor.yaml file. Defaults are filled in.
+func ParseConfig(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ConfigFile, err)
+	}
+	if err := c.normalize(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FromProcfile creates a config running the named process types from the given Procfile contents.
+func FromProcfile(content string, names []string) (*Config, error) {
+	declared := make(map[string]string)
+	for _, p := range procfile.Parse(content) {
+		if _, ok := declared[p.Name]; !ok {
+			declared[p.Name] = p.Command
+		}
+	}
+	var c Config
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		cmd, ok := declared[name]
+		if !ok {
+			return nil, fmt.Errorf("process type %q not found in Procfile", name)
+		}
+		c.Processes = append(c.Processes, Process{Name: name, Command: cmd})
+	}
+	if err := c.normalize(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ReadConfig reads a config written by Write.
+func ReadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %w", path, err)
+	}
+	if err := c.normalize(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Write writes the config as JSON to path.
+func (c *Config) Write(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling config: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// normalize fills in defaults and validates the config.
+func (c *Config) normalize() error {
+	if c.GracePeriodSeconds == 0 {
+		c.GracePeriodSeconds = defaultGracePeriodSeconds
+	}
+	for i := range c.Processes {
+		p := &c.Processes[i]
+		if p.Restart == "" {
+			p.Restart = RestartAlways
+		}
+		if p.Readiness != nil && p.Readiness.TimeoutSeconds == 0 {
+			p.Readiness.TimeoutSeconds = defaultReadyTimeoutSeconds
+		}
+	}
+	return c.validate()
+}
+
+func (c *Config) validate() error {
+	if len(c.Processes) == 0 {
+		return fmt.Errorf("no processes declared")
+	}
+	if c.GracePeriodSeconds < 0 {
+		return fmt.Errorf("gracePeriodSeconds must not be negative")
+	}
+	names := make(map[string]bool, len(c.Processes))
+	for _, p := range c.Processes {
+		if !processNameRe.MatchString(p.Name) {
+			return fmt.Errorf("invalid process name %q", p.Name)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate process %q", p.Name)
+		}
+		names[p.Name] = true
+	}
+	for _, p := range c.Processes {
+		if strings.TrimSpace(p.Command) == "" {
+			return fmt.Errorf("process %q: command must not be empty", p.Name)
+		}
+		switch p.Restart {
+		case RestartAlways, RestartOnFailure, RestartNever:
+		default:
+			return fmt.Errorf("process %q: unknown restart policy %q, want one of %q, %q or %q", p.Name, p.Restart, RestartAlways, RestartOnFailure, RestartNever)
+		}
+		for _, dep := range p.After {
+			if dep == p.Name {
+				return fmt.Errorf("process %q: must not start after itself", p.Name)
+			}
+			if !names[dep] {
+				return fmt.Errorf("process %q: unknown process %q in after", p.Name, dep)
+			}
+		}
+		if r := p.Readiness; r != nil {
+			set := 0
+			for _, v := range []string{r.TCP, r.HTTP, r.Command} {
+				if v != "" {
+					set++
+				}
+			}
+			if set != 1 {
+				return fmt.Errorf("process %q: readiness must set exactly one of tcp, http or command", p.Name)
+			}
+			if r.TimeoutSeconds < 0 {
+				return fmt.Errorf("process %q: readiness timeoutSeconds must not be negative", p.Name)
+			}
+		}
+	}
+	_, err := c.StartOrder()
+	return err
+}
+
+// StartOrder returns the processes ordered so that every process comes after the processes it
+// depends on. Independent processes keep their declaration order.
+func (c *Config) StartOrder() ([]Process, error) {
+	var ordered []Process
+	placed := make(map[string]bool, len(c.Processes))
+	for len(ordered) < len(c.Processes) {
+		progress := false
+		for _, p := range c.Processes {
+			if placed[p.Name] || !allPlaced(p.After, placed) {
+				continue
+			}
+			ordered = append(ordered, p)
+			placed[p.Name] = true
+			progress = true
+		}
+		if !progress {
+			var cyclic []string
+			for _, p := range c.Processes {
+				if !placed[p.Name] {
+					cyclic = append(cyclic, p.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between processes %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func allPlaced(names []string, placed map[string]bool) bool {
+	for _, n := range names {
+		if !placed[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Process) logPrefix() bool {
+	return p.LogPrefix == nil || *p.LogPrefix
+}
@@ -0,0 +1,219 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseConfig(t *testing.T) {
+	no := false
+	testCases := []struct {
+		name    string
+		data    string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			data: `
+processes:
+- name: web
+  command: ./server
+`,
+			want: &Config{
+				GracePeriodSeconds: defaultGracePeriodSeconds,
+				Processes:          []Process{{Name: "web", Command: "./server", Restart: RestartAlways}},
+			},
+		},
+		{
+			name: "all fields",
+			data: `
+gracePeriodSeconds: 5
+processes:
+- name: app
+  command: gunicorn -b :8081 main:app
+  readiness:
+    tcp: 127.0.0.1:8081
+- name: nginx
+  command: nginx -c nginx.conf
+  after: [app]
+  logPrefix: false
+- name: migrate
+  command: ./migrate
+  restart: never
+  readiness:
+    http: http://localhost:8081/healthz
+    timeoutSeconds: 5
+`,
+			want: &Config{
+				GracePeriodSeconds: 5,
+				Processes: []Process{
+					{Name: "app", Command: "gunicorn -b :8081 main:app", Restart: RestartAlways, Readiness: &Readiness{TCP: "127.0.0.1:8081", TimeoutSeconds: defaultReadyTimeoutSeconds}},
+					{Name: "nginx", Command: "nginx -c nginx.conf", Restart: RestartAlways, After: []string{"app"}, LogPrefix: &no},
+					{Name: "migrate", Command: "./migrate", Restart: RestartNever, Readiness: &Readiness{HTTP: "http://localhost:8081/healthz", TimeoutSeconds: 5}},
+				},
+			},
+		},
+		{
+			name:    "no processes",
+			data:    `processes: []`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			data:    "processes:\n- name: web\n  command: a\n  restarts: never",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate name",
+			data:    "processes:\n- name: web\n  command: a\n- name: web\n  command: b",
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			data:    "processes:\n- name: my web\n  command: a",
+			wantErr: true,
+		},
+		{
+			name:    "empty command",
+			data:    "processes:\n- name: web\n  command: ' '",
+			wantErr: true,
+		},
+		{
+			name:    "unknown restart policy",
+			data:    "processes:\n- name: web\n  command: a\n  restart: sometimes",
+			wantErr: true,
+		},
+		{
+			name:    "unknown dependency",
+			data:    "processes:\n- name: web\n  command: a\n  after: [db]",
+			wantErr: true,
+		},
+		{
+			name:    "dependency cycle",
+			data:    "processes:\n- name: a\n  command: a\n  after: [b]\n- name: b\n  command: b\n  after: [a]",
+			wantErr: true,
+		},
+		{
+			name:    "multiple readiness probes",
+			data:    "processes:\n- name: web\n  command: a\n  readiness:\n    tcp: :8080\n    command: 'true'",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tc.data))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseConfig() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFromProcfile(t *testing.T) {
+	procfile := "web: ./server\nworker: ./worker\nclock: ./clock\n_sidekiq: ./sidekiq"
+	testCases := []struct {
+		name    string
+		names   []string
+		want    []Process
+		wantErr bool
+	}{
+		{
+			name:  "selected processes",
+			names: []string{"web", " worker", ""},
+			want: []Process{
+				{Name: "web", Command: "./server", Restart: RestartAlways},
+				{Name: "worker", Command: "./worker", Restart: RestartAlways},
+			},
+		},
+		{
+			name:  "leading underscore",
+			names: []string{"_sidekiq"},
+			want: []Process{
+				{Name: "_sidekiq", Command: "./sidekiq", Restart: RestartAlways},
+			},
+		},
+		{
+			name:    "missing process",
+			names:   []string{"web", "release"},
+			wantErr: true,
+		},
+		{
+			name:    "no processes",
+			names:   []string{""},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromProcfile(procfile, tc.names)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("FromProcfile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got.Processes); diff != "" {
+				t.Errorf("FromProcfile() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStartOrder(t *testing.T) {
+	c := &Config{Processes: []Process{
+		{Name: "nginx", After: []string{"app"}},
+		{Name: "worker", After: []string{"migrate"}},
+		{Name: "app", After: []string{"migrate"}},
+		{Name: "migrate"},
+	}}
+	got, err := c.StartOrder()
+	if err != nil {
+		t.Fatalf("StartOrder() got error: %v", err)
+	}
+	var names []string
+	for _, p := range got {
+		names = append(names, p.Name)
+	}
+	want := []string{"migrate", "worker", "app", "nginx"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("StartOrder() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteReadConfig(t *testing.T) {
+	want, err := ParseConfig([]byte("processes:\n- name: web\n  command: ./server\n  readiness:\n    tcp: :8080"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "supervisor.json")
+	if err := want.Write(path); err != nil {
+		t.Fatalf("Write() got error: %v", err)
+	}
+	got, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("ReadConfig() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadConfig() mismatch (-want +got):\n%s", diff)
+	}
+}
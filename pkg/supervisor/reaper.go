@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reaper reaps the orphaned descendants that are re-parented to the supervisor, which runs as PID 1
+// of the container. The exit status of the children the supervisor starts itself is left to
+// exec.Cmd.Wait, so they are tracked from their start until their Wait has returned.
+type reaper struct {
+	mu    sync.Mutex
+	owned map[int]bool
+}
+
+func newReaper() *reaper {
+	return &reaper{owned: make(map[int]bool)}
+}
+
+// start starts cmd and tracks it until release is called.
+func (r *reaper) start(cmd *exec.Cmd) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	r.owned[cmd.Process.Pid] = true
+	return nil
+}
+
+// release stops tracking cmd once its Wait has returned.
+func (r *reaper) release(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.owned, cmd.Process.Pid)
+}
+
+// run makes the current process the subreaper of its descendants, so orphans are re-parented to it
+// even when it is not PID 1, and reaps them whenever a child exits. It returns a function that stops
+// the reaping.
+func (r *reaper) run() (func(), error) {
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		return nil, err
+	}
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGCHLD)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-sigc:
+				r.reap()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigc)
+		close(done)
+		<-stopped
+		// Reap orphans that exited after the last signal was handled.
+		r.reap()
+	}, nil
+}
+
+// reap waits for every exited child that is not tracked. Holding the lock while scanning ensures
+// that a child started concurrently is tracked before it can be seen.
+func (r *reaper) reap() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, pid := range zombieChildren() {
+		if r.owned[pid] {
+			continue
+		}
+		var ws unix.WaitStatus
+		unix.Wait4(pid, &ws, unix.WNOHANG, nil)
+	}
+}
+
+// zombieChildren returns the pids of the children of the current process that have exited but
+// have not been waited for.
+func zombieChildren() []int {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil
+	}
+	self := os.Getpid()
+	var pids []int
+	for _, stat := range stats {
+		b, err := os.ReadFile(stat)
+		if err != nil {
+			// The process has exited and been reaped since the glob.
+			continue
+		}
+		// The command name in parentheses may contain spaces, the state and the parent pid follow it.
+		s := string(b)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err != nil || ppid != self {
+			continue
+		}
+		if pid, err := strconv.Atoi(filepath.Base(filepath.Dir(stat))); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
@@ -0,0 +1,454 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	probeInterval     = 500 * time.Millisecond
+	minRestartBackoff = time.Second
+	maxRestartBackoff = 30 * time.Second
+	// healthyRunTime is how long a process must run before its restart backoff is reset.
+	healthyRunTime = 10 * time.Second
+)
+
+// Main is the entrypoint of the launch-time supervisor. It expects the path of a config written by
+// Config.Write as its only argument and returns the exit code.
+func Main(args []string) int {
+	logger := &lineWriter{w: os.Stderr, prefix: "[supervisor] "}
+	if len(args) != 1 {
+		fmt.Fprintf(logger, "usage: supervise <config.json>\n")
+		return 2
+	}
+	cfg, err := ReadConfig(args[0])
+	if err != nil {
+		fmt.Fprintf(logger, "reading config: %v\n", err)
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	return New(cfg, os.Stdout, os.Stderr).Run(ctx)
+}
+
+// Supervisor runs the processes of a Config.
+type Supervisor struct {
+	cfg    *Config
+	stdout io.Writer
+	stderr io.Writer
+	log    io.Writer
+	// outMu serializes writes from all processes so lines are not interleaved.
+	outMu sync.Mutex
+
+	reaper *reaper
+
+	mu       sync.Mutex
+	running  map[string]*exec.Cmd
+	stopping bool
+	exitCode int
+	cancel   context.CancelFunc
+}
+
+var (
+	errStopping = errors.New("supervisor is stopping")
+	errExited   = errors.New("process exited")
+)
+
+// New returns a Supervisor writing process output to stdout and stderr.
+func New(cfg *Config, stdout, stderr io.Writer) *Supervisor {
+	s := &Supervisor{
+		cfg:     cfg,
+		stdout:  stdout,
+		stderr:  stderr,
+		running: make(map[string]*exec.Cmd),
+		reaper:  newReaper(),
+	}
+	s.log = s.prefixed(stderr, "supervisor")
+	return s
+}
+
+// Run starts all processes and blocks until they have all exited, a process fails permanently, or
+// ctx is cancelled. It returns the exit code the supervisor should exit with.
+func (s *Supervisor) Run(ctx context.Context) int {
+	order, err := s.cfg.StartOrder()
+	if err != nil {
+		fmt.Fprintf(s.log, "%v\n", err)
+		return 2
+	}
+	if stop, err := s.reaper.run(); err != nil {
+		fmt.Fprintf(s.log, "orphaned processes will not be reaped: %v\n", err)
+	} else {
+		defer stop()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.cancel = cancel
+
+	ready := make(map[string]chan struct{}, len(order))
+	for _, p := range order {
+		ready[p.Name] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range order {
+		wg.Add(1)
+		go func(p Process) {
+			defer wg.Done()
+			if code, failed := s.supervise(ctx, p, ready); failed {
+				s.fail(code)
+			}
+		}(p)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.terminate(order, done)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitCode
+}
+
+// supervise runs a single process until it exits for good. It returns the exit code and whether
+// the supervisor should shut down because of it.
+func (s *Supervisor) supervise(ctx context.Context, p Process, ready map[string]chan struct{}) (int, bool) {
+	for _, dep := range p.After {
+		select {
+		case <-ready[dep]:
+		case <-ctx.Done():
+			return 0, false
+		}
+	}
+
+	var readyOnce sync.Once
+	// isReady is only written by the readiness wait of the current start, which has finished by the
+	// time it is read again.
+	isReady := false
+	markReady := func() { readyOnce.Do(func() { close(ready[p.Name]) }) }
+	backoff := minRestartBackoff
+	for {
+		start := time.Now()
+		cmd, waitc, err := s.start(p)
+		if err == errStopping {
+			return 0, false
+		}
+		if err != nil {
+			fmt.Fprintf(s.log, "starting %s: %v\n", p.Name, err)
+			return 1, true
+		}
+		fmt.Fprintf(s.log, "started %s (pid %d)\n", p.Name, cmd.Process.Pid)
+
+		// Readiness is awaited once per start, until the process first becomes ready. A process that
+		// exits before it is ready is left to its restart policy.
+		var probed chan struct{}
+		if p.Readiness == nil {
+			markReady()
+		} else if !isReady {
+			probed = make(chan struct{})
+			go func() {
+				defer close(probed)
+				err := s.awaitReady(ctx, p, waitc)
+				switch {
+				case err == nil:
+					fmt.Fprintf(s.log, "%s is ready\n", p.Name)
+					isReady = true
+					markReady()
+				case err == errExited || ctx.Err() != nil:
+					// Left to the restart policy or the shutdown.
+				default:
+					fmt.Fprintf(s.log, "%s did not become ready: %v\n", p.Name, err)
+					s.fail(1)
+				}
+			}()
+		}
+
+		<-waitc
+		if probed != nil {
+			<-probed
+		}
+		code := exitCode(cmd.ProcessState)
+		s.mu.Lock()
+		delete(s.running, p.Name)
+		s.mu.Unlock()
+		fmt.Fprintf(s.log, "%s exited with code %d\n", p.Name, code)
+
+		if ctx.Err() != nil {
+			return code, false
+		}
+		if p.Restart == RestartNever || (p.Restart == RestartOnFailure && code == 0) {
+			if code != 0 {
+				return code, true
+			}
+			// A process that completed successfully, e.g. a migration, unblocks its dependents.
+			markReady()
+			return 0, false
+		}
+
+		if time.Since(start) >= healthyRunTime {
+			backoff = minRestartBackoff
+		}
+		fmt.Fprintf(s.log, "restarting %s in %v\n", p.Name, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return code, false
+		}
+		if backoff *= 2; backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// start starts the process in its own process group and returns a channel closed once it has exited.
+func (s *Supervisor) start(p Process) (*exec.Cmd, <-chan struct{}, error) {
+	cmd := exec.Command("/bin/sh", "-c", p.Command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, stderr := s.stdout, s.stderr
+	if p.logPrefix() {
+		stdout, stderr = s.prefixed(stdout, p.Name), s.prefixed(stderr, p.Name)
+	} else {
+		stdout, stderr = &lockedWriter{w: stdout, mu: &s.outMu}, &lockedWriter{w: stderr, mu: &s.outMu}
+	}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	// Starting under the lock guarantees terminate either sees the process or prevents its start.
+	s.mu.Lock()
+	if s.stopping {
+		s.mu.Unlock()
+		return nil, nil, errStopping
+	}
+	if err := s.reaper.start(cmd); err != nil {
+		s.mu.Unlock()
+		return nil, nil, err
+	}
+	s.running[p.Name] = cmd
+	s.mu.Unlock()
+
+	waitc := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		s.reaper.release(cmd)
+		for _, w := range []io.Writer{stdout, stderr} {
+			if lw, ok := w.(*lineWriter); ok {
+				lw.Flush()
+			}
+		}
+		close(waitc)
+	}()
+	return cmd, waitc, nil
+}
+
+// awaitReady polls the readiness probe of p until it succeeds, the process exits or the timeout expires.
+func (s *Supervisor) awaitReady(ctx context.Context, p Process, exited <-chan struct{}) error {
+	deadline := time.After(time.Duration(p.Readiness.TimeoutSeconds) * time.Second)
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for {
+		if s.probe(ctx, p.Readiness) == nil {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-exited:
+			return errExited
+		case <-deadline:
+			return fmt.Errorf("timed out after %ds", p.Readiness.TimeoutSeconds)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Supervisor) probe(ctx context.Context, r *Readiness) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	switch {
+	case r.TCP != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", r.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case r.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+	default:
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", r.Command)
+		if err := s.reaper.start(cmd); err != nil {
+			return err
+		}
+		defer s.reaper.release(cmd)
+		return cmd.Wait()
+	}
+}
+
+// terminate stops all running processes in reverse start order, killing them if they have not
+// exited after the grace period. done is closed once all processes have exited.
+func (s *Supervisor) terminate(order []Process, done <-chan struct{}) {
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+	for i := len(order) - 1; i >= 0; i-- {
+		s.signal(order[i].Name, syscall.SIGTERM)
+	}
+	select {
+	case <-done:
+		return
+	case <-time.After(time.Duration(s.cfg.GracePeriodSeconds) * time.Second):
+	}
+	fmt.Fprintf(s.log, "processes did not exit within %ds, killing them\n", s.cfg.GracePeriodSeconds)
+	for _, p := range order {
+		s.signal(p.Name, syscall.SIGKILL)
+	}
+	<-done
+}
+
+// signal sends sig to the process group of the named process, if it is running.
+func (s *Supervisor) signal(name string, sig syscall.Signal) {
+	s.mu.Lock()
+	cmd, ok := s.running[name]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	// A negative pid signals the whole process group, including children of the shell.
+	syscall.Kill(-cmd.Process.Pid, sig)
+}
+
+// fail records the exit code of the first permanently failed process and shuts down the supervisor.
+func (s *Supervisor) fail(code int) {
+	s.mu.Lock()
+	if s.exitCode == 0 {
+		if code == 0 {
+			code = 1
+		}
+		s.exitCode = code
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// exitCode returns the exit code of a process, using the shell convention of 128+n for a process
+// terminated by signal n.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+func (s *Supervisor) prefixed(w io.Writer, name string) *lineWriter {
+	return &lineWriter{w: w, prefix: "[" + name + "] ", mu: &s.outMu}
+}
+
+// lineWriter writes complete lines to w, each prefixed with prefix. Partial lines are buffered
+// until they are completed or Flush is called.
+type lineWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+
+	bufMu sync.Mutex
+	buf   []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.bufMu.Lock()
+	defer lw.bufMu.Unlock()
+	lw.buf = append(lw.buf, p...)
+	i := bytes.LastIndexByte(lw.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lines := lw.buf[:i+1]
+	if err := lw.writeLines(lines); err != nil {
+		return 0, err
+	}
+	lw.buf = append(lw.buf[:0], lw.buf[i+1:]...)
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line.
+func (lw *lineWriter) Flush() error {
+	lw.bufMu.Lock()
+	defer lw.bufMu.Unlock()
+	if len(lw.buf) == 0 {
+		return nil
+	}
+	err := lw.writeLines(append(lw.buf, '\n'))
+	lw.buf = lw.buf[:0]
+	return err
+}
+
+func (lw *lineWriter) writeLines(lines []byte) error {
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(lines, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		out.WriteString(lw.prefix)
+		out.Write(line)
+	}
+	if lw.mu != nil {
+		lw.mu.Lock()
+		defer lw.mu.Unlock()
+	}
+	_, err := lw.w.Write(out.Bytes())
+	return err
+}
+
+// lockedWriter serializes writes to w.
+type lockedWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	testCases := []struct {
+		name      string
+		processes []Process
+		// cancelOn cancels the run once the output contains it, for processes that never exit.
+		cancelOn   string
+		wantCode   int
+		wantOutput []string
+	}{
+		{
+			name: "one-shot processes",
+			processes: []Process{
+				{Name: "a", Command: "echo hello", Restart: RestartNever},
+				{Name: "b", Command: "echo world >&2", Restart: RestartOnFailure},
+			},
+			wantOutput: []string{"[a] hello\n", "[b] world\n"},
+		},
+		{
+			name: "failure stops supervisor",
+			processes: []Process{
+				{Name: "server", Command: "sleep 30", Restart: RestartAlways},
+				{Name: "bad", Command: "exit 3", Restart: RestartNever},
+			},
+			wantCode:   3,
+			wantOutput: []string{"[supervisor] bad exited with code 3\n"},
+		},
+		{
+			name: "dependent waits for readiness",
+			processes: []Process{
+				{Name: "second", Command: "cat " + marker, Restart: RestartNever, After: []string{"first"}},
+				{Name: "first", Command: "sleep 0.2 && echo ready > " + marker + " && sleep 30", Restart: RestartAlways, Readiness: &Readiness{Command: "test -f " + marker, TimeoutSeconds: 5}},
+			},
+			cancelOn:   "[second] ready",
+			wantOutput: []string{"[second] ready\n"},
+		},
+		{
+			name: "readiness timeout",
+			processes: []Process{
+				{Name: "slow", Command: "sleep 30", Readiness: &Readiness{Command: "false", TimeoutSeconds: 1}},
+			},
+			wantCode:   1,
+			wantOutput: []string{"[supervisor] slow did not become ready"},
+		},
+		{
+			name: "no prefix",
+			processes: []Process{
+				{Name: "a", Command: "echo plain", Restart: RestartNever, LogPrefix: new(bool)},
+			},
+			wantOutput: []string{"plain\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{Processes: tc.processes, GracePeriodSeconds: 1}
+			var out syncBuffer
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+			s := New(cfg, &out, &out)
+			if tc.cancelOn != "" {
+				go func() {
+					for !strings.Contains(out.String(), tc.cancelOn) {
+						time.Sleep(50 * time.Millisecond)
+					}
+					cancel()
+				}()
+			}
+
+			got := s.Run(ctx)
+
+			if got != tc.wantCode {
+				t.Errorf("Run() = %d, want %d, output:\n%s", got, tc.wantCode, out.String())
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunRestarts(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	cfg := &Config{
+		GracePeriodSeconds: 1,
+		Processes: []Process{
+			// Fails on the first run and succeeds on the second.
+			{Name: "flaky", Command: "if [ -f " + counter + " ]; then echo second; else touch " + counter + "; exit 1; fi", Restart: RestartOnFailure},
+		},
+	}
+	var out syncBuffer
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if got := New(cfg, &out, &out).Run(ctx); got != 0 {
+		t.Errorf("Run() = %d, want 0, output:\n%s", got, out.String())
+	}
+	for _, want := range []string{"restarting flaky", "[flaky] second\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunRestartsBeforeReady(t *testing.T) {
+	dir := t.TempDir()
+	counter, marker := filepath.Join(dir, "count"), filepath.Join(dir, "marker")
+	cfg := &Config{
+		GracePeriodSeconds: 1,
+		Processes: []Process{
+			// Exits before it is ready on the first run, and becomes ready on the second.
+			{
+				Name:      "server",
+				Command:   "if [ -f " + counter + " ]; then touch " + marker + "; sleep 30; else touch " + counter + "; exit 1; fi",
+				Restart:   RestartAlways,
+				Readiness: &Readiness{Command: "test -f " + marker, TimeoutSeconds: 10},
+			},
+			{Name: "client", Command: "echo connected", Restart: RestartNever, After: []string{"server"}},
+		},
+	}
+	var out syncBuffer
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	go func() {
+		for !strings.Contains(out.String(), "[client] connected") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		cancel()
+	}()
+
+	if got := New(cfg, &out, &out).Run(ctx); got != 0 {
+		t.Errorf("Run() = %d, want 0, output:\n%s", got, out.String())
+	}
+	for _, want := range []string{"restarting server", "server is ready", "[client] connected\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Run() output missing %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "did not become ready") {
+		t.Errorf("Run() failed readiness of a restarted process, output:\n%s", out.String())
+	}
+}
+
+func TestRunReapsOrphans(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "orphan.pid")
+	cfg := &Config{
+		GracePeriodSeconds: 1,
+		Processes: []Process{
+			// The inner shell exits right away, orphaning its background child, which exits while the
+			// supervised process is still running.
+			{Name: "parent", Command: "sh -c 'sleep 0.2 & echo $! > " + pidFile + "'; sleep 1; echo done", Restart: RestartNever},
+		},
+	}
+	var out syncBuffer
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if got := New(cfg, &out, &out).Run(ctx); got != 0 {
+		t.Errorf("Run() = %d, want 0, output:\n%s", got, out.String())
+	}
+	if !strings.Contains(out.String(), "[parent] done\n") {
+		t.Errorf("Run() output missing %q, got:\n%s", "[parent] done", out.String())
+	}
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Reading orphan pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatalf("Parsing orphan pid %q: %v", b, err)
+	}
+	for _, zombie := range zombieChildren() {
+		if zombie == pid {
+			t.Errorf("Orphaned process %d was not reaped", pid)
+		}
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var out bytes.Buffer
+	lw := &lineWriter{w: &out, prefix: "[p] "}
+	for _, s := range []string{"hel", "lo\nwor", "ld\n\nlast"} {
+		if _, err := lw.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "[p] hello\n[p] world\n[p] \n[p] last\n"
+	if got := out.String(); got != want {
+		t.Errorf("lineWriter output = %q, want %q", got, want)
+	}
+}
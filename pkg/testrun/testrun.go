@@ -0,0 +1,371 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testrun runs an application's tests during the build when GOOGLE_RUN_TESTS is set.
+package testrun
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// resultsDir is the directory in BUILDER_OUTPUT that test reports are collected into, with a
+	// subdirectory for each buildpack.
+	resultsDir = "test-results"
+	// goTestReport is the name of the collected `go test -json` output.
+	goTestReport = "go-test.json"
+	// maxReportedFailures is the number of failed tests named in the build error.
+	maxReportedFailures = 10
+)
+
+// Enabled returns whether the application's tests should be run. Tests are never run in dev mode,
+// where the application is rebuilt on every change.
+func Enabled(ctx *gcp.Context) (bool, error) {
+	enabled, err := env.IsPresentAndTrue(env.RunTests)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	if enabled && devmode.Enabled(ctx) {
+		ctx.Logf("Skipping tests in dev mode.")
+		return false, nil
+	}
+	return enabled, nil
+}
+
+// Config describes how a buildpack runs an application's tests.
+type Config struct {
+	// Command runs the tests.
+	Command []string
+	// Reports are glob patterns of the JUnit XML reports written by Command. Relative patterns are
+	// relative to the application root.
+	Reports []string
+	// GoJSON is whether Command writes `go test -json` events to stdout.
+	GoJSON bool
+	// NoTestsExitCode, if not zero, is the exit code with which Command reports that it found no
+	// tests. Finding no tests does not fail the build.
+	NoTestsExitCode int
+	// Options configure how Command is run, in addition to attributing it to the user.
+	Options []gcp.ExecOption
+}
+
+// Summary counts the results of a test run.
+type Summary struct {
+	Passed  int
+	Failed  int
+	Skipped int
+	// Failures names the failed tests, or the packages that failed without a failed test, such as
+	// Go packages that do not compile.
+	Failures []string
+}
+
+func (s *Summary) String() string {
+	return fmt.Sprintf("%d passed, %d failed, %d skipped", s.Passed, s.Failed, s.Skipped)
+}
+
+// Run runs the application's tests, collects their reports into BUILDER_OUTPUT and returns a user
+// error if any test fails.
+func Run(ctx *gcp.Context, cfg Config) error {
+	opts := append([]gcp.ExecOption{gcp.WithUserAttribution}, cfg.Options...)
+	if cfg.GoJSON {
+		// The events are summarized below rather than logged.
+		opts = append(opts, gcp.WithLogOutput(false))
+	}
+	result, execErr := ctx.Exec(cfg.Command, opts...)
+	if execErr != nil && result != nil && cfg.NoTestsExitCode != 0 && result.ExitCode == cfg.NoTestsExitCode {
+		ctx.Logf("No tests found.")
+		execErr = nil
+	}
+
+	summary := &Summary{}
+	outDir := reportsDir(ctx)
+	if cfg.GoJSON && result != nil {
+		output, err := summary.addGoTestJSON(strings.NewReader(result.Stdout))
+		if err != nil {
+			return gcp.InternalErrorf("reading go test output: %v", err)
+		}
+		for _, o := range output {
+			ctx.Logf("%s", o)
+		}
+		if outDir != "" {
+			if err := saveReport(outDir, goTestReport, strings.NewReader(result.Stdout)); err != nil {
+				return err
+			}
+		}
+	}
+	reports, err := findReports(ctx.ApplicationRoot(), cfg.Reports)
+	if err != nil {
+		return err
+	}
+	for _, r := range reports {
+		if err := summary.addJUnitReport(r); err != nil {
+			ctx.Warnf("Skipping unreadable test report %s: %v", r, err)
+			continue
+		}
+		if outDir != "" {
+			if err := copyReport(ctx, outDir, r); err != nil {
+				return err
+			}
+		}
+	}
+	if outDir != "" && (cfg.GoJSON || len(reports) > 0) {
+		ctx.Logf("Saved test reports to %s.", outDir)
+	}
+	ctx.Logf("Tests: %s.", summary)
+
+	if execErr == nil && summary.Failed == 0 {
+		return nil
+	}
+	if len(summary.Failures) == 0 {
+		// The command failed without a report of which tests failed, so its own error explains why.
+		return execErr
+	}
+	failures := summary.Failures
+	if len(failures) > maxReportedFailures {
+		failures = append(failures[:maxReportedFailures:maxReportedFailures], fmt.Sprintf("and %d more", len(summary.Failures)-maxReportedFailures))
+	}
+	if summary.Failed == 0 {
+		return gcp.UserErrorf("tests failed in %s", strings.Join(failures, ", "))
+	}
+	return gcp.UserErrorf("%d of %d tests failed: %s", summary.Failed, summary.Passed+summary.Failed, strings.Join(failures, ", "))
+}
+
+// reportsDir returns the directory in BUILDER_OUTPUT that this buildpack's test reports are
+// collected into, or "" if BUILDER_OUTPUT is not set.
+func reportsDir(ctx *gcp.Context) string {
+	out := ctx.BuilderOutputDir()
+	if out == "" {
+		return ""
+	}
+	return filepath.Join(out, resultsDir, ctx.BuildpackID())
+}
+
+// findReports returns the files that match any of the patterns, in order. Relative patterns are
+// relative to root.
+func findReports(root string, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var reports []string
+	for _, p := range patterns {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, gcp.InternalErrorf("finding test reports matching %q: %v", p, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				reports = append(reports, m)
+			}
+		}
+	}
+	return reports, nil
+}
+
+// copyReport copies the report to dir, keeping its path relative to the application root so that
+// reports of different modules with the same name do not collide. Reports outside the application
+// root, such as those written to a layer, are copied to the top of dir.
+func copyReport(ctx *gcp.Context, dir, report string) error {
+	rel, err := filepath.Rel(ctx.ApplicationRoot(), report)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(report)
+	}
+	f, err := os.Open(report)
+	if err != nil {
+		return gcp.InternalErrorf("opening test report: %v", err)
+	}
+	defer f.Close()
+	return saveReport(dir, rel, f)
+}
+
+// saveReport writes the content of r to name in dir.
+func saveReport(dir, name string, r io.Reader) error {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return gcp.InternalErrorf("creating test report directory: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return gcp.InternalErrorf("creating test report: %v", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return gcp.InternalErrorf("writing test report %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return gcp.InternalErrorf("writing test report %s: %v", path, err)
+	}
+	return nil
+}
+
+// addJUnitReport counts the test cases in a JUnit XML report. A report has either a <testsuites>
+// or a <testsuite> root, and a test case failed if it has a <failure> or <error> child. Nothing is
+// counted from a report that cannot be parsed.
+func (s *Summary) addJUnitReport(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := xml.NewDecoder(f)
+	var (
+		report          Summary
+		inCase          bool
+		name            string
+		failed, skipped bool
+	)
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			s.Passed += report.Passed
+			s.Failed += report.Failed
+			s.Skipped += report.Skipped
+			s.Failures = append(s.Failures, report.Failures...)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "testcase":
+				inCase, name, failed, skipped = true, junitCaseName(t), false, false
+			case "failure", "error":
+				failed = failed || inCase
+			case "skipped":
+				skipped = skipped || inCase
+			}
+		case xml.EndElement:
+			if t.Name.Local != "testcase" {
+				continue
+			}
+			inCase = false
+			switch {
+			case failed:
+				report.Failed++
+				report.Failures = append(report.Failures, name)
+			case skipped:
+				report.Skipped++
+			default:
+				report.Passed++
+			}
+		}
+	}
+}
+
+// junitCaseName returns the name of a <testcase>, qualified by its class if it has one.
+func junitCaseName(e xml.StartElement) string {
+	var class, name string
+	for _, a := range e.Attr {
+		switch a.Name.Local {
+		case "classname":
+			class = a.Value
+		case "name":
+			name = a.Value
+		}
+	}
+	if class == "" {
+		return name
+	}
+	return class + "." + name
+}
+
+// goTestEvent is an event written by `go test -json`.
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// addGoTestJSON counts the top-level tests in `go test -json` output and returns the output of the
+// failed tests and packages. Subtests are not counted separately from the test that runs them.
+func (s *Summary) addGoTestJSON(r io.Reader) ([]string, error) {
+	type key struct{ pkg, test string }
+	output := map[key][]string{}
+	failedTests := map[string]int{}
+	var failed []key
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Bytes()
+		var e goTestEvent
+		if err := json.Unmarshal(line, &e); err != nil {
+			// `go test -json` passes through lines it cannot convert, such as build errors of
+			// packages without tests.
+			output[key{}] = append(output[key{}], string(line))
+			continue
+		}
+		k := key{e.Package, e.Test}
+		if strings.Contains(e.Test, "/") {
+			// Keep the output of subtests with the test that runs them.
+			k.test = e.Test[:strings.Index(e.Test, "/")]
+			if e.Action != "output" {
+				continue
+			}
+		}
+		switch e.Action {
+		case "output":
+			output[k] = append(output[k], strings.TrimSuffix(e.Output, "\n"))
+		case "pass":
+			if e.Test != "" {
+				s.Passed++
+			}
+		case "skip":
+			if e.Test != "" {
+				s.Skipped++
+			}
+		case "fail":
+			if e.Test != "" {
+				s.Failed++
+				failedTests[e.Package]++
+				s.Failures = append(s.Failures, e.Package+"."+e.Test)
+			} else if failedTests[e.Package] == 0 {
+				s.Failures = append(s.Failures, e.Package)
+			}
+			failed = append(failed, k)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	var logs []string
+	logs = append(logs, output[key{}]...)
+	for _, k := range failed {
+		if k.test == "" && failedTests[k.pkg] > 0 {
+			// The output of the failed tests has been kept already.
+			continue
+		}
+		logs = append(logs, output[k]...)
+	}
+	return logs, nil
+}
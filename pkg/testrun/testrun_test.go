@@ -0,0 +1,292 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testrun
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="com.example.AppTest" tests="4">
+  <testcase classname="com.example.AppTest" name="passes"/>
+  <testcase classname="com.example.AppTest" name="fails">
+    <failure message="expected 1">stack</failure>
+  </testcase>
+  <testcase classname="com.example.AppTest" name="errors"><error/></testcase>
+  <testcase classname="com.example.AppTest" name="skips"><skipped/></testcase>
+</testsuite>
+`
+
+const passingJUnitReport = `<testsuites>
+  <testsuite name="a"><testcase name="one"/><testcase name="two"/></testsuite>
+  <testsuite name="b"><testcase name="three"/></testsuite>
+</testsuites>
+`
+
+func TestAddJUnitReport(t *testing.T) {
+	testCases := []struct {
+		name      string
+		report    string
+		want      Summary
+		wantError bool
+	}{
+		{
+			name:   "testsuite root",
+			report: junitReport,
+			want: Summary{
+				Passed:   1,
+				Failed:   2,
+				Skipped:  1,
+				Failures: []string{"com.example.AppTest.fails", "com.example.AppTest.errors"},
+			},
+		},
+		{
+			name:   "testsuites root",
+			report: passingJUnitReport,
+			want:   Summary{Passed: 3},
+		},
+		{
+			name:      "truncated report",
+			report:    `<testsuite><testcase name="one"/><testcase name="two">`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "TEST-report.xml")
+			if err := os.WriteFile(path, []byte(tc.report), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var got Summary
+			err := got.addJUnitReport(path)
+			if tc.wantError != (err != nil) {
+				t.Fatalf("addJUnitReport() got error: %v, want error? %v", err, tc.wantError)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("addJUnitReport() summary mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddGoTestJSON(t *testing.T) {
+	testCases := []struct {
+		name       string
+		events     string
+		want       Summary
+		wantOutput []string
+	}{
+		{
+			name: "passing tests",
+			events: `{"Action":"run","Package":"example.com/app","Test":"TestA"}
+{"Action":"output","Package":"example.com/app","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"pass","Package":"example.com/app","Test":"TestA"}
+{"Action":"skip","Package":"example.com/app","Test":"TestB"}
+{"Action":"pass","Package":"example.com/app"}
+`,
+			want: Summary{Passed: 1, Skipped: 1},
+		},
+		{
+			name: "failed subtest",
+			events: `{"Action":"run","Package":"example.com/app","Test":"TestA"}
+{"Action":"run","Package":"example.com/app","Test":"TestA/case"}
+{"Action":"output","Package":"example.com/app","Test":"TestA/case","Output":"    app_test.go:10: got 1, want 2\n"}
+{"Action":"fail","Package":"example.com/app","Test":"TestA/case"}
+{"Action":"fail","Package":"example.com/app","Test":"TestA"}
+{"Action":"pass","Package":"example.com/app","Test":"TestB"}
+{"Action":"output","Package":"example.com/app","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/app"}
+`,
+			want:       Summary{Passed: 1, Failed: 1, Failures: []string{"example.com/app.TestA"}},
+			wantOutput: []string{"    app_test.go:10: got 1, want 2"},
+		},
+		{
+			name: "package does not compile",
+			events: `# example.com/app
+app.go:3:1: syntax error
+{"Action":"output","Package":"example.com/app","Output":"FAIL\texample.com/app [build failed]\n"}
+{"Action":"fail","Package":"example.com/app"}
+`,
+			want:       Summary{Failures: []string{"example.com/app"}},
+			wantOutput: []string{"# example.com/app", "app.go:3:1: syntax error", "FAIL\texample.com/app [build failed]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Summary
+			output, err := got.addGoTestJSON(strings.NewReader(tc.events))
+			if err != nil {
+				t.Fatalf("addGoTestJSON() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("addGoTestJSON() summary mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantOutput, output, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("addGoTestJSON() output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name        string
+		script      string
+		noTestsCode int
+		report      string
+		wantError   string
+		wantReports []string
+	}{
+		{
+			name:        "passing tests",
+			script:      "exit 0",
+			report:      passingJUnitReport,
+			wantReports: []string{"target/surefire-reports/TEST-app.xml"},
+		},
+		{
+			name:        "failing tests",
+			script:      "exit 1",
+			report:      junitReport,
+			wantError:   "2 of 3 tests failed: com.example.AppTest.fails, com.example.AppTest.errors",
+			wantReports: []string{"target/surefire-reports/TEST-app.xml"},
+		},
+		{
+			name:      "command fails without reports",
+			script:    "echo 'no test runner' >&2; exit 2",
+			wantError: "no test runner",
+		},
+		{
+			name:        "no tests found",
+			script:      "exit 5",
+			noTestsCode: 5,
+		},
+		{
+			name:        "other exit code with no tests exit code",
+			script:      "echo 'internal error' >&2; exit 3",
+			noTestsCode: 5,
+			wantError:   "internal error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := t.TempDir()
+			if tc.report != "" {
+				path := filepath.Join(app, "target/surefire-reports/TEST-app.xml")
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tc.report), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out := t.TempDir()
+			t.Setenv("BUILDER_OUTPUT", out)
+			ctx := gcp.NewContext(
+				gcp.WithApplicationRoot(app),
+				gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.java.maven"}),
+				gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}),
+			)
+
+			err := Run(ctx, Config{
+				Command:         []string{"sh", "-c", tc.script},
+				Reports:         []string{"target/surefire-reports/TEST-*.xml", "*/target/surefire-reports/TEST-*.xml"},
+				NoTestsExitCode: tc.noTestsCode,
+				Options:         []gcp.ExecOption{gcp.WithWorkDir(app)},
+			})
+			if tc.wantError == "" && err != nil {
+				t.Fatalf("Run() got error: %v", err)
+			}
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("Run() got error: %v, want error containing %q", err, tc.wantError)
+				}
+				if be, ok := err.(*buildererror.Error); !ok || be.Type != buildererror.StatusUnknown {
+					t.Errorf("Run() got error %#v, want a user-attributed error", err)
+				}
+			}
+			for _, r := range tc.wantReports {
+				if _, err := os.Stat(filepath.Join(out, resultsDir, "google.java.maven", r)); err != nil {
+					t.Errorf("Report %s was not collected into BUILDER_OUTPUT: %v", r, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	testCases := []struct {
+		name      string
+		runTests  string
+		devMode   string
+		want      bool
+		wantError bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:     "enabled",
+			runTests: "true",
+			want:     true,
+		},
+		{
+			name:     "disabled",
+			runTests: "false",
+		},
+		{
+			name:     "dev mode",
+			runTests: "true",
+			devMode:  "true",
+		},
+		{
+			name:      "invalid value",
+			runTests:  "sometimes",
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.runTests != "" {
+				t.Setenv(env.RunTests, tc.runTests)
+			}
+			if tc.devMode != "" {
+				t.Setenv(env.DevMode, tc.devMode)
+			}
+
+			got, err := Enabled(gcp.NewContext())
+			if tc.wantError != (err != nil) {
+				t.Fatalf("Enabled() got error: %v, want error? %v", err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("Enabled() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Dialect identifies the syntax of a version constraint.
+type Dialect string
+
+// Supported constraint dialects.
+const (
+	// Semver is the Masterminds semver syntax understood by ResolveVersion, e.g. "^1.2 || >= 2.1".
+	Semver Dialect = "semver"
+	// PEP440 is the Python version specifier syntax, e.g. "~=3.11" or ">=3.10,<3.13".
+	PEP440 Dialect = "PEP 440"
+	// RubyGems is the Gem::Requirement syntax used in Gemfiles, e.g. "~> 3.2".
+	RubyGems Dialect = "RubyGems"
+	// Composer is the Composer constraint syntax used in composer.json, e.g. "^8.1|^8.2".
+	Composer Dialect = "Composer"
+)
+
+var (
+	// plainVersionRegexp matches constraints that are a bare, possibly partial or wildcard, version.
+	// These are passed through unchanged in every dialect.
+	plainVersionRegexp  = regexp.MustCompile(`^v?[0-9]+(\.([0-9]+|[xX*]))*$|^[xX*]$`)
+	pep440ClauseRegexp  = regexp.MustCompile(`^(~=|===|==|!=|<=|>=|<|>)?\s*([0-9]+(?:\.[0-9]+)*)(\.\*)?$`)
+	rubyClauseRegexp    = regexp.MustCompile(`^(~>|>=|<=|!=|=|>|<)?\s*([0-9]+(?:\.[0-9]+)*)$`)
+	composerTermRegexp  = regexp.MustCompile(`^(\^|~|>=|<=|!=|==|=|>|<)?v?([0-9]+(?:\.[0-9]+)*)(\.[xX*])?$`)
+	composerRangeRegexp = regexp.MustCompile(`^v?([0-9]+(?:\.[0-9]+)*)\s+-\s+v?([0-9]+(?:\.[0-9]+)*)$`)
+	// composerOperatorSpaceRegexp matches the space between an operator and its version.
+	composerOperatorSpaceRegexp = regexp.MustCompile(`([<>=!~^]+)\s+`)
+	composerStabilityRegexp     = regexp.MustCompile(`@[a-zA-Z]+`)
+	composerOrRegexp            = regexp.MustCompile(`\|\|?`)
+)
+
+// WithDialect indicates the constraint passed to ResolveVersion is written in the given dialect.
+func WithDialect(d Dialect) ResolveVersionOption {
+	return func(o *resolveParams) {
+		o.dialect = d
+	}
+}
+
+// TranslateConstraint converts a constraint written in the given dialect to the semver syntax
+// understood by ResolveVersion. Bare versions such as "3.11" or "3.2.*", and constraints that are
+// not valid in the dialect but are valid semver, are returned unchanged.
+func TranslateConstraint(d Dialect, constraint string) (string, error) {
+	c := strings.TrimSpace(constraint)
+	if c == "" || plainVersionRegexp.MatchString(c) {
+		return c, nil
+	}
+	var (
+		translated string
+		err        error
+	)
+	switch d {
+	case "", Semver:
+		return c, nil
+	case PEP440:
+		translated, err = translatePEP440(c)
+	case RubyGems:
+		translated, err = translateRubyGems(c)
+	case Composer:
+		translated, err = translateComposer(c)
+	default:
+		return "", fmt.Errorf("unknown version constraint dialect %q", d)
+	}
+	if err != nil {
+		// Constraints in the semver syntax of the other runtimes, e.g. "^3.11", are accepted too.
+		if _, serr := semver.NewConstraint(c); serr == nil {
+			return c, nil
+		}
+		return "", fmt.Errorf("parsing %s version constraint %q: %w", d, constraint, err)
+	}
+	return translated, nil
+}
+
+func translatePEP440(c string) (string, error) {
+	var out []string
+	for _, clause := range strings.Split(c, ",") {
+		clause = strings.TrimSpace(clause)
+		m := pep440ClauseRegexp.FindStringSubmatch(clause)
+		if m == nil {
+			return "", fmt.Errorf("unsupported specifier %q", clause)
+		}
+		op, v, wildcard := m[1], m[2], m[3] != ""
+		if wildcard && op != "==" && op != "!=" {
+			return "", fmt.Errorf("wildcard is only allowed with == and != in %q", clause)
+		}
+		switch op {
+		case "":
+			out = append(out, v)
+		case "~=":
+			if !strings.Contains(v, ".") {
+				return "", fmt.Errorf("~= requires at least two release segments in %q", clause)
+			}
+			out = append(out, compatibleRange(v))
+		case "==", "!=":
+			if wildcard {
+				out = append(out, strings.TrimPrefix(op, "=")+v+".x")
+			} else {
+				out = append(out, strings.TrimPrefix(op, "=")+padRelease(v))
+			}
+		case "===":
+			out = append(out, "="+v)
+		default:
+			out = append(out, op+v)
+		}
+	}
+	return strings.Join(out, ", "), nil
+}
+
+func translateRubyGems(c string) (string, error) {
+	var out []string
+	for _, clause := range strings.Split(c, ",") {
+		clause = strings.Trim(strings.TrimSpace(clause), `"'`)
+		m := rubyClauseRegexp.FindStringSubmatch(clause)
+		if m == nil {
+			return "", fmt.Errorf("unsupported requirement %q", clause)
+		}
+		op, v := m[1], m[2]
+		switch op {
+		case "":
+			out = append(out, v)
+		case "~>":
+			out = append(out, compatibleRange(v))
+		case "=", "!=":
+			out = append(out, op+padRelease(v))
+		default:
+			out = append(out, op+v)
+		}
+	}
+	return strings.Join(out, ", "), nil
+}
+
+func translateComposer(c string) (string, error) {
+	c = composerStabilityRegexp.ReplaceAllString(c, "")
+	var alternatives []string
+	for _, alt := range composerOrRegexp.Split(c, -1) {
+		alt = strings.TrimSpace(alt)
+		if m := composerRangeRegexp.FindStringSubmatch(alt); m != nil {
+			alternatives = append(alternatives, ">="+m[1]+", "+composerRangeUpper(m[2]))
+			continue
+		}
+		alt = composerOperatorSpaceRegexp.ReplaceAllString(alt, "$1")
+		var terms []string
+		for _, term := range strings.FieldsFunc(alt, func(r rune) bool { return r == ',' || r == ' ' }) {
+			m := composerTermRegexp.FindStringSubmatch(term)
+			if m == nil {
+				return "", fmt.Errorf("unsupported constraint %q", term)
+			}
+			op, v, wildcard := m[1], m[2], m[3] != ""
+			if wildcard {
+				if op != "" && op != "=" && op != "==" && op != "!=" {
+					return "", fmt.Errorf("wildcard is not allowed with %s in %q", op, term)
+				}
+				v += ".x"
+			}
+			switch op {
+			case "~":
+				terms = append(terms, compatibleRange(v))
+			case "==":
+				terms = append(terms, "="+v)
+			default:
+				terms = append(terms, op+v)
+			}
+		}
+		if len(terms) == 0 {
+			return "", fmt.Errorf("empty constraint")
+		}
+		alternatives = append(alternatives, strings.Join(terms, ", "))
+	}
+	return strings.Join(alternatives, " || "), nil
+}
+
+// composerRangeUpper returns the upper bound of a hyphenated range, where a partial version acts as
+// a wildcard, e.g. "1.0 - 2.0" allows every 2.0.x release.
+func composerRangeUpper(v string) string {
+	if strings.Count(v, ".") >= 2 {
+		return "<=" + v
+	}
+	return "<" + bumpRelease(v+".0")
+}
+
+// compatibleRange returns the range allowed by the pessimistic operators ~= (PEP 440), ~> (RubyGems)
+// and ~ (Composer): the last release segment may increase, e.g. "3.2" allows ">=3.2, <4".
+func compatibleRange(v string) string {
+	if !strings.Contains(v, ".") {
+		return ">=" + v + ", <" + bumpRelease(v+".0")
+	}
+	return ">=" + v + ", <" + bumpRelease(v)
+}
+
+// bumpRelease drops the last segment of a release and increments the one before it.
+func bumpRelease(v string) string {
+	parts := strings.Split(v, ".")
+	parts = parts[:len(parts)-1]
+	last := len(parts) - 1
+	n, _ := strconv.Atoi(parts[last])
+	parts[last] = strconv.Itoa(n + 1)
+	return strings.Join(parts, ".")
+}
+
+// padRelease appends zero segments to a release so that it matches exactly one version.
+func padRelease(v string) string {
+	for strings.Count(v, ".") < 2 {
+		v += ".0"
+	}
+	return v
+}
+
+// DotnetRollForward translates an SDK version and rollForward policy from global.json into a
+// constraint. Policies select the newest SDK allowed, so "patch" and "latestPatch" both stay within
+// the feature band, e.g. "8.0.100" with "latestFeature" becomes ">=8.0.100, <8.1.0". An empty
+// policy returns the version unchanged.
+func DotnetRollForward(sdkVersion, policy string) (string, error) {
+	if policy == "" {
+		return sdkVersion, nil
+	}
+	parts := strings.Split(sdkVersion, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("global.json rollForward %q requires a full SDK version, got %q", policy, sdkVersion)
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return "", fmt.Errorf("global.json rollForward %q requires a full SDK version, got %q", policy, sdkVersion)
+		}
+		nums[i] = n
+	}
+	major, minor, band := nums[0], nums[1], nums[2]/100*100
+	lower := ">=" + sdkVersion
+	switch policy {
+	case "disable":
+		return "=" + sdkVersion, nil
+	case "patch", "latestPatch":
+		return fmt.Sprintf("%s, <%d.%d.%d", lower, major, minor, band+100), nil
+	case "feature", "latestFeature":
+		return fmt.Sprintf("%s, <%d.%d.0", lower, major, minor+1), nil
+	case "minor", "latestMinor":
+		return fmt.Sprintf("%s, <%d.0.0", lower, major+1), nil
+	case "major", "latestMajor":
+		return lower, nil
+	}
+	return "", fmt.Errorf("unknown global.json rollForward policy %q", policy)
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateConstraint(t *testing.T) {
+	testCases := []struct {
+		name       string
+		dialect    Dialect
+		constraint string
+		want       string
+	}{
+		{name: "plain version passes through", dialect: PEP440, constraint: "3.11", want: "3.11"},
+		{name: "plain wildcard passes through", dialect: RubyGems, constraint: "3.2.*", want: "3.2.*"},
+		{name: "semver is unchanged", dialect: Semver, constraint: "^1.2 || >=2.1", want: "^1.2 || >=2.1"},
+		{name: "pep440 compatible minor", dialect: PEP440, constraint: "~=3.11", want: ">=3.11, <4"},
+		{name: "pep440 compatible patch", dialect: PEP440, constraint: "~=3.11.2", want: ">=3.11.2, <3.12"},
+		{name: "pep440 range", dialect: PEP440, constraint: ">=3.10,<3.13", want: ">=3.10, <3.13"},
+		{name: "pep440 exact", dialect: PEP440, constraint: "==3.12", want: "=3.12.0"},
+		{name: "pep440 prefix match", dialect: PEP440, constraint: "==3.12.*", want: "=3.12.x"},
+		{name: "pep440 exclusion", dialect: PEP440, constraint: ">=3.9, !=3.10.*", want: ">=3.9, !=3.10.x"},
+		{name: "rubygems pessimistic minor", dialect: RubyGems, constraint: "~> 3.2", want: ">=3.2, <4"},
+		{name: "rubygems pessimistic patch", dialect: RubyGems, constraint: "~> 3.2.1", want: ">=3.2.1, <3.3"},
+		{name: "rubygems pessimistic major", dialect: RubyGems, constraint: "~> 3", want: ">=3, <4"},
+		{name: "rubygems multiple requirements", dialect: RubyGems, constraint: `">= 3.1", "< 3.4"`, want: ">=3.1, <3.4"},
+		{name: "composer or", dialect: Composer, constraint: "^8.1|^8.2", want: "^8.1 || ^8.2"},
+		{name: "composer double pipe", dialect: Composer, constraint: "^7.4 || ^8.0", want: "^7.4 || ^8.0"},
+		{name: "composer tilde", dialect: Composer, constraint: "~8.1", want: ">=8.1, <9"},
+		{name: "composer space separated and", dialect: Composer, constraint: ">= 8.1 <8.4", want: ">=8.1, <8.4"},
+		{name: "composer hyphen range", dialect: Composer, constraint: "8.1 - 8.3", want: ">=8.1, <8.4"},
+		{name: "composer stability flag", dialect: Composer, constraint: "^8.2@stable", want: "^8.2"},
+		{name: "pep440 semver fallback", dialect: PEP440, constraint: "^3.11", want: "^3.11"},
+		{name: "pep440 semver fallback space separated", dialect: PEP440, constraint: ">=3.10 <3.13", want: ">=3.10 <3.13"},
+		{name: "rubygems semver fallback", dialect: RubyGems, constraint: "^3.2", want: "^3.2"},
+		{name: "composer semver fallback", dialect: Composer, constraint: ">=8.*", want: ">=8.*"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := TranslateConstraint(tc.dialect, tc.constraint)
+			if err != nil {
+				t.Fatalf("TranslateConstraint(%q, %q) got error: %v", tc.dialect, tc.constraint, err)
+			}
+			if got != tc.want {
+				t.Errorf("TranslateConstraint(%q, %q) = %q, want %q", tc.dialect, tc.constraint, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTranslateConstraintErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		dialect    Dialect
+		constraint string
+	}{
+		{name: "pep440 compatible single segment", dialect: PEP440, constraint: "~=3"},
+		{name: "pep440 wildcard with compatible release", dialect: PEP440, constraint: "~=3.*"},
+		{name: "pep440 unknown operator", dialect: PEP440, constraint: "%3.11"},
+		{name: "rubygems prerelease", dialect: RubyGems, constraint: "~> 3.3.0.preview1"},
+		{name: "composer garbage", dialect: Composer, constraint: "^8.1 | latest"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := TranslateConstraint(tc.dialect, tc.constraint)
+			if err == nil {
+				t.Fatalf("TranslateConstraint(%q, %q) got nil error, want error", tc.dialect, tc.constraint)
+			}
+			if !strings.Contains(err.Error(), string(tc.dialect)) {
+				t.Errorf("TranslateConstraint(%q, %q) error %q does not name the dialect", tc.dialect, tc.constraint, err)
+			}
+		})
+	}
+}
+
+func TestResolveVersionWithDialect(t *testing.T) {
+	versions := []string{"3.9.19", "3.10.14", "3.11.9", "3.12.4", "3.13.0", "4.0.0"}
+	testCases := []struct {
+		dialect    Dialect
+		constraint string
+		want       string
+	}{
+		{dialect: PEP440, constraint: "~=3.11", want: "3.13.0"},
+		{dialect: PEP440, constraint: ">=3.10,<3.13", want: "3.12.4"},
+		{dialect: PEP440, constraint: "==3.10.*", want: "3.10.14"},
+		{dialect: RubyGems, constraint: "~> 3.10.0", want: "3.10.14"},
+		{dialect: Composer, constraint: "~3.9.0|^3.11 <3.12", want: "3.11.9"},
+		{dialect: PEP440, constraint: "^3.11", want: "3.13.0"},
+		{dialect: PEP440, constraint: ">=3.10 <3.13", want: "3.12.4"},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect)+" "+tc.constraint, func(t *testing.T) {
+			got, err := ResolveVersion(tc.constraint, versions, WithDialect(tc.dialect))
+			if err != nil {
+				t.Fatalf("ResolveVersion(%q, WithDialect(%q)) got error: %v", tc.constraint, tc.dialect, err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveVersion(%q, WithDialect(%q)) = %q, want %q", tc.constraint, tc.dialect, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDotnetRollForward(t *testing.T) {
+	versions := []string{"6.0.428", "8.0.100", "8.0.110", "8.0.204", "8.0.303", "8.1.100", "9.0.100"}
+	testCases := []struct {
+		policy    string
+		want      string
+		wantError bool
+	}{
+		{policy: "disable", want: "8.0.110"},
+		{policy: "patch", want: "8.0.110"},
+		{policy: "latestPatch", want: "8.0.110"},
+		{policy: "feature", want: "8.0.303"},
+		{policy: "latestFeature", want: "8.0.303"},
+		{policy: "minor", want: "8.1.100"},
+		{policy: "latestMajor", want: "9.0.100"},
+		{policy: "sideways", wantError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			c, err := DotnetRollForward("8.0.110", tc.policy)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("DotnetRollForward(8.0.110, %q) got nil error, want error", tc.policy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DotnetRollForward(8.0.110, %q) got error: %v", tc.policy, err)
+			}
+			got, err := ResolveVersion(c, versions)
+			if err != nil {
+				t.Fatalf("ResolveVersion(%q) got error: %v", c, err)
+			}
+			if got != tc.want {
+				t.Errorf("rollForward %q resolved to %q, want %q", tc.policy, got, tc.want)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 type resolveParams struct {
 	noSanitize bool
+	dialect    Dialect
 }
 
 // ResolveVersionOption configures ResolveVersion.
@@ -47,6 +48,10 @@ func ResolveVersion(constraint string, versions []string, opts ...ResolveVersion
 		// use the latest version if no constraint was provided
 		constraint = "*"
 	}
+	constraint, err := TranslateConstraint(params.dialect, constraint)
+	if err != nil {
+		return "", err
+	}
 	c, err := semver.NewConstraint(constraint)
 	if err != nil {
 		return "", err